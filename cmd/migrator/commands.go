@@ -0,0 +1,304 @@
+// cmd/migrator/commands.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+var (
+	errUsage   = errors.New("usage")
+	errAborted = errors.New("aborted")
+)
+
+// usage описание подкоманд для -h
+const usage = `Usage: migrator [flags] [command]
+
+Commands:
+  up [N]     apply all pending migrations or the next N (default command)
+  down [N]   roll back all migrations or the last N
+  goto V     migrate up or down to version V
+  force V    set version V without running migrations and clear the dirty flag
+             (V = -1 means no version)
+  version    print current version
+  status     list migrations with applied/pending state
+
+down, goto to a lower version and force ask for confirmation unless --yes is set.
+
+Flags:
+`
+
+// migrator выполняет подкоманды утилиты
+type migrator struct {
+	m          *migrate.Migrate
+	openSource func() (source.Driver, error) // источник миграций для списка в status
+	yes        bool                          // не спрашивать подтверждение
+	in         *bufio.Reader
+	out        io.Writer
+}
+
+// run выполняет подкоманду из args. Без подкоманды применяются все миграции, как раньше
+func (mg *migrator) run(args []string) error {
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
+	name, args := args[0], args[1:]
+
+	switch name {
+	case "up":
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return mg.report(mg.m.Up())
+		}
+
+		return mg.report(mg.m.Steps(n))
+
+	case "down":
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+
+		what := "all migrations"
+		if n > 0 {
+			what = fmt.Sprintf("the last %d migration(s)", n)
+		}
+		if err := mg.confirm("This will roll back " + what + "."); err != nil {
+			return err
+		}
+		if n == 0 {
+			return mg.report(mg.m.Down())
+		}
+
+		return mg.report(mg.m.Steps(-n))
+
+	case "goto":
+		v, err := requiredVersion(args)
+		if err != nil {
+			return err
+		}
+		if v < 0 {
+			return fmt.Errorf("%w: goto needs a version >= 0, use down to roll back everything", errUsage)
+		}
+
+		current, _, err := mg.version()
+		if err != nil {
+			return err
+		}
+		if v < current {
+			if err := mg.confirm(fmt.Sprintf("This will roll back from version %d to %d.", current, v)); err != nil {
+				return err
+			}
+		}
+
+		return mg.report(mg.m.Migrate(uint(v)))
+
+	case "force":
+		v, err := requiredVersion(args)
+		if err != nil {
+			return err
+		}
+
+		// force не выполняет миграции: схема и записанная версия могут разойтись
+		if err := mg.confirm(fmt.Sprintf("This will set version %d without running migrations.", v)); err != nil {
+			return err
+		}
+		if err := mg.m.Force(v); err != nil {
+			return err
+		}
+
+		return mg.printVersion()
+
+	case "version":
+		if len(args) > 0 {
+			return fmt.Errorf("%w: version takes no arguments", errUsage)
+		}
+
+		return mg.printVersion()
+
+	case "status":
+		if len(args) > 0 {
+			return fmt.Errorf("%w: status takes no arguments", errUsage)
+		}
+
+		return mg.status()
+
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+}
+
+// report печатает результат up/down/goto и новую версию
+func (mg *migrator) report(err error) error {
+	var short migrate.ErrShortLimit
+
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		fmt.Fprintln(mg.out, "no migrations to apply")
+	case errors.As(err, &short):
+		// применилось столько, сколько было: сообщаем, но это не ошибка
+		fmt.Fprintf(mg.out, "%d fewer migration(s) available than requested\n", short.Short)
+	case err != nil:
+		return err
+	}
+
+	return mg.printVersion()
+}
+
+// version текущая версия схемы; -1 - миграции ещё не применялись
+func (mg *migrator) version() (int, bool, error) {
+	v, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return int(v), dirty, nil
+}
+
+func (mg *migrator) printVersion() error {
+	v, dirty, err := mg.version()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case v < 0:
+		fmt.Fprintln(mg.out, "no migrations applied")
+	case dirty:
+		// миграция упала посередине: схему нужно поправить вручную и выполнить force
+		fmt.Fprintf(mg.out, "version %d (dirty)\n", v)
+	default:
+		fmt.Fprintf(mg.out, "version %d\n", v)
+	}
+
+	return nil
+}
+
+// status печатает все миграции источника и применены ли они.
+// Миграции применяются строго по порядку, поэтому применены все версии не больше текущей.
+func (mg *migrator) status() error {
+	current, dirty, err := mg.version()
+	if err != nil {
+		return err
+	}
+
+	src, err := mg.openSource()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	migrations, err := listMigrations(src)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(mg.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+	for _, mig := range migrations {
+		state := "pending"
+		switch {
+		case int(mig.version) == current && dirty:
+			state = "dirty"
+		case int(mig.version) <= current:
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", mig.version, mig.name, state)
+	}
+
+	return w.Flush()
+}
+
+// migration миграция из источника
+type migration struct {
+	version uint
+	name    string
+}
+
+// listMigrations читает список миграций через драйвер источника (как это делает сам migrate)
+func listMigrations(src source.Driver) ([]migration, error) {
+	var migrations []migration
+	v, err := src.First()
+	for err == nil {
+		name := ""
+		if r, identifier, err := src.ReadUp(v); err == nil {
+			_ = r.Close()
+			name = identifier
+		}
+		migrations = append(migrations, migration{version: v, name: name})
+
+		v, err = src.Next(v)
+	}
+	// источник сообщает о конце списка через os.ErrNotExist
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return migrations, nil
+}
+
+// confirm спрашивает подтверждение опасной операции, если не задан --yes
+func (mg *migrator) confirm(action string) error {
+	if mg.yes {
+		return nil
+	}
+
+	fmt.Fprintf(mg.out, "%s Continue? [y/N]: ", action)
+	answer, err := mg.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errAborted
+	}
+}
+
+// optionalCount необязательное число миграций N > 0; 0 - все
+func optionalCount(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return 0, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%w: N must be a positive number, got %q", errUsage, args[0])
+		}
+
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%w: too many arguments", errUsage)
+	}
+}
+
+// requiredVersion обязательный номер версии
+func requiredVersion(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: version is required", errUsage)
+	}
+
+	v, err := strconv.Atoi(args[0])
+	if err != nil || v < -1 {
+		return 0, fmt.Errorf("%w: invalid version %q", errUsage, args[0])
+	}
+
+	return v, nil
+}
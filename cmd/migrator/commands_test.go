@@ -0,0 +1,132 @@
+// cmd/migrator/commands_test.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"grpc-service-ref/internal/storage/schema"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_Commands(t *testing.T) {
+	mg, out := newTestMigrator(t, "")
+
+	require.NoError(t, mg.run([]string{"up", "3"}))
+	assert.Equal(t, "version 3\n", out.String())
+
+	out.Reset()
+	require.NoError(t, mg.run([]string{"status"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Regexp(t, `^1\s+init\s+applied$`, lines[1])
+	assert.Regexp(t, `^3\s+\S+\s+applied$`, lines[3])
+	assert.Regexp(t, `^4\s+\S+\s+pending$`, lines[4])
+
+	// откат с --yes не спрашивает подтверждения
+	mg.yes = true
+	out.Reset()
+	require.NoError(t, mg.run([]string{"down", "2"}))
+	assert.Equal(t, "version 1\n", out.String())
+
+	out.Reset()
+	require.NoError(t, mg.run([]string{"goto", "5"}))
+	assert.Equal(t, "version 5\n", out.String())
+
+	out.Reset()
+	require.NoError(t, mg.run([]string{"force", "4"}))
+	assert.Equal(t, "version 4\n", out.String())
+	// force не трогает схему: возвращаем версию, которой она соответствует
+	require.NoError(t, mg.run([]string{"force", "5"}))
+
+	// без подкоманды - все миграции, как раньше
+	out.Reset()
+	require.NoError(t, mg.run(nil))
+	assert.NotContains(t, out.String(), "version 4")
+	require.NoError(t, mg.run(nil))
+	assert.Contains(t, out.String(), "no migrations to apply")
+
+	out.Reset()
+	require.NoError(t, mg.run([]string{"down"}))
+	assert.Equal(t, "no migrations applied\n", out.String())
+}
+
+func TestMigrator_Confirm(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		args    []string
+		wantErr error
+		want    int
+	}{
+		{name: "down declined", answer: "n\n", args: []string{"down", "1"}, wantErr: errAborted, want: 3},
+		{name: "down no answer", answer: "", args: []string{"down"}, wantErr: errAborted, want: 3},
+		{name: "down confirmed", answer: "y\n", args: []string{"down", "1"}, want: 2},
+		{name: "goto lower declined", answer: "no\n", args: []string{"goto", "1"}, wantErr: errAborted, want: 3},
+		{name: "goto higher", answer: "", args: []string{"goto", "4"}, want: 4},
+		{name: "force declined", answer: "\n", args: []string{"force", "1"}, wantErr: errAborted, want: 3},
+		{name: "force confirmed", answer: "YES\n", args: []string{"force", "1"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mg, _ := newTestMigrator(t, tt.answer)
+			require.NoError(t, mg.run([]string{"up", "3"}))
+
+			err := mg.run(tt.args)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			v, _, err := mg.version()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v)
+		})
+	}
+}
+
+func TestMigrator_Usage(t *testing.T) {
+	mg, _ := newTestMigrator(t, "")
+
+	for _, args := range [][]string{
+		{"bogus"},
+		{"up", "0"},
+		{"down", "x"},
+		{"down", "1", "2"},
+		{"goto"},
+		{"goto", "-1"},
+		{"force", "-2"},
+		{"version", "1"},
+	} {
+		assert.ErrorIs(t, mg.run(args), errUsage, args)
+	}
+}
+
+// newTestMigrator мигратор пустой временной базы; answer - ввод пользователя на вопросы
+func newTestMigrator(t *testing.T, answer string) (*migrator, *bytes.Buffer) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	src, err := schema.Source("sqlite")
+	require.NoError(t, err)
+	m, err := migrate.NewWithSourceInstance("migrations", src, "sqlite3://"+path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = m.Close() })
+
+	out := &bytes.Buffer{}
+
+	return &migrator{
+		m:          m,
+		openSource: func() (source.Driver, error) { return schema.Source("sqlite") },
+		in:         bufio.NewReader(strings.NewReader(answer)),
+		out:        out,
+	}, out
+}
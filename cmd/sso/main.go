@@ -29,7 +29,7 @@ func main() {
 	fmt.Println("Логгер загружен:\n", log)
 
 	// инициализируем приложение (app)
-	application := app.New(log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(log, cfg)
 
 	// ВАРИАНТ ЗАПУСКА 1: запустить gRPC-сервер приложения (вариант без GracefulStop)
 	//application.GRPCServer.MustRun()
@@ -47,6 +47,16 @@ func main() {
 		application.GRPCServer.MustRun()
 	}()
 
+	// HTTP-сервер (вход через внешних провайдеров) запускаем аналогично
+	go func() {
+		application.HTTPServer.MustRun()
+	}()
+
+	// окончательное удаление учётных записей после срока хранения
+	go func() {
+		application.Purge.MustRun()
+	}()
+
 	//Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -54,6 +64,8 @@ func main() {
 	<-stop
 	// initiate graceful shutdown
 	application.GRPCServer.Stop() // Assuming GRPCServer has Stop() method for graceful shutdown
+	application.HTTPServer.Stop()
+	application.Purge.Stop()
 	log.Info("Gracefully stopped")
 
 	// TODO: Далее предлагаю вам самостоятельно написать
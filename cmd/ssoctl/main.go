@@ -0,0 +1,131 @@
+// cmd/ssoctl/main.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"grpc-service-ref/internal/storage"
+)
+
+// Утилита обслуживания SSO: резервные копии базы SQLite, восстановление, проверка целостности и сжатие,
+// импорт и экспорт пользователей, выгрузка данных пользователя.
+// Все команды можно выполнять, пока сервис работает (восстановление лучше делать при остановленном сервисе).
+// запуск утилиты:
+// go run ./cmd/ssoctl backup --storage-path=./storage/sso.db --dir=./storage/backups
+// go run ./cmd/ssoctl restore --storage-path=./storage/sso.db --from=./storage/backups/sso-20240101-120000.db
+// go run ./cmd/ssoctl check --storage-path=./storage/sso.db
+// go run ./cmd/ssoctl vacuum --storage-path=./storage/sso.db
+// go run ./cmd/ssoctl import-users --config=./config/config_local.yaml --file=./users.csv
+// go run ./cmd/ssoctl export-users --config=./config/config_local.yaml --out=./users.jsonl
+// go run ./cmd/ssoctl export-user-data --config=./config/config_local.yaml --email=user@example.com --out=./user.json
+
+var (
+	errUsage   = errors.New("usage")
+	errAborted = errors.New("aborted")
+	// описание флагов команды уже напечатано по -h
+	errHelp = errors.New("help requested")
+)
+
+// usage описание команд для -h
+const usage = `Usage: ssoctl <command> [flags]
+
+Commands:
+  backup    copy the database to a timestamped file (online backup API)
+  restore   replace the database with a backup after checking its integrity and schema version
+  check     run PRAGMA integrity_check
+  vacuum    write a compacted copy of the database (VACUUM INTO)
+
+  import-users  create users from a CSV or JSONL file with bcrypt, argon2, scrypt or PBKDF2 password hashes
+  export-users  write active users with their password hashes to a CSV or JSONL file
+
+  export-user-data  write all the data stored about a user to a JSON archive (data subject request)
+
+Run "ssoctl <command> -h" for command flags.
+`
+
+func main() {
+	ctl := &ctl{
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		openStorage: openStorage,
+	}
+
+	if err := ctl.run(context.Background(), os.Args[1:]); err != nil {
+		switch {
+		case errors.Is(err, errHelp):
+			return
+		case errors.Is(err, errUsage):
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		case errors.Is(err, errAborted):
+			fmt.Println("aborted")
+			os.Exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// ctl выполняет команды утилиты
+type ctl struct {
+	in          *bufio.Reader
+	out         io.Writer
+	openStorage func(configPath string) (storage.Storage, error) // хранилище для команд с пользователями
+}
+
+func (c *ctl) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: command is required", errUsage)
+	}
+	name, args := args[0], args[1:]
+
+	switch name {
+	case "backup":
+		return c.backup(ctx, args)
+	case "restore":
+		return c.restore(ctx, args)
+	case "check":
+		return c.check(ctx, args)
+	case "vacuum":
+		return c.vacuum(ctx, args)
+	case "import-users":
+		return c.importUsers(ctx, args)
+	case "export-users":
+		return c.exportUsers(ctx, args)
+	case "export-user-data":
+		return c.exportUserData(ctx, args)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(c.out, usage)
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+}
+
+// confirm спрашивает подтверждение опасной операции, если не задан --yes
+func (c *ctl) confirm(yes bool, action string) error {
+	if yes {
+		return nil
+	}
+
+	fmt.Fprintf(c.out, "%s Continue? [y/N]: ", action)
+	answer, err := c.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errAborted
+	}
+}
@@ -0,0 +1,255 @@
+// cmd/ssoctl/sqlite.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/storage/schema"
+	"grpc-service-ref/internal/storage/sqlite"
+)
+
+// Команды обслуживания базы SQLite (storage.driver: sqlite)
+
+// timestampLayout метка времени в именах копий: по ней копии сортируются по порядку создания
+const timestampLayout = "20060102-150405"
+
+func (c *ctl) backup(ctx context.Context, args []string) error {
+	fs := newFlagSet("backup")
+	storagePath := fs.String("storage-path", "", "path to storage")
+	dir := fs.String("dir", "", "directory for the backup (default: next to the database)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *storagePath == "" {
+		return fmt.Errorf("%w: storage-path is required", errUsage)
+	}
+
+	if *dir == "" {
+		*dir = filepath.Dir(*storagePath)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	dest := filepath.Join(*dir, timestampedName(*storagePath, ""))
+	if err := newBackup(ctx, *storagePath, dest); err != nil {
+		return err
+	}
+
+	// копия, которую нельзя восстановить, хуже, чем никакой
+	if err := checkIntegrity(ctx, dest); err != nil {
+		return fmt.Errorf("backup %s: %w", dest, err)
+	}
+
+	fmt.Fprintln(c.out, dest)
+
+	return nil
+}
+
+func (c *ctl) restore(ctx context.Context, args []string) error {
+	fs := newFlagSet("restore")
+	storagePath := fs.String("storage-path", "", "path to storage")
+	from := fs.String("from", "", "backup file to restore")
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *storagePath == "" || *from == "" {
+		return fmt.Errorf("%w: storage-path and from are required", errUsage)
+	}
+
+	if err := checkIntegrity(ctx, *from); err != nil {
+		return fmt.Errorf("backup %s: %w", *from, err)
+	}
+
+	version, err := checkBackupVersion(ctx, *from)
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", *from, err)
+	}
+
+	if err := c.confirm(*yes, fmt.Sprintf(
+		"This will replace all data in %s with %s (schema version %d).", *storagePath, *from, version,
+	)); err != nil {
+		return err
+	}
+
+	// Пока база восстанавливается, мигратор и сервис с auto_migrate её схему не трогают
+	unlock, err := schema.Lock(ctx, "sqlite", *storagePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+
+	// Текущую базу сохраняем рядом: восстановление из не той копии тоже нужно уметь откатить
+	if _, err := os.Stat(*storagePath); err == nil {
+		saved := filepath.Join(filepath.Dir(*storagePath), timestampedName(*storagePath, "pre-restore"))
+		if err := newBackup(ctx, *storagePath, saved); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "current database saved to", saved)
+	}
+
+	if err := sqlite.Backup(ctx, *from, *storagePath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "restored %s from %s\n", *storagePath, *from)
+	if latest, err := schema.Latest("sqlite"); err == nil && version < latest {
+		fmt.Fprintf(c.out, "schema version %d is behind %d: run migrator or start the service with storage.auto_migrate\n",
+			version, latest)
+	}
+
+	return nil
+}
+
+func (c *ctl) check(ctx context.Context, args []string) error {
+	fs := newFlagSet("check")
+	storagePath := fs.String("storage-path", "", "path to storage")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *storagePath == "" {
+		return fmt.Errorf("%w: storage-path is required", errUsage)
+	}
+
+	if err := checkIntegrity(ctx, *storagePath); err != nil {
+		return err
+	}
+
+	version, dirty, err := sqlite.SchemaVersion(ctx, *storagePath, schema.DefaultTable)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "ok, schema version %d", version)
+	if dirty {
+		fmt.Fprint(c.out, " (dirty)")
+	}
+	fmt.Fprintln(c.out)
+
+	return nil
+}
+
+func (c *ctl) vacuum(ctx context.Context, args []string) error {
+	fs := newFlagSet("vacuum")
+	storagePath := fs.String("storage-path", "", "path to storage")
+	out := fs.String("out", "", "file for the compacted copy (default: timestamped file next to the database)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *storagePath == "" {
+		return fmt.Errorf("%w: storage-path is required", errUsage)
+	}
+
+	if *out == "" {
+		*out = filepath.Join(filepath.Dir(*storagePath), timestampedName(*storagePath, "vacuum"))
+	}
+
+	if err := sqlite.VacuumInto(ctx, *storagePath, *out); err != nil {
+		return err
+	}
+
+	before, err := os.Stat(*storagePath)
+	if err != nil {
+		return err
+	}
+	after, err := os.Stat(*out)
+	if err != nil {
+		return err
+	}
+	// сжатую копию ставят на место рабочей через restore
+	fmt.Fprintf(c.out, "%s: %d -> %d bytes\n", *out, before.Size(), after.Size())
+
+	return nil
+}
+
+// newBackup копирует базу в новый файл: sqlite.Backup заменил бы существующий,
+// например копию, сделанную в ту же секунду
+func newBackup(ctx context.Context, storagePath string, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	return sqlite.Backup(ctx, storagePath, dest)
+}
+
+// checkIntegrity возвращает ошибку с проблемами, найденными PRAGMA integrity_check
+func checkIntegrity(ctx context.Context, path string) error {
+	problems, err := sqlite.IntegrityCheck(ctx, path)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed:\n%s", strings.Join(problems, "\n"))
+	}
+
+	return nil
+}
+
+// checkBackupVersion проверяет, что схема копии подходит этой сборке, и возвращает её версию
+func checkBackupVersion(ctx context.Context, path string) (int, error) {
+	version, dirty, err := sqlite.SchemaVersion(ctx, path, schema.DefaultTable)
+	if err != nil {
+		return 0, err
+	}
+
+	latest, err := schema.Latest("sqlite")
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case version < 0:
+		return 0, errors.New("no schema version: not an SSO database")
+	case dirty:
+		return 0, fmt.Errorf("%w: version %d", schema.ErrDirtySchema, version)
+	case version > latest:
+		return 0, fmt.Errorf("%w: version %d, latest known %d", schema.ErrSchemaTooNew, version, latest)
+	}
+
+	return version, nil
+}
+
+// timestampedName имя копии базы: sso.db -> sso-20240101-120000.db или sso-<kind>-20240101-120000.db
+func timestampedName(storagePath string, kind string) string {
+	base := filepath.Base(storagePath)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	if ext == "" {
+		ext = ".db"
+	}
+	if kind != "" {
+		name += "-" + kind
+	}
+
+	return name + "-" + time.Now().Format(timestampLayout) + ext
+}
+
+// parseFlags разбирает флаги команды; -h печатает их описание и не считается ошибкой
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return errHelp
+	case err != nil:
+		// flag уже напечатал ошибку и описание флагов
+		return fmt.Errorf("%w: %v", errUsage, err)
+	case fs.NArg() > 0:
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, fs.Args())
+	}
+
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("ssoctl "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	return fs
+}
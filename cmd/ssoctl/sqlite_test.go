@@ -0,0 +1,143 @@
+// cmd/ssoctl/sqlite_test.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/storage/schema"
+)
+
+func TestBackupRestore(t *testing.T) {
+	live := newTestDatabase(t)
+	dir := t.TempDir()
+	c, out := newTestCtl("")
+	ctx := context.Background()
+
+	require.NoError(t, c.run(ctx, []string{"backup", "--storage-path=" + live, "--dir=" + dir}))
+	backup := strings.TrimSpace(out.String())
+	assert.Equal(t, dir, filepath.Dir(backup))
+
+	exec(t, live, "DELETE FROM users")
+
+	require.NoError(t, c.run(ctx, []string{"restore", "--storage-path=" + live, "--from=" + backup, "--yes"}))
+	assert.Equal(t, 1, countUsers(t, live))
+
+	// прежняя база сохранена рядом
+	saved, err := filepath.Glob(filepath.Join(filepath.Dir(live), "sso-pre-restore-*.db"))
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, 0, countUsers(t, saved[0]))
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"check", "--storage-path=" + live}))
+	assert.Contains(t, out.String(), "ok, schema version")
+
+	out.Reset()
+	compacted := filepath.Join(dir, "compacted.db")
+	require.NoError(t, c.run(ctx, []string{"vacuum", "--storage-path=" + live, "--out=" + compacted}))
+	assert.Equal(t, 1, countUsers(t, compacted))
+}
+
+func TestRestore_Refuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		spoil   string
+		wantErr error
+	}{
+		{name: "declined", answer: "n\n", wantErr: errAborted},
+		{name: "newer schema", spoil: "UPDATE migrations SET version = version + 1", wantErr: schema.ErrSchemaTooNew},
+		{name: "dirty schema", spoil: "UPDATE migrations SET dirty = 1", wantErr: schema.ErrDirtySchema},
+		{name: "not migrated", spoil: "DROP TABLE migrations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := newTestDatabase(t)
+			backup := newTestDatabase(t)
+			exec(t, backup, "DELETE FROM users")
+			if tt.spoil != "" {
+				exec(t, backup, tt.spoil)
+			}
+
+			c, _ := newTestCtl(tt.answer)
+			err := c.run(context.Background(), []string{"restore", "--storage-path=" + live, "--from=" + backup})
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+
+			// рабочая база не изменилась
+			assert.Equal(t, 1, countUsers(t, live))
+		})
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	c, _ := newTestCtl("")
+
+	for _, args := range [][]string{
+		nil,
+		{"bogus"},
+		{"backup"},
+		{"restore", "--storage-path=sso.db"},
+		{"check", "--storage-path=sso.db", "extra"},
+		{"import-users", "--config=sso.yaml"},
+		{"export-users", "--config=sso.yaml", "--format=xml"},
+		{"export-user-data", "--config=sso.yaml"},
+		{"export-user-data", "--config=sso.yaml", "--user=1", "--email=user@example.com"},
+	} {
+		assert.ErrorIs(t, c.run(context.Background(), args), errUsage, args)
+	}
+}
+
+// newTestDatabase временная база sso.db со схемой и одним пользователем
+func newTestDatabase(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	_, err := schema.Migrate(context.Background(), "sqlite", path)
+	require.NoError(t, err)
+	exec(t, path, "INSERT INTO users(tenant_id, email, pass_hash) VALUES (1, 'user@example.com', 'hash')")
+
+	return path
+}
+
+func newTestCtl(answer string) (*ctl, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+
+	return &ctl{in: bufio.NewReader(strings.NewReader(answer)), out: out}, out
+}
+
+func exec(t *testing.T, path string, query string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(query)
+	require.NoError(t, err)
+}
+
+func countUsers(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n))
+
+	return n
+}
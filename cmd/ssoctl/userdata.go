@@ -0,0 +1,75 @@
+// cmd/ssoctl/userdata.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/dataexport"
+)
+
+// Выгрузка данных пользователя по запросу, пришедшему в поддержку (GDPR).
+// Архив тот же, что возвращает RPC Auth/ExportMyData (см. lib/dataexport), но без ограничения частоты.
+// Пользователя можно указать по ID: так выгружаются и удалённые пользователи, чьи данные ещё хранятся.
+
+// exportUserData команда export-user-data
+func (c *ctl) exportUserData(ctx context.Context, args []string) error {
+	fs := newFlagSet("export-user-data")
+	configPath := fs.String("config", "", "path to the service config")
+	userID := fs.Int64("user", 0, "ID of the user to export")
+	email := fs.String("email", "", "email of the user to export (instead of --user)")
+	tenantID := fs.Int64("tenant", models.DefaultTenantID, "tenant of the user with --email")
+	out := fs.String("out", "", "file to write (default: stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return fmt.Errorf("%w: config is required", errUsage)
+	}
+	if (*userID == 0) == (*email == "") {
+		return fmt.Errorf("%w: either user or email is required", errUsage)
+	}
+
+	st, err := c.openStorage(*configPath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if *email != "" {
+		user, err := st.User(ctx, *tenantID, *email)
+		if err != nil {
+			return err
+		}
+		*userID = user.ID
+	}
+
+	archive, err := dataexport.Export(ctx, st, *userID)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err := c.out.Write(archive)
+		return err
+	}
+
+	// в архиве персональные данные: читать его должен только владелец
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(archive); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "exported data of user %d to %s\n", *userID, *out)
+
+	return nil
+}
@@ -0,0 +1,56 @@
+// cmd/ssoctl/userdata_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/dataexport"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/memory"
+)
+
+func TestExportUserData(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+
+	admin, err := st.SaveUser(ctx, models.DefaultTenantID, "admin@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, st.UpdateAdminStatus(ctx, models.DefaultTenantID, models.SystemActorID, admin, true))
+	userID, err := st.SaveUser(ctx, models.DefaultTenantID, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	// по email
+	c, out := newUsersCtl(st)
+	require.NoError(t, c.run(ctx, []string{"export-user-data", "--config=sso.yaml", "--email=user@example.com"}))
+
+	var archive dataexport.Archive
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &archive))
+	assert.Equal(t, userID, archive.User.ID)
+
+	// удалённого пользователя - по ID, пока данные не удалены окончательно
+	require.NoError(t, st.DeleteUser(ctx, models.DefaultTenantID, admin, userID))
+
+	c, _ = newUsersCtl(st)
+	err = c.run(ctx, []string{"export-user-data", "--config=sso.yaml", "--email=user@example.com"})
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	file := filepath.Join(t.TempDir(), "user.json")
+	require.NoError(t, c.run(ctx, []string{"export-user-data", "--config=sso.yaml", "--user=" + strconv.FormatInt(userID, 10), "--out=" + file}))
+
+	raw, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &archive))
+	assert.NotNil(t, archive.User.DeletedAt)
+
+	// файл не перезаписывается
+	require.Error(t, c.run(ctx, []string{"export-user-data", "--config=sso.yaml", "--user=" + strconv.FormatInt(userID, 10), "--out=" + file}))
+}
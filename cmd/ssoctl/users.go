@@ -0,0 +1,361 @@
+// cmd/ssoctl/users.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-service-ref/internal/app"
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/passhash"
+	"grpc-service-ref/internal/storage"
+)
+
+// Импорт и экспорт пользователей организации, например при переезде с другого IdP.
+// Файл - CSV с заголовком email,is_admin,password_hash или JSONL с теми же полями.
+// Хэши паролей переносятся как есть (bcrypt, argon2, scrypt, PBKDF2, см. lib/passhash)
+// и заменяются на bcrypt при первом входе пользователя.
+
+// exportPageSize сколько пользователей читается из хранилища за раз при экспорте
+const exportPageSize = 500
+
+// userRecord пользователь в файле импорта и экспорта
+type userRecord struct {
+	Email        string `json:"email"`
+	IsAdmin      bool   `json:"is_admin"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// csvHeader колонки CSV; при импорте порядок колонок может быть любым
+var csvHeader = []string{"email", "is_admin", "password_hash"}
+
+// openStorage открывает хранилище по конфигу сервиса
+func openStorage(configPath string) (storage.Storage, error) {
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, err
+	}
+
+	return app.OpenStorage(config.MustLoadPath(configPath))
+}
+
+func (c *ctl) importUsers(ctx context.Context, args []string) error {
+	fs := newFlagSet("import-users")
+	configPath := fs.String("config", "", "path to the service config")
+	file := fs.String("file", "", "CSV or JSONL file with users")
+	format := fs.String("format", "", "csv or jsonl (default: by file extension)")
+	tenantID := fs.Int64("tenant", models.DefaultTenantID, "tenant to import users into")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *configPath == "" || *file == "" {
+		return fmt.Errorf("%w: config and file are required", errUsage)
+	}
+
+	fileFormat, err := userFileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := readUsers(f, fileFormat)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+
+	// Весь файл проверяется до записи: ошибка в середине не должна оставить половину пользователей
+	if err := validateUsers(records); err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+
+	st, err := c.openStorage(*configPath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	imported, skipped := 0, 0
+	for _, r := range records {
+		id, err := st.SaveUser(ctx, *tenantID, r.Email, []byte(r.PasswordHash))
+		if errors.Is(err, storage.ErrUserExists) {
+			// повторный запуск импорта не трогает уже перенесённых пользователей
+			fmt.Fprintf(c.out, "skip %s: already exists\n", r.Email)
+			skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("import %s: %w", r.Email, err)
+		}
+
+		if r.IsAdmin {
+			if err := st.UpdateAdminStatus(ctx, *tenantID, models.SystemActorID, id, true); err != nil {
+				return fmt.Errorf("import %s: %w", r.Email, err)
+			}
+		}
+		imported++
+	}
+
+	fmt.Fprintf(c.out, "imported %d user(s), skipped %d existing\n", imported, skipped)
+
+	return nil
+}
+
+func (c *ctl) exportUsers(ctx context.Context, args []string) error {
+	fs := newFlagSet("export-users")
+	configPath := fs.String("config", "", "path to the service config")
+	out := fs.String("out", "", "file to write (default: stdout)")
+	format := fs.String("format", "", "csv or jsonl (default: by file extension, csv for stdout)")
+	tenantID := fs.Int64("tenant", models.DefaultTenantID, "tenant to export users from")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return fmt.Errorf("%w: config is required", errUsage)
+	}
+
+	fileFormat, err := userFileFormat(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	st, err := c.openStorage(*configPath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	records, disabled, err := collectUsers(ctx, st, *tenantID)
+	if err != nil {
+		return err
+	}
+
+	w := c.out
+	if *out != "" {
+		// в файле хэши паролей: читать его должен только владелец
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := writeUsers(w, fileFormat, records); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		fmt.Fprintf(c.out, "exported %d user(s) to %s, skipped %d disabled\n", len(records), *out, disabled)
+	}
+
+	return nil
+}
+
+// collectUsers читает активных пользователей организации.
+// Отключённые не экспортируются: после импорта в другую систему они снова смогли бы войти.
+func collectUsers(ctx context.Context, st storage.Storage, tenantID int64) ([]userRecord, int, error) {
+	var records []userRecord
+	disabled := 0
+
+	for offset := 0; ; offset += exportPageSize {
+		users, total, err := st.Users(ctx, storage.UserFilter{TenantID: tenantID}, offset, exportPageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, user := range users {
+			if user.Disabled {
+				disabled++
+				continue
+			}
+
+			// администратором пользователь может быть и через группу: в файле это прямой флаг
+			isAdmin, err := st.IsAdmin(ctx, user.ID)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			records = append(records, userRecord{
+				Email:        user.Email,
+				IsAdmin:      isAdmin,
+				PasswordHash: string(user.PassHash),
+			})
+		}
+
+		if len(users) == 0 || offset+len(users) >= total {
+			return records, disabled, nil
+		}
+	}
+}
+
+// userFileFormat формат файла: из флага --format или по расширению файла
+func userFileFormat(format string, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		case ".csv", "":
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "csv", "jsonl":
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: unknown format %q, use --format=csv or --format=jsonl", errUsage, format)
+	}
+}
+
+func readUsers(r io.Reader, format string) ([]userRecord, error) {
+	if format == "jsonl" {
+		return readUsersJSONL(r)
+	}
+
+	return readUsersCSV(r)
+}
+
+func readUsersJSONL(r io.Reader) ([]userRecord, error) {
+	var records []userRecord
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record userRecord
+		dec := json.NewDecoder(strings.NewReader(scanner.Text()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func readUsersCSV(r io.Reader) ([]userRecord, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvHeader, name) {
+			return nil, fmt.Errorf("header: unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("header: email column is required")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []userRecord
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		isAdmin := false
+		if value := field(row, "is_admin"); value != "" {
+			isAdmin, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid is_admin %q", line, value)
+			}
+		}
+
+		records = append(records, userRecord{
+			Email:        field(row, "email"),
+			IsAdmin:      isAdmin,
+			PasswordHash: field(row, "password_hash"),
+		})
+	}
+}
+
+// validateUsers проверяет email и формат хэшей всех пользователей файла.
+// Пустой хэш допустим: такой пользователь входит только через внешних провайдеров.
+func validateUsers(records []userRecord) error {
+	var errs []error
+	seen := make(map[string]bool, len(records))
+
+	for i, r := range records {
+		switch {
+		case r.Email == "":
+			errs = append(errs, fmt.Errorf("user %d: email is required", i+1))
+		case seen[strings.ToLower(r.Email)]:
+			errs = append(errs, fmt.Errorf("user %d: duplicate email %s", i+1, r.Email))
+		}
+		seen[strings.ToLower(r.Email)] = true
+
+		if r.PasswordHash == "" {
+			continue
+		}
+		if _, err := passhash.Identify([]byte(r.PasswordHash)); err != nil {
+			errs = append(errs, fmt.Errorf("user %d (%s): %w", i+1, r.Email, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func writeUsers(w io.Writer, format string, records []userRecord) error {
+	if format == "jsonl" {
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write([]string{r.Email, strconv.FormatBool(r.IsAdmin), r.PasswordHash}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,127 @@
+// cmd/ssoctl/users_test.go
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/passhash"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/memory"
+)
+
+const (
+	argon2Hash = "$argon2id$v=19$m=65536,t=3,p=4$MIIRqgvgQbgj220jfp0MPA$YfwJSVjtjSU0zzV/P3S9nnQ/USre2wvJMjfCIjrTQbg"
+	pbkdf2Hash = "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso="
+)
+
+func TestImportExportUsers(t *testing.T) {
+	st := memory.New()
+	c, out := newUsersCtl(st)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// в хэшах argon2 и scrypt есть запятые: такие поля берутся в кавычки
+	file := writeFile(t, dir, "users.csv", "email,password_hash,is_admin\n"+
+		"admin@example.com,\""+argon2Hash+"\",true\n"+
+		"user@example.com,"+pbkdf2Hash+",\n"+
+		"federated@example.com,,false\n")
+
+	require.NoError(t, c.run(ctx, []string{"import-users", "--config=sso.yaml", "--file=" + file}))
+	assert.Contains(t, out.String(), "imported 3 user(s), skipped 0 existing")
+
+	admin, err := st.User(ctx, models.DefaultTenantID, "admin@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, argon2Hash, string(admin.PassHash))
+	isAdmin, err := st.IsAdmin(ctx, admin.ID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	// повторный импорт пропускает уже перенесённых пользователей
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"import-users", "--config=sso.yaml", "--file=" + file}))
+	assert.Contains(t, out.String(), "imported 0 user(s), skipped 3 existing")
+
+	// отключённые пользователи не экспортируются
+	federated, err := st.User(ctx, models.DefaultTenantID, "federated@example.com")
+	require.NoError(t, err)
+	federated.Disabled = true
+	require.NoError(t, st.UpdateUser(ctx, federated))
+
+	exported := filepath.Join(dir, "users.jsonl")
+	require.NoError(t, c.run(ctx, []string{"export-users", "--config=sso.yaml", "--out=" + exported}))
+
+	f, err := os.Open(exported)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := readUsers(f, "jsonl")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []userRecord{
+		{Email: "admin@example.com", IsAdmin: true, PasswordHash: argon2Hash},
+		{Email: "user@example.com", PasswordHash: pbkdf2Hash},
+	}, records)
+
+	// экспорт можно импортировать в другое хранилище
+	other := memory.New()
+	c, _ = newUsersCtl(other)
+	require.NoError(t, c.run(ctx, []string{"import-users", "--config=sso.yaml", "--file=" + exported}))
+
+	user, err := other.User(ctx, models.DefaultTenantID, "user@example.com")
+	require.NoError(t, err)
+	assert.NoError(t, passhash.Verify(user.PassHash, "correct horse"))
+}
+
+func TestImportUsers_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unknown hash", "users.csv", "email,password_hash\nuser@example.com,{SSHA}c2VjcmV0\n"},
+		{"malformed hash", "users.csv", "email,password_hash\nuser@example.com,$scrypt$ln=4$abc$def\n"},
+		{"duplicate email", "users.jsonl", `{"email":"user@example.com"}` + "\n" + `{"email":"user@example.com"}` + "\n"},
+		{"missing email", "users.jsonl", `{"email":"ok@example.com"}` + "\n" + `{"is_admin":true}` + "\n"},
+		{"unknown column", "users.csv", "email,password\nuser@example.com,secret\n"},
+		{"invalid admin flag", "users.csv", "email,is_admin\nuser@example.com,maybe\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := memory.New()
+			c, _ := newUsersCtl(st)
+			file := writeFile(t, t.TempDir(), tt.file, tt.content)
+
+			require.Error(t, c.run(context.Background(), []string{"import-users", "--config=sso.yaml", "--file=" + file}))
+
+			// файл с ошибкой не импортируется даже частично
+			_, total, err := st.Users(context.Background(), storage.UserFilter{TenantID: models.DefaultTenantID}, 0, 10)
+			require.NoError(t, err)
+			assert.Zero(t, total)
+		})
+	}
+}
+
+func newUsersCtl(st storage.Storage) (*ctl, *strings.Builder) {
+	c, _ := newTestCtl("")
+	out := &strings.Builder{}
+	c.out = out
+	c.openStorage = func(string) (storage.Storage, error) { return st, nil }
+
+	return c, out
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
@@ -2,31 +2,55 @@ module grpc-service-ref
 
 go 1.21.6
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/Alexxtn105/protos v0.0.0-20240309122918-6b56226caa44
+	github.com/brianvoe/gofakeit/v6 v6.23.2
+	github.com/crewjam/saml v0.4.14
+	github.com/fergusstrange/embedded-postgres v1.25.0
+	github.com/go-ldap/ldap/v3 v3.4.6
+	github.com/golang-jwt/jwt/v5 v5.0.0
+	github.com/golang-migrate/migrate/v4 v4.16.2
+	github.com/grpc-ecosystem/go-grpc-middleware/v2 v2.0.0
+	github.com/hashicorp/go-hclog v1.6.2
+	github.com/ilyakaznacheev/cleanenv v1.5.0
+	github.com/jimlambrt/gldap v0.1.13
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/russellhaering/goxmldsig v1.3.0
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.21.0
+	google.golang.org/grpc v1.62.0
+)
 
 require (
-	github.com/Alexxtn105/protos v0.0.0-20240309122918-6b56226caa44 // indirect
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
 	github.com/BurntSushi/toml v1.2.1 // indirect
-	github.com/brianvoe/gofakeit/v6 v6.23.2 // indirect
+	github.com/beevik/etree v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/golang-jwt/jwt/v5 v5.0.0 // indirect
-	github.com/golang-migrate/migrate/v4 v4.16.2 // indirect
+	github.com/fatih/color v1.16.0 // indirect
+	github.com/go-asn1-ber/asn1-ber v1.5.5 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
-	github.com/grpc-ecosystem/go-grpc-middleware/v2 v2.0.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/ilyakaznacheev/cleanenv v1.5.0 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/mattn/go-sqlite3 v1.14.22 // indirect
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/mattermost/xml-roundtrip-validator v0.1.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
-	golang.org/x/crypto v0.18.0 // indirect
-	golang.org/x/net v0.20.0 // indirect
-	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
-	google.golang.org/grpc v1.62.0 // indirect
 	google.golang.org/protobuf v1.32.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
 )
+
+// Контракт (protos) развивается вместе с сервисом: локальная копия репозитория
+// github.com/Alexxtn105/protos лежит в ./protos. После публикации новой версии
+// контракта директиву можно убрать и обновить версию через go get.
+replace github.com/Alexxtn105/protos => ./protos
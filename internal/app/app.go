@@ -5,41 +5,243 @@ import (
 	//"log/slog"
 	//"time"
 
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
 	grpcapp "grpc-service-ref/internal/app/grpc"
+	httpapp "grpc-service-ref/internal/app/http"
+	purgeapp "grpc-service-ref/internal/app/purge"
+	"grpc-service-ref/internal/config"
+	federationhttp "grpc-service-ref/internal/http/federation"
+	"grpc-service-ref/internal/http/samlidp"
+	"grpc-service-ref/internal/http/scim"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/lib/mail"
+	"grpc-service-ref/internal/lib/secrets"
+	"grpc-service-ref/internal/services/apps"
 	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/federation"
+	"grpc-service-ref/internal/services/groups"
+	"grpc-service-ref/internal/services/invitations"
+	"grpc-service-ref/internal/services/ldapauth"
+	"grpc-service-ref/internal/services/permissions"
+	"grpc-service-ref/internal/services/policies"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/postgres"
+	"grpc-service-ref/internal/storage/schema"
 	"grpc-service-ref/internal/storage/sqlite"
-	"log/slog"
-	"time"
 )
 
 type App struct {
 	GRPCServer *grpcapp.App
-	Storage    *sqlite.Storage //Added by Alexx
+	HTTPServer *httpapp.App
+	Purge      *purgeapp.App
+	Storage    storage.Storage //Added by Alexx
 }
 
+// New создаёт приложение со всеми зависимостями.
+// Настроек стало много, поэтому передаём конфиг целиком.
 func New(
 	log *slog.Logger,
-	grpcPort int,
-	storagePath string,
-	tokenTTL time.Duration,
+	cfg *config.Config,
 ) *App {
 
-	storage, err := sqlite.New(storagePath)
+	// Секреты приложений хранятся зашифрованными мастер-ключом из конфига
+	keyring, err := secrets.NewKeyring(cfg.Secrets.MasterKeys, cfg.Secrets.ActiveKey)
+	if err != nil {
+		panic(err)
+	}
+
+	// Схема базы: новые миграции применяются при запуске (storage.auto_migrate),
+	// а с базой, обновлённой более новой сборкой, сервис не запускается.
+	// Это нужно сделать до открытия хранилища: sqlite.New подготавливает запросы к таблицам
+	if err := prepareSchema(log, cfg); err != nil {
+		panic(err)
+	}
+
+	storage, err := newStorage(cfg, keyring)
+	if err != nil {
+		panic(err)
+	}
+
+	// Шифруем секреты, записанные открытым текстом или прежним мастер-ключом
+	encrypted, err := storage.EncryptAppSecrets(context.Background())
 	if err != nil {
+		log.Error("failed to encrypt app secrets", sl.Err(err))
 		panic(err)
 	}
+	if encrypted > 0 {
+		log.Info("app secrets encrypted", slog.Int("apps", encrypted), slog.String("key_id", keyring.ActiveKeyID()))
+	}
 
-	// Трижды передаваемый storage... Увы, таковы издержки минималистичных интерфейсов.
+	// Многократно передаваемый storage... Увы, таковы издержки минималистичных интерфейсов.
 	// Но подумайте о том, что не во всех случаях реализациями этих интерфейсов
 	// может быть storage, это даёт нам больше гибкости.
 	// В любом случае, если эта концепция вам не по душе,
 	// вы всегда вольны сделать по своему.
-	authService := auth.New(log, storage, storage, storage, tokenTTL)
+	// Пароли проверяются локально, а для настроенных приложений
+	// и доменов email - в LDAP-каталогах
+	routes := make([]auth.CredentialRoute, 0, len(cfg.LDAP))
+	for _, ldapCfg := range cfg.LDAP {
+		routes = append(routes, auth.CredentialRoute{
+			Verifier: ldapauth.New(log, ldapCfg, storage, storage, storage, storage),
+			AppIDs:   ldapCfg.AppIDs,
+			Domains:  ldapCfg.Domains,
+		})
+	}
+	verifier := auth.NewCredentialRouter(auth.NewLocalVerifier(log, storage, storage), routes...)
+
+	authService := auth.New(log, storage, storage, storage, storage, storage, storage, storage, storage, storage, storage,
+		verifier, cfg.TokenTTL, cfg.AccountDeletion.Retention, cfg.DataExport.Interval)
+
+	permissionsService := permissions.New(log, authService, storage)
+
+	groupsService := groups.New(log, authService, storage, storage)
+
+	appsService := apps.New(log, authService, storage, cfg.Secrets.RotationGracePeriod)
+
+	// Письма с приглашениями пока сохраняются в файлы (см. config.MailConfig)
+	invitationsService := invitations.New(
+		log,
+		authService,
+		storage,
+		authService,
+		storage,
+		storage,
+		storage,
+		mail.NewFileSender(cfg.Mail.Dir),
+		cfg.Invitations,
+	)
+
+	policiesService := policies.New(log, authService, storage, storage, storage, storage)
+
+	federationService := federation.New(
+		log,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		cfg.Federation,
+		cfg.TokenTTL,
+		nil,
+	)
+
+	grpcApp := grpcapp.New(log, authService, permissionsService, groupsService, appsService, invitationsService,
+		policiesService, cfg.GRPC.Port)
+
+	mux := http.NewServeMux()
+	federationhttp.Register(mux, federationService)
+
+	// SAML IdP включается, только если для него настроены сертификат и ключ
+	if cfg.SAML.CertificatePath != "" && cfg.SAML.KeyPath != "" {
+		key, certificate, err := samlidp.LoadKeyPair(cfg.SAML.CertificatePath, cfg.SAML.KeyPath)
+		if err != nil {
+			panic(err)
+		}
 
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+		idp, err := samlidp.New(log, cfg.SAML.BaseURL, key, certificate, cfg.SAML.SessionTTL, storage, storage, storage, verifier)
+		if err != nil {
+			panic(err)
+		}
+		idp.Register(mux)
+	}
+
+	if cfg.SCIM.AppID != 0 {
+		scim.Register(mux, log, cfg.SCIM.AppID, storage, storage, storage)
+	}
+
+	httpApp := httpapp.New(log, mux, cfg.HTTP.Port, cfg.HTTP.Timeout)
+
+	// Удалённые учётные записи окончательно удаляются в фоне по истечении срока хранения
+	purgeApp := purgeapp.New(log, storage, cfg.AccountDeletion.Retention, cfg.AccountDeletion.PurgeInterval)
 
 	return &App{
 		GRPCServer: grpcApp,
+		HTTPServer: httpApp,
+		Purge:      purgeApp,
 		Storage:    storage,
 	}
 }
+
+// prepareSchema применяет встроенные миграции, если включён storage.auto_migrate,
+// и проверяет, что схема базы не новее той, что знает эта сборка.
+// Неизвестный драйвер здесь пропускается: его отклонит newStorage.
+func prepareSchema(log *slog.Logger, cfg *config.Config) error {
+	var driver, target string
+	switch cfg.Storage.Driver {
+	case "", "sqlite":
+		driver, target = "sqlite", cfg.StoragePath
+	case "postgres":
+		driver, target = "postgres", cfg.Storage.DSN
+	default:
+		return nil
+	}
+
+	if cfg.Storage.AutoMigrate {
+		status, err := schema.Migrate(context.Background(), driver, target)
+		if err != nil {
+			return err
+		}
+		log.Info("database schema is up to date", slog.Int("version", status.Version))
+
+		return nil
+	}
+
+	status, err := schema.Check(driver, target)
+	if err != nil {
+		return err
+	}
+	if status.Pending() {
+		// запросы к новым таблицам и колонкам будут падать
+		log.Warn("database schema is behind, run migrator or enable storage.auto_migrate",
+			slog.Int("version", status.Version),
+			slog.Int("latest", status.Latest),
+		)
+	}
+
+	return nil
+}
+
+// OpenStorage opens the storage configured in cfg for maintenance tools (ssoctl).
+// Схема базы здесь не проверяется и не мигрируется.
+func OpenStorage(cfg *config.Config) (storage.Storage, error) {
+	keyring, err := secrets.NewKeyring(cfg.Secrets.MasterKeys, cfg.Secrets.ActiveKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStorage(cfg, keyring)
+}
+
+// newStorage открывает хранилище, выбранное в конфиге (storage.driver)
+func newStorage(cfg *config.Config, keyring *secrets.Keyring) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case "", "sqlite":
+		if cfg.StoragePath == "" {
+			return nil, errors.New("storage_path is required for sqlite storage")
+		}
+
+		return sqlite.New(cfg.StoragePath, keyring, sqlite.Options{
+			MaxOpenConns: cfg.Storage.SQLite.MaxOpenConns,
+			MaxIdleConns: cfg.Storage.SQLite.MaxIdleConns,
+			BusyTimeout:  cfg.Storage.SQLite.BusyTimeout,
+			JournalMode:  cfg.Storage.SQLite.JournalMode,
+			Synchronous:  cfg.Storage.SQLite.Synchronous,
+			ForeignKeys:  cfg.Storage.SQLite.ForeignKeys,
+		})
+	case "postgres":
+		if cfg.Storage.DSN == "" {
+			return nil, errors.New("storage.dsn is required for postgres storage")
+		}
+
+		return postgres.New(cfg.Storage.DSN, keyring)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
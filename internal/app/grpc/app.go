@@ -14,16 +14,36 @@ import (
 	"log/slog"
 	"net"
 
+	appsgrpc "grpc-service-ref/internal/grpc/apps"
 	authgrpc "grpc-service-ref/internal/grpc/auth"
+	groupsgrpc "grpc-service-ref/internal/grpc/groups"
+	invitationsgrpc "grpc-service-ref/internal/grpc/invitations"
+	permissionsgrpc "grpc-service-ref/internal/grpc/permissions"
+	policiesgrpc "grpc-service-ref/internal/grpc/policies"
 
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/selector"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// noPayloadLogging RPC-методы, в запросах или ответах которых есть секреты или персональные данные:
+// их тела не логируются вовсе, иначе эти данные окажутся в логах в открытом виде
+var noPayloadLogging = map[string]bool{
+	ssov1.Apps_CreateApp_FullMethodName:               true, // секрет нового приложения
+	ssov1.Apps_RotateAppSecret_FullMethodName:         true, // новый секрет приложения
+	ssov1.Auth_DeleteAccount_FullMethodName:           true, // пароль для подтверждения удаления
+	ssov1.Auth_ExportMyData_FullMethodName:            true, // архив со всеми данными пользователя
+	ssov1.Auth_TokenExchange_FullMethodName:           true, // секрет приложения, токен субъекта и выданный токен
+	ssov1.Invitations_AcceptInvitation_FullMethodName: true, // токен приглашения и пароль нового пользователя
+}
+
 // Структура, которая будет представлять приложение gRPC-сервера
 // и интерфейс для сервисного слоя — в нашем случае это только Auth,
 // но потенциально сервисов может быть больше.
@@ -39,7 +59,16 @@ type App struct {
 // Установка:
 // go get github.com/grpc-ecosystem/go-grpc-middleware/v2@v2.0.0
 // Один из параметров authgrpc.Auth - это интерфейс сервисного слоя, НЕ ПУТАТЬ с gRPC-сервисом Auth!!! Его мы напишем чуть ниже.
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
+func New(
+	log *slog.Logger,
+	authService authgrpc.Auth,
+	permissionsService permissionsgrpc.Permissions,
+	groupsService groupsgrpc.Groups,
+	appsService appsgrpc.Apps,
+	invitationsService invitationsgrpc.Invitations,
+	policiesService policiesgrpc.Policies,
+	port int,
+) *App {
 	// TODO: создать gRPCServer и подключить к нему интерсепторы
 	// пример создания сервера:
 	// gRPCServer := grpc.NewServer(opts)
@@ -92,8 +121,13 @@ func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
 	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
 		//интерцептор восстановления после паники
 		recovery.UnaryServerInterceptor(recoveryOpts...),
-		//интерцептор логирования
-		logging.UnaryServerInterceptor(InterceptorLogger(log), loggingOpts...),
+		//интерцептор логирования (кроме методов с секретами, см. noPayloadLogging)
+		selector.UnaryServerInterceptor(
+			logging.UnaryServerInterceptor(InterceptorLogger(log), loggingOpts...),
+			selector.MatchFunc(func(_ context.Context, callMeta interceptors.CallMeta) bool {
+				return !noPayloadLogging[callMeta.FullMethod()]
+			}),
+		),
 
 		//Помимо этих двух, могут также понадобиться интерсепторы для следующих целей:
 		//трейсинг, метрики, алерты, авторизация и др.
@@ -109,6 +143,21 @@ func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
 	// теперь связаны с сервером gRPC.
 	authgrpc.Register(gRPCServer, authService)
 
+	// Регистрируем gRPC-сервис Permissions (роли и права в приложениях)
+	permissionsgrpc.Register(gRPCServer, permissionsService)
+
+	// Регистрируем gRPC-сервис Groups (группы пользователей)
+	groupsgrpc.Register(gRPCServer, groupsService)
+
+	// Регистрируем gRPC-сервис Apps (доступ к приложениям)
+	appsgrpc.Register(gRPCServer, appsService)
+
+	// Регистрируем gRPC-сервис Invitations (приглашения пользователей)
+	invitationsgrpc.Register(gRPCServer, invitationsService)
+
+	// Регистрируем gRPC-сервис Policies (политики доступа, ABAC)
+	policiesgrpc.Register(gRPCServer, policiesService)
+
 	// Вернуть объект App со всеми необходимыми полями
 	return &App{
 		log:        log,
@@ -0,0 +1,77 @@
+// internal/app/http/app.go
+
+// Приложение HTTP-сервера - по аналогии с gRPC-сервером (internal/app/grpc).
+// Нужен для браузерных сценариев, которые не ложатся на gRPC
+// (например, вход через внешних провайдеров с редиректами).
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"grpc-service-ref/internal/lib/logger/sl"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int //порт на котором будет работать HTTP-сервер
+}
+
+// New creates new HTTP server app.
+func New(log *slog.Logger, handler http.Handler, port int, timeout time.Duration) *App {
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: timeout,
+		ReadTimeout:       timeout,
+		WriteTimeout:      timeout,
+	}
+
+	return &App{
+		log:        log,
+		httpServer: httpServer,
+		port:       port,
+	}
+}
+
+// MustRun runs HTTP server and panics if any error occurs.
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	l, err := net.Listen("tcp", a.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("http server started", slog.String("addr", l.Addr().String()))
+
+	// После Shutdown() Serve возвращает http.ErrServerClosed - это штатная остановка
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Stop stops HTTP server.
+// Как и в gRPC-сервере: перестаём принимать новые запросы и ждём завершения текущих.
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping HTTP server", slog.Int("port", a.port))
+
+	if err := a.httpServer.Shutdown(context.Background()); err != nil {
+		a.log.Error("failed to stop HTTP server", sl.Err(err))
+	}
+}
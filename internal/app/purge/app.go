@@ -0,0 +1,97 @@
+// internal/app/purge/app.go
+
+// Фоновое приложение окончательного удаления учётных записей - по аналогии
+// с gRPC- и HTTP-серверами (internal/app/grpc, internal/app/http).
+// Раз в interval удаляет пользователей, у которых истёк срок хранения после удаления.
+package purgeapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"grpc-service-ref/internal/lib/logger/sl"
+)
+
+// Purger окончательно удаляет пользователей, помеченных удалёнными раньше deletedBefore
+type Purger interface {
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+type App struct {
+	log       *slog.Logger
+	purger    Purger
+	retention time.Duration // сколько хранятся данные удалённого пользователя
+	interval  time.Duration // как часто запускается удаление
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// New creates new purge app.
+func New(log *slog.Logger, purger Purger, retention time.Duration, interval time.Duration) *App {
+	return &App{
+		log:       log,
+		purger:    purger,
+		retention: retention,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// MustRun runs purge loop and panics if any error occurs.
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Run purges deleted users every interval until Stop is called.
+// Ошибка удаления не останавливает цикл: попробуем ещё раз на следующем шаге.
+func (a *App) Run() error {
+	defer close(a.done)
+
+	a.log.Info("account purge started",
+		slog.Duration("retention", a.retention),
+		slog.Duration("interval", a.interval),
+	)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.purge()
+
+		select {
+		case <-a.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops purge loop and waits for the current purge to finish.
+func (a *App) Stop() {
+	const op = "purgeapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping account purge")
+
+	close(a.stop)
+	<-a.done
+}
+
+func (a *App) purge() {
+	const op = "purgeapp.purge"
+
+	log := a.log.With(slog.String("op", op))
+
+	purged, err := a.purger.PurgeDeletedUsers(context.Background(), time.Now().Add(-a.retention))
+	if err != nil {
+		// часть пользователей могла быть удалена до ошибки
+		log.Error("failed to purge deleted users", slog.Int("purged", purged), sl.Err(err))
+		return
+	}
+	if purged > 0 {
+		log.Info("deleted users purged", slog.Int("purged", purged))
+	}
+}
@@ -0,0 +1,77 @@
+// internal/config/config_test.go
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/config"
+)
+
+func TestParseLDAPRole(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected config.LDAPRole
+		wantErr  bool
+	}{
+		{value: "admin", expected: config.LDAPRole{Admin: true}},
+		{value: "7:developer", expected: config.LDAPRole{AppID: 7, Name: "developer"}},
+		{value: "7: developer ", expected: config.LDAPRole{AppID: 7, Name: "developer"}},
+		{value: "Admin", wantErr: true},
+		{value: "developer", wantErr: true},
+		{value: "app:developer", wantErr: true},
+		{value: "0:developer", wantErr: true},
+		{value: "7:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			role, err := config.ParseLDAPRole(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, role)
+		})
+	}
+}
+
+func TestMustLoadPath_RoleMapping(t *testing.T) {
+	// роль с опечаткой не должна молча пропадать: сервис не запускается
+	invalid := writeConfig(t, `
+ldap:
+  - name: "corp"
+    role_mapping:
+      "cn=admins,dc=example,dc=com": "admins"
+`)
+	assert.PanicsWithValue(t,
+		`invalid config: ldap "corp": role_mapping for "cn=admins,dc=example,dc=com": `+
+			`unknown role "admins": expected "admin" or "<app_id>:<role>"`,
+		func() { config.MustLoadPath(invalid) })
+
+	valid := writeConfig(t, `
+ldap:
+  - name: "corp"
+    role_mapping:
+      "cn=admins,dc=example,dc=com": "admin"
+      "cn=devs,dc=example,dc=com": "2:developer"
+`)
+	cfg := config.MustLoadPath(valid)
+	require.Len(t, cfg.LDAP, 1)
+	assert.Len(t, cfg.LDAP[0].RoleMapping, 2)
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
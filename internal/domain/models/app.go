@@ -1,7 +1,116 @@
 package models
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Виды приложений
+const (
+	AppKindJWT  = "jwt"  // обычное приложение, пользователи получают JWT
+	AppKindSAML = "saml" // SAML service provider, пользователи получают SAML assertion
+	AppKindSCIM = "scim" // клиент SCIM API: его секрет - bearer-токен провижининга, войти в него нельзя
+)
+
+// Режимы доступа к приложению
+const (
+	AppAccessOpen       = "open"        // войти может любой пользователь организации
+	AppAccessInviteOnly = "invite_only" // только участники приложения
+	AppAccessDomain     = "domain"      // участники и пользователи с email из разрешённых доменов
+)
+
+// Алгоритмы подписи токенов приложения (HMAC секретом приложения)
+const (
+	TokenAlgHS256 = "HS256" // по умолчанию
+	TokenAlgHS384 = "HS384"
+	TokenAlgHS512 = "HS512"
+)
+
 type App struct {
-	ID     int
-	Name   string
-	Secret string
+	ID       int
+	TenantID int64 // Войти в приложение могут только пользователи его организации
+	Name     string
+	Secret   string // Текущий секрет: им подписываются новые токены
+	Kind     string
+	// Предыдущий секрет после ротации: подписанные им токены принимаются до PreviousSecretExpiresAt
+	PreviousSecret          string
+	PreviousSecretExpiresAt time.Time
+	// Кто может войти в приложение: один из AppAccess*
+	AccessMode string
+	// Домены email для режима AppAccessDomain
+	AllowedDomains []string
+	// В отключённое приложение нельзя войти, а его токены не принимаются
+	Disabled bool
+	// Заполняется только для приложений вида AppKindSAML
+	SAML SAMLServiceProvider
+	// Настройки выдаваемых приложению токенов
+	Tokens TokenSettings
+}
+
+// TokenSettings настройки токенов приложения: время жизни, алгоритм подписи и дополнительные claims.
+// Нулевое значение - настройки по умолчанию.
+type TokenSettings struct {
+	AccessTTL time.Duration // Время жизни токена; 0 - token_ttl из конфига
+	Algorithm string        // Один из TokenAlg*; пустой - TokenAlgHS256
+	// Атрибуты пользователя (заданные администратором, см. сервис policies),
+	// которые попадают в токен одноимёнными claims. Отсутствующие у пользователя атрибуты пропускаются.
+	ClaimAttributes []string
+	// Постоянные claims, одинаковые во всех токенах приложения
+	StaticClaims map[string]string
+}
+
+// SAMLServiceProvider настройки SAML service provider'а
+type SAMLServiceProvider struct {
+	EntityID    string // Идентификатор SP (обычно адрес его метаданных)
+	ACSURL      string // Assertion Consumer Service: куда отправляется ответ IdP
+	Certificate string // Сертификат SP в формате PEM (необязательный)
+}
+
+// VerificationSecrets returns the secrets valid for checking app tokens at the moment now:
+// the current one and, during the grace period after rotation, the previous one.
+func (a App) VerificationSecrets(now time.Time) []string {
+	secrets := []string{a.Secret}
+	if a.PreviousSecret != "" && now.Before(a.PreviousSecretExpiresAt) {
+		secrets = append(secrets, a.PreviousSecret)
+	}
+
+	return secrets
+}
+
+// Admits reports whether the user with the email may log into the app.
+// isMember - является ли пользователь участником приложения.
+// Неизвестный режим доступа считается закрытым, в приложение провижининга не входит никто.
+func (a App) Admits(email string, isMember bool) bool {
+	if a.Kind == AppKindSCIM {
+		return false
+	}
+
+	switch a.AccessMode {
+	case AppAccessOpen, "":
+		return true
+	case AppAccessInviteOnly:
+		return isMember
+	case AppAccessDomain:
+		if isMember {
+			return true
+		}
+
+		i := strings.LastIndex(email, "@")
+		if i < 0 {
+			return false
+		}
+		domain := email[i+1:]
+
+		return slices.ContainsFunc(a.AllowedDomains, func(d string) bool {
+			return strings.EqualFold(d, domain)
+		})
+	default:
+		return false
+	}
+}
+
+// ValidAppAccessMode reports whether mode is one of AppAccess*
+func ValidAppAccessMode(mode string) bool {
+	return mode == AppAccessOpen || mode == AppAccessInviteOnly || mode == AppAccessDomain
 }
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Действия, которые записываются в журнал аудита
+const (
+	AuditActionSetAdmin          = "set_admin"           // изменение статуса администратора
+	AuditActionAssignRole        = "assign_role"         // назначение роли пользователю
+	AuditActionRevokeRole        = "revoke_role"         // отзыв роли у пользователя
+	AuditActionSetGroupAdmin     = "set_group_admin"     // изменение статуса группы администраторов
+	AuditActionAssignGroupRole   = "assign_group_role"   // выдача роли группе
+	AuditActionRevokeGroupRole   = "revoke_group_role"   // отзыв роли у группы
+	AuditActionAddGroupMember    = "add_group_member"    // добавление пользователя в группу
+	AuditActionRemoveGroupMember = "remove_group_member" // исключение пользователя из группы
+	AuditActionAddSubgroup       = "add_subgroup"        // вложение группы в группу
+	AuditActionRemoveSubgroup    = "remove_subgroup"     // исключение вложенной группы
+	AuditActionSetAppAccess      = "set_app_access"      // изменение режима доступа к приложению
+	AuditActionAddAppMember      = "add_app_member"      // добавление участника приложения
+	AuditActionRemoveAppMember   = "remove_app_member"   // исключение участника приложения
+	AuditActionCreateInvitation  = "create_invitation"   // приглашение пользователя
+	AuditActionRevokeInvitation  = "revoke_invitation"   // отзыв приглашения
+	AuditActionAcceptInvitation  = "accept_invitation"   // принятие приглашения
+	AuditActionSetPolicy         = "set_policy"          // новая версия политики доступа приложения
+	AuditActionSetUserAttributes = "set_user_attributes" // изменение атрибутов пользователя
+	AuditActionCreateApp         = "create_app"          // создание приложения
+	AuditActionUpdateApp         = "update_app"          // изменение имени или настроек приложения
+	AuditActionSetAppDisabled    = "set_app_disabled"    // отключение или включение приложения
+	AuditActionDeleteApp         = "delete_app"          // удаление приложения
+	AuditActionRotateAppSecret   = "rotate_app_secret"   // выдача приложению нового секрета
+	AuditActionSetTokenSettings  = "set_token_settings"  // изменение настроек токенов приложения
+	AuditActionDeleteUser        = "delete_user"         // удаление пользователя (до окончательного удаления)
+	AuditActionPurgeUser         = "purge_user"          // окончательное удаление пользователя по истечении срока хранения
+)
+
+// SystemActorID ActorID действий, которые выполняет сам сервис, а не пользователь
+// (например, синхронизация статуса администратора с LDAP-каталогом)
+const SystemActorID int64 = 0
+
+// AuditUserTargetActions действия, у которых TargetID - ID пользователя
+var AuditUserTargetActions = []string{
+	AuditActionSetAdmin,
+	AuditActionAssignRole,
+	AuditActionRevokeRole,
+	AuditActionSetUserAttributes,
+	AuditActionDeleteUser,
+	AuditActionPurgeUser,
+}
+
+// AuditUserValueActions действия с группой или приложением, у которых ID пользователя записан в OldValue или NewValue
+var AuditUserValueActions = []string{
+	AuditActionAddGroupMember,
+	AuditActionRemoveGroupMember,
+	AuditActionAddAppMember,
+	AuditActionRemoveAppMember,
+}
+
+// AuditInvitationActions действия с приглашением, у которых TargetID - ID приглашения
+var AuditInvitationActions = []string{
+	AuditActionCreateInvitation,
+	AuditActionRevokeInvitation,
+	AuditActionAcceptInvitation,
+}
+
+// AuditEvent запись журнала аудита: кто, что и с кем сделал
+type AuditEvent struct {
+	ID        int64
+	ActorID   int64  // ID пользователя, выполнившего действие; SystemActorID - сам сервис
+	Action    string // Одно из AuditAction*
+	TargetID  int64  // ID объекта действия (например, пользователя)
+	OldValue  string
+	NewValue  string
+	CreatedAt time.Time
+}
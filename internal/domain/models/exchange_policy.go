@@ -0,0 +1,11 @@
+package models
+
+// ExchangePolicy политика обмена токенов:
+// приложение SourceAppID может обменять токен пользователя
+// на токен для приложения TargetAppID с разрешёнными Scopes
+type ExchangePolicy struct {
+	ID          int64
+	SourceAppID int
+	TargetAppID int
+	Scopes      []string
+}
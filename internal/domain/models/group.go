@@ -0,0 +1,16 @@
+package models
+
+// Group группа пользователей
+type Group struct {
+	ID         int64
+	TenantID   int64
+	Name       string
+	ExternalID string  // Идентификатор группы во внешней системе (externalId в SCIM)
+	Members    []int64 // ID пользователей - участников группы
+	// Участники вложенных групп считаются участниками и этой группы
+	Subgroups []int64
+	// Участники группы администраторов - администраторы
+	IsAdmin bool
+	// ID ролей, выданных группе
+	Roles []int64
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Состояния приглашения
+const (
+	InvitationPending  = "pending"  // ждёт принятия
+	InvitationAccepted = "accepted" // принято
+	InvitationRevoked  = "revoked"  // отозвано администратором
+	InvitationExpired  = "expired"  // истёк срок действия
+)
+
+// Invitation приглашение пользователя в приложение (и в организацию приложения)
+type Invitation struct {
+	ID        int64
+	TenantID  int64
+	AppID     int
+	Email     string
+	RoleID    int64 // Роль, выдаваемая при принятии (0 - без роли)
+	CreatedBy int64 // Администратор, создавший приглашение
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// Заполняются при принятии или отзыве
+	AcceptedBy int64
+	AcceptedAt time.Time
+	RevokedAt  time.Time
+}
+
+// Status returns the state of the invitation at the moment now (one of Invitation*)
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case !i.AcceptedAt.IsZero():
+		return InvitationAccepted
+	case !i.RevokedAt.IsZero():
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Policy версия политики доступа (ABAC) приложения.
+// Document - JSON-документ с правилами (формат описан в пакете lib/policy).
+type Policy struct {
+	AppID     int
+	Version   int
+	Document  string
+	CreatedBy int64 // 0, если автор удалён окончательно
+	CreatedAt time.Time
+}
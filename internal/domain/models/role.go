@@ -0,0 +1,10 @@
+package models
+
+// Role роль пользователя в приложении (RBAC).
+// Роль задаётся для конкретного приложения и даёт набор прав (например, "reports:read").
+type Role struct {
+	ID          int64
+	AppID       int
+	Name        string
+	Permissions []string
+}
@@ -0,0 +1,12 @@
+package models
+
+// DefaultTenantID организация по умолчанию: в неё попадают пользователи и приложения,
+// созданные до появления организаций, и пользователи, зарегистрированные без указания приложения
+const DefaultTenantID int64 = 1
+
+// Tenant организация (клиент) со своим изолированным каталогом пользователей и групп.
+// Email пользователя и имя группы уникальны только в пределах организации.
+type Tenant struct {
+	ID   int64
+	Name string
+}
@@ -2,6 +2,11 @@ package models
 
 type User struct {
 	ID       int64
+	TenantID int64 // Организация, в каталоге которой находится пользователь
 	Email    string
 	PassHash []byte
+	// Отключённый пользователь не может войти (например, после увольнения)
+	Disabled bool
+	// Идентификатор пользователя во внешней системе (externalId в SCIM)
+	ExternalID string
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserData всё, что хранится о пользователе: строка users и записи, связанные с ней по ID пользователя.
+// Собирается для выгрузки данных по запросу самого пользователя (GDPR).
+// Данные других пользователей сюда не попадают: у групп нет списка участников, у ролей - прав.
+type UserData struct {
+	User      User
+	IsAdmin   bool      // Собственный флаг администратора (без административных групп)
+	DeletedAt time.Time // Не нулевое, если учётная запись удалена и ждёт окончательного удаления
+
+	Attributes  map[string]string
+	Identities  []FederatedIdentity // Учётные записи внешних провайдеров
+	Groups      []Group             // Группы, в которые пользователь входит напрямую
+	Roles       []Role              // Роли, выданные пользователю напрямую
+	Apps        []int               // Приложения, участником которых добавлен пользователь
+	Invitations []Invitation        // Приглашения на его email, принятые или созданные им
+	Policies    []Policy            // Версии политик доступа, созданные им
+	Audit       []AuditEvent        // Записи журнала, где пользователь - автор или объект действия
+}
+
+// FederatedIdentity учётная запись пользователя у внешнего провайдера
+type FederatedIdentity struct {
+	Provider string
+	Subject  string
+}
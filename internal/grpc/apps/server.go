@@ -0,0 +1,376 @@
+// internal/grpc/apps/server.go
+package apps
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/bearer"
+	"grpc-service-ref/internal/services/apps"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverAPI реализация gRPC-сервиса Apps (управление приложениями и доступ к ним)
+type serverAPI struct {
+	ssov1.UnimplementedAppsServer
+	apps Apps
+}
+
+// Apps интерфейс сервисного слоя приложений
+type Apps interface {
+	Create(ctx context.Context, callerToken string, app models.App) (models.App, error)
+	List(ctx context.Context, callerToken string, offset int, limit int) ([]models.App, int, error)
+	Update(ctx context.Context, callerToken string, app models.App) error
+	SetDisabled(ctx context.Context, callerToken string, appID int, disabled bool) error
+	Delete(ctx context.Context, callerToken string, appID int) error
+	RotateSecret(ctx context.Context, callerToken string, appID int) (string, time.Time, error)
+	SetTokenSettings(ctx context.Context, callerToken string, appID int, settings models.TokenSettings) error
+	SetAccess(ctx context.Context, callerToken string, appID int, mode string, domains []string) error
+	AddMember(ctx context.Context, callerToken string, appID int, userID int64) error
+	RemoveMember(ctx context.Context, callerToken string, appID int, userID int64) error
+	Members(ctx context.Context, callerToken string, appID int) ([]int64, error)
+}
+
+// Register регистрация serverAPI в gRPC-сервере
+func Register(gRPCServer *grpc.Server, apps Apps) {
+	ssov1.RegisterAppsServer(gRPCServer, &serverAPI{apps: apps})
+}
+
+// CreateApp RPC-метод создания приложения
+func (s *serverAPI) CreateApp(
+	ctx context.Context,
+	req *ssov1.CreateAppRequest,
+) (*ssov1.CreateAppResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	app, err := s.apps.Create(ctx, token, models.App{
+		Name: req.GetName(),
+		Kind: req.GetKind(),
+		SAML: models.SAMLServiceProvider{
+			EntityID:    req.GetSamlEntityId(),
+			ACSURL:      req.GetSamlAcsUrl(),
+			Certificate: req.GetSamlCertificate(),
+		},
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to create app")
+	}
+
+	return &ssov1.CreateAppResponse{App: toProto(app), Secret: app.Secret}, nil
+}
+
+// ListApps RPC-метод получения страницы приложений организации
+func (s *serverAPI) ListApps(
+	ctx context.Context,
+	req *ssov1.ListAppsRequest,
+) (*ssov1.ListAppsResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetOffset() < 0 || req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+
+	list, total, err := s.apps.List(ctx, token, int(req.GetOffset()), int(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err, "failed to list apps")
+	}
+
+	resp := &ssov1.ListAppsResponse{Apps: make([]*ssov1.App, 0, len(list)), Total: int32(total)}
+	for _, app := range list {
+		resp.Apps = append(resp.Apps, toProto(app))
+	}
+
+	return resp, nil
+}
+
+// UpdateApp RPC-метод изменения имени и настроек приложения
+func (s *serverAPI) UpdateApp(
+	ctx context.Context,
+	req *ssov1.UpdateAppRequest,
+) (*ssov1.UpdateAppResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	err := s.apps.Update(ctx, token, models.App{
+		ID:   int(req.GetAppId()),
+		Name: req.GetName(),
+		Kind: req.GetKind(),
+		SAML: models.SAMLServiceProvider{
+			EntityID:    req.GetSamlEntityId(),
+			ACSURL:      req.GetSamlAcsUrl(),
+			Certificate: req.GetSamlCertificate(),
+		},
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to update app")
+	}
+
+	return &ssov1.UpdateAppResponse{}, nil
+}
+
+// SetAppDisabled RPC-метод отключения и включения приложения
+func (s *serverAPI) SetAppDisabled(
+	ctx context.Context,
+	req *ssov1.SetAppDisabledRequest,
+) (*ssov1.SetAppDisabledResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := s.apps.SetDisabled(ctx, token, int(req.GetAppId()), req.GetDisabled()); err != nil {
+		return nil, toStatus(err, "failed to set app disabled")
+	}
+
+	return &ssov1.SetAppDisabledResponse{}, nil
+}
+
+// DeleteApp RPC-метод удаления приложения
+func (s *serverAPI) DeleteApp(
+	ctx context.Context,
+	req *ssov1.DeleteAppRequest,
+) (*ssov1.DeleteAppResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := s.apps.Delete(ctx, token, int(req.GetAppId())); err != nil {
+		return nil, toStatus(err, "failed to delete app")
+	}
+
+	return &ssov1.DeleteAppResponse{}, nil
+}
+
+// RotateAppSecret RPC-метод выдачи приложению нового секрета
+func (s *serverAPI) RotateAppSecret(
+	ctx context.Context,
+	req *ssov1.RotateAppSecretRequest,
+) (*ssov1.RotateAppSecretResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	secret, previousExpiresAt, err := s.apps.RotateSecret(ctx, token, int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err, "failed to rotate app secret")
+	}
+
+	return &ssov1.RotateAppSecretResponse{
+		Secret:                  secret,
+		PreviousSecretExpiresAt: previousExpiresAt.Unix(),
+	}, nil
+}
+
+// SetAppTokenSettings RPC-метод изменения настроек токенов приложения
+func (s *serverAPI) SetAppTokenSettings(
+	ctx context.Context,
+	req *ssov1.SetAppTokenSettingsRequest,
+) (*ssov1.SetAppTokenSettingsResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	settings := req.GetTokenSettings()
+	err := s.apps.SetTokenSettings(ctx, token, int(req.GetAppId()), models.TokenSettings{
+		AccessTTL:       time.Duration(settings.GetAccessTokenTtlSeconds()) * time.Second,
+		Algorithm:       settings.GetAlgorithm(),
+		ClaimAttributes: settings.GetClaimAttributes(),
+		StaticClaims:    settings.GetStaticClaims(),
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to set token settings")
+	}
+
+	return &ssov1.SetAppTokenSettingsResponse{}, nil
+}
+
+// SetAppAccess RPC-метод изменения режима доступа к приложению
+func (s *serverAPI) SetAppAccess(
+	ctx context.Context,
+	req *ssov1.SetAppAccessRequest,
+) (*ssov1.SetAppAccessResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetAccessMode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access_mode is required")
+	}
+
+	err := s.apps.SetAccess(ctx, token, int(req.GetAppId()), req.GetAccessMode(), req.GetAllowedDomains())
+	if err != nil {
+		return nil, toStatus(err, "failed to set app access")
+	}
+
+	return &ssov1.SetAppAccessResponse{}, nil
+}
+
+// AddAppMember RPC-метод добавления участника приложения
+func (s *serverAPI) AddAppMember(
+	ctx context.Context,
+	req *ssov1.AppMemberRequest,
+) (*ssov1.AppMemberResponse, error) {
+	token, err := validateAppMember(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apps.AddMember(ctx, token, int(req.GetAppId()), req.GetUserId()); err != nil {
+		return nil, toStatus(err, "failed to add app member")
+	}
+
+	return &ssov1.AppMemberResponse{}, nil
+}
+
+// RemoveAppMember RPC-метод исключения участника приложения
+func (s *serverAPI) RemoveAppMember(
+	ctx context.Context,
+	req *ssov1.AppMemberRequest,
+) (*ssov1.AppMemberResponse, error) {
+	token, err := validateAppMember(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apps.RemoveMember(ctx, token, int(req.GetAppId()), req.GetUserId()); err != nil {
+		return nil, toStatus(err, "failed to remove app member")
+	}
+
+	return &ssov1.AppMemberResponse{}, nil
+}
+
+// ListAppMembers RPC-метод получения участников приложения
+func (s *serverAPI) ListAppMembers(
+	ctx context.Context,
+	req *ssov1.ListAppMembersRequest,
+) (*ssov1.ListAppMembersResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	members, err := s.apps.Members(ctx, token, int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err, "failed to list app members")
+	}
+
+	return &ssov1.ListAppMembersResponse{UserIds: members}, nil
+}
+
+func validateAppMember(ctx context.Context, req *ssov1.AppMemberRequest) (string, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	return token, nil
+}
+
+func toProto(app models.App) *ssov1.App {
+	return &ssov1.App{
+		Id:              int32(app.ID),
+		Name:            app.Name,
+		Kind:            app.Kind,
+		Disabled:        app.Disabled,
+		AccessMode:      app.AccessMode,
+		AllowedDomains:  app.AllowedDomains,
+		SamlEntityId:    app.SAML.EntityID,
+		SamlAcsUrl:      app.SAML.ACSURL,
+		SamlCertificate: app.SAML.Certificate,
+		TokenSettings: &ssov1.TokenSettings{
+			AccessTokenTtlSeconds: int64(app.Tokens.AccessTTL / time.Second),
+			Algorithm:             app.Tokens.Algorithm,
+			ClaimAttributes:       app.Tokens.ClaimAttributes,
+			StaticClaims:          app.Tokens.StaticClaims,
+		},
+	}
+}
+
+// toStatus переводит ошибки сервисного слоя в gRPC-статусы
+func toStatus(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "caller is not admin")
+	case errors.Is(err, apps.ErrInvalidAccessMode):
+		return status.Error(codes.InvalidArgument, "invalid access mode or allowed domains")
+	case errors.Is(err, apps.ErrInvalidApp):
+		return status.Error(codes.InvalidArgument, "invalid app kind or SAML settings")
+	case errors.Is(err, apps.ErrInvalidTokenSettings):
+		return status.Error(codes.InvalidArgument, "invalid token TTL, algorithm or claims")
+	case errors.Is(err, storage.ErrAppExists):
+		return status.Error(codes.AlreadyExists, "app already exists")
+	case errors.Is(err, storage.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	}
+
+	return status.Error(codes.Internal, internalMsg)
+}
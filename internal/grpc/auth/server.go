@@ -3,6 +3,9 @@ package auth
 import (
 	"context"
 	"errors"
+	"time"
+
+	"grpc-service-ref/internal/lib/bearer"
 	"grpc-service-ref/internal/services/auth"
 	"grpc-service-ref/internal/storage"
 
@@ -37,9 +40,25 @@ type Auth interface {
 		ctx context.Context,
 		email string,
 		password string,
+		appID int,
 	) (userID int64, err error)
 
-	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	IsAdmin(ctx context.Context, callerToken string, userID int64) (bool, error)
+
+	ExchangeToken(
+		ctx context.Context,
+		subjectToken string,
+		appID int,
+		appSecret string,
+		audience int,
+		scopes []string,
+	) (token string, granted []string, err error)
+
+	SetAdmin(ctx context.Context, callerToken string, userID int64, isAdmin bool) error
+
+	DeleteAccount(ctx context.Context, callerToken string, userID int64, password string) (purgeAt time.Time, err error)
+
+	ExportMyData(ctx context.Context, callerToken string) (archive []byte, err error)
 }
 
 // Register регистрация serverAPI в gRPC-сервере
@@ -87,6 +106,20 @@ func (s *serverAPI) Login(
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
 
+		// Пользователь существует, но не допущен в это приложение
+		if errors.Is(err, auth.ErrAppAccessDenied) {
+			return nil, status.Error(codes.PermissionDenied, "access to the app denied")
+		}
+
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "app not found")
+		}
+
+		// Приложение отключено администратором
+		if errors.Is(err, storage.ErrAppDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "app is disabled")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to login")
 	}
 
@@ -106,31 +139,49 @@ func (s *serverAPI) Register(
 		return nil, status.Error(codes.InvalidArgument, "password is required")
 	}
 
-	uid, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
+	// app_id необязателен: без него пользователь попадает в организацию по умолчанию
+	uid, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
 	if err != nil {
 		// Ошибку storage.ErrUserExists мы создадим ниже
 		if errors.Is(err, storage.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "user already exists")
 		}
 
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "app not found")
+		}
+
+		if errors.Is(err, storage.ErrAppDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "app is disabled")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to register user")
 	}
 
 	return &ssov1.RegisterResponse{UserId: uid}, nil
 }
 
-// IsAdmin RPC-метод получения статуса администратора по ИД пользователя
+// IsAdmin RPC-метод получения статуса администратора по ИД пользователя.
+// Токен вызывающего передаётся в метаданных: "authorization: Bearer <token>".
 func (s *serverAPI) IsAdmin(
 	ctx context.Context,
 	req *ssov1.IsAdminRequest,
 ) (*ssov1.IsAdminResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
 	if req.UserId == 0 {
 		return nil, status.Error(codes.InvalidArgument, "user_id is required")
 	}
 
-	isAdmin, err := s.auth.IsAdmin(ctx, req.GetUserId())
+	isAdmin, err := s.auth.IsAdmin(ctx, token, req.GetUserId())
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, storage.ErrUserNotFound):
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 
@@ -140,6 +191,147 @@ func (s *serverAPI) IsAdmin(
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
 
+// TokenExchange RPC-метод обмена токена пользователя на токен для другого приложения (RFC 8693)
+func (s *serverAPI) TokenExchange(
+	ctx context.Context,
+	req *ssov1.TokenExchangeRequest,
+) (*ssov1.TokenExchangeResponse, error) {
+	if req.GetSubjectToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetAppSecret() == "" {
+		return nil, status.Error(codes.InvalidArgument, "app_secret is required")
+	}
+
+	if req.GetAudience() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "audience is required")
+	}
+
+	token, scopes, err := s.auth.ExchangeToken(
+		ctx,
+		req.GetSubjectToken(),
+		int(req.GetAppId()),
+		req.GetAppSecret(),
+		int(req.GetAudience()),
+		req.GetScopes(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidAppCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid app credentials")
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.InvalidArgument, "invalid subject token")
+		case errors.Is(err, auth.ErrExchangeNotAllowed):
+			return nil, status.Error(codes.PermissionDenied, "token exchange not allowed")
+		case errors.Is(err, storage.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, "audience app not found")
+		case errors.Is(err, storage.ErrAppDisabled):
+			return nil, status.Error(codes.FailedPrecondition, "audience app is disabled")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to exchange token")
+	}
+
+	return &ssov1.TokenExchangeResponse{Token: token, Scopes: scopes}, nil
+}
+
+// SetAdmin RPC-метод изменения статуса администратора.
+// Токен вызывающего передаётся в метаданных: "authorization: Bearer <token>".
+func (s *serverAPI) SetAdmin(
+	ctx context.Context,
+	req *ssov1.SetAdminRequest,
+) (*ssov1.SetAdminResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	err := s.auth.SetAdmin(ctx, token, req.GetUserId(), req.GetIsAdmin())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "caller is not admin")
+		case errors.Is(err, auth.ErrLastAdmin):
+			return nil, status.Error(codes.FailedPrecondition, "can't demote the last admin")
+		case errors.Is(err, storage.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to set admin status")
+	}
+
+	return &ssov1.SetAdminResponse{IsAdmin: req.GetIsAdmin()}, nil
+}
+
+// DeleteAccount RPC-метод удаления учётной записи.
+// Токен вызывающего передаётся в метаданных: "authorization: Bearer <token>".
+func (s *serverAPI) DeleteAccount(
+	ctx context.Context,
+	req *ssov1.DeleteAccountRequest,
+) (*ssov1.DeleteAccountResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	purgeAt, err := s.auth.DeleteAccount(ctx, token, req.GetUserId(), req.GetPassword())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid password")
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "caller is not admin")
+		case errors.Is(err, auth.ErrLastAdmin):
+			return nil, status.Error(codes.FailedPrecondition, "can't delete the last admin")
+		case errors.Is(err, storage.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to delete account")
+	}
+
+	return &ssov1.DeleteAccountResponse{PurgeAt: purgeAt.Unix()}, nil
+}
+
+// ExportMyData RPC-метод выгрузки данных вызывающего.
+// Токен вызывающего передаётся в метаданных: "authorization: Bearer <token>".
+func (s *serverAPI) ExportMyData(
+	ctx context.Context,
+	_ *ssov1.ExportMyDataRequest,
+) (*ssov1.ExportMyDataResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	archive, err := s.auth.ExportMyData(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, auth.ErrExportRateLimited):
+			return nil, status.Error(codes.ResourceExhausted, "data export was requested recently, try again later")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+
+	return &ssov1.ExportMyDataResponse{Archive: archive}, nil
+}
+
 /*
 func validateRegister(req *ssov1.RegisterRequest) error {
 
@@ -171,4 +363,3 @@ func validateIsAdmin(req *ssov1.IsAdminRequest) error {
 	return nil
 }
 */
-// TODO: сделать "ручку" для изменения статуса администратора
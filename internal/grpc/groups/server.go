@@ -0,0 +1,348 @@
+// internal/grpc/groups/server.go
+package groups
+
+import (
+	"context"
+	"errors"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/bearer"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/groups"
+	"grpc-service-ref/internal/storage"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverAPI реализация gRPC-сервиса Groups (группы пользователей)
+type serverAPI struct {
+	ssov1.UnimplementedGroupsServer
+	groups Groups
+}
+
+// Groups интерфейс сервисного слоя групп
+type Groups interface {
+	CreateGroup(ctx context.Context, callerToken string, name string) (int64, error)
+	Group(ctx context.Context, callerToken string, groupID int64) (models.Group, error)
+	DeleteGroup(ctx context.Context, callerToken string, groupID int64) error
+	AddGroupMember(ctx context.Context, callerToken string, groupID int64, userID int64) error
+	RemoveGroupMember(ctx context.Context, callerToken string, groupID int64, userID int64) error
+	AddSubgroup(ctx context.Context, callerToken string, groupID int64, subgroupID int64) error
+	RemoveSubgroup(ctx context.Context, callerToken string, groupID int64, subgroupID int64) error
+	AssignGroupRole(ctx context.Context, callerToken string, groupID int64, roleID int64) error
+	RevokeGroupRole(ctx context.Context, callerToken string, groupID int64, roleID int64) error
+	SetGroupAdmin(ctx context.Context, callerToken string, groupID int64, isAdmin bool) error
+	UserGroups(ctx context.Context, callerToken string, userID int64) ([]models.Group, error)
+}
+
+// Register регистрация serverAPI в gRPC-сервере
+func Register(gRPCServer *grpc.Server, groups Groups) {
+	ssov1.RegisterGroupsServer(gRPCServer, &serverAPI{groups: groups})
+}
+
+// CreateGroup RPC-метод создания группы
+func (s *serverAPI) CreateGroup(
+	ctx context.Context,
+	req *ssov1.CreateGroupRequest,
+) (*ssov1.CreateGroupResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	id, err := s.groups.CreateGroup(ctx, token, req.GetName())
+	if err != nil {
+		return nil, toStatus(err, "failed to create group")
+	}
+
+	return &ssov1.CreateGroupResponse{GroupId: id}, nil
+}
+
+// GetGroup RPC-метод получения группы
+func (s *serverAPI) GetGroup(
+	ctx context.Context,
+	req *ssov1.GetGroupRequest,
+) (*ssov1.GetGroupResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	group, err := s.groups.Group(ctx, token, req.GetGroupId())
+	if err != nil {
+		return nil, toStatus(err, "failed to get group")
+	}
+
+	return &ssov1.GetGroupResponse{Group: toProto(group)}, nil
+}
+
+// DeleteGroup RPC-метод удаления группы
+func (s *serverAPI) DeleteGroup(
+	ctx context.Context,
+	req *ssov1.DeleteGroupRequest,
+) (*ssov1.DeleteGroupResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	if err := s.groups.DeleteGroup(ctx, token, req.GetGroupId()); err != nil {
+		return nil, toStatus(err, "failed to delete group")
+	}
+
+	return &ssov1.DeleteGroupResponse{}, nil
+}
+
+// AddGroupMember RPC-метод добавления пользователя в группу
+func (s *serverAPI) AddGroupMember(
+	ctx context.Context,
+	req *ssov1.GroupMemberRequest,
+) (*ssov1.GroupMemberResponse, error) {
+	token, err := validateGroupMember(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.AddGroupMember(ctx, token, req.GetGroupId(), req.GetUserId()); err != nil {
+		return nil, toStatus(err, "failed to add group member")
+	}
+
+	return &ssov1.GroupMemberResponse{}, nil
+}
+
+// RemoveGroupMember RPC-метод исключения пользователя из группы
+func (s *serverAPI) RemoveGroupMember(
+	ctx context.Context,
+	req *ssov1.GroupMemberRequest,
+) (*ssov1.GroupMemberResponse, error) {
+	token, err := validateGroupMember(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.RemoveGroupMember(ctx, token, req.GetGroupId(), req.GetUserId()); err != nil {
+		return nil, toStatus(err, "failed to remove group member")
+	}
+
+	return &ssov1.GroupMemberResponse{}, nil
+}
+
+// AddSubgroup RPC-метод вложения группы в группу
+func (s *serverAPI) AddSubgroup(
+	ctx context.Context,
+	req *ssov1.SubgroupRequest,
+) (*ssov1.SubgroupResponse, error) {
+	token, err := validateSubgroup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.AddSubgroup(ctx, token, req.GetGroupId(), req.GetSubgroupId()); err != nil {
+		return nil, toStatus(err, "failed to add subgroup")
+	}
+
+	return &ssov1.SubgroupResponse{}, nil
+}
+
+// RemoveSubgroup RPC-метод исключения вложенной группы
+func (s *serverAPI) RemoveSubgroup(
+	ctx context.Context,
+	req *ssov1.SubgroupRequest,
+) (*ssov1.SubgroupResponse, error) {
+	token, err := validateSubgroup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.RemoveSubgroup(ctx, token, req.GetGroupId(), req.GetSubgroupId()); err != nil {
+		return nil, toStatus(err, "failed to remove subgroup")
+	}
+
+	return &ssov1.SubgroupResponse{}, nil
+}
+
+// AssignGroupRole RPC-метод выдачи роли группе
+func (s *serverAPI) AssignGroupRole(
+	ctx context.Context,
+	req *ssov1.GroupRoleRequest,
+) (*ssov1.GroupRoleResponse, error) {
+	token, err := validateGroupRole(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.AssignGroupRole(ctx, token, req.GetGroupId(), req.GetRoleId()); err != nil {
+		return nil, toStatus(err, "failed to assign group role")
+	}
+
+	return &ssov1.GroupRoleResponse{}, nil
+}
+
+// RevokeGroupRole RPC-метод отзыва роли у группы
+func (s *serverAPI) RevokeGroupRole(
+	ctx context.Context,
+	req *ssov1.GroupRoleRequest,
+) (*ssov1.GroupRoleResponse, error) {
+	token, err := validateGroupRole(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groups.RevokeGroupRole(ctx, token, req.GetGroupId(), req.GetRoleId()); err != nil {
+		return nil, toStatus(err, "failed to revoke group role")
+	}
+
+	return &ssov1.GroupRoleResponse{}, nil
+}
+
+// SetGroupAdmin RPC-метод изменения статуса группы администраторов
+func (s *serverAPI) SetGroupAdmin(
+	ctx context.Context,
+	req *ssov1.SetGroupAdminRequest,
+) (*ssov1.SetGroupAdminResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	if err := s.groups.SetGroupAdmin(ctx, token, req.GetGroupId(), req.GetIsAdmin()); err != nil {
+		return nil, toStatus(err, "failed to set group admin status")
+	}
+
+	return &ssov1.SetGroupAdminResponse{IsAdmin: req.GetIsAdmin()}, nil
+}
+
+// ListUserGroups RPC-метод получения всех групп пользователя (с учётом вложенности)
+func (s *serverAPI) ListUserGroups(
+	ctx context.Context,
+	req *ssov1.ListUserGroupsRequest,
+) (*ssov1.ListUserGroupsResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	userGroups, err := s.groups.UserGroups(ctx, token, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err, "failed to list user groups")
+	}
+
+	resp := &ssov1.ListUserGroupsResponse{Groups: make([]*ssov1.Group, 0, len(userGroups))}
+	for _, group := range userGroups {
+		resp.Groups = append(resp.Groups, toProto(group))
+	}
+
+	return resp, nil
+}
+
+func validateGroupMember(ctx context.Context, req *ssov1.GroupMemberRequest) (string, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	return token, nil
+}
+
+func validateSubgroup(ctx context.Context, req *ssov1.SubgroupRequest) (string, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	if req.GetSubgroupId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "subgroup_id is required")
+	}
+
+	return token, nil
+}
+
+func validateGroupRole(ctx context.Context, req *ssov1.GroupRoleRequest) (string, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetGroupId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	if req.GetRoleId() == 0 {
+		return "", status.Error(codes.InvalidArgument, "role_id is required")
+	}
+
+	return token, nil
+}
+
+func toProto(group models.Group) *ssov1.Group {
+	return &ssov1.Group{
+		Id:        group.ID,
+		Name:      group.Name,
+		IsAdmin:   group.IsAdmin,
+		Members:   group.Members,
+		Subgroups: group.Subgroups,
+		RoleIds:   group.Roles,
+	}
+}
+
+// toStatus переводит ошибки сервисного слоя в gRPC-статусы
+func toStatus(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "caller is not admin")
+	case errors.Is(err, groups.ErrInvalidGroup):
+		return status.Error(codes.InvalidArgument, "invalid group name")
+	case errors.Is(err, storage.ErrGroupExists):
+		return status.Error(codes.AlreadyExists, "group already exists")
+	case errors.Is(err, storage.ErrGroupCycle):
+		return status.Error(codes.FailedPrecondition, "group nesting cycle")
+	case errors.Is(err, storage.ErrLastAdmin):
+		return status.Error(codes.FailedPrecondition, "can't remove the last admin")
+	case errors.Is(err, storage.ErrGroupNotFound):
+		return status.Error(codes.NotFound, "group not found")
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, storage.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	}
+
+	return status.Error(codes.Internal, internalMsg)
+}
@@ -0,0 +1,171 @@
+// internal/grpc/invitations/server.go
+package invitations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/bearer"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/invitations"
+	"grpc-service-ref/internal/storage"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverAPI реализация gRPC-сервиса Invitations (приглашения пользователей)
+type serverAPI struct {
+	ssov1.UnimplementedInvitationsServer
+	invitations Invitations
+}
+
+// Invitations интерфейс сервисного слоя приглашений
+type Invitations interface {
+	Create(ctx context.Context, callerToken string, appID int, email string, roleID int64) (models.Invitation, error)
+	List(ctx context.Context, callerToken string, appID int) ([]models.Invitation, error)
+	Revoke(ctx context.Context, callerToken string, invitationID int64) error
+	Accept(ctx context.Context, token string, password string) (int64, error)
+}
+
+// Register регистрация serverAPI в gRPC-сервере
+func Register(gRPCServer *grpc.Server, invitations Invitations) {
+	ssov1.RegisterInvitationsServer(gRPCServer, &serverAPI{invitations: invitations})
+}
+
+// CreateInvitation RPC-метод создания приглашения
+func (s *serverAPI) CreateInvitation(
+	ctx context.Context,
+	req *ssov1.CreateInvitationRequest,
+) (*ssov1.CreateInvitationResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	inv, err := s.invitations.Create(ctx, token, int(req.GetAppId()), req.GetEmail(), req.GetRoleId())
+	if err != nil {
+		return nil, toStatus(err, "failed to create invitation")
+	}
+
+	return &ssov1.CreateInvitationResponse{Invitation: toProto(inv)}, nil
+}
+
+// ListInvitations RPC-метод получения приглашений в приложение
+func (s *serverAPI) ListInvitations(
+	ctx context.Context,
+	req *ssov1.ListInvitationsRequest,
+) (*ssov1.ListInvitationsResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	list, err := s.invitations.List(ctx, token, int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err, "failed to list invitations")
+	}
+
+	resp := &ssov1.ListInvitationsResponse{Invitations: make([]*ssov1.Invitation, 0, len(list))}
+	for _, inv := range list {
+		resp.Invitations = append(resp.Invitations, toProto(inv))
+	}
+
+	return resp, nil
+}
+
+// RevokeInvitation RPC-метод отзыва приглашения
+func (s *serverAPI) RevokeInvitation(
+	ctx context.Context,
+	req *ssov1.RevokeInvitationRequest,
+) (*ssov1.RevokeInvitationResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetInvitationId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invitation_id is required")
+	}
+
+	if err := s.invitations.Revoke(ctx, token, req.GetInvitationId()); err != nil {
+		return nil, toStatus(err, "failed to revoke invitation")
+	}
+
+	return &ssov1.RevokeInvitationResponse{}, nil
+}
+
+// AcceptInvitation RPC-метод принятия приглашения
+func (s *serverAPI) AcceptInvitation(
+	ctx context.Context,
+	req *ssov1.AcceptInvitationRequest,
+) (*ssov1.AcceptInvitationResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	userID, err := s.invitations.Accept(ctx, req.GetToken(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err, "failed to accept invitation")
+	}
+
+	return &ssov1.AcceptInvitationResponse{UserId: userID}, nil
+}
+
+func toProto(inv models.Invitation) *ssov1.Invitation {
+	return &ssov1.Invitation{
+		Id:         inv.ID,
+		AppId:      int32(inv.AppID),
+		Email:      inv.Email,
+		RoleId:     inv.RoleID,
+		Status:     inv.Status(time.Now()),
+		CreatedBy:  inv.CreatedBy,
+		ExpiresAt:  inv.ExpiresAt.Unix(),
+		AcceptedBy: inv.AcceptedBy,
+	}
+}
+
+// toStatus переводит ошибки сервисного слоя в gRPC-статусы
+func toStatus(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "caller is not admin")
+	case errors.Is(err, invitations.ErrInvalidEmail):
+		return status.Error(codes.InvalidArgument, "invalid email")
+	case errors.Is(err, invitations.ErrPasswordRequired):
+		return status.Error(codes.InvalidArgument, "password is required to register a new user")
+	case errors.Is(err, invitations.ErrInvitationInactive):
+		return status.Error(codes.FailedPrecondition, "invitation is already accepted, revoked or expired")
+	case errors.Is(err, storage.ErrInvitationNotFound):
+		return status.Error(codes.NotFound, "invitation not found")
+	case errors.Is(err, storage.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, storage.ErrAppDisabled):
+		return status.Error(codes.FailedPrecondition, "app is disabled")
+	case errors.Is(err, storage.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	case errors.Is(err, storage.ErrUserExists):
+		return status.Error(codes.AlreadyExists, "user already exists")
+	}
+
+	return status.Error(codes.Internal, internalMsg)
+}
@@ -0,0 +1,271 @@
+// internal/grpc/permissions/server.go
+package permissions
+
+import (
+	"context"
+	"errors"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/bearer"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/permissions"
+	"grpc-service-ref/internal/storage"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverAPI реализация gRPC-сервиса Permissions (роли и права в приложениях)
+type serverAPI struct {
+	ssov1.UnimplementedPermissionsServer
+	permissions Permissions
+}
+
+// Permissions интерфейс сервисного слоя ролей и прав
+type Permissions interface {
+	CreateRole(ctx context.Context, callerToken string, appID int, name string, permissions []string) (int64, error)
+	SetRolePermissions(ctx context.Context, callerToken string, roleID int64, permissions []string) error
+	DeleteRole(ctx context.Context, callerToken string, roleID int64) error
+	Roles(ctx context.Context, callerToken string, appID int) ([]models.Role, error)
+	AssignRole(ctx context.Context, callerToken string, userID int64, roleID int64) error
+	RevokeRole(ctx context.Context, callerToken string, userID int64, roleID int64) error
+	UserRoles(ctx context.Context, callerToken string, userID int64, appID int) ([]string, error)
+	CheckPermission(ctx context.Context, callerToken string, userID int64, appID int, permission string) (bool, error)
+}
+
+// Register регистрация serverAPI в gRPC-сервере
+func Register(gRPCServer *grpc.Server, permissions Permissions) {
+	ssov1.RegisterPermissionsServer(gRPCServer, &serverAPI{permissions: permissions})
+}
+
+// CreateRole RPC-метод создания роли в приложении
+func (s *serverAPI) CreateRole(
+	ctx context.Context,
+	req *ssov1.CreateRoleRequest,
+) (*ssov1.CreateRoleResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	id, err := s.permissions.CreateRole(ctx, token, int(req.GetAppId()), req.GetName(), req.GetPermissions())
+	if err != nil {
+		return nil, toStatus(err, "failed to create role")
+	}
+
+	return &ssov1.CreateRoleResponse{RoleId: id}, nil
+}
+
+// SetRolePermissions RPC-метод замены прав роли
+func (s *serverAPI) SetRolePermissions(
+	ctx context.Context,
+	req *ssov1.SetRolePermissionsRequest,
+) (*ssov1.SetRolePermissionsResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetRoleId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role_id is required")
+	}
+
+	err := s.permissions.SetRolePermissions(ctx, token, req.GetRoleId(), req.GetPermissions())
+	if err != nil {
+		return nil, toStatus(err, "failed to set role permissions")
+	}
+
+	return &ssov1.SetRolePermissionsResponse{}, nil
+}
+
+// DeleteRole RPC-метод удаления роли
+func (s *serverAPI) DeleteRole(
+	ctx context.Context,
+	req *ssov1.DeleteRoleRequest,
+) (*ssov1.DeleteRoleResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetRoleId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role_id is required")
+	}
+
+	if err := s.permissions.DeleteRole(ctx, token, req.GetRoleId()); err != nil {
+		return nil, toStatus(err, "failed to delete role")
+	}
+
+	return &ssov1.DeleteRoleResponse{}, nil
+}
+
+// ListRoles RPC-метод получения ролей приложения
+func (s *serverAPI) ListRoles(
+	ctx context.Context,
+	req *ssov1.ListRolesRequest,
+) (*ssov1.ListRolesResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	roles, err := s.permissions.Roles(ctx, token, int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err, "failed to list roles")
+	}
+
+	resp := &ssov1.ListRolesResponse{Roles: make([]*ssov1.Role, 0, len(roles))}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, &ssov1.Role{
+			Id:          role.ID,
+			AppId:       int32(role.AppID),
+			Name:        role.Name,
+			Permissions: role.Permissions,
+		})
+	}
+
+	return resp, nil
+}
+
+// AssignRole RPC-метод назначения роли пользователю
+func (s *serverAPI) AssignRole(
+	ctx context.Context,
+	req *ssov1.AssignRoleRequest,
+) (*ssov1.AssignRoleResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if req.GetRoleId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role_id is required")
+	}
+
+	if err := s.permissions.AssignRole(ctx, token, req.GetUserId(), req.GetRoleId()); err != nil {
+		return nil, toStatus(err, "failed to assign role")
+	}
+
+	return &ssov1.AssignRoleResponse{}, nil
+}
+
+// RevokeRole RPC-метод отзыва роли у пользователя
+func (s *serverAPI) RevokeRole(
+	ctx context.Context,
+	req *ssov1.RevokeRoleRequest,
+) (*ssov1.RevokeRoleResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if req.GetRoleId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role_id is required")
+	}
+
+	if err := s.permissions.RevokeRole(ctx, token, req.GetUserId(), req.GetRoleId()); err != nil {
+		return nil, toStatus(err, "failed to revoke role")
+	}
+
+	return &ssov1.RevokeRoleResponse{}, nil
+}
+
+// GetUserRoles RPC-метод получения ролей пользователя в приложении
+func (s *serverAPI) GetUserRoles(
+	ctx context.Context,
+	req *ssov1.GetUserRolesRequest,
+) (*ssov1.GetUserRolesResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	roles, err := s.permissions.UserRoles(ctx, token, req.GetUserId(), int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err, "failed to get user roles")
+	}
+
+	return &ssov1.GetUserRolesResponse{Roles: roles}, nil
+}
+
+// CheckPermission RPC-метод проверки права пользователя в приложении
+func (s *serverAPI) CheckPermission(
+	ctx context.Context,
+	req *ssov1.CheckPermissionRequest,
+) (*ssov1.CheckPermissionResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	allowed, err := s.permissions.CheckPermission(ctx, token, req.GetUserId(), int(req.GetAppId()), req.GetPermission())
+	if err != nil {
+		return nil, toStatus(err, "failed to check permission")
+	}
+
+	return &ssov1.CheckPermissionResponse{Allowed: allowed}, nil
+}
+
+// toStatus переводит ошибки сервисного слоя в gRPC-статусы
+func toStatus(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "caller is not admin")
+	case errors.Is(err, permissions.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, "invalid role name or permissions")
+	case errors.Is(err, storage.ErrRoleExists):
+		return status.Error(codes.AlreadyExists, "role already exists")
+	case errors.Is(err, storage.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, storage.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	}
+
+	return status.Error(codes.Internal, internalMsg)
+}
@@ -0,0 +1,196 @@
+// internal/grpc/policies/server.go
+package policies
+
+import (
+	"context"
+	"errors"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/bearer"
+	"grpc-service-ref/internal/lib/policy"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/policies"
+	"grpc-service-ref/internal/storage"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverAPI реализация gRPC-сервиса Policies (политики доступа, ABAC)
+type serverAPI struct {
+	ssov1.UnimplementedPoliciesServer
+	policies Policies
+}
+
+// Policies интерфейс сервисного слоя политик доступа
+type Policies interface {
+	SetPolicy(ctx context.Context, callerToken string, appID int, document string) (int, error)
+	Policy(ctx context.Context, callerToken string, appID int, version int) (models.Policy, error)
+	SetUserAttributes(ctx context.Context, callerToken string, userID int64, attributes map[string]string) error
+	Authorize(ctx context.Context, callerToken string, req policies.Request) (policies.Decision, error)
+}
+
+// Register регистрация serverAPI в gRPC-сервере
+func Register(gRPCServer *grpc.Server, policies Policies) {
+	ssov1.RegisterPoliciesServer(gRPCServer, &serverAPI{policies: policies})
+}
+
+// SetPolicy RPC-метод сохранения новой версии политики приложения
+func (s *serverAPI) SetPolicy(
+	ctx context.Context,
+	req *ssov1.SetPolicyRequest,
+) (*ssov1.SetPolicyResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetDocument() == "" {
+		return nil, status.Error(codes.InvalidArgument, "document is required")
+	}
+
+	version, err := s.policies.SetPolicy(ctx, token, int(req.GetAppId()), req.GetDocument())
+	if err != nil {
+		return nil, toStatus(err, "failed to set policy")
+	}
+
+	return &ssov1.SetPolicyResponse{Version: int32(version)}, nil
+}
+
+// GetPolicy RPC-метод получения версии политики приложения
+func (s *serverAPI) GetPolicy(
+	ctx context.Context,
+	req *ssov1.GetPolicyRequest,
+) (*ssov1.GetPolicyResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetVersion() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "version must not be negative")
+	}
+
+	pol, err := s.policies.Policy(ctx, token, int(req.GetAppId()), int(req.GetVersion()))
+	if err != nil {
+		return nil, toStatus(err, "failed to get policy")
+	}
+
+	return &ssov1.GetPolicyResponse{
+		Version:   int32(pol.Version),
+		Document:  pol.Document,
+		CreatedBy: pol.CreatedBy,
+		CreatedAt: pol.CreatedAt.Unix(),
+	}, nil
+}
+
+// SetUserAttributes RPC-метод замены атрибутов пользователя
+func (s *serverAPI) SetUserAttributes(
+	ctx context.Context,
+	req *ssov1.SetUserAttributesRequest,
+) (*ssov1.SetUserAttributesResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if err := s.policies.SetUserAttributes(ctx, token, req.GetUserId(), req.GetAttributes()); err != nil {
+		return nil, toStatus(err, "failed to set user attributes")
+	}
+
+	return &ssov1.SetUserAttributesResponse{}, nil
+}
+
+// Authorize RPC-метод проверки доступа по политике приложения.
+// Токен администратора нужен только для пробного вычисления черновика политики.
+func (s *serverAPI) Authorize(
+	ctx context.Context,
+	req *ssov1.AuthorizeRequest,
+) (*ssov1.AuthorizeResponse, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok && req.GetDryRunPolicy() != "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required for dry run")
+	}
+
+	if err := validateAuthorize(req); err != nil {
+		return nil, err
+	}
+
+	decision, err := s.policies.Authorize(ctx, token, policies.Request{
+		UserID:       req.GetUserId(),
+		AppID:        int(req.GetAppId()),
+		Action:       req.GetAction(),
+		Resource:     req.GetResource(),
+		Context:      req.GetContext(),
+		DryRunPolicy: req.GetDryRunPolicy(),
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to authorize")
+	}
+
+	return &ssov1.AuthorizeResponse{
+		Allowed:       decision.Allowed,
+		Rules:         decision.Rules,
+		Reasons:       decision.Reasons,
+		PolicyVersion: int32(decision.PolicyVersion),
+		DryRun:        decision.DryRun,
+	}, nil
+}
+
+func validateAuthorize(req *ssov1.AuthorizeRequest) error {
+	if req.GetUserId() == 0 {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if req.GetAppId() == 0 {
+		return status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if req.GetAction() == "" {
+		return status.Error(codes.InvalidArgument, "action is required")
+	}
+
+	return nil
+}
+
+// toStatus переводит ошибки сервисного слоя в gRPC-статусы
+func toStatus(err error, internalMsg string) error {
+	var invalidPolicy *policy.ValidationError
+
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "caller is not admin")
+	case errors.As(err, &invalidPolicy):
+		// причина нужна автору политики, чтобы исправить документ
+		return status.Error(codes.InvalidArgument, invalidPolicy.Error())
+	case errors.Is(err, policies.ErrInvalidAttributes):
+		return status.Error(codes.InvalidArgument, "invalid user attributes")
+	case errors.Is(err, storage.ErrPolicyNotFound):
+		return status.Error(codes.NotFound, "policy not found")
+	case errors.Is(err, storage.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, storage.ErrAppDisabled):
+		return status.Error(codes.FailedPrecondition, "app is disabled")
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	}
+
+	return status.Error(codes.Internal, internalMsg)
+}
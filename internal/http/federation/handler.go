@@ -0,0 +1,126 @@
+// internal/http/federation/handler.go
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grpc-service-ref/internal/services/federation"
+	"grpc-service-ref/internal/storage"
+)
+
+// HTTP-хэндлеры входа через внешних OIDC-провайдеров.
+// В отличие от остальных ручек, это браузерный сценарий с редиректами,
+// поэтому он живёт на HTTP, а не на gRPC:
+//
+//	GET /federation/login?provider=<name>&app_id=<id> - редирект на страницу входа провайдера
+//	GET /federation/callback?code=...&state=...       - возврат от провайдера, в ответе наш токен
+
+// Federation интерфейс сервисного слоя федерации
+type Federation interface {
+	LoginURL(ctx context.Context, provider string, appID int) (string, error)
+	Callback(ctx context.Context, state string, code string) (token string, err error)
+}
+
+type handler struct {
+	federation Federation
+}
+
+// Register регистрирует хэндлеры федерации в HTTP-роутере
+func Register(mux *http.ServeMux, federation Federation) {
+	h := &handler{federation: federation}
+
+	mux.HandleFunc("/federation/login", h.login)
+	mux.HandleFunc("/federation/callback", h.callback)
+}
+
+func (h *handler) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	provider := r.URL.Query().Get("provider")
+	if provider == "" {
+		writeError(w, http.StatusBadRequest, "provider is required")
+		return
+	}
+
+	appID, err := strconv.Atoi(r.URL.Query().Get("app_id"))
+	if err != nil || appID == 0 {
+		writeError(w, http.StatusBadRequest, "app_id is required")
+		return
+	}
+
+	url, err := h.federation.LoginURL(r.Context(), provider, appID)
+	if err != nil {
+		switch {
+		case errors.Is(err, federation.ErrProviderNotFound):
+			writeError(w, http.StatusNotFound, "identity provider not found")
+		case errors.Is(err, storage.ErrAppNotFound):
+			writeError(w, http.StatusNotFound, "app not found")
+		case errors.Is(err, storage.ErrAppDisabled):
+			writeError(w, http.StatusForbidden, "app is disabled")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to start login")
+		}
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (h *handler) callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+
+	// Провайдер сообщает об отказе (например, пользователь отменил вход) параметром error
+	if q.Get("error") != "" {
+		writeError(w, http.StatusUnauthorized, "identity provider error: "+q.Get("error"))
+		return
+	}
+
+	if q.Get("state") == "" || q.Get("code") == "" {
+		writeError(w, http.StatusBadRequest, "state and code are required")
+		return
+	}
+
+	token, err := h.federation.Callback(r.Context(), q.Get("state"), q.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, federation.ErrInvalidState):
+			writeError(w, http.StatusBadRequest, "invalid or expired state")
+		case errors.Is(err, federation.ErrInvalidIDToken), errors.Is(err, federation.ErrEmailNotVerified):
+			writeError(w, http.StatusUnauthorized, "identity provider authentication failed")
+		case errors.Is(err, federation.ErrUserDisabled):
+			writeError(w, http.StatusForbidden, "user is disabled")
+		case errors.Is(err, federation.ErrAppAccessDenied):
+			writeError(w, http.StatusForbidden, "access to the app denied")
+		case errors.Is(err, storage.ErrAppDisabled):
+			writeError(w, http.StatusForbidden, "app is disabled")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to login")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
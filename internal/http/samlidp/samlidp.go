@@ -0,0 +1,228 @@
+// internal/http/samlidp/samlidp.go
+package samlidp
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+)
+
+// SAML 2.0 identity provider для приложений, которые не умеют работать с JWT.
+// Протокольную часть (разбор AuthnRequest, подпись assertion, POST binding)
+// делает библиотека crewjam/saml, а мы даём ей:
+//   - список service provider'ов - это приложения вида models.AppKindSAML;
+//   - сессии пользователей: вход по email и паролю через тот же CredentialVerifier,
+//     что и в Auth.Login (локальный хэш или LDAP).
+//
+// Эндпоинты:
+//
+//	GET      /saml/metadata - метаданные IdP (entity ID, сертификат, адреса SSO)
+//	GET/POST /saml/sso      - приём AuthnRequest (Redirect и POST binding)
+
+const (
+	metadataPath = "/saml/metadata"
+	ssoPath      = "/saml/sso"
+)
+
+// AppProvider интерфейс для получения SAML-приложений из хранилища
+type AppProvider interface {
+	SAMLApp(ctx context.Context, entityID string) (models.App, error)
+}
+
+// AppMemberProvider интерфейс проверки участия пользователя в приложении
+// (нужен для приложений с ограниченным доступом)
+type AppMemberProvider interface {
+	IsAppMember(ctx context.Context, appID int, userID int64) (bool, error)
+}
+
+// UserProvider интерфейс получения пользователя: сессия IdP перечитывает его при каждом использовании
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// IdentityProvider SAML IdP поверх пользователей и приложений сервиса
+type IdentityProvider struct {
+	log      *slog.Logger
+	idp      *saml.IdentityProvider
+	apps     AppProvider
+	members  AppMemberProvider
+	users    UserProvider
+	verifier auth.CredentialVerifier
+	sessions *sessionStore
+}
+
+// New returns a new instance of SAML IdentityProvider.
+// baseURL - внешний адрес HTTP-сервера, от него строятся адреса эндпоинтов.
+func New(
+	log *slog.Logger,
+	baseURL string,
+	key crypto.Signer,
+	certificate *x509.Certificate,
+	sessionTTL time.Duration,
+	apps AppProvider,
+	members AppMemberProvider,
+	users UserProvider,
+	verifier auth.CredentialVerifier,
+) (*IdentityProvider, error) {
+	const op = "samlidp.New"
+
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	metadataURL := *base
+	metadataURL.Path += metadataPath
+	ssoURL := *base
+	ssoURL.Path += ssoPath
+
+	p := &IdentityProvider{
+		log:      log,
+		apps:     apps,
+		members:  members,
+		users:    users,
+		verifier: verifier,
+		sessions: newSessionStore(sessionTTL, base.Scheme == "https"),
+	}
+
+	p.idp = &saml.IdentityProvider{
+		Key:                     key,
+		Signer:                  key,
+		Certificate:             certificate,
+		Logger:                  logger{log: log},
+		MetadataURL:             metadataURL,
+		SSOURL:                  ssoURL,
+		ServiceProviderProvider: p,
+		SessionProvider:         p,
+		SignatureMethod:         dsig.RSASHA256SignatureMethod,
+	}
+
+	return p, nil
+}
+
+// LoadKeyPair загружает сертификат и ключ IdP из PEM-файлов
+func LoadKeyPair(certificatePath string, keyPath string) (crypto.Signer, *x509.Certificate, error) {
+	const op = "samlidp.LoadKeyPair"
+
+	pair, err := tls.LoadX509KeyPair(certificatePath, keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: unsupported private key type", op)
+	}
+
+	return key, certificate, nil
+}
+
+// Register регистрирует SAML-эндпоинты в HTTP-роутере
+func (p *IdentityProvider) Register(mux *http.ServeMux) {
+	mux.HandleFunc(metadataPath, p.idp.ServeMetadata)
+	mux.HandleFunc(ssoPath, p.idp.ServeSSO)
+}
+
+// GetServiceProvider returns metadata of SAML app registered with given entity ID.
+// Реализует saml.ServiceProviderProvider: метаданные SP собираем из полей приложения.
+func (p *IdentityProvider) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	app, err := p.apps.SAMLApp(r.Context(), serviceProviderID)
+	if err != nil {
+		// отключённое приложение для входа не существует
+		if errors.Is(err, storage.ErrAppNotFound) || errors.Is(err, storage.ErrAppDisabled) {
+			// библиотека ожидает именно os.ErrNotExist
+			return nil, os.ErrNotExist
+		}
+
+		return nil, err
+	}
+
+	isDefault := true
+	descriptor := saml.SPSSODescriptor{
+		SSODescriptor: saml.SSODescriptor{
+			RoleDescriptor: saml.RoleDescriptor{
+				ProtocolSupportEnumeration: "urn:oasis:names:tc:SAML:2.0:protocol",
+			},
+		},
+		AssertionConsumerServices: []saml.IndexedEndpoint{{
+			Binding:   saml.HTTPPostBinding,
+			Location:  app.SAML.ACSURL,
+			Index:     1,
+			IsDefault: &isDefault,
+		}},
+	}
+
+	// Если у SP есть сертификат, assertion шифруется его ключом
+	if app.SAML.Certificate != "" {
+		certificate, err := certificateData(app.SAML.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("app %d: %w", app.ID, err)
+		}
+
+		descriptor.KeyDescriptors = []saml.KeyDescriptor{{
+			Use: "encryption",
+			KeyInfo: saml.KeyInfo{
+				X509Data: saml.X509Data{
+					X509Certificates: []saml.X509Certificate{{Data: certificate}},
+				},
+			},
+		}}
+	}
+
+	return &saml.EntityDescriptor{
+		EntityID:         app.SAML.EntityID,
+		SPSSODescriptors: []saml.SPSSODescriptor{descriptor},
+	}, nil
+}
+
+// certificateData возвращает сертификат из PEM в виде base64 DER, как он записывается в метаданных
+func certificateData(certificatePEM string) (string, error) {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("invalid service provider certificate")
+	}
+
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return "", fmt.Errorf("invalid service provider certificate: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(block.Bytes), nil
+}
+
+// logger адаптер slog для логгера crewjam/saml
+type logger struct {
+	log *slog.Logger
+}
+
+func (l logger) Printf(format string, v ...any) { l.log.Warn("saml: " + fmt.Sprintf(format, v...)) }
+func (l logger) Print(v ...any)                 { l.log.Warn("saml: " + fmt.Sprint(v...)) }
+func (l logger) Println(v ...any)               { l.log.Warn("saml: " + fmt.Sprint(v...)) }
+func (l logger) Fatal(v ...any)                 { l.Panic(v...) }
+func (l logger) Fatalf(format string, v ...any) { l.Panicf(format, v...) }
+func (l logger) Fatalln(v ...any)               { l.Panic(v...) }
+func (l logger) Panic(v ...any)                 { panic(fmt.Sprint(v...)) }
+func (l logger) Panicf(format string, v ...any) { panic(fmt.Sprintf(format, v...)) }
+func (l logger) Panicln(v ...any)               { panic(fmt.Sprint(v...)) }
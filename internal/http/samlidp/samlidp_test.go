@@ -0,0 +1,399 @@
+// internal/http/samlidp/samlidp_test.go
+package samlidp_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"html"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/http/samlidp"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+)
+
+// Тесты IdP с настоящим SAML service provider'ом из crewjam/saml:
+// SP формирует AuthnRequest и проверяет подпись (и расшифровывает) полученный assertion.
+
+const (
+	appID        = 5
+	spEntityID   = "https://sp.example/saml/metadata"
+	spACSURL     = "https://sp.example/saml/acs"
+	userID       = 42
+	userEmail    = "user@example.com"
+	userPassword = "password"
+)
+
+func TestIdentityProvider_Login(t *testing.T) {
+	env := newTestEnv(t)
+
+	// Первый запрос: сессии нет, IdP показывает форму входа
+	requestID, form := env.startLogin(t)
+	require.Contains(t, form, `name="password"`)
+
+	// Отправляем форму с паролем и получаем assertion для SP
+	resp := env.submit(t, form, userEmail, userPassword)
+	require.Equal(t, http.StatusOK, resp.status)
+
+	assertion := env.parseResponse(t, resp.body, requestID)
+	assert.Equal(t, userEmail, assertion.Subject.NameID.Value)
+	assert.Equal(t, "relay", resp.relayState)
+
+	// Второй запрос: сессия уже есть, IdP сразу отвечает assertion'ом без формы входа
+	requestID, body := env.startLogin(t)
+	assertion = env.parseResponse(t, body, requestID)
+	assert.Equal(t, userEmail, assertion.Subject.NameID.Value)
+}
+
+func TestIdentityProvider_InvalidCredentials(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, form := env.startLogin(t)
+
+	resp := env.submit(t, form, userEmail, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+	assert.NotContains(t, resp.body, "SAMLResponse")
+
+	// сессия не создана: повторный запрос снова ведёт на форму входа
+	_, form = env.startLogin(t)
+	assert.Contains(t, form, `name="password"`)
+}
+
+func TestIdentityProvider_AppAccessDenied(t *testing.T) {
+	env := newTestEnv(t)
+
+	app := env.apps[spEntityID]
+	app.AccessMode = models.AppAccessDomain
+	app.AllowedDomains = []string{"other.example"}
+	env.apps[spEntityID] = app
+
+	_, form := env.startLogin(t)
+
+	// пароль верный, но домен пользователя не допущен в приложение
+	resp := env.submit(t, form, userEmail, userPassword)
+	assert.Equal(t, http.StatusForbidden, resp.status)
+	assert.NotContains(t, resp.body, "SAMLResponse")
+	assert.Contains(t, resp.body, `name="password"`)
+}
+
+func TestIdentityProvider_SessionUserDisabled(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, form := env.startLogin(t)
+	resp := env.submit(t, form, userEmail, userPassword)
+	require.Equal(t, http.StatusOK, resp.status)
+
+	// пользователя отключили после входа: сессия больше не действует
+	user := env.users[userID]
+	user.Disabled = true
+	env.users[userID] = user
+
+	_, body := env.startLogin(t)
+	assert.NotContains(t, body, "SAMLResponse")
+	assert.Contains(t, body, `name="password"`)
+
+	// и не оживает после включения пользователя
+	user.Disabled = false
+	env.users[userID] = user
+
+	_, body = env.startLogin(t)
+	assert.Contains(t, body, `name="password"`)
+}
+
+func TestIdentityProvider_SessionUserDeleted(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, form := env.startLogin(t)
+	resp := env.submit(t, form, userEmail, userPassword)
+	require.Equal(t, http.StatusOK, resp.status)
+
+	delete(env.users, userID)
+
+	_, body := env.startLogin(t)
+	assert.NotContains(t, body, "SAMLResponse")
+	assert.Contains(t, body, `name="password"`)
+}
+
+func TestIdentityProvider_SessionOfAnotherTenant(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, form := env.startLogin(t)
+	resp := env.submit(t, form, userEmail, userPassword)
+	require.Equal(t, http.StatusOK, resp.status)
+
+	// SP теперь принадлежит другой организации: сессия её пользователя не подходит
+	app := env.apps[spEntityID]
+	app.TenantID = models.DefaultTenantID + 1
+	env.apps[spEntityID] = app
+
+	_, status, body := env.authnRequest(t)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotContains(t, body, "SAMLResponse")
+	assert.Contains(t, body, `name="password"`)
+}
+
+func TestIdentityProvider_UnknownServiceProvider(t *testing.T) {
+	env := newTestEnv(t)
+	env.sp.EntityID = "https://unknown.example/saml/metadata"
+
+	authURL, err := env.sp.MakeRedirectAuthenticationRequest("relay")
+	require.NoError(t, err)
+
+	resp, err := env.client.Get(authURL.String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestIdentityProvider_Metadata(t *testing.T) {
+	env := newTestEnv(t)
+
+	assert.Equal(t, env.server.URL+"/saml/metadata", env.sp.IDPMetadata.EntityID)
+	require.Len(t, env.sp.IDPMetadata.IDPSSODescriptors, 1)
+	assert.Equal(t, env.server.URL+"/saml/sso", env.sp.IDPMetadata.IDPSSODescriptors[0].SingleSignOnServices[0].Location)
+}
+
+type testEnv struct {
+	server *httptest.Server
+	client *http.Client
+	sp     *saml.ServiceProvider
+	apps   fakeApps
+	users  fakeUsers
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	idpKey, idpCert := newKeyPair(t, "idp")
+	spKey, spCert := newKeyPair(t, "sp")
+
+	apps := fakeApps{
+		spEntityID: {
+			ID:       appID,
+			TenantID: models.DefaultTenantID,
+			Name:     "vendor",
+			Kind:     models.AppKindSAML,
+			SAML: models.SAMLServiceProvider{
+				EntityID:    spEntityID,
+				ACSURL:      spACSURL,
+				Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: spCert.Raw})),
+			},
+		},
+	}
+
+	users := fakeUsers{
+		userID: {ID: userID, TenantID: models.DefaultTenantID, Email: userEmail},
+	}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	idp, err := samlidp.New(log, server.URL, idpKey, idpCert, time.Hour, apps, apps, users, fakeVerifier{users: users})
+	require.NoError(t, err)
+	idp.Register(mux)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(server.URL + "/saml/metadata")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var metadata saml.EntityDescriptor
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&metadata))
+
+	metadataURL, _ := url.Parse(spEntityID)
+	acsURL, _ := url.Parse(spACSURL)
+
+	return &testEnv{
+		server: server,
+		client: client,
+		apps:   apps,
+		users:  users,
+		sp: &saml.ServiceProvider{
+			EntityID:    spEntityID,
+			Key:         spKey,
+			Certificate: spCert,
+			MetadataURL: *metadataURL,
+			AcsURL:      *acsURL,
+			IDPMetadata: &metadata,
+		},
+	}
+}
+
+// startLogin отправляет AuthnRequest по Redirect binding и возвращает его ID и ответ IdP
+func (e *testEnv) startLogin(t *testing.T) (string, string) {
+	t.Helper()
+
+	requestID, status, body := e.authnRequest(t)
+	require.Equal(t, http.StatusOK, status)
+
+	return requestID, body
+}
+
+// authnRequest отправляет AuthnRequest по Redirect binding и возвращает его ID, статус и тело ответа IdP
+func (e *testEnv) authnRequest(t *testing.T) (string, int, string) {
+	t.Helper()
+
+	req, err := e.sp.MakeAuthenticationRequest(
+		e.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		saml.HTTPRedirectBinding,
+		saml.HTTPPostBinding,
+	)
+	require.NoError(t, err)
+
+	authURL, err := req.Redirect("relay", e.sp)
+	require.NoError(t, err)
+
+	resp, err := e.client.Get(authURL.String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return req.ID, resp.StatusCode, string(body)
+}
+
+type submitResult struct {
+	status     int
+	body       string
+	relayState string
+}
+
+// submit отправляет форму входа, как это сделал бы браузер
+func (e *testEnv) submit(t *testing.T, form string, email string, password string) submitResult {
+	t.Helper()
+
+	resp, err := e.client.PostForm(e.server.URL+"/saml/sso", url.Values{
+		"SAMLRequest": {inputValue(t, form, "SAMLRequest")},
+		"RelayState":  {inputValue(t, form, "RelayState")},
+		"email":       {email},
+		"password":    {password},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	result := submitResult{status: resp.StatusCode, body: string(body)}
+	if strings.Contains(result.body, "SAMLResponse") {
+		result.relayState = inputValue(t, result.body, "RelayState")
+	}
+
+	return result
+}
+
+// parseResponse передаёт SAMLResponse из автоматически отправляемой формы IdP в SP
+func (e *testEnv) parseResponse(t *testing.T, body string, requestID string) *saml.Assertion {
+	t.Helper()
+
+	form := url.Values{"SAMLResponse": {inputValue(t, body, "SAMLResponse")}}
+	req := httptest.NewRequest(http.MethodPost, spACSURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	require.NoError(t, req.ParseForm())
+
+	assertion, err := e.sp.ParseResponse(req, []string{requestID})
+	require.NoError(t, err)
+
+	return assertion
+}
+
+func inputValue(t *testing.T, body string, name string) string {
+	t.Helper()
+
+	m := regexp.MustCompile(`name="` + name + `" value="([^"]*)"`).FindStringSubmatch(body)
+	require.Len(t, m, 2, "input %s not found", name)
+
+	return html.UnescapeString(m[1])
+}
+
+func newKeyPair(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return key, cert
+}
+
+// fakeApps SAML-приложения по entity ID
+type fakeApps map[string]models.App
+
+func (a fakeApps) SAMLApp(_ context.Context, entityID string) (models.App, error) {
+	app, ok := a[entityID]
+	if !ok {
+		return models.App{}, storage.ErrAppNotFound
+	}
+
+	return app, nil
+}
+
+// IsAppMember участников у приложений нет
+func (a fakeApps) IsAppMember(_ context.Context, _ int, _ int64) (bool, error) {
+	return false, nil
+}
+
+// fakeUsers пользователи по ID
+type fakeUsers map[int64]models.User
+
+func (u fakeUsers) UserByID(_ context.Context, id int64) (models.User, error) {
+	user, ok := u[id]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// fakeVerifier знает единственный пароль - пользователя userID
+type fakeVerifier struct {
+	users fakeUsers
+}
+
+func (v fakeVerifier) VerifyCredentials(_ context.Context, email string, password string, app models.App) (models.User, error) {
+	user, ok := v.users[userID]
+	if !ok || email != user.Email || password != userPassword || app.ID != appID {
+		return models.User{}, auth.ErrInvalidCredentials
+	}
+
+	return user, nil
+}
@@ -0,0 +1,291 @@
+// internal/http/samlidp/session.go
+package samlidp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сессии пользователей IdP. После входа пользователь получает cookie,
+// и пока сессия жива, следующие AuthnRequest (в том числе от других SP)
+// обрабатываются без повторного ввода пароля - это и есть SSO.
+
+const sessionCookie = "sso_saml_session"
+
+// GetSession returns the session of logged in user or renders the login form.
+// Реализует saml.SessionProvider: если сессии нет, ответ пользователю уже записан и возвращается nil.
+func (p *IdentityProvider) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	const op = "samlidp.GetSession"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.String("entity_id", req.ServiceProviderMetadata.EntityID),
+	)
+
+	// Отправленная форма входа
+	if r.Method == http.MethodPost && r.PostForm.Get("email") != "" {
+		email := r.PostForm.Get("email")
+
+		app, err := p.apps.SAMLApp(r.Context(), req.ServiceProviderMetadata.EntityID)
+		if err != nil {
+			log.Error("failed to get app", sl.Err(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return nil
+		}
+
+		user, err := p.verifier.VerifyCredentials(r.Context(), email, r.PostForm.Get("password"), app)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				log.Info("invalid credentials")
+				p.renderLogin(w, req, email, "Invalid email or password", http.StatusUnauthorized)
+				return nil
+			}
+
+			log.Error("failed to verify credentials", sl.Err(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return nil
+		}
+
+		if !p.checkAccess(w, r, req, log, app, user.ID, user.Email) {
+			return nil
+		}
+
+		session, err := p.sessions.create(app.TenantID, user.ID)
+		if err != nil {
+			log.Error("failed to create session", sl.Err(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return nil
+		}
+
+		http.SetCookie(w, p.sessions.cookie(session))
+		log.Info("user logged in", slog.Int64("user_id", user.ID))
+
+		return session.forUser(user)
+	}
+
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if session, ok := p.sessions.get(c.Value); ok {
+			// Сессия общая для всех SP, а доступ к каждому приложению проверяется отдельно
+			app, err := p.apps.SAMLApp(r.Context(), req.ServiceProviderMetadata.EntityID)
+			if err != nil {
+				log.Error("failed to get app", sl.Err(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return nil
+			}
+
+			// SP чужой организации: можно войти под пользователем его организации
+			if app.TenantID != session.tenantID {
+				log.Info("session of another tenant", slog.Int64("user_id", session.userID))
+				p.renderLogin(w, req, "", "Access to the application denied", http.StatusForbidden)
+				return nil
+			}
+
+			// Пользователя могли отключить или удалить после входа, поэтому перечитываем его при каждом запросе
+			user, err := p.users.UserByID(r.Context(), session.userID)
+			if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+				log.Error("failed to get user", sl.Err(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return nil
+			}
+			if err != nil || user.Disabled || user.TenantID != session.tenantID {
+				log.Info("session user is no longer active", slog.Int64("user_id", session.userID))
+				p.sessions.delete(c.Value)
+				p.renderLogin(w, req, "", "", http.StatusOK)
+				return nil
+			}
+
+			if !p.checkAccess(w, r, req, log, app, user.ID, user.Email) {
+				return nil
+			}
+
+			return session.forUser(user)
+		}
+	}
+
+	p.renderLogin(w, req, "", "", http.StatusOK)
+
+	return nil
+}
+
+// checkAccess проверяет, что пользователь допущен в приложение SP с учётом его режима доступа.
+// Если нет, ответ уже записан (форма входа с сообщением, можно войти под другим пользователем)
+// и возвращается false.
+func (p *IdentityProvider) checkAccess(
+	w http.ResponseWriter,
+	r *http.Request,
+	req *saml.IdpAuthnRequest,
+	log *slog.Logger,
+	app models.App,
+	userID int64,
+	email string,
+) bool {
+	isMember := false
+	if app.AccessMode != models.AppAccessOpen {
+		var err error
+		isMember, err = p.members.IsAppMember(r.Context(), app.ID, userID)
+		if err != nil {
+			log.Error("failed to check app membership", sl.Err(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	if !app.Admits(email, isMember) {
+		log.Info("app access denied", slog.Int64("user_id", userID))
+		p.renderLogin(w, req, email, "Access to the application denied", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// renderLogin выводит форму входа. AuthnRequest передаётся в скрытом поле,
+// поэтому форма отправляется на тот же SSO-эндпоинт по POST binding.
+func (p *IdentityProvider) renderLogin(w http.ResponseWriter, req *saml.IdpAuthnRequest, email string, message string, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	err := loginTemplate.Execute(w, map[string]string{
+		"URL":         req.IDP.SSOURL.String(),
+		"SAMLRequest": base64.StdEncoding.EncodeToString(req.RequestBuffer),
+		"RelayState":  req.RelayState,
+		"Email":       email,
+		"Message":     message,
+	})
+	if err != nil {
+		p.log.Error("failed to render login form", sl.Err(err))
+	}
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<form method="post" action="{{.URL}}">
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<input type="hidden" name="SAMLRequest" value="{{.SAMLRequest}}">
+<input type="hidden" name="RelayState" value="{{.RelayState}}">
+<p><label>Email <input type="email" name="email" value="{{.Email}}" required autofocus></label></p>
+<p><label>Password <input type="password" name="password" required></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body>
+</html>
+`))
+
+// userSession сессия пользователя IdP. Хранятся только организация и ID пользователя:
+// остальное (email, статус) перечитывается при каждом использовании сессии.
+type userSession struct {
+	id         string
+	tenantID   int64
+	userID     int64
+	createTime time.Time
+	expireTime time.Time
+}
+
+// forUser возвращает SAML-сессию для выдачи assertion'а пользователю user
+func (s *userSession) forUser(user models.User) *saml.Session {
+	return &saml.Session{
+		ID:           s.id,
+		CreateTime:   s.createTime,
+		ExpireTime:   s.expireTime,
+		Index:        s.id,
+		NameID:       user.Email,
+		NameIDFormat: string(saml.EmailAddressNameIDFormat),
+		SubjectID:    strconv.FormatInt(user.ID, 10),
+		UserName:     user.Email,
+		UserEmail:    user.Email,
+	}
+}
+
+// sessionStore сессии пользователей в памяти
+type sessionStore struct {
+	ttl    time.Duration
+	secure bool
+
+	mu       sync.Mutex
+	sessions map[string]*userSession
+}
+
+func newSessionStore(ttl time.Duration, secure bool) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		secure:   secure,
+		sessions: make(map[string]*userSession),
+	}
+}
+
+func (s *sessionStore) create(tenantID int64, userID int64) (*userSession, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	now := saml.TimeNow()
+	session := &userSession{
+		id:         id,
+		tenantID:   tenantID,
+		userID:     userID,
+		createTime: now,
+		expireTime: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// заодно удаляем просроченные сессии
+	for sid, ss := range s.sessions {
+		if now.After(ss.expireTime) {
+			delete(s.sessions, sid)
+		}
+	}
+	s.sessions[id] = session
+
+	return session, nil
+}
+
+func (s *sessionStore) get(id string) (*userSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || saml.TimeNow().After(session.expireTime) {
+		return nil, false
+	}
+
+	return session, true
+}
+
+func (s *sessionStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+func (s *sessionStore) cookie(session *userSession) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.id,
+		Path:     "/saml",
+		Expires:  session.expireTime,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,45 @@
+// internal/http/scim/filter.go
+package scim
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Фильтры SCIM (RFC 7644, раздел 3.4.2.2). Поддерживаем то, чем реально пользуются
+// системы провижининга для поиска существующих записей: одно условие вида
+//
+//	<атрибут> eq "<значение>"
+//
+// Имена атрибутов и оператор - без учёта регистра.
+
+// filter разобранное условие фильтра
+type filter struct {
+	attribute string // имя атрибута в нижнем регистре
+	value     string
+}
+
+// parseFilter разбирает параметр filter. Пустая строка - без фильтра.
+func parseFilter(raw string) (filter, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return filter{}, nil
+	}
+
+	attribute, rest, ok := strings.Cut(raw, " ")
+	if !ok {
+		return filter{}, badRequest(errInvalidFilter, "invalid filter")
+	}
+
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return filter{}, badRequest(errInvalidFilter, "only 'eq' filters are supported")
+	}
+
+	value, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return filter{}, badRequest(errInvalidFilter, "filter value must be a quoted string")
+	}
+
+	return filter{attribute: strings.ToLower(attribute), value: value}, nil
+}
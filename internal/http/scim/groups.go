@@ -0,0 +1,388 @@
+// internal/http/scim/groups.go
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Ресурс Group. Участники группы - пользователи (members[].value - ID пользователя).
+
+type groupResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []member `json:"members"`
+	Meta        *meta    `json:"meta,omitempty"`
+}
+
+type member struct {
+	Value string `json:"value"`
+	Ref   string `json:"$ref,omitempty"`
+}
+
+func toGroupResource(r *http.Request, group models.Group) groupResource {
+	members := make([]member, 0, len(group.Members))
+	for _, userID := range group.Members {
+		members = append(members, member{
+			Value: strconv.FormatInt(userID, 10),
+			Ref:   location(r, "Users", userID),
+		})
+	}
+
+	return groupResource{
+		Schemas:     []string{schemaGroup},
+		ID:          strconv.FormatInt(group.ID, 10),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.Name,
+		Members:     members,
+		Meta: &meta{
+			ResourceType: "Group",
+			Location:     location(r, "Groups", group.ID),
+		},
+	}
+}
+
+// apply переносит атрибуты ресурса в группу (создание и PUT)
+func (res groupResource) apply(group *models.Group) error {
+	name := strings.TrimSpace(res.DisplayName)
+	if name == "" {
+		return badRequest(errInvalidValue, "displayName is required")
+	}
+
+	members, err := memberIDs(res.Members)
+	if err != nil {
+		return err
+	}
+
+	group.Name = name
+	group.ExternalID = res.ExternalID
+	group.Members = members
+
+	return nil
+}
+
+func (h *handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	groupFilter := storage.GroupFilter{TenantID: tenant(r)}
+	switch f.attribute {
+	case "":
+	case "displayname":
+		groupFilter.Name = f.value
+	case "externalid":
+		groupFilter.ExternalID = f.value
+	default:
+		writeError(w, http.StatusBadRequest, errInvalidFilter, "unsupported filter attribute")
+		return
+	}
+
+	startIndex, count := pagination(r)
+
+	groups, total, err := h.groups.Groups(r.Context(), groupFilter, startIndex-1, count)
+	if err != nil {
+		h.internalError(w, "failed to list groups", err)
+		return
+	}
+
+	resources := make([]any, 0, len(groups))
+	for _, group := range groups {
+		resources = append(resources, toGroupResource(r, group))
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	var res groupResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	var group models.Group
+	if err := res.apply(&group); err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	// Группа без запрошенного состава клиенту не нужна: создание и состав - одна транзакция
+	group.TenantID = tenant(r)
+	err := h.groups.WithinTx(r.Context(), func(ctx context.Context) error {
+		id, err := h.groups.SaveGroup(ctx, group.TenantID, group.Name, group.ExternalID)
+		if err != nil {
+			return err
+		}
+		group.ID = id
+
+		return h.groups.SetGroupMembers(ctx, group.TenantID, id, group.Members)
+	})
+	if err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	h.log.Info("group provisioned", slog.Int64("group_id", group.ID))
+
+	w.Header().Set("Location", location(r, "Groups", group.ID))
+	writeJSON(w, http.StatusCreated, toGroupResource(r, group))
+}
+
+func (h *handler) getGroup(w http.ResponseWriter, r *http.Request, id int64) {
+	group, ok := h.group(w, r, id)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toGroupResource(r, group))
+}
+
+func (h *handler) replaceGroup(w http.ResponseWriter, r *http.Request, id int64) {
+	group, ok := h.group(w, r, id)
+	if !ok {
+		return
+	}
+
+	var res groupResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	if err := res.apply(&group); err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	h.saveGroup(w, r, group)
+}
+
+func (h *handler) patchGroup(w http.ResponseWriter, r *http.Request, id int64) {
+	group, ok := h.group(w, r, id)
+	if !ok {
+		return
+	}
+
+	req, err := decodePatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	for _, op := range req.Operations {
+		if err := applyGroupPatch(&group, op); err != nil {
+			h.writeRequestError(w, err)
+			return
+		}
+	}
+
+	h.saveGroup(w, r, group)
+}
+
+func (h *handler) deleteGroup(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := h.groups.DeleteGroup(r.Context(), tenant(r), id); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrGroupNotFound):
+			writeError(w, http.StatusNotFound, "", "group not found")
+			return
+		case errors.Is(err, storage.ErrLastAdmin):
+			writeError(w, http.StatusConflict, "", "group contains the last admin")
+			return
+		}
+
+		h.internalError(w, "failed to delete group", err)
+		return
+	}
+
+	h.log.Info("group deleted", slog.Int64("group_id", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// group читает группу; если её нет или произошла ошибка, ответ уже записан.
+// Группы других организаций для клиента не существуют.
+func (h *handler) group(w http.ResponseWriter, r *http.Request, id int64) (models.Group, bool) {
+	group, err := h.groups.Group(r.Context(), id)
+	if err == nil && group.TenantID != tenant(r) {
+		err = storage.ErrGroupNotFound
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			writeError(w, http.StatusNotFound, "", "group not found")
+			return models.Group{}, false
+		}
+
+		h.internalError(w, "failed to get group", err)
+		return models.Group{}, false
+	}
+
+	return group, true
+}
+
+// saveGroup сохраняет атрибуты и состав группы в одной транзакции: изменение не применяется частично
+func (h *handler) saveGroup(w http.ResponseWriter, r *http.Request, group models.Group) {
+	err := h.groups.WithinTx(r.Context(), func(ctx context.Context) error {
+		if err := h.groups.UpdateGroup(ctx, group); err != nil {
+			return err
+		}
+
+		return h.groups.SetGroupMembers(ctx, group.TenantID, group.ID, group.Members)
+	})
+	if err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toGroupResource(r, group))
+}
+
+// writeGroupError записывает ответ на ошибку сохранения группы или её состава
+func (h *handler) writeGroupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrGroupExists):
+		writeError(w, http.StatusConflict, errUniqueness, "group with this displayName already exists")
+	case errors.Is(err, storage.ErrGroupNotFound):
+		writeError(w, http.StatusNotFound, "", "group not found")
+	case errors.Is(err, storage.ErrUserNotFound):
+		writeError(w, http.StatusBadRequest, errInvalidValue, "member user not found")
+	case errors.Is(err, storage.ErrLastAdmin):
+		writeError(w, http.StatusConflict, "", "can't remove the last admin from the group")
+	default:
+		h.internalError(w, "failed to save group", err)
+	}
+}
+
+// applyGroupPatch применяет одну операцию PATCH к группе
+func applyGroupPatch(group *models.Group, op patchOperation) error {
+	if op.Op != "add" && op.Op != "replace" && op.Op != "remove" {
+		return badRequest(errInvalidSyntax, "unsupported patch operation: "+op.Op)
+	}
+
+	// Без path значение - объект с заменяемыми атрибутами
+	if op.Path == "" {
+		if op.Op == "remove" {
+			return badRequest(errInvalidPath, "path is required for remove operation")
+		}
+
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return badRequest(errInvalidValue, "value must be an object")
+		}
+
+		for path, value := range attrs {
+			if err := applyGroupPatch(group, patchOperation{Op: op.Op, Path: path, Value: value}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	path := strings.ToLower(op.Path)
+
+	// Удаление одного участника: members[value eq "<id>"]
+	if rest, ok := strings.CutPrefix(path, "members["); ok && op.Op == "remove" {
+		f, err := parseFilter(strings.TrimSuffix(rest, "]"))
+		if err != nil || f.attribute != "value" {
+			return badRequest(errInvalidPath, "unsupported members filter")
+		}
+
+		id, err := strconv.ParseInt(f.value, 10, 64)
+		if err != nil {
+			return badRequest(errInvalidValue, "invalid member value")
+		}
+
+		group.Members = slices.DeleteFunc(group.Members, func(m int64) bool { return m == id })
+		return nil
+	}
+
+	switch path {
+	case "displayname":
+		var name string
+		if op.Op == "remove" || json.Unmarshal(op.Value, &name) != nil || strings.TrimSpace(name) == "" {
+			return badRequest(errInvalidValue, "displayName must be a non-empty string")
+		}
+		group.Name = strings.TrimSpace(name)
+	case "externalid":
+		if op.Op == "remove" {
+			group.ExternalID = ""
+			return nil
+		}
+
+		var externalID string
+		if err := json.Unmarshal(op.Value, &externalID); err != nil {
+			return badRequest(errInvalidValue, "externalId must be a string")
+		}
+		group.ExternalID = externalID
+	case "members":
+		var members []member
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				return badRequest(errInvalidValue, "members must be an array")
+			}
+		}
+
+		ids, err := memberIDs(members)
+		if err != nil {
+			return err
+		}
+
+		switch op.Op {
+		case "add":
+			for _, id := range ids {
+				if !slices.Contains(group.Members, id) {
+					group.Members = append(group.Members, id)
+				}
+			}
+		case "replace":
+			group.Members = ids
+		case "remove":
+			// remove без значения удаляет всех участников
+			if len(op.Value) == 0 {
+				group.Members = nil
+				return nil
+			}
+
+			group.Members = slices.DeleteFunc(group.Members, func(m int64) bool { return slices.Contains(ids, m) })
+		}
+	default:
+		return badRequest(errInvalidPath, "unsupported path: "+op.Path)
+	}
+
+	return nil
+}
+
+func memberIDs(members []member) ([]int64, error) {
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m.Value, 10, 64)
+		if err != nil || id < 1 {
+			return nil, badRequest(errInvalidValue, "invalid member value: "+m.Value)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
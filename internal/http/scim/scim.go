@@ -0,0 +1,337 @@
+// internal/http/scim/scim.go
+package scim
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/storage"
+)
+
+// SCIM 2.0 API (RFC 7643, RFC 7644) для автоматического провижининга пользователей
+// и групп из внешних систем (например, HR):
+//
+//	GET/POST               /scim/v2/Users       - список (filter, startIndex, count) / создание
+//	GET/PUT/PATCH/DELETE   /scim/v2/Users/<id>  - пользователь
+//	GET/POST               /scim/v2/Groups      - список / создание
+//	GET/PUT/PATCH/DELETE   /scim/v2/Groups/<id> - группа
+//
+// Доступ - по bearer-токену, которым служит секрет выделенного приложения провижининга
+// (вида models.AppKindSCIM, войти в него нельзя). Секрет ротируется как у любого приложения.
+// Видны и изменяются только пользователи и группы организации этого приложения.
+// DELETE пользователя не удаляет его, а отключает учётную запись (active = false).
+
+const (
+	basePath = "/scim/v2/"
+
+	contentType = "application/scim+json"
+
+	schemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	defaultCount = 100
+	maxCount     = 1000
+)
+
+// Значения scimType в ответах с ошибкой (RFC 7644, раздел 3.12)
+const (
+	errInvalidFilter = "invalidFilter"
+	errInvalidValue  = "invalidValue"
+	errInvalidPath   = "invalidPath"
+	errInvalidSyntax = "invalidSyntax"
+	errUniqueness    = "uniqueness"
+)
+
+// UserStorage интерфейс хранилища пользователей
+type UserStorage interface {
+	SaveUser(ctx context.Context, tenantID int64, email string, passHash []byte) (int64, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	Users(ctx context.Context, filter storage.UserFilter, offset int, limit int) ([]models.User, int, error)
+	UpdateUser(ctx context.Context, user models.User) error
+	// WithinTx выполняет вызовы хранилища внутри fn в одной транзакции
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// GroupStorage интерфейс хранилища групп
+type GroupStorage interface {
+	SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error)
+	Group(ctx context.Context, id int64) (models.Group, error)
+	Groups(ctx context.Context, filter storage.GroupFilter, offset int, limit int) ([]models.Group, int, error)
+	UpdateGroup(ctx context.Context, group models.Group) error
+	SetGroupMembers(ctx context.Context, tenantID int64, groupID int64, userIDs []int64) error
+	DeleteGroup(ctx context.Context, tenantID int64, id int64) error
+	// WithinTx выполняет вызовы хранилища внутри fn в одной транзакции
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AppProvider интерфейс для получения App (приложения) из хранилища
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+type handler struct {
+	log    *slog.Logger
+	appID  int // приложение провижининга, секрет которого служит bearer-токеном
+	apps   AppProvider
+	users  UserStorage
+	groups GroupStorage
+}
+
+// Register регистрирует SCIM API в HTTP-роутере.
+// Запросы принимаются только с секретом приложения appID в качестве bearer-токена.
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	appID int,
+	apps AppProvider,
+	users UserStorage,
+	groups GroupStorage,
+) {
+	h := &handler{
+		log:    log,
+		appID:  appID,
+		apps:   apps,
+		users:  users,
+		groups: groups,
+	}
+
+	mux.Handle(basePath, h.authenticate(http.HandlerFunc(h.route)))
+}
+
+// authenticate проверяет bearer-токен: это должен быть секрет приложения провижининга
+// (в период ротации - и прежний), а само приложение должно быть вида models.AppKindSCIM.
+// Токены пользователей не принимаются, даже если выданы для этого приложения.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "", "bearer token is required")
+			return
+		}
+
+		app, err := h.apps.App(r.Context(), h.appID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrAppNotFound) && !errors.Is(err, storage.ErrAppDisabled) {
+				h.log.Error("failed to get provisioning app", sl.Err(err))
+				writeError(w, http.StatusInternalServerError, "", "internal error")
+				return
+			}
+
+			h.log.Warn("provisioning app is not available", sl.Err(err))
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "", "invalid token")
+			return
+		}
+
+		valid := false
+		for _, secret := range app.VerificationSecrets(time.Now()) {
+			if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+				valid = true
+			}
+		}
+		if !valid {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "", "invalid token")
+			return
+		}
+
+		// в приложение другого вида могут входить пользователи, поэтому его секрет для провижининга не годится
+		if app.Kind != models.AppKindSCIM {
+			h.log.Error("provisioning app must be of kind scim", slog.Int("app_id", app.ID), slog.String("kind", app.Kind))
+			writeError(w, http.StatusForbidden, "", "provisioning app is misconfigured")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, app.TenantID)))
+	})
+}
+
+// tenantKey ключ контекста запроса с организацией из токена
+type tenantKey struct{}
+
+// tenant возвращает организацию, в пределах которой выполняется запрос (проставляется в authenticate)
+func tenant(r *http.Request) int64 {
+	tenantID, _ := r.Context().Value(tenantKey{}).(int64)
+
+	return tenantID
+}
+
+// route разбирает путь /scim/v2/<Resource>[/<id>] и вызывает нужный хэндлер
+func (h *handler) route(w http.ResponseWriter, r *http.Request) {
+	resource, rawID, hasID := strings.Cut(strings.TrimPrefix(r.URL.Path, basePath), "/")
+
+	var id int64
+	if hasID {
+		var err error
+		id, err = strconv.ParseInt(rawID, 10, 64)
+		if err != nil || id < 1 {
+			writeError(w, http.StatusNotFound, "", "resource not found")
+			return
+		}
+	}
+
+	switch {
+	case resource == "Users" && !hasID && r.Method == http.MethodGet:
+		h.listUsers(w, r)
+	case resource == "Users" && !hasID && r.Method == http.MethodPost:
+		h.createUser(w, r)
+	case resource == "Users" && hasID && r.Method == http.MethodGet:
+		h.getUser(w, r, id)
+	case resource == "Users" && hasID && r.Method == http.MethodPut:
+		h.replaceUser(w, r, id)
+	case resource == "Users" && hasID && r.Method == http.MethodPatch:
+		h.patchUser(w, r, id)
+	case resource == "Users" && hasID && r.Method == http.MethodDelete:
+		h.deleteUser(w, r, id)
+	case resource == "Groups" && !hasID && r.Method == http.MethodGet:
+		h.listGroups(w, r)
+	case resource == "Groups" && !hasID && r.Method == http.MethodPost:
+		h.createGroup(w, r)
+	case resource == "Groups" && hasID && r.Method == http.MethodGet:
+		h.getGroup(w, r, id)
+	case resource == "Groups" && hasID && r.Method == http.MethodPut:
+		h.replaceGroup(w, r, id)
+	case resource == "Groups" && hasID && r.Method == http.MethodPatch:
+		h.patchGroup(w, r, id)
+	case resource == "Groups" && hasID && r.Method == http.MethodDelete:
+		h.deleteGroup(w, r, id)
+	case resource == "Users" || resource == "Groups":
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "", "resource not found")
+	}
+}
+
+// internalError логирует ошибку хранилища и отвечает 500
+func (h *handler) internalError(w http.ResponseWriter, msg string, err error) {
+	h.log.Error(msg, sl.Err(err))
+	writeError(w, http.StatusInternalServerError, "", "internal error")
+}
+
+// meta атрибут meta ресурса
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+func location(r *http.Request, resource string, id int64) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + basePath + resource + "/" + strconv.FormatInt(id, 10)
+}
+
+// listResponse ответ на запрос списка ресурсов
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// pagination разбирает параметры startIndex (с единицы) и count
+func pagination(r *http.Request) (startIndex int, count int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+
+	count, err = strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil {
+		count = defaultCount
+	}
+	count = min(max(count, 0), maxCount)
+
+	return startIndex, count
+}
+
+// patchRequest запрос PATCH (RFC 7644, раздел 3.5.2)
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func decodePatch(r *http.Request) (patchRequest, error) {
+	var req patchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return patchRequest{}, err
+	}
+
+	for i := range req.Operations {
+		// Некоторые клиенты присылают "Replace", "Add" и т.п.
+		req.Operations[i].Op = strings.ToLower(req.Operations[i].Op)
+	}
+
+	return req, nil
+}
+
+// scimError ошибка обработки запроса, которая отдаётся клиенту как есть
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimError) Error() string {
+	return e.detail
+}
+
+func badRequest(scimType string, detail string) error {
+	return &scimError{status: http.StatusBadRequest, scimType: scimType, detail: detail}
+}
+
+// errorResponse тело ответа с ошибкой
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	writeJSON(w, status, errorResponse{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+// writeRequestError отвечает ошибкой scimError или 500 для остальных ошибок
+func (h *handler) writeRequestError(w http.ResponseWriter, err error) {
+	var e *scimError
+	if errors.As(err, &e) {
+		writeError(w, e.status, e.scimType, e.detail)
+		return
+	}
+
+	h.internalError(w, "failed to handle request", err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
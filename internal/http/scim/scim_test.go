@@ -0,0 +1,676 @@
+// internal/http/scim/scim_test.go
+package scim_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/http/scim"
+	"grpc-service-ref/internal/lib/jwt"
+	"grpc-service-ref/internal/storage"
+)
+
+// Тесты SCIM API с хранилищем в памяти (fakeStorage)
+
+const (
+	provisioningAppID  = 3
+	provisioningSecret = "hr-secret"
+	previousSecret     = "hr-previous-secret"
+	otherAppID         = 1
+)
+
+func TestSCIM_Authentication(t *testing.T) {
+	env := newTestEnv(t)
+
+	resp := env.do(t, "", http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+
+	resp = env.do(t, "not-a-token", http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+
+	// секрет другого приложения
+	resp = env.do(t, "test-secret", http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+
+	// токен пользователя, даже выданный для приложения провижининга, не принимается
+	resp = env.do(t, env.userToken(t, provisioningAppID), http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.status)
+
+	resp = env.get(t, "/scim/v2/Users")
+	assert.Equal(t, http.StatusOK, resp.status)
+
+	// в период ротации принимается и прежний секрет
+	resp = env.do(t, previousSecret, http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusOK, resp.status)
+}
+
+func TestSCIM_Authentication_AppIsNotSCIM(t *testing.T) {
+	// в обычное приложение могут входить пользователи, поэтому провижининг через него запрещён
+	env := newTestEnvForApp(t, otherAppID)
+
+	resp := env.do(t, "test-secret", http.MethodGet, "/scim/v2/Users", nil)
+	assert.Equal(t, http.StatusForbidden, resp.status)
+}
+
+func TestSCIM_UserLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+
+	// создание
+	resp := env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{
+		"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName":   "joiner@example.com",
+		"externalId": "hr-1",
+		"password":   "secret-password",
+	})
+	require.Equal(t, http.StatusCreated, resp.status)
+	id := resp.body["id"].(string)
+	assert.Equal(t, "joiner@example.com", resp.body["userName"])
+	assert.Equal(t, "hr-1", resp.body["externalId"])
+	assert.Equal(t, true, resp.body["active"])
+	assert.NotContains(t, resp.body, "password")
+
+	// повторное создание
+	resp = env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "joiner@example.com"})
+	assert.Equal(t, http.StatusConflict, resp.status)
+	assert.Equal(t, "uniqueness", resp.body["scimType"])
+
+	// поиск по фильтру
+	resp = env.get(t, "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "joiner@example.com"`))
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(1), resp.body["totalResults"])
+
+	resp = env.get(t, "/scim/v2/Users?filter="+url.QueryEscape(`externalId eq "unknown"`))
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(0), resp.body["totalResults"])
+
+	// PATCH: смена userName и отключение (значение строкой, как присылает Azure AD)
+	resp = env.send(t, http.MethodPatch, "/scim/v2/Users/"+id, map[string]any{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]any{
+			{"op": "Replace", "path": "userName", "value": "renamed@example.com"},
+			{"op": "replace", "path": "active", "value": "False"},
+		},
+	})
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, "renamed@example.com", resp.body["userName"])
+	assert.Equal(t, false, resp.body["active"])
+
+	// PATCH без path
+	resp = env.send(t, http.MethodPatch, "/scim/v2/Users/"+id, map[string]any{
+		"Operations": []map[string]any{{"op": "replace", "value": map[string]any{"active": true}}},
+	})
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, true, resp.body["active"])
+
+	// DELETE не удаляет пользователя, а отключает его
+	resp = env.do(t, provisioningSecret, http.MethodDelete, "/scim/v2/Users/"+id, nil)
+	require.Equal(t, http.StatusNoContent, resp.status)
+
+	user, err := env.storage.User(context.Background(), models.DefaultTenantID, "renamed@example.com")
+	require.NoError(t, err)
+	assert.True(t, user.Disabled)
+	assert.NotEmpty(t, user.PassHash)
+
+	resp = env.get(t, "/scim/v2/Users/"+id)
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, false, resp.body["active"])
+}
+
+func TestSCIM_UserFailCases(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           any
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:           "Create without userName",
+			method:         http.MethodPost,
+			path:           "/scim/v2/Users",
+			body:           map[string]any{"externalId": "hr-2"},
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "invalidValue",
+		},
+		{
+			name:           "Unsupported filter operator",
+			method:         http.MethodGet,
+			path:           "/scim/v2/Users?filter=" + url.QueryEscape(`userName co "example"`),
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "invalidFilter",
+		},
+		{
+			name:           "Unsupported filter attribute",
+			method:         http.MethodGet,
+			path:           "/scim/v2/Users?filter=" + url.QueryEscape(`nickName eq "joe"`),
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "invalidFilter",
+		},
+		{
+			name:           "Unknown user",
+			method:         http.MethodGet,
+			path:           "/scim/v2/Users/999",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Patch unsupported path",
+			method: http.MethodPatch,
+			path:   "/scim/v2/Users/1",
+			body: map[string]any{
+				"Operations": []map[string]any{{"op": "replace", "path": "nickName", "value": "joe"}},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "invalidPath",
+		},
+	}
+
+	resp := env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "user@example.com"})
+	require.Equal(t, http.StatusCreated, resp.status)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := env.send(t, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, resp.status)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, resp.body["scimType"])
+			}
+		})
+	}
+}
+
+func TestSCIM_CreateUser_RolledBack(t *testing.T) {
+	env := newTestEnv(t)
+	env.storage.updateUserErr = errors.New("storage is unavailable")
+
+	// externalId и active сохраняются после создания: если это не удалось, пользователя не должно остаться
+	resp := env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{
+		"userName":   "user@example.com",
+		"externalId": "hr-1",
+		"active":     false,
+	})
+	require.Equal(t, http.StatusInternalServerError, resp.status)
+
+	_, err := env.storage.User(context.Background(), models.DefaultTenantID, "user@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// после сбоя пользователя можно создать заново
+	env.storage.updateUserErr = nil
+	resp = env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{
+		"userName":   "user@example.com",
+		"externalId": "hr-1",
+		"active":     false,
+	})
+	require.Equal(t, http.StatusCreated, resp.status)
+	assert.Equal(t, "hr-1", resp.body["externalId"])
+	assert.Equal(t, false, resp.body["active"])
+}
+
+func TestSCIM_Pagination(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		resp := env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": email})
+		require.Equal(t, http.StatusCreated, resp.status)
+	}
+
+	resp := env.get(t, "/scim/v2/Users?startIndex=2&count=1")
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(3), resp.body["totalResults"])
+	assert.Equal(t, float64(2), resp.body["startIndex"])
+	assert.Equal(t, float64(1), resp.body["itemsPerPage"])
+
+	resources := resp.body["Resources"].([]any)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "b@example.com", resources[0].(map[string]any)["userName"])
+}
+
+func TestSCIM_GroupLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+
+	var userIDs []string
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		resp := env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": email})
+		require.Equal(t, http.StatusCreated, resp.status)
+		userIDs = append(userIDs, resp.body["id"].(string))
+	}
+
+	resp := env.send(t, http.MethodPost, "/scim/v2/Groups", map[string]any{
+		"displayName": "devs",
+		"members":     []map[string]string{{"value": userIDs[0]}},
+	})
+	require.Equal(t, http.StatusCreated, resp.status)
+	id := resp.body["id"].(string)
+	assert.Equal(t, []string{userIDs[0]}, memberValues(resp.body))
+
+	// добавление и удаление участников
+	resp = env.send(t, http.MethodPatch, "/scim/v2/Groups/"+id, map[string]any{
+		"Operations": []map[string]any{
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": userIDs[1]}}},
+			{"op": "remove", "path": `members[value eq "` + userIDs[0] + `"]`},
+		},
+	})
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, []string{userIDs[1]}, memberValues(resp.body))
+
+	// неизвестный участник
+	resp = env.send(t, http.MethodPatch, "/scim/v2/Groups/"+id, map[string]any{
+		"Operations": []map[string]any{
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": "999"}}},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.status)
+
+	// изменение с неизвестным участником не применяется и частично
+	resp = env.send(t, http.MethodPatch, "/scim/v2/Groups/"+id, map[string]any{
+		"Operations": []map[string]any{
+			{"op": "replace", "path": "displayName", "value": "renamed"},
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": "999"}}},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.status)
+
+	resp = env.get(t, "/scim/v2/Groups?filter="+url.QueryEscape(`displayName eq "devs"`))
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(1), resp.body["totalResults"])
+
+	// группа с неизвестным участником не создаётся
+	resp = env.send(t, http.MethodPost, "/scim/v2/Groups", map[string]any{
+		"displayName": "qa",
+		"members":     []map[string]string{{"value": "999"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.status)
+
+	resp = env.get(t, "/scim/v2/Groups?filter="+url.QueryEscape(`displayName eq "qa"`))
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(0), resp.body["totalResults"])
+
+	// группы удаляются по-настоящему
+	resp = env.do(t, provisioningSecret, http.MethodDelete, "/scim/v2/Groups/"+id, nil)
+	require.Equal(t, http.StatusNoContent, resp.status)
+
+	resp = env.get(t, "/scim/v2/Groups/"+id)
+	assert.Equal(t, http.StatusNotFound, resp.status)
+}
+
+func memberValues(body map[string]any) []string {
+	var values []string
+	for _, m := range body["members"].([]any) {
+		values = append(values, m.(map[string]any)["value"].(string))
+	}
+
+	return values
+}
+
+func TestSCIM_TenantIsolation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// пользователь и группа другой организации с тем же email и именем
+	const otherTenant int64 = 2
+	userID, err := env.storage.SaveUser(ctx, otherTenant, "same@example.com", []byte{})
+	require.NoError(t, err)
+	groupID, err := env.storage.SaveGroup(ctx, otherTenant, "staff", "")
+	require.NoError(t, err)
+
+	resp := env.get(t, "/scim/v2/Users")
+	require.Equal(t, http.StatusOK, resp.status)
+	assert.Equal(t, float64(0), resp.body["totalResults"])
+
+	resp = env.get(t, "/scim/v2/Users/"+strconv.FormatInt(userID, 10))
+	assert.Equal(t, http.StatusNotFound, resp.status)
+
+	resp = env.send(t, http.MethodDelete, "/scim/v2/Groups/"+strconv.FormatInt(groupID, 10), nil)
+	assert.Equal(t, http.StatusNotFound, resp.status)
+
+	// в своей организации те же email и имя группы свободны
+	resp = env.send(t, http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "same@example.com"})
+	require.Equal(t, http.StatusCreated, resp.status)
+
+	// но чужого пользователя в свою группу добавить нельзя
+	resp = env.send(t, http.MethodPost, "/scim/v2/Groups", map[string]any{
+		"displayName": "staff",
+		"members":     []map[string]any{{"value": strconv.FormatInt(userID, 10)}},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.status)
+
+	resp = env.send(t, http.MethodPost, "/scim/v2/Groups", map[string]any{"displayName": "staff"})
+	assert.Equal(t, http.StatusCreated, resp.status)
+}
+
+type testEnv struct {
+	server  *httptest.Server
+	storage *fakeStorage
+}
+
+type response struct {
+	status int
+	body   map[string]any
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	return newTestEnvForApp(t, provisioningAppID)
+}
+
+// newTestEnvForApp поднимает SCIM API, для которого приложением провижининга настроено appID
+func newTestEnvForApp(t *testing.T, appID int) *testEnv {
+	t.Helper()
+
+	st := newFakeStorage()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	mux := http.NewServeMux()
+	scim.Register(mux, log, appID, st, st, st)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &testEnv{server: server, storage: st}
+}
+
+// userToken выдаёт JWT пользователя для приложения appID
+func (e *testEnv) userToken(t *testing.T, appID int) string {
+	t.Helper()
+
+	app, err := e.storage.App(context.Background(), appID)
+	require.NoError(t, err)
+
+	token, err := jwt.NewToken(models.User{ID: 100, TenantID: app.TenantID, Email: "hr@example.com"}, app, nil, nil, time.Hour)
+	require.NoError(t, err)
+
+	return token
+}
+
+func (e *testEnv) get(t *testing.T, path string) response {
+	return e.do(t, provisioningSecret, http.MethodGet, path, nil)
+}
+
+func (e *testEnv) send(t *testing.T, method string, path string, body any) response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(b)
+	}
+
+	return e.do(t, provisioningSecret, method, path, reader)
+}
+
+func (e *testEnv) do(t *testing.T, token string, method string, path string, body io.Reader) response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, e.server.URL+path, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/scim+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	result := response{status: resp.StatusCode}
+	if resp.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result.body))
+	}
+
+	return result
+}
+
+// fakeStorage хранилище пользователей, групп и приложений в памяти
+type fakeStorage struct {
+	mu     sync.Mutex
+	users  []models.User
+	groups map[int64]models.Group
+	nextID int64
+	// updateUserErr если задана, UpdateUser возвращает её
+	updateUserErr error
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{groups: make(map[int64]models.Group)}
+}
+
+func (s *fakeStorage) App(_ context.Context, id int) (models.App, error) {
+	switch id {
+	case provisioningAppID:
+		return models.App{
+			ID:                      id,
+			TenantID:                models.DefaultTenantID,
+			Name:                    "hr",
+			Kind:                    models.AppKindSCIM,
+			Secret:                  provisioningSecret,
+			PreviousSecret:          previousSecret,
+			PreviousSecretExpiresAt: time.Now().Add(time.Hour),
+		}, nil
+	case otherAppID:
+		return models.App{ID: id, TenantID: models.DefaultTenantID, Name: "test", Kind: models.AppKindJWT, Secret: "test-secret"}, nil
+	}
+
+	return models.App{}, storage.ErrAppNotFound
+}
+
+func (s *fakeStorage) SaveUser(_ context.Context, tenantID int64, email string, passHash []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return 0, storage.ErrUserExists
+		}
+	}
+
+	user := models.User{ID: int64(len(s.users) + 1), TenantID: tenantID, Email: email, PassHash: passHash}
+	s.users = append(s.users, user)
+
+	return user.ID, nil
+}
+
+func (s *fakeStorage) User(_ context.Context, tenantID int64, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) UserByID(_ context.Context, id int64) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || int(id) > len(s.users) {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return s.users[id-1], nil
+}
+
+func (s *fakeStorage) Users(_ context.Context, filter storage.UserFilter, offset int, limit int) ([]models.User, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []models.User
+	for _, u := range s.users {
+		if u.TenantID == filter.TenantID &&
+			(filter.Email == "" || u.Email == filter.Email) && (filter.ExternalID == "" || u.ExternalID == filter.ExternalID) {
+			matched = append(matched, u)
+		}
+	}
+
+	return page(matched, offset, limit), len(matched), nil
+}
+
+func (s *fakeStorage) UpdateUser(_ context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.updateUserErr != nil {
+		return s.updateUserErr
+	}
+
+	if user.ID < 1 || int(user.ID) > len(s.users) {
+		return storage.ErrUserNotFound
+	}
+	for _, u := range s.users {
+		if u.TenantID == user.TenantID && u.Email == user.Email && u.ID != user.ID {
+			return storage.ErrUserExists
+		}
+	}
+
+	// пароль при обновлении не меняется
+	user.PassHash = s.users[user.ID-1].PassHash
+	s.users[user.ID-1] = user
+
+	return nil
+}
+
+func (s *fakeStorage) SaveGroup(_ context.Context, tenantID int64, name string, externalID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, g := range s.groups {
+		if g.TenantID == tenantID && g.Name == name {
+			return 0, storage.ErrGroupExists
+		}
+	}
+
+	s.nextID++
+	s.groups[s.nextID] = models.Group{ID: s.nextID, TenantID: tenantID, Name: name, ExternalID: externalID}
+
+	return s.nextID, nil
+}
+
+func (s *fakeStorage) Group(_ context.Context, id int64) (models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[id]
+	if !ok {
+		return models.Group{}, storage.ErrGroupNotFound
+	}
+
+	return group, nil
+}
+
+func (s *fakeStorage) Groups(_ context.Context, filter storage.GroupFilter, offset int, limit int) ([]models.Group, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []models.Group
+	for _, g := range s.groups {
+		if g.TenantID == filter.TenantID &&
+			(filter.Name == "" || g.Name == filter.Name) && (filter.ExternalID == "" || g.ExternalID == filter.ExternalID) {
+			matched = append(matched, g)
+		}
+	}
+	slices.SortFunc(matched, func(a, b models.Group) int { return int(a.ID - b.ID) })
+
+	return page(matched, offset, limit), len(matched), nil
+}
+
+func (s *fakeStorage) UpdateGroup(_ context.Context, group models.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.groups[group.ID]
+	if !ok {
+		return storage.ErrGroupNotFound
+	}
+	for _, g := range s.groups {
+		if g.TenantID == current.TenantID && g.Name == group.Name && g.ID != group.ID {
+			return storage.ErrGroupExists
+		}
+	}
+
+	current.Name = group.Name
+	current.ExternalID = group.ExternalID
+	s.groups[group.ID] = current
+
+	return nil
+}
+
+func (s *fakeStorage) SetGroupMembers(_ context.Context, tenantID int64, groupID int64, userIDs []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[groupID]
+	if !ok || group.TenantID != tenantID {
+		return storage.ErrGroupNotFound
+	}
+	for _, id := range userIDs {
+		if id < 1 || int(id) > len(s.users) || s.users[id-1].TenantID != tenantID {
+			return storage.ErrUserNotFound
+		}
+	}
+
+	group.Members = slices.Clone(userIDs)
+	s.groups[groupID] = group
+
+	return nil
+}
+
+func (s *fakeStorage) DeleteGroup(_ context.Context, tenantID int64, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group, ok := s.groups[id]; !ok || group.TenantID != tenantID {
+		return storage.ErrGroupNotFound
+	}
+	delete(s.groups, id)
+
+	return nil
+}
+
+// WithinTx откатывает пользователей и группы, если fn вернула ошибку
+func (s *fakeStorage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.mu.Lock()
+	users, groups, nextID := slices.Clone(s.users), maps.Clone(s.groups), s.nextID
+	s.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		s.mu.Lock()
+		s.users, s.groups, s.nextID = users, groups, nextID
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func page[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+
+	return items[offset:min(offset+limit, len(items))]
+}
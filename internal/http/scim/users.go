@@ -0,0 +1,363 @@
+// internal/http/scim/users.go
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Ресурс User. userName - это email пользователя.
+
+type userResource struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id,omitempty"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Emails     []email  `json:"emails,omitempty"`
+	Active     *bool    `json:"active,omitempty"`
+	// Пароль можно передать только при создании, в ответах его нет
+	Password string `json:"password,omitempty"`
+	Meta     *meta  `json:"meta,omitempty"`
+}
+
+type email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+func toUserResource(r *http.Request, user models.User) userResource {
+	active := !user.Disabled
+
+	return userResource{
+		Schemas:    []string{schemaUser},
+		ID:         strconv.FormatInt(user.ID, 10),
+		ExternalID: user.ExternalID,
+		UserName:   user.Email,
+		Emails:     []email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:     &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Location:     location(r, "Users", user.ID),
+		},
+	}
+}
+
+// apply переносит атрибуты ресурса в пользователя (создание и PUT)
+func (res userResource) apply(user *models.User) error {
+	userName := strings.TrimSpace(res.UserName)
+	if userName == "" {
+		// некоторые клиенты присылают email только в emails
+		for _, e := range res.Emails {
+			if e.Primary || userName == "" {
+				userName = strings.TrimSpace(e.Value)
+			}
+		}
+	}
+	if userName == "" {
+		return badRequest(errInvalidValue, "userName is required")
+	}
+
+	user.Email = userName
+	user.ExternalID = res.ExternalID
+	// active по умолчанию true
+	user.Disabled = res.Active != nil && !*res.Active
+
+	return nil
+}
+
+func (h *handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	userFilter := storage.UserFilter{TenantID: tenant(r)}
+	switch f.attribute {
+	case "":
+	case "username", "emails", "emails.value":
+		userFilter.Email = f.value
+	case "externalid":
+		userFilter.ExternalID = f.value
+	default:
+		writeError(w, http.StatusBadRequest, errInvalidFilter, "unsupported filter attribute")
+		return
+	}
+
+	startIndex, count := pagination(r)
+
+	users, total, err := h.users.Users(r.Context(), userFilter, startIndex-1, count)
+	if err != nil {
+		h.internalError(w, "failed to list users", err)
+		return
+	}
+
+	resources := make([]any, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, toUserResource(r, user))
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var res userResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	var user models.User
+	if err := res.apply(&user); err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	// Без пароля пользователь сможет входить только через внешние провайдеры (LDAP, OIDC):
+	// пустой хэш не совпадёт ни с одним паролем
+	passHash := []byte{}
+	if res.Password != "" {
+		var err error
+		passHash, err = bcrypt.GenerateFromPassword([]byte(res.Password), bcrypt.DefaultCost)
+		if err != nil {
+			h.internalError(w, "failed to generate password hash", err)
+			return
+		}
+	}
+
+	// externalId и active сохраняются отдельно от создания, но в той же транзакции:
+	// иначе при ошибке останется пользователь без них (например, активный вместо отключённого)
+	user.TenantID = tenant(r)
+	err := h.users.WithinTx(r.Context(), func(ctx context.Context) error {
+		id, err := h.users.SaveUser(ctx, user.TenantID, user.Email, passHash)
+		if err != nil {
+			return err
+		}
+		user.ID = id
+
+		if user.ExternalID == "" && !user.Disabled {
+			return nil
+		}
+
+		return h.users.UpdateUser(ctx, user)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			writeError(w, http.StatusConflict, errUniqueness, "user already exists")
+			return
+		}
+
+		h.internalError(w, "failed to save user", err)
+		return
+	}
+
+	h.log.Info("user provisioned", slog.Int64("user_id", user.ID))
+
+	w.Header().Set("Location", location(r, "Users", user.ID))
+	writeJSON(w, http.StatusCreated, toUserResource(r, user))
+}
+
+func (h *handler) getUser(w http.ResponseWriter, r *http.Request, id int64) {
+	user, ok := h.user(w, r, id)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserResource(r, user))
+}
+
+func (h *handler) replaceUser(w http.ResponseWriter, r *http.Request, id int64) {
+	user, ok := h.user(w, r, id)
+	if !ok {
+		return
+	}
+
+	var res userResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	if err := res.apply(&user); err != nil {
+		h.writeRequestError(w, err)
+		return
+	}
+
+	h.saveUser(w, r, user)
+}
+
+func (h *handler) patchUser(w http.ResponseWriter, r *http.Request, id int64) {
+	user, ok := h.user(w, r, id)
+	if !ok {
+		return
+	}
+
+	req, err := decodePatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidSyntax, "invalid request body")
+		return
+	}
+
+	for _, op := range req.Operations {
+		if err := applyUserPatch(&user, op); err != nil {
+			h.writeRequestError(w, err)
+			return
+		}
+	}
+
+	h.saveUser(w, r, user)
+}
+
+// deleteUser не удаляет пользователя, а отключает его учётную запись
+func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request, id int64) {
+	user, ok := h.user(w, r, id)
+	if !ok {
+		return
+	}
+
+	user.Disabled = true
+	if err := h.users.UpdateUser(r.Context(), user); err != nil {
+		h.internalError(w, "failed to disable user", err)
+		return
+	}
+
+	h.log.Info("user deprovisioned", slog.Int64("user_id", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// user читает пользователя; если его нет или произошла ошибка, ответ уже записан.
+// Пользователи других организаций для клиента не существуют.
+func (h *handler) user(w http.ResponseWriter, r *http.Request, id int64) (models.User, bool) {
+	user, err := h.users.UserByID(r.Context(), id)
+	if err == nil && user.TenantID != tenant(r) {
+		err = storage.ErrUserNotFound
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			writeError(w, http.StatusNotFound, "", "user not found")
+			return models.User{}, false
+		}
+
+		h.internalError(w, "failed to get user", err)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+func (h *handler) saveUser(w http.ResponseWriter, r *http.Request, user models.User) {
+	if err := h.users.UpdateUser(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			writeError(w, http.StatusConflict, errUniqueness, "user with this userName already exists")
+		case errors.Is(err, storage.ErrUserNotFound):
+			writeError(w, http.StatusNotFound, "", "user not found")
+		default:
+			h.internalError(w, "failed to update user", err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserResource(r, user))
+}
+
+// applyUserPatch применяет одну операцию PATCH к пользователю
+func applyUserPatch(user *models.User, op patchOperation) error {
+	if op.Op != "add" && op.Op != "replace" && op.Op != "remove" {
+		return badRequest(errInvalidSyntax, "unsupported patch operation: "+op.Op)
+	}
+
+	// Без path значение - объект с заменяемыми атрибутами
+	if op.Path == "" {
+		if op.Op == "remove" {
+			return badRequest(errInvalidPath, "path is required for remove operation")
+		}
+
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return badRequest(errInvalidValue, "value must be an object")
+		}
+
+		for path, value := range attrs {
+			if err := applyUserPatch(user, patchOperation{Op: op.Op, Path: path, Value: value}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	switch strings.ToLower(op.Path) {
+	case "active":
+		if op.Op == "remove" {
+			return badRequest(errInvalidPath, "active can't be removed")
+		}
+
+		active, err := boolValue(op.Value)
+		if err != nil {
+			return err
+		}
+		user.Disabled = !active
+	case "username":
+		if op.Op == "remove" {
+			return badRequest(errInvalidPath, "userName can't be removed")
+		}
+
+		var userName string
+		if err := json.Unmarshal(op.Value, &userName); err != nil || strings.TrimSpace(userName) == "" {
+			return badRequest(errInvalidValue, "userName must be a non-empty string")
+		}
+		user.Email = strings.TrimSpace(userName)
+	case "externalid":
+		if op.Op == "remove" {
+			user.ExternalID = ""
+			return nil
+		}
+
+		var externalID string
+		if err := json.Unmarshal(op.Value, &externalID); err != nil {
+			return badRequest(errInvalidValue, "externalId must be a string")
+		}
+		user.ExternalID = externalID
+	default:
+		return badRequest(errInvalidPath, "unsupported path: "+op.Path)
+	}
+
+	return nil
+}
+
+// boolValue разбирает булево значение. Некоторые клиенты (например, Azure AD)
+// присылают его строкой: "True" / "False".
+func boolValue(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+
+	return false, badRequest(errInvalidValue, "value must be a boolean")
+}
@@ -0,0 +1,26 @@
+// internal/lib/bearer/bearer.go
+package bearer
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// FromIncomingContext достаёт токен вызывающего из метаданных gRPC-запроса
+// ("authorization: Bearer <token>")
+func FromIncomingContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, v := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok && token != "" {
+			return token, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,208 @@
+// internal/lib/dataexport/dataexport.go
+package dataexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+)
+
+// Выгрузка персональных данных пользователя (запрос субъекта данных, GDPR).
+// Архив - JSON-документ со строкой users и всеми записями, связанными с пользователем по ID
+// (см. models.UserData). Формат общий для RPC Auth/ExportMyData и ssoctl export-user-data.
+// Хэш пароля в архив не попадает: вместо него флаг has_password.
+
+// Format версия формата архива; меняется при несовместимых изменениях
+const Format = "sso-user-data/v1"
+
+// UserDataProvider источник данных пользователя (реализован хранилищем)
+type UserDataProvider interface {
+	UserData(ctx context.Context, userID int64) (models.UserData, error)
+}
+
+// Archive выгрузка данных пользователя
+type Archive struct {
+	Format      string            `json:"format"`
+	ExportedAt  time.Time         `json:"exported_at"`
+	User        User              `json:"user"`
+	Attributes  map[string]string `json:"attributes"`
+	Identities  []Identity        `json:"federated_identities"`
+	Groups      []Group           `json:"groups"`
+	Roles       []Role            `json:"roles"`
+	Apps        []int             `json:"app_memberships"`
+	Invitations []Invitation      `json:"invitations"`
+	Policies    []Policy          `json:"policies"`
+	AuditLog    []AuditEvent      `json:"audit_log"`
+}
+
+type User struct {
+	ID          int64      `json:"id"`
+	TenantID    int64      `json:"tenant_id"`
+	Email       string     `json:"email"`
+	ExternalID  string     `json:"external_id,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	IsAdmin     bool       `json:"is_admin"`
+	HasPassword bool       `json:"has_password"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Identity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+type Group struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id,omitempty"`
+	IsAdmin    bool   `json:"is_admin"`
+}
+
+type Role struct {
+	ID    int64  `json:"id"`
+	AppID int    `json:"app_id"`
+	Name  string `json:"name"`
+}
+
+type Invitation struct {
+	ID         int64      `json:"id"`
+	AppID      int        `json:"app_id"`
+	Email      string     `json:"email"`
+	RoleID     int64      `json:"role_id,omitempty"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedBy int64      `json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Status     string     `json:"status"`
+}
+
+type Policy struct {
+	AppID     int       `json:"app_id"`
+	Version   int       `json:"version"`
+	Document  string    `json:"document"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	ActorID   int64     `json:"actor_id"`
+	Action    string    `json:"action"`
+	TargetID  int64     `json:"target_id"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Export collects the data of the user and returns it as a JSON archive.
+func Export(ctx context.Context, provider UserDataProvider, userID int64) ([]byte, error) {
+	const op = "dataexport.Export"
+
+	data, err := provider.UserData(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	archive, err := json.MarshalIndent(New(data, time.Now()), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return archive, nil
+}
+
+// New builds the archive of the user data exported at the moment exportedAt.
+func New(data models.UserData, exportedAt time.Time) Archive {
+	archive := Archive{
+		Format:     Format,
+		ExportedAt: exportedAt.UTC(),
+		User: User{
+			ID:          data.User.ID,
+			TenantID:    data.User.TenantID,
+			Email:       data.User.Email,
+			ExternalID:  data.User.ExternalID,
+			Disabled:    data.User.Disabled,
+			IsAdmin:     data.IsAdmin,
+			HasPassword: len(data.User.PassHash) > 0,
+			DeletedAt:   optionalTime(data.DeletedAt),
+		},
+		Attributes: data.Attributes,
+		// пустые списки - [], а не null: так проще читать архив
+		Identities:  make([]Identity, 0, len(data.Identities)),
+		Groups:      make([]Group, 0, len(data.Groups)),
+		Roles:       make([]Role, 0, len(data.Roles)),
+		Apps:        make([]int, 0, len(data.Apps)),
+		Invitations: make([]Invitation, 0, len(data.Invitations)),
+		Policies:    make([]Policy, 0, len(data.Policies)),
+		AuditLog:    make([]AuditEvent, 0, len(data.Audit)),
+	}
+	if archive.Attributes == nil {
+		archive.Attributes = make(map[string]string)
+	}
+
+	for _, identity := range data.Identities {
+		archive.Identities = append(archive.Identities, Identity{Provider: identity.Provider, Subject: identity.Subject})
+	}
+	for _, group := range data.Groups {
+		archive.Groups = append(archive.Groups, Group{
+			ID:         group.ID,
+			Name:       group.Name,
+			ExternalID: group.ExternalID,
+			IsAdmin:    group.IsAdmin,
+		})
+	}
+	for _, role := range data.Roles {
+		archive.Roles = append(archive.Roles, Role{ID: role.ID, AppID: role.AppID, Name: role.Name})
+	}
+	archive.Apps = append(archive.Apps, data.Apps...)
+	for _, inv := range data.Invitations {
+		archive.Invitations = append(archive.Invitations, Invitation{
+			ID:         inv.ID,
+			AppID:      inv.AppID,
+			Email:      inv.Email,
+			RoleID:     inv.RoleID,
+			CreatedBy:  inv.CreatedBy,
+			CreatedAt:  inv.CreatedAt.UTC(),
+			ExpiresAt:  inv.ExpiresAt.UTC(),
+			AcceptedBy: inv.AcceptedBy,
+			AcceptedAt: optionalTime(inv.AcceptedAt),
+			RevokedAt:  optionalTime(inv.RevokedAt),
+			Status:     inv.Status(exportedAt),
+		})
+	}
+	for _, p := range data.Policies {
+		archive.Policies = append(archive.Policies, Policy{
+			AppID:     p.AppID,
+			Version:   p.Version,
+			Document:  p.Document,
+			CreatedAt: p.CreatedAt.UTC(),
+		})
+	}
+	for _, event := range data.Audit {
+		archive.AuditLog = append(archive.AuditLog, AuditEvent{
+			ID:        event.ID,
+			ActorID:   event.ActorID,
+			Action:    event.Action,
+			TargetID:  event.TargetID,
+			OldValue:  event.OldValue,
+			NewValue:  event.NewValue,
+			CreatedAt: event.CreatedAt.UTC(),
+		})
+	}
+
+	return archive
+}
+
+// optionalTime nil для нулевого времени, чтобы поле не попадало в JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+
+	return &t
+}
@@ -0,0 +1,80 @@
+package dataexport
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/memory"
+)
+
+func TestExport(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+
+	userID, err := st.SaveUser(ctx, models.DefaultTenantID, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, st.UpdateAdminStatus(ctx, models.DefaultTenantID, models.SystemActorID, userID, true))
+	require.NoError(t, st.SetUserAttributes(ctx, models.DefaultTenantID, userID, userID, map[string]string{"department": "sales"}))
+
+	raw, err := Export(ctx, st, userID)
+	require.NoError(t, err)
+
+	// хэш пароля в архив не попадает
+	assert.NotContains(t, string(raw), `"hash"`)
+	assert.NotContains(t, string(raw), "pass_hash")
+
+	var archive Archive
+	require.NoError(t, json.Unmarshal(raw, &archive))
+	assert.Equal(t, Format, archive.Format)
+	assert.Equal(t, User{
+		ID:          userID,
+		TenantID:    models.DefaultTenantID,
+		Email:       "user@example.com",
+		IsAdmin:     true,
+		HasPassword: true,
+	}, archive.User)
+	assert.Equal(t, map[string]string{"department": "sales"}, archive.Attributes)
+	assert.Empty(t, archive.Roles)
+	require.Len(t, archive.AuditLog, 2)
+	assert.Equal(t, models.AuditActionSetAdmin, archive.AuditLog[0].Action)
+	assert.Equal(t, models.AuditActionSetUserAttributes, archive.AuditLog[1].Action)
+
+	// пустые списки выгружаются как [], а не null
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &fields))
+	assert.JSONEq(t, `[]`, string(fields["roles"]))
+
+	_, err = Export(ctx, st, userID+1)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func TestNew_Invitation(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	archive := New(models.UserData{
+		User: models.User{ID: 1, Email: "user@example.com"},
+		Invitations: []models.Invitation{{
+			ID:         7,
+			Email:      "user@example.com",
+			CreatedAt:  now.Add(-time.Hour),
+			ExpiresAt:  now.Add(time.Hour),
+			AcceptedBy: 1,
+			AcceptedAt: now.Add(-time.Minute),
+		}},
+	}, now)
+
+	require.Len(t, archive.Invitations, 1)
+	inv := archive.Invitations[0]
+	assert.Equal(t, models.InvitationAccepted, inv.Status)
+	require.NotNil(t, inv.AcceptedAt)
+	assert.Nil(t, inv.RevokedAt)
+	assert.Nil(t, archive.User.DeletedAt)
+	assert.False(t, archive.User.HasPassword)
+}
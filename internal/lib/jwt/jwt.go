@@ -2,23 +2,128 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"grpc-service-ref/internal/domain/models"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// NewToken creates new JWT token for given user app
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims, которые выставляет сам сервис: настройки токенов приложения не могут их задать
+var reservedClaims = []string{
+	"uid", "email", "exp", "app_id", "tenant_id", "roles", "scope", "act",
+	"iss", "sub", "aud", "iat", "nbf", "jti",
+}
+
+// Алгоритмы подписи, доступные приложениям (models.TokenAlg*)
+var signingMethods = map[string]jwt.SigningMethod{
+	models.TokenAlgHS256: jwt.SigningMethodHS256,
+	models.TokenAlgHS384: jwt.SigningMethodHS384,
+	models.TokenAlgHS512: jwt.SigningMethodHS512,
+}
+
+// IsReservedClaim reports whether the claim is set by the service itself
+func IsReservedClaim(name string) bool {
+	return slices.Contains(reservedClaims, name)
+}
+
+// ValidAlgorithm reports whether alg is one of models.TokenAlg*
+func ValidAlgorithm(alg string) bool {
+	_, ok := signingMethods[alg]
+	return ok
+}
+
+// signingMethod алгоритм подписи токенов приложения, по умолчанию HS256
+func signingMethod(app models.App) jwt.SigningMethod {
+	if method, ok := signingMethods[app.Tokens.Algorithm]; ok {
+		return method
+	}
+
+	return jwt.SigningMethodHS256
+}
+
+// Claims данные, извлечённые из проверенного токена
+type Claims struct {
+	UserID int64
+	Email  string
+	AppID  int
+	// TenantID - организация пользователя: email уникален только в её пределах
+	TenantID int64
+	Scopes   []string
+	// HasScope - в токене есть claim "scope" (токен получен обменом). Пустой scope
+	// значит "без прав", а не "без ограничений", поэтому отличаем его от отсутствия claim.
+	HasScope bool
+	// Roles - роли пользователя в приложении AppID на момент выдачи токена
+	Roles []string
+	// Actor - содержимое claim "act" (RFC 8693): кто действует от имени пользователя.
+	// Пустой, если токен выдан пользователю напрямую.
+	Actor map[string]any
+}
+
+// NewToken creates new JWT token for given user app.
+// roles - роли пользователя в этом приложении, они попадают в claim "roles".
+// attributes - атрибуты пользователя, из них в токен попадают указанные в настройках приложения.
+// Время жизни и алгоритм подписи берутся из настроек токенов приложения,
+// defaultTTL - время жизни для приложений, у которых оно не задано.
+func NewToken(
+	user models.User,
+	app models.App,
+	roles []string,
+	attributes map[string]string,
+	defaultTTL time.Duration,
+) (string, error) {
+	return NewTokenWithClaims(user, app, roles, attributes, defaultTTL, nil)
+}
+
+// NewTokenWithClaims creates new JWT token for given user and app with additional claims.
+// Дополнительные claims не могут перезаписать основные (uid, email, exp, app_id, tenant_id, roles),
+// а claims из настроек приложения - ни основные, ни дополнительные.
+func NewTokenWithClaims(
+	user models.User,
+	app models.App,
+	roles []string,
+	attributes map[string]string,
+	defaultTTL time.Duration,
+	extra map[string]any,
+) (string, error) {
+	duration := defaultTTL
+	if app.Tokens.AccessTTL > 0 {
+		duration = app.Tokens.AccessTTL
+	}
+
+	token := jwt.New(signingMethod(app))
 	//добавляем в токен всю необходимую информацию
 	claims := token.Claims.(jwt.MapClaims) //утверждение типа интерфейса. Проверямый тип - jwt.MapClaims, значение token.Claims. Это что-то типа преобразования типа
+	for k, v := range app.Tokens.StaticClaims {
+		claims[k] = v
+	}
+	for _, name := range app.Tokens.ClaimAttributes {
+		if value, ok := attributes[name]; ok {
+			claims[name] = value
+		}
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
 	claims["uid"] = user.ID
 	claims["email"] = user.Email
 	//В ней мы задаём срок действия (TTL) токена в виде конкретной временной метки, до которой он будет считаться валидным.
 	//После этого дедлайна токен будет считаться "протухшим", на стороне клиента мы его не будем принимать.
 	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.ID
+	claims["tenant_id"] = user.TenantID
+	// пустой список, а не null: получателю не нужно отличать "нет ролей" от "нет claim"
+	if roles == nil {
+		roles = []string{}
+	}
+	claims["roles"] = roles
 
 	//подписываем токен, используя секретный ключ приложения
 	tokenString, err := token.SignedString([]byte(app.Secret))
@@ -28,3 +133,119 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 
 	return tokenString, nil
 }
+
+// ParseToken проверяет подпись и срок действия токена и возвращает его содержимое.
+// Токен подписан секретом приложения, поэтому приложение ищется функцией appByID
+// по app_id из (ещё не проверенного) токена. Алгоритм подписи должен совпадать с настройками приложения,
+// а подпись проверяется каждым из действующих секретов по очереди:
+// после ротации секрета токены, подписанные прежним, какое-то время остаются действительными.
+func ParseToken(tokenString string, appByID func(appID int) (models.App, error)) (Claims, error) {
+	const op = "jwt.ParseToken"
+
+	// ошибку поиска приложения возвращаем как есть: вызывающий код сам решит,
+	// считать ли, например, неизвестное приложение невалидным токеном
+	var secretErr error
+	var secrets []string
+	var method jwt.SigningMethod
+	current := 0
+
+	keyFunc := func(token *jwt.Token) (any, error) {
+		if secrets == nil {
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				return nil, ErrInvalidToken
+			}
+
+			appID, ok := claims["app_id"].(float64)
+			if !ok {
+				return nil, ErrInvalidToken
+			}
+
+			app, err := appByID(int(appID))
+			if err != nil {
+				secretErr = err
+				return nil, err
+			}
+			secrets = app.VerificationSecrets(time.Now())
+			method = signingMethod(app)
+		}
+
+		// токен, подписанный не тем алгоритмом, что настроен у приложения, не принимаем
+		if token.Method.Alg() != method.Alg() {
+			return nil, ErrInvalidToken
+		}
+
+		return []byte(secrets[current]), nil
+	}
+
+	var token *jwt.Token
+	var err error
+	for {
+		token, err = jwt.Parse(tokenString, keyFunc, jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(), jwt.SigningMethodHS384.Alg(), jwt.SigningMethodHS512.Alg(),
+		}))
+		if errors.Is(err, jwt.ErrTokenSignatureInvalid) && current+1 < len(secrets) {
+			current++
+			continue
+		}
+		break
+	}
+	if secretErr != nil {
+		return Claims{}, fmt.Errorf("%s: %w", op, secretErr)
+	}
+	if err != nil {
+		// ошибки библиотеки (подпись, срок действия, формат) приводим к одной
+		if errors.Is(err, jwt.ErrTokenMalformed) ||
+			errors.Is(err, jwt.ErrTokenSignatureInvalid) ||
+			errors.Is(err, jwt.ErrTokenExpired) ||
+			errors.Is(err, jwt.ErrTokenNotValidYet) ||
+			errors.Is(err, jwt.ErrTokenUnverifiable) {
+			return Claims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+		}
+
+		return Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mc := token.Claims.(jwt.MapClaims)
+
+	// токены без срока действия мы не выдаём
+	if _, ok := mc["exp"]; !ok {
+		return Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	uid, ok := mc["uid"].(float64)
+	if !ok {
+		return Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	claims := Claims{
+		UserID: int64(uid),
+		AppID:  int(mc["app_id"].(float64)),
+	}
+	claims.Email, _ = mc["email"].(string)
+
+	// токены, выданные до появления организаций, относятся к организации по умолчанию
+	claims.TenantID = models.DefaultTenantID
+	if tenantID, ok := mc["tenant_id"].(float64); ok {
+		claims.TenantID = int64(tenantID)
+	}
+
+	if scope, ok := mc["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+		claims.HasScope = true
+	}
+
+	if roles, ok := mc["roles"].([]any); ok {
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				claims.Roles = append(claims.Roles, name)
+			}
+		}
+	}
+
+	if act, ok := mc["act"].(map[string]any); ok {
+		claims.Actor = act
+	}
+
+	return claims, nil
+}
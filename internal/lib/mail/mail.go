@@ -0,0 +1,52 @@
+// internal/lib/mail/mail.go
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message письмо пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string // Текст письма (text/plain)
+}
+
+// FileSender реализация отправки писем для разработки и тестов:
+// вместо отправки письмо сохраняется в каталог dir файлом <время>-<получатель>.eml,
+// который можно открыть любым почтовым клиентом.
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender returns a new instance of FileSender
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{dir: dir}
+}
+
+// Send writes the message to a file in the sender directory
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	const op = "mail.FileSender.Send"
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// получатель попадает в имя файла, поэтому убираем из него разделители путей
+	to := strings.NewReplacer("/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), to)
+
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, msg.Body)
+
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
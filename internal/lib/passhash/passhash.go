@@ -0,0 +1,322 @@
+// internal/lib/passhash/passhash.go
+package passhash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Проверка паролей по хэшам разных форматов.
+// Сервис сам хэширует пароли только bcrypt, остальные форматы приходят с пользователями,
+// импортированными из других систем, и заменяются на bcrypt при первом успешном входе.
+//
+// Поддерживаемые форматы:
+//   bcrypt  $2a$10$..., $2b$..., $2y$...
+//   argon2  $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash> (и $argon2i$), PHC
+//   scrypt  $scrypt$ln=16,r=8,p=1$<salt>$<hash>, PHC и passlib
+//   PBKDF2  pbkdf2_sha256$<iterations>$<salt>$<hash> (Django, также pbkdf2_sha1)
+//           $pbkdf2-sha256$<rounds>$<salt>$<hash> (passlib, также $pbkdf2$ и $pbkdf2-sha512$)
+
+var (
+	ErrMismatch      = errors.New("password does not match the hash")
+	ErrUnknownFormat = errors.New("unknown password hash format")
+	ErrMalformed     = errors.New("malformed password hash")
+)
+
+// Format формат хэша пароля
+type Format string
+
+const (
+	FormatBcrypt Format = "bcrypt"
+	FormatArgon2 Format = "argon2"
+	FormatScrypt Format = "scrypt"
+	FormatPBKDF2 Format = "pbkdf2"
+)
+
+// Ограничения параметров: хэш из чужой базы не должен заставить сервис
+// тратить на одну проверку пароля гигабайты памяти или минуты процессора
+const (
+	maxArgon2Memory = 1 << 21 // KiB, 2 GiB
+	maxArgon2Time   = 100
+	maxScryptLogN   = 22
+	maxPBKDF2Rounds = 10_000_000
+)
+
+// Identify returns the format of the hash and checks that its parameters are valid.
+// Пустой хэш (пользователь без пароля) - ErrUnknownFormat.
+func Identify(hash []byte) (Format, error) {
+	h, err := parse(hash)
+	if err != nil {
+		return "", err
+	}
+
+	return h.format, nil
+}
+
+// Verify checks the password against the hash of any supported format.
+// Неверный пароль - ErrMismatch.
+func Verify(hash []byte, password string) error {
+	h, err := parse(hash)
+	if err != nil {
+		return err
+	}
+
+	if !h.verify(password) {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether the hash should be replaced with bcrypt after a successful login
+func NeedsRehash(hash []byte) bool {
+	format, err := Identify(hash)
+
+	return err == nil && format != FormatBcrypt
+}
+
+// parsed разобранный хэш
+type parsed struct {
+	format Format
+	verify func(password string) bool
+}
+
+func parse(hash []byte) (parsed, error) {
+	s := string(hash)
+
+	switch {
+	case strings.HasPrefix(s, "$2a$"), strings.HasPrefix(s, "$2b$"), strings.HasPrefix(s, "$2y$"):
+		return parseBcrypt(hash)
+	case strings.HasPrefix(s, "$argon2"):
+		return parseArgon2(s)
+	case strings.HasPrefix(s, "$scrypt$"):
+		return parseScrypt(s)
+	case strings.HasPrefix(s, "pbkdf2_"):
+		return parseDjangoPBKDF2(s)
+	case strings.HasPrefix(s, "$pbkdf2"):
+		return parsePasslibPBKDF2(s)
+	default:
+		return parsed{}, ErrUnknownFormat
+	}
+}
+
+func parseBcrypt(hash []byte) (parsed, error) {
+	if _, err := bcrypt.Cost(hash); err != nil {
+		return parsed{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	return parsed{
+		format: FormatBcrypt,
+		verify: func(password string) bool {
+			return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+		},
+	}, nil
+}
+
+// parseArgon2 $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>; версию v= старые библиотеки не пишут
+func parseArgon2(s string) (parsed, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) == 5 {
+		parts = []string{parts[0], parts[1], "v=19", parts[2], parts[3], parts[4]}
+	}
+	if len(parts) != 6 {
+		return parsed{}, ErrMalformed
+	}
+
+	var key func(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte
+	switch parts[1] {
+	case "argon2id":
+		key = argon2.IDKey
+	case "argon2i":
+		key = argon2.Key
+	default:
+		// argon2d в golang.org/x/crypto нет
+		return parsed{}, ErrUnknownFormat
+	}
+
+	// golang.org/x/crypto реализует только версию 1.3 (0x13)
+	if parts[2] != "v=19" {
+		return parsed{}, fmt.Errorf("%w: unsupported argon2 version %q", ErrMalformed, parts[2])
+	}
+
+	params, err := parseParams(parts[3], "m", "t", "p")
+	if err != nil {
+		return parsed{}, err
+	}
+	memory, time, threads := params[0], params[1], params[2]
+	if memory > maxArgon2Memory || time > maxArgon2Time || threads > 255 {
+		return parsed{}, fmt.Errorf("%w: argon2 parameters are too large", ErrMalformed)
+	}
+
+	salt, want, err := decodeSaltAndHash(parts[4], parts[5])
+	if err != nil {
+		return parsed{}, err
+	}
+
+	return parsed{
+		format: FormatArgon2,
+		verify: func(password string) bool {
+			got := key([]byte(password), salt, uint32(time), uint32(memory), uint8(threads), uint32(len(want)))
+			return subtle.ConstantTimeCompare(got, want) == 1
+		},
+	}, nil
+}
+
+// parseScrypt $scrypt$ln=16,r=8,p=1$<salt>$<hash>, N = 2^ln
+func parseScrypt(s string) (parsed, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 5 {
+		return parsed{}, ErrMalformed
+	}
+
+	params, err := parseParams(parts[2], "ln", "r", "p")
+	if err != nil {
+		return parsed{}, err
+	}
+	logN, r, p := params[0], params[1], params[2]
+	if logN > maxScryptLogN || r*p >= 1<<30 {
+		return parsed{}, fmt.Errorf("%w: scrypt parameters are too large", ErrMalformed)
+	}
+
+	salt, want, err := decodeSaltAndHash(parts[3], parts[4])
+	if err != nil {
+		return parsed{}, err
+	}
+
+	return parsed{
+		format: FormatScrypt,
+		verify: func(password string) bool {
+			got, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, len(want))
+			return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+		},
+	}, nil
+}
+
+// parseDjangoPBKDF2 pbkdf2_sha256$<iterations>$<salt>$<hash>:
+// соль используется как есть, хэш - в стандартном base64
+func parseDjangoPBKDF2(s string) (parsed, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 {
+		return parsed{}, ErrMalformed
+	}
+
+	var newHash func() hash.Hash
+	switch parts[0] {
+	case "pbkdf2_sha256":
+		newHash = sha256.New
+	case "pbkdf2_sha1":
+		newHash = sha1.New
+	default:
+		return parsed{}, ErrUnknownFormat
+	}
+
+	want, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 || parts[2] == "" {
+		return parsed{}, ErrMalformed
+	}
+
+	return pbkdf2Hash(newHash, parts[1], []byte(parts[2]), want)
+}
+
+// parsePasslibPBKDF2 $pbkdf2-sha256$<rounds>$<salt>$<hash>: соль и хэш в base64 passlib
+func parsePasslibPBKDF2(s string) (parsed, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 5 {
+		return parsed{}, ErrMalformed
+	}
+
+	var newHash func() hash.Hash
+	switch parts[1] {
+	case "pbkdf2":
+		newHash = sha1.New
+	case "pbkdf2-sha256":
+		newHash = sha256.New
+	case "pbkdf2-sha512":
+		newHash = sha512.New
+	default:
+		return parsed{}, ErrUnknownFormat
+	}
+
+	salt, want, err := decodeSaltAndHash(parts[3], parts[4])
+	if err != nil {
+		return parsed{}, err
+	}
+
+	return pbkdf2Hash(newHash, parts[2], salt, want)
+}
+
+func pbkdf2Hash(newHash func() hash.Hash, rounds string, salt []byte, want []byte) (parsed, error) {
+	iter, err := strconv.Atoi(rounds)
+	if err != nil || iter <= 0 {
+		return parsed{}, fmt.Errorf("%w: invalid pbkdf2 rounds %q", ErrMalformed, rounds)
+	}
+	if iter > maxPBKDF2Rounds {
+		return parsed{}, fmt.Errorf("%w: pbkdf2 rounds are too large", ErrMalformed)
+	}
+
+	return parsed{
+		format: FormatPBKDF2,
+		verify: func(password string) bool {
+			got := pbkdf2.Key([]byte(password), salt, iter, len(want), newHash)
+			return subtle.ConstantTimeCompare(got, want) == 1
+		},
+	}, nil
+}
+
+// parseParams разбирает параметры вида m=65536,t=3,p=4 в заданном порядке; все должны быть > 0
+func parseParams(s string, names ...string) ([]int, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != len(names) {
+		return nil, fmt.Errorf("%w: invalid parameters %q", ErrMalformed, s)
+	}
+
+	values := make([]int, len(names))
+	for i, field := range fields {
+		value, ok := strings.CutPrefix(field, names[i]+"=")
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid parameters %q", ErrMalformed, s)
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: invalid parameters %q", ErrMalformed, s)
+		}
+		values[i] = n
+	}
+
+	return values, nil
+}
+
+func decodeSaltAndHash(salt string, hash string) ([]byte, []byte, error) {
+	saltBytes, err := decodeBase64(salt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid salt", ErrMalformed)
+	}
+
+	hashBytes, err := decodeBase64(hash)
+	if err != nil || len(hashBytes) == 0 {
+		return nil, nil, fmt.Errorf("%w: invalid hash", ErrMalformed)
+	}
+
+	return saltBytes, hashBytes, nil
+}
+
+// decodeBase64 base64 без паддинга (PHC) и его вариант из passlib, где вместо '+' используется '.'
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.ReplaceAll(s, ".", "+"), "=")
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
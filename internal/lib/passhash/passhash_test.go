@@ -0,0 +1,82 @@
+// internal/lib/passhash/passhash_test.go
+package passhash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Хэши scrypt и PBKDF2 получены hashlib из Python (в форматах Django и passlib),
+// argon2 - пример из документации argon2-cffi
+func TestVerify(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		format   Format
+	}{
+		{"bcrypt", string(bcryptHash), "correct horse", FormatBcrypt},
+		{"argon2id", "$argon2id$v=19$m=65536,t=3,p=4$MIIRqgvgQbgj220jfp0MPA$YfwJSVjtjSU0zzV/P3S9nnQ/USre2wvJMjfCIjrTQbg",
+			"correct horse battery staple", FormatArgon2},
+		{"scrypt", "$scrypt$ln=4,r=8,p=1$AQJzYWx0c2FsdP7/$rneSOTopoXfsuo0NB8Udum8X36bGsZpmy5T5C6wZ8iM",
+			"correct horse", FormatScrypt},
+		{"django pbkdf2_sha256", "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso=",
+			"correct horse", FormatPBKDF2},
+		{"django pbkdf2_sha1", "pbkdf2_sha1$1000$seasalt$iQvkNOF1wEL4Khh8eogJ8rUhipM=",
+			"correct horse", FormatPBKDF2},
+		{"passlib pbkdf2", "$pbkdf2$1000$AQJzYWx0c2FsdP7/$wk4Lzux23eOG1gnWCT0GxIjTZ6k",
+			"correct horse", FormatPBKDF2},
+		{"passlib pbkdf2-sha256", "$pbkdf2-sha256$1000$AQJzYWx0c2FsdP7/$qBrKfFubCKM0JC9S18Nb0jltR2yT7RGC2KPYa5CnBDE",
+			"correct horse", FormatPBKDF2},
+		{"passlib pbkdf2-sha512",
+			"$pbkdf2-sha512$1000$AQJzYWx0c2FsdP7/$5UWMi/h.o3ICkuVhCIBSQRDRAWi62rPpMCNsuVAVdAtCUQ6H/Kv6jHv2RxzbZpjhrjSRlSW9k3hC14jFyIWmGw",
+			"correct horse", FormatPBKDF2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := Identify([]byte(tt.hash))
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+
+			assert.NoError(t, Verify([]byte(tt.hash), tt.password))
+			assert.ErrorIs(t, Verify([]byte(tt.hash), tt.password+"!"), ErrMismatch)
+
+			assert.Equal(t, tt.format != FormatBcrypt, NeedsRehash([]byte(tt.hash)))
+		})
+	}
+}
+
+func TestIdentify_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+	}{
+		{"empty", "", ErrUnknownFormat},
+		{"plain text", "secret", ErrUnknownFormat},
+		{"md5 crypt", "$1$salt$hash", ErrUnknownFormat},
+		{"argon2d", "$argon2d$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA", ErrUnknownFormat},
+		{"truncated bcrypt", "$2a$10$abc", ErrMalformed},
+		{"argon2 params", "$argon2id$v=19$m=65536,p=4$c2FsdA$aGFzaA", ErrMalformed},
+		{"argon2 memory", "$argon2id$v=19$m=99999999,t=3,p=4$c2FsdA$aGFzaA", ErrMalformed},
+		{"scrypt cost", "$scrypt$ln=40,r=8,p=1$c2FsdA$aGFzaA", ErrMalformed},
+		{"scrypt base64", "$scrypt$ln=4,r=8,p=1$c2FsdA$!!!", ErrMalformed},
+		{"pbkdf2 rounds", "pbkdf2_sha256$abc$salt$aGFzaA==", ErrMalformed},
+		{"pbkdf2 hash", "pbkdf2_sha256$1000$salt$", ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Identify([]byte(tt.hash))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.False(t, NeedsRehash([]byte(tt.hash)))
+		})
+	}
+}
@@ -0,0 +1,450 @@
+// internal/lib/policy/policy.go
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Встроенный движок политик доступа (ABAC). Политика приложения - JSON-документ со списком правил:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "id": "managers-read-own-reports",
+//	      "effect": "allow",
+//	      "actions": ["reports:read"],
+//	      "conditions": [
+//	        {"attribute": "subject.roles", "op": "contains", "value": "manager"},
+//	        {"attribute": "resource.department", "op": "eq", "ref": "subject.department"},
+//	        {"attribute": "context.hour", "op": "gte", "value": 9},
+//	        {"attribute": "context.hour", "op": "lt", "value": 18}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Правило применяется к действию, если оно подходит под один из шаблонов actions (path.Match, "*" - любое),
+// и срабатывает, если выполнены все условия. Атрибуты адресуются как subject.<имя>, resource.<имя>
+// и context.<имя>; значение атрибута - список строк (у большинства атрибутов он из одного элемента).
+// Отсутствующий атрибут не удовлетворяет ни одному условию, кроме проверки "exists" со значением false.
+// Решения комбинируются по принципу deny-overrides: сработавшее правило deny запрещает действие,
+// иначе действие разрешено, если сработало хотя бы одно правило allow. По умолчанию - запрет.
+
+// Эффекты правил
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Операторы условий
+const (
+	OpEq       = "eq"       // одно из значений атрибута равно значению условия
+	OpNe       = "ne"       // ни одно из значений атрибута не равно значению условия
+	OpIn       = "in"       // одно из значений атрибута входит в список значений условия
+	OpNotIn    = "not_in"   // ни одно из значений атрибута не входит в список значений условия
+	OpContains = "contains" // атрибут содержит все значения условия
+	OpGt       = "gt"       // числовые сравнения первого значения атрибута со значением условия
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpExists   = "exists" // атрибут задан (значение условия true или false)
+)
+
+// Пространства имён атрибутов
+const (
+	Subject  = "subject"
+	Resource = "resource"
+	Context  = "context"
+)
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// ValidationError ошибка разбора политики с причиной, понятной автору политики
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return ErrInvalidPolicy.Error() + ": " + e.Reason
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidPolicy
+}
+
+// Attributes атрибуты субъекта, ресурса или контекста запроса
+type Attributes map[string][]string
+
+// Set sets the single value of the attribute.
+func (a Attributes) Set(name string, value string) {
+	a[name] = []string{value}
+}
+
+// Policy разобранная политика приложения
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule правило политики
+type Rule struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description,omitempty"`
+	Effect      string      `json:"effect"`
+	Actions     []string    `json:"actions"`
+	Conditions  []Condition `json:"conditions,omitempty"`
+}
+
+// Condition условие правила: сравнение атрибута со значением (Value) или с другим атрибутом (Ref)
+type Condition struct {
+	Attribute string  `json:"attribute"`
+	Op        string  `json:"op"`
+	Value     Operand `json:"value,omitempty"`
+	Ref       string  `json:"ref,omitempty"`
+}
+
+// Operand значение условия. В JSON может быть строкой, числом, булевым значением или массивом из них.
+type Operand []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *Operand) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	values := make([]string, 0, len(list))
+	for _, raw := range list {
+		value, err := scalar(raw)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+
+	*o = values
+
+	return nil
+}
+
+// scalar переводит строку, число или булево значение JSON в строку
+func scalar(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	raw = bytes.TrimSpace(raw)
+
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String(), nil
+	}
+
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return strconv.FormatBool(b), nil
+	}
+
+	return "", fmt.Errorf("unsupported value %s", raw)
+}
+
+// Request запрос на авторизацию: может ли субъект выполнить действие над ресурсом
+type Request struct {
+	Action   string
+	Subject  Attributes
+	Resource Attributes
+	Context  Attributes
+}
+
+// Decision решение движка с объяснением
+type Decision struct {
+	Allowed bool
+	// Правила, определившие решение: сработавшие deny при запрете или сработавшие allow при разрешении
+	Rules []string
+	// Объяснение по каждому правилу и итог, в порядке вычисления
+	Reasons []string
+}
+
+// Parse parses and validates the policy document. Ошибка разбора - *ValidationError.
+func Parse(document []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(document))
+	dec.DisallowUnknownFields()
+
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, &ValidationError{Reason: err.Error()}
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, &ValidationError{Reason: err.Error()}
+	}
+
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	ids := make(map[string]bool, len(p.Rules))
+
+	for i, rule := range p.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d: id is required", i+1)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %q: duplicate id", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return fmt.Errorf("rule %q: effect must be %q or %q", rule.ID, EffectAllow, EffectDeny)
+		}
+
+		if len(rule.Actions) == 0 {
+			return fmt.Errorf("rule %q: actions are required", rule.ID)
+		}
+		for _, action := range rule.Actions {
+			if _, err := path.Match(action, ""); err != nil || action == "" {
+				return fmt.Errorf("rule %q: invalid action pattern %q", rule.ID, action)
+			}
+		}
+
+		for j, cond := range rule.Conditions {
+			if err := cond.validate(); err != nil {
+				return fmt.Errorf("rule %q: condition %d: %v", rule.ID, j+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c Condition) validate() error {
+	if !validAttribute(c.Attribute) {
+		return fmt.Errorf("invalid attribute %q", c.Attribute)
+	}
+
+	if c.Ref != "" {
+		if !validAttribute(c.Ref) {
+			return fmt.Errorf("invalid ref %q", c.Ref)
+		}
+		if c.Value != nil {
+			return errors.New("value and ref are mutually exclusive")
+		}
+	}
+
+	switch c.Op {
+	case OpEq, OpNe, OpIn, OpNotIn, OpContains:
+		if c.Value == nil && c.Ref == "" {
+			return errors.New("value or ref is required")
+		}
+	case OpGt, OpGte, OpLt, OpLte:
+		if c.Ref != "" {
+			return nil
+		}
+		if len(c.Value) != 1 {
+			return errors.New("a single numeric value is required")
+		}
+		if _, err := strconv.ParseFloat(c.Value[0], 64); err != nil {
+			return fmt.Errorf("value %q is not a number", c.Value[0])
+		}
+	case OpExists:
+		if c.Ref != "" || len(c.Value) != 1 || (c.Value[0] != "true" && c.Value[0] != "false") {
+			return errors.New("value must be true or false")
+		}
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+
+	return nil
+}
+
+// validAttribute проверяет, что имя атрибута имеет вид <пространство имён>.<имя>
+func validAttribute(name string) bool {
+	ns, attr, ok := strings.Cut(name, ".")
+	if !ok || attr == "" {
+		return false
+	}
+
+	return ns == Subject || ns == Resource || ns == Context
+}
+
+// Evaluate evaluates the policy for the request.
+func (p *Policy) Evaluate(req Request) Decision {
+	var allowedBy, deniedBy []string
+	var reasons []string
+
+	for _, rule := range p.Rules {
+		if !rule.applies(req.Action) {
+			reasons = append(reasons, fmt.Sprintf("rule %q: not applicable to action %q", rule.ID, req.Action))
+			continue
+		}
+
+		if i, ok := rule.matches(req); !ok {
+			cond := rule.Conditions[i]
+			reasons = append(reasons, fmt.Sprintf("rule %q: condition %d (%s) not met", rule.ID, i+1, cond))
+			continue
+		}
+
+		reasons = append(reasons, fmt.Sprintf("rule %q: matched, effect %s", rule.ID, rule.Effect))
+		if rule.Effect == EffectDeny {
+			deniedBy = append(deniedBy, rule.ID)
+		} else {
+			allowedBy = append(allowedBy, rule.ID)
+		}
+	}
+
+	switch {
+	case len(deniedBy) > 0:
+		reasons = append(reasons, "denied by "+quoteAll(deniedBy))
+		return Decision{Allowed: false, Rules: deniedBy, Reasons: reasons}
+	case len(allowedBy) > 0:
+		reasons = append(reasons, "allowed by "+quoteAll(allowedBy))
+		return Decision{Allowed: true, Rules: allowedBy, Reasons: reasons}
+	}
+
+	reasons = append(reasons, "denied: no rule allows the action")
+
+	return Decision{Allowed: false, Reasons: reasons}
+}
+
+func (r Rule) applies(action string) bool {
+	for _, pattern := range r.Actions {
+		if ok, _ := path.Match(pattern, action); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches проверяет условия правила. Если условие не выполнено, возвращается его индекс.
+func (r Rule) matches(req Request) (int, bool) {
+	for i, cond := range r.Conditions {
+		if !cond.holds(req) {
+			return i, false
+		}
+	}
+
+	return 0, true
+}
+
+func (c Condition) holds(req Request) bool {
+	values, ok := req.lookup(c.Attribute)
+
+	operand := []string(c.Value)
+	if c.Ref != "" {
+		ref, ok := req.lookup(c.Ref)
+		if !ok {
+			return false
+		}
+		operand = ref
+	}
+
+	if c.Op == OpExists {
+		return ok == (operand[0] == "true")
+	}
+	if !ok {
+		return false
+	}
+
+	switch c.Op {
+	case OpEq, OpIn:
+		return intersects(values, operand)
+	case OpNe, OpNotIn:
+		return !intersects(values, operand)
+	case OpContains:
+		for _, v := range operand {
+			if !slices.Contains(values, v) {
+				return false
+			}
+		}
+		return true
+	case OpGt, OpGte, OpLt, OpLte:
+		return compare(c.Op, values, operand)
+	}
+
+	return false
+}
+
+// String returns the condition in a human-readable form, e.g. "resource.department eq subject.department".
+func (c Condition) String() string {
+	if c.Ref != "" {
+		return c.Attribute + " " + c.Op + " " + c.Ref
+	}
+
+	if len(c.Value) == 1 {
+		return c.Attribute + " " + c.Op + " " + strconv.Quote(c.Value[0])
+	}
+
+	return c.Attribute + " " + c.Op + " " + quoteAll(c.Value)
+}
+
+// lookup возвращает значения атрибута; пустой список считается отсутствующим атрибутом
+func (req Request) lookup(name string) ([]string, bool) {
+	ns, attr, _ := strings.Cut(name, ".")
+
+	var attrs Attributes
+	switch ns {
+	case Subject:
+		attrs = req.Subject
+	case Resource:
+		attrs = req.Resource
+	case Context:
+		attrs = req.Context
+	}
+
+	values := attrs[attr]
+
+	return values, len(values) > 0
+}
+
+func intersects(a []string, b []string) bool {
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compare сравнивает первые значения как числа; не числа не удовлетворяют условию
+func compare(op string, values []string, operand []string) bool {
+	a, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return false
+	}
+
+	b, err := strconv.ParseFloat(operand[0], 64)
+	if err != nil {
+		return false
+	}
+
+	switch op {
+	case OpGt:
+		return a > b
+	case OpGte:
+		return a >= b
+	case OpLt:
+		return a < b
+	case OpLte:
+		return a <= b
+	}
+
+	return false
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reportsPolicy = `{
+  "rules": [
+    {
+      "id": "managers-read-own-reports",
+      "effect": "allow",
+      "actions": ["reports:read"],
+      "conditions": [
+        {"attribute": "subject.roles", "op": "contains", "value": "manager"},
+        {"attribute": "resource.department", "op": "eq", "ref": "subject.department"},
+        {"attribute": "context.hour", "op": "gte", "value": 9},
+        {"attribute": "context.hour", "op": "lt", "value": 18}
+      ]
+    },
+    {
+      "id": "admins-everything",
+      "effect": "allow",
+      "actions": ["*"],
+      "conditions": [
+        {"attribute": "subject.groups", "op": "in", "value": ["admins", "security"]}
+      ]
+    },
+    {
+      "id": "no-archived",
+      "effect": "deny",
+      "actions": ["reports:*"],
+      "conditions": [
+        {"attribute": "resource.archived", "op": "eq", "value": true}
+      ]
+    }
+  ]
+}`
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse([]byte(reportsPolicy))
+	require.NoError(t, err)
+
+	manager := Attributes{"roles": {"manager", "employee"}, "department": {"sales"}}
+
+	tests := []struct {
+		name    string
+		req     Request
+		allowed bool
+		rules   []string
+	}{
+		{
+			name: "Manager reads own department during business hours",
+			req: Request{
+				Action:   "reports:read",
+				Subject:  manager,
+				Resource: Attributes{"department": {"sales"}},
+				Context:  Attributes{"hour": {"10"}},
+			},
+			allowed: true,
+			rules:   []string{"managers-read-own-reports"},
+		},
+		{
+			name: "Manager reads other department",
+			req: Request{
+				Action:   "reports:read",
+				Subject:  manager,
+				Resource: Attributes{"department": {"hr"}},
+				Context:  Attributes{"hour": {"10"}},
+			},
+		},
+		{
+			name: "Manager reads after hours",
+			req: Request{
+				Action:   "reports:read",
+				Subject:  manager,
+				Resource: Attributes{"department": {"sales"}},
+				Context:  Attributes{"hour": {"18"}},
+			},
+		},
+		{
+			name: "Manager writes",
+			req: Request{
+				Action:   "reports:write",
+				Subject:  manager,
+				Resource: Attributes{"department": {"sales"}},
+				Context:  Attributes{"hour": {"10"}},
+			},
+		},
+		{
+			name: "Subject without department",
+			req: Request{
+				Action:   "reports:read",
+				Subject:  Attributes{"roles": {"manager"}},
+				Resource: Attributes{"department": {"sales"}},
+				Context:  Attributes{"hour": {"10"}},
+			},
+		},
+		{
+			name: "Admin group",
+			req: Request{
+				Action:  "billing:refund",
+				Subject: Attributes{"groups": {"security"}},
+			},
+			allowed: true,
+			rules:   []string{"admins-everything"},
+		},
+		{
+			name: "Deny overrides allow",
+			req: Request{
+				Action:   "reports:read",
+				Subject:  Attributes{"groups": {"admins"}},
+				Resource: Attributes{"archived": {"true"}},
+			},
+			rules: []string{"no-archived"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := p.Evaluate(tt.req)
+
+			assert.Equal(t, tt.allowed, decision.Allowed)
+			assert.Equal(t, tt.rules, decision.Rules)
+			// по строке объяснения на каждое правило и итог
+			assert.Len(t, decision.Reasons, len(p.Rules)+1)
+		})
+	}
+}
+
+func TestEvaluate_Reasons(t *testing.T) {
+	p, err := Parse([]byte(reportsPolicy))
+	require.NoError(t, err)
+
+	decision := p.Evaluate(Request{
+		Action:   "reports:read",
+		Subject:  Attributes{"roles": {"manager"}, "department": {"sales"}},
+		Resource: Attributes{"department": {"hr"}},
+		Context:  Attributes{"hour": {"10"}},
+	})
+
+	assert.Equal(t, []string{
+		`rule "managers-read-own-reports": condition 2 (resource.department eq subject.department) not met`,
+		`rule "admins-everything": condition 1 (subject.groups in ["admins", "security"]) not met`,
+		`rule "no-archived": condition 1 (resource.archived eq "true") not met`,
+		"denied: no rule allows the action",
+	}, decision.Reasons)
+}
+
+func TestCondition_Operators(t *testing.T) {
+	req := Request{
+		Subject: Attributes{"level": {"5"}, "tags": {"a", "b"}, "name": {"x"}},
+	}
+
+	tests := []struct {
+		cond  Condition
+		holds bool
+	}{
+		{Condition{Attribute: "subject.level", Op: OpGt, Value: Operand{"4"}}, true},
+		{Condition{Attribute: "subject.level", Op: OpLte, Value: Operand{"4.5"}}, false},
+		{Condition{Attribute: "subject.name", Op: OpGt, Value: Operand{"1"}}, false},
+		{Condition{Attribute: "subject.tags", Op: OpContains, Value: Operand{"a", "b"}}, true},
+		{Condition{Attribute: "subject.tags", Op: OpContains, Value: Operand{"a", "c"}}, false},
+		{Condition{Attribute: "subject.name", Op: OpNe, Value: Operand{"y"}}, true},
+		{Condition{Attribute: "subject.tags", Op: OpNotIn, Value: Operand{"b", "c"}}, false},
+		{Condition{Attribute: "subject.missing", Op: OpNe, Value: Operand{"y"}}, false},
+		{Condition{Attribute: "subject.missing", Op: OpExists, Value: Operand{"false"}}, true},
+		{Condition{Attribute: "subject.name", Op: OpExists, Value: Operand{"true"}}, true},
+		{Condition{Attribute: "subject.name", Op: OpEq, Ref: "subject.missing"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cond.String(), func(t *testing.T) {
+			assert.Equal(t, tt.holds, tt.cond.holds(req))
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "Not JSON", document: `rules`},
+		{name: "Unknown field", document: `{"rules": [], "version": 2}`},
+		{name: "Missing id", document: `{"rules": [{"effect": "allow", "actions": ["a"]}]}`},
+		{
+			name:     "Duplicate id",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"]}, {"id": "r", "effect": "deny", "actions": ["a"]}]}`,
+		},
+		{name: "Unknown effect", document: `{"rules": [{"id": "r", "effect": "maybe", "actions": ["a"]}]}`},
+		{name: "No actions", document: `{"rules": [{"id": "r", "effect": "allow"}]}`},
+		{name: "Bad action pattern", document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["[a"]}]}`},
+		{
+			name:     "Unknown namespace",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "user.x", "op": "eq", "value": "1"}]}]}`,
+		},
+		{
+			name:     "Unknown op",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "subject.x", "op": "like", "value": "1"}]}]}`,
+		},
+		{
+			name:     "Missing value",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "subject.x", "op": "eq"}]}]}`,
+		},
+		{
+			name:     "Value and ref",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "subject.x", "op": "eq", "value": "1", "ref": "resource.x"}]}]}`,
+		},
+		{
+			name:     "Not a number",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "subject.x", "op": "gt", "value": "ten"}]}]}`,
+		},
+		{
+			name:     "Object value",
+			document: `{"rules": [{"id": "r", "effect": "allow", "actions": ["a"], "conditions": [{"attribute": "subject.x", "op": "eq", "value": {"a": 1}}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.document))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidPolicy))
+		})
+	}
+}
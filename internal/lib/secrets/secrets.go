@@ -0,0 +1,175 @@
+// internal/lib/secrets/secrets.go
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Шифрование секретов приложений (envelope encryption).
+// Каждый секрет шифруется собственным случайным ключом данных (AES-256-GCM), а ключ данных -
+// мастер-ключом из конфига. Рядом с зашифрованным секретом хранится ID мастер-ключа,
+// поэтому мастер-ключ можно сменить: старые ключи остаются в конфиге, пока секреты
+// не перешифрованы новым активным ключом.
+
+var (
+	ErrUnknownKey    = errors.New("unknown master key")
+	ErrInvalidSecret = errors.New("invalid encrypted secret")
+)
+
+const keySize = 32 // AES-256
+
+// Keyring набор мастер-ключей по ID. Новые секреты шифруются активным ключом.
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewKeyring returns a keyring of base64-encoded 32-byte master keys.
+// active - ID ключа, которым шифруются новые секреты, он должен быть среди keys.
+func NewKeyring(keys map[string]string, active string) (*Keyring, error) {
+	const op = "secrets.NewKeyring"
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no master keys", op)
+	}
+
+	k := &Keyring{keys: make(map[string][]byte, len(keys)), active: active}
+	for id, encoded := range keys {
+		if id == "" {
+			return nil, fmt.Errorf("%s: empty master key id", op)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: master key %q: %w", op, id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("%s: master key %q must be %d bytes", op, id, keySize)
+		}
+
+		k.keys[id] = key
+	}
+
+	if _, ok := k.keys[active]; !ok {
+		return nil, fmt.Errorf("%s: active master key %q: %w", op, active, ErrUnknownKey)
+	}
+
+	return k, nil
+}
+
+// ActiveKeyID returns ID of the master key used for new secrets.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Seal encrypts the secret with a new data key wrapped by the active master key.
+// Возвращает зашифрованный секрет и ID мастер-ключа, который нужно сохранить вместе с ним.
+func (k *Keyring) Seal(secret string) (string, string, error) {
+	const op = "secrets.Keyring.Seal"
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// ID мастер-ключа входит в аутентифицируемые данные: подменить его в строке базы нельзя
+	wrapped, err := encrypt(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	ciphertext, err := encrypt(dataKey, []byte(secret), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed := base64.RawURLEncoding.EncodeToString(wrapped) + "." + base64.RawURLEncoding.EncodeToString(ciphertext)
+
+	return sealed, k.active, nil
+}
+
+// Open decrypts the secret sealed with the master key keyID.
+func (k *Keyring) Open(sealed string, keyID string) (string, error) {
+	const op = "secrets.Keyring.Open"
+
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%s: %q: %w", op, keyID, ErrUnknownKey)
+	}
+
+	encodedKey, encodedSecret, ok := strings.Cut(sealed, ".")
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidSecret)
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidSecret)
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(encodedSecret)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidSecret)
+	}
+
+	dataKey, err := decrypt(masterKey, wrapped, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := decrypt(dataKey, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return string(secret), nil
+}
+
+// encrypt шифрует AES-GCM, nonce записывается перед шифротекстом
+func encrypt(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrInvalidSecret
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	key1 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", keySize)))
+	key2 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", keySize)))
+)
+
+func TestSealOpen(t *testing.T) {
+	keyring, err := NewKeyring(map[string]string{"k1": key1, "k2": key2}, "k1")
+	require.NoError(t, err)
+
+	sealed, keyID, err := keyring.Seal("app-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyID)
+	assert.NotContains(t, sealed, "app-secret")
+
+	// каждый раз новый ключ данных и nonce
+	again, _, err := keyring.Seal("app-secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+
+	secret, err := keyring.Open(sealed, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, "app-secret", secret)
+
+	// секрет нельзя расшифровать чужим ключом, даже подменив ID ключа
+	_, err = keyring.Open(sealed, "k2")
+	require.ErrorIs(t, err, ErrInvalidSecret)
+
+	_, err = keyring.Open(sealed, "k3")
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = keyring.Open(sealed[:len(sealed)-2]+"AA", keyID)
+	require.ErrorIs(t, err, ErrInvalidSecret)
+
+	_, err = keyring.Open("not-sealed", keyID)
+	require.ErrorIs(t, err, ErrInvalidSecret)
+
+	// после смены активного ключа старые секреты по-прежнему расшифровываются
+	rotated, err := NewKeyring(map[string]string{"k1": key1, "k2": key2}, "k2")
+	require.NoError(t, err)
+
+	secret, err = rotated.Open(sealed, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, "app-secret", secret)
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   map[string]string
+		active string
+	}{
+		{name: "No keys", active: "k1"},
+		{name: "Unknown active key", keys: map[string]string{"k1": key1}, active: "k2"},
+		{name: "Not base64", keys: map[string]string{"k1": "not base64!"}, active: "k1"},
+		{name: "Short key", keys: map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}, active: "k1"},
+		{name: "Empty key id", keys: map[string]string{"": key1}, active: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.keys, tt.active)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,427 @@
+// internal/services/apps/apps.go
+package apps
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/jwt"
+	"grpc-service-ref/internal/lib/logger/sl"
+)
+
+// Сервис управления приложениями: создание, изменение, отключение и удаление,
+// а также доступ к ним (режим доступа и участники).
+// Управлять приложениями может только администратор и только приложениями своей организации.
+
+var (
+	ErrInvalidAccessMode    = errors.New("invalid access mode")
+	ErrInvalidApp           = errors.New("invalid app")
+	ErrInvalidTokenSettings = errors.New("invalid token settings")
+)
+
+// Размер страницы списка приложений
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// Максимальное время жизни токенов приложения
+const maxAccessTokenTTL = 7 * 24 * time.Hour
+
+// Имена атрибутов пользователя (как в сервисе policies)
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AdminAuthorizer проверяет, что токен вызывающего принадлежит администратору,
+// и возвращает его (реализован сервисом auth)
+type AdminAuthorizer interface {
+	AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error)
+}
+
+// AppStorage интерфейс хранилища приложений.
+// Изменения ограничены организацией tenantID: чужие приложения и пользователи для неё не существуют.
+type AppStorage interface {
+	SaveApp(ctx context.Context, tenantID int64, actorID int64, app models.App) (int, error)
+	Apps(ctx context.Context, tenantID int64, offset int, limit int) ([]models.App, int, error)
+	UpdateApp(ctx context.Context, tenantID int64, actorID int64, app models.App) error
+	SetAppDisabled(ctx context.Context, tenantID int64, actorID int64, appID int, disabled bool) error
+	DeleteApp(ctx context.Context, tenantID int64, actorID int64, appID int) error
+	RotateAppSecret(ctx context.Context, tenantID int64, actorID int64, appID int, secret string, previousExpiresAt time.Time) error
+	SetAppTokenSettings(ctx context.Context, tenantID int64, actorID int64, appID int, settings models.TokenSettings) error
+	SetAppAccess(ctx context.Context, tenantID int64, actorID int64, appID int, mode string, domains []string) error
+	AddAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error
+	RemoveAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error
+	AppMembers(ctx context.Context, tenantID int64, appID int) ([]int64, error)
+}
+
+// Apps структура сервиса приложений
+type Apps struct {
+	log     *slog.Logger
+	admins  AdminAuthorizer
+	storage AppStorage
+	// Сколько после ротации принимается прежний секрет приложения
+	secretGracePeriod time.Duration
+}
+
+// New returns a new instance of Apps service
+func New(log *slog.Logger, admins AdminAuthorizer, storage AppStorage, secretGracePeriod time.Duration) *Apps {
+	return &Apps{
+		log:               log,
+		admins:            admins,
+		storage:           storage,
+		secretGracePeriod: secretGracePeriod,
+	}
+}
+
+// Create creates an app in the caller's organization with a generated secret.
+// Секрет возвращается только здесь: получить его позже нельзя.
+func (a *Apps) Create(ctx context.Context, callerToken string, app models.App) (models.App, error) {
+	const op = "Apps.Create"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", app.Name),
+	)
+
+	app, err := normalizeApp(app)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	caller, err := a.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.Secret, err = newSecret()
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.TenantID = caller.TenantID
+	app.AccessMode = models.AppAccessOpen
+	app.Tokens = models.TokenSettings{Algorithm: models.TokenAlgHS256}
+
+	app.ID, err = a.storage.SaveApp(ctx, caller.TenantID, caller.ID, app)
+	if err != nil {
+		log.Error("failed to save app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app created", slog.Int("app_id", app.ID), slog.Int64("actor_id", caller.ID))
+
+	return app, nil
+}
+
+// List returns a page of the caller's organization apps and the total number of its apps.
+// Секреты приложений не возвращаются.
+func (a *Apps) List(ctx context.Context, callerToken string, offset int, limit int) ([]models.App, int, error) {
+	const op = "Apps.List"
+
+	caller, err := a.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+
+	apps, total, err := a.storage.Apps(ctx, caller.TenantID, max(offset, 0), limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range apps {
+		apps[i].Secret = ""
+		apps[i].PreviousSecret = ""
+	}
+
+	return apps, total, nil
+}
+
+// Update changes name, kind and SAML settings of the app.
+func (a *Apps) Update(ctx context.Context, callerToken string, app models.App) error {
+	const op = "Apps.Update"
+
+	app, err := normalizeApp(app)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.change(ctx, op, callerToken, app.ID,
+		func(caller models.User) error {
+			return a.storage.UpdateApp(ctx, caller.TenantID, caller.ID, app)
+		},
+		slog.String("name", app.Name),
+		slog.String("kind", app.Kind),
+	)
+}
+
+// SetDisabled disables or enables the app. В отключённое приложение нельзя войти,
+// а выданные для него токены перестают приниматься.
+func (a *Apps) SetDisabled(ctx context.Context, callerToken string, appID int, disabled bool) error {
+	return a.change(ctx, "Apps.SetDisabled", callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.SetAppDisabled(ctx, caller.TenantID, caller.ID, appID, disabled)
+		},
+		slog.Bool("disabled", disabled),
+	)
+}
+
+// Delete deletes the app with its roles, members, invitations and policies.
+func (a *Apps) Delete(ctx context.Context, callerToken string, appID int) error {
+	return a.change(ctx, "Apps.Delete", callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.DeleteApp(ctx, caller.TenantID, caller.ID, appID)
+		},
+	)
+}
+
+// RotateSecret issues a new secret for the app and returns it with the time until which
+// the previous secret remains valid. Новые токены сразу подписываются новым секретом, а токены,
+// подписанные прежним, принимаются до конца периода ротации - за это время приложение
+// должно перейти на новый секрет.
+func (a *Apps) RotateSecret(ctx context.Context, callerToken string, appID int) (string, time.Time, error) {
+	const op = "Apps.RotateSecret"
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	previousExpiresAt := time.Now().Add(a.secretGracePeriod)
+
+	err = a.change(ctx, op, callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.RotateAppSecret(ctx, caller.TenantID, caller.ID, appID, secret, previousExpiresAt)
+		},
+		slog.Time("previous_secret_expires_at", previousExpiresAt),
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return secret, previousExpiresAt, nil
+}
+
+// SetTokenSettings replaces the settings of tokens issued for the app: lifetime, signing algorithm
+// and additional claims. Настройки применяются к новым токенам; после смены алгоритма
+// ранее выданные токены приложения перестают приниматься.
+func (a *Apps) SetTokenSettings(ctx context.Context, callerToken string, appID int, settings models.TokenSettings) error {
+	const op = "Apps.SetTokenSettings"
+
+	settings, err := normalizeTokenSettings(settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.change(ctx, op, callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.SetAppTokenSettings(ctx, caller.TenantID, caller.ID, appID, settings)
+		},
+		slog.Duration("access_ttl", settings.AccessTTL),
+		slog.String("algorithm", settings.Algorithm),
+		slog.Any("claim_attributes", settings.ClaimAttributes),
+		slog.Any("static_claims", settings.StaticClaims),
+	)
+}
+
+// SetAccess changes the access mode and allowed email domains of the app.
+// Для режима models.AppAccessDomain нужен хотя бы один домен, для остальных домены не задаются.
+func (a *Apps) SetAccess(ctx context.Context, callerToken string, appID int, mode string, domains []string) error {
+	const op = "Apps.SetAccess"
+
+	domains, err := normalizeAccess(mode, domains)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.change(ctx, op, callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.SetAppAccess(ctx, caller.TenantID, caller.ID, appID, mode, domains)
+		},
+		slog.String("access_mode", mode),
+		slog.Any("allowed_domains", domains),
+	)
+}
+
+// AddMember allows the user to log into the app.
+func (a *Apps) AddMember(ctx context.Context, callerToken string, appID int, userID int64) error {
+	return a.change(ctx, "Apps.AddMember", callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.AddAppMember(ctx, caller.TenantID, caller.ID, appID, userID)
+		},
+		slog.Int64("user_id", userID),
+	)
+}
+
+// RemoveMember revokes app membership of the user.
+func (a *Apps) RemoveMember(ctx context.Context, callerToken string, appID int, userID int64) error {
+	return a.change(ctx, "Apps.RemoveMember", callerToken, appID,
+		func(caller models.User) error {
+			return a.storage.RemoveAppMember(ctx, caller.TenantID, caller.ID, appID, userID)
+		},
+		slog.Int64("user_id", userID),
+	)
+}
+
+// Members returns IDs of the app members.
+func (a *Apps) Members(ctx context.Context, callerToken string, appID int) ([]int64, error) {
+	const op = "Apps.Members"
+
+	caller, err := a.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := a.storage.AppMembers(ctx, caller.TenantID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// change проверяет, что вызывающий - администратор, и выполняет изменение приложения
+// в его организации. Изменения записываются хранилищем в журнал аудита от имени вызывающего.
+func (a *Apps) change(
+	ctx context.Context,
+	op string,
+	callerToken string,
+	appID int,
+	apply func(caller models.User) error,
+	attrs ...any,
+) error {
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	).With(attrs...)
+
+	caller, err := a.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("actor_id", caller.ID))
+
+	if err := apply(caller); err != nil {
+		log.Error("failed to change app", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app changed")
+
+	return nil
+}
+
+// normalizeAccess проверяет режим доступа и приводит домены к виду "example.com"
+func normalizeAccess(mode string, domains []string) ([]string, error) {
+	if !models.ValidAppAccessMode(mode) {
+		return nil, ErrInvalidAccessMode
+	}
+
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || strings.ContainsAny(d, " @") {
+			return nil, fmt.Errorf("%w: invalid domain %q", ErrInvalidAccessMode, d)
+		}
+		normalized = append(normalized, d)
+	}
+
+	if (mode == models.AppAccessDomain) != (len(normalized) > 0) {
+		return nil, fmt.Errorf("%w: allowed domains are required only for %q mode", ErrInvalidAccessMode, models.AppAccessDomain)
+	}
+
+	return normalized, nil
+}
+
+// normalizeApp проверяет имя, вид и SAML-настройки приложения.
+// У обычного приложения и приложения провижининга SAML-настройки сбрасываются,
+// у SAML-приложения entity ID и ACS URL обязательны.
+func normalizeApp(app models.App) (models.App, error) {
+	app.Name = strings.TrimSpace(app.Name)
+	if app.Name == "" {
+		return models.App{}, ErrInvalidApp
+	}
+
+	if app.Kind == "" {
+		app.Kind = models.AppKindJWT
+	}
+
+	switch app.Kind {
+	case models.AppKindJWT, models.AppKindSCIM:
+		app.SAML = models.SAMLServiceProvider{}
+	case models.AppKindSAML:
+		if app.SAML.EntityID == "" || app.SAML.ACSURL == "" {
+			return models.App{}, ErrInvalidApp
+		}
+		if _, err := url.ParseRequestURI(app.SAML.ACSURL); err != nil {
+			return models.App{}, ErrInvalidApp
+		}
+	default:
+		return models.App{}, ErrInvalidApp
+	}
+
+	return app, nil
+}
+
+// normalizeTokenSettings проверяет настройки токенов: время жизни в пределах maxAccessTokenTTL
+// с точностью до секунды, известный алгоритм и имена claims, не совпадающие со служебными и друг с другом.
+func normalizeTokenSettings(settings models.TokenSettings) (models.TokenSettings, error) {
+	if settings.AccessTTL < 0 || settings.AccessTTL > maxAccessTokenTTL || settings.AccessTTL%time.Second != 0 {
+		return models.TokenSettings{}, fmt.Errorf("%w: access TTL must be whole seconds up to %s",
+			ErrInvalidTokenSettings, maxAccessTokenTTL)
+	}
+
+	if settings.Algorithm == "" {
+		settings.Algorithm = models.TokenAlgHS256
+	}
+	if !jwt.ValidAlgorithm(settings.Algorithm) {
+		return models.TokenSettings{}, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidTokenSettings, settings.Algorithm)
+	}
+
+	attributes := make([]string, 0, len(settings.ClaimAttributes))
+	for _, name := range settings.ClaimAttributes {
+		if !attributeName.MatchString(name) || jwt.IsReservedClaim(name) {
+			return models.TokenSettings{}, fmt.Errorf("%w: invalid claim attribute %q", ErrInvalidTokenSettings, name)
+		}
+		if !slices.Contains(attributes, name) {
+			attributes = append(attributes, name)
+		}
+	}
+	settings.ClaimAttributes = attributes
+
+	for name := range settings.StaticClaims {
+		if name == "" || strings.ContainsFunc(name, unicode.IsSpace) || jwt.IsReservedClaim(name) ||
+			slices.Contains(attributes, name) {
+			return models.TokenSettings{}, fmt.Errorf("%w: invalid static claim %q", ErrInvalidTokenSettings, name)
+		}
+	}
+
+	return settings, nil
+}
+
+// newSecret генерирует секрет приложения, которым подписываются его токены
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -3,15 +3,20 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	//"google.golang.org/genproto/googleapis/storage/v1"
 
 	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/dataexport"
 	"grpc-service-ref/internal/lib/jwt"
 	"grpc-service-ref/internal/lib/logger/sl"
 	"grpc-service-ref/internal/storage"
@@ -25,21 +30,31 @@ type UserStorage interface {
 */
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrInvalidToken          = errors.New("invalid token")
+	ErrInvalidAppCredentials = errors.New("invalid app credentials")
+	ErrExchangeNotAllowed    = errors.New("token exchange not allowed")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrLastAdmin             = errors.New("can't demote the last admin")
+	ErrAppAccessDenied       = errors.New("app access denied")
+	ErrExportRateLimited     = errors.New("data export rate limited")
 )
 
 // UserSaver Интерфейс сохранения пользователя
 type UserSaver interface {
 	SaveUser(
 		ctx context.Context,
+		tenantID int64,
 		email string,
 		passHash []byte,
 	) (uid int64, err error)
 }
 
-// UserProvider Интерфейс получения пользователя
+// UserProvider Интерфейс получения пользователя.
+// Email уникален только в пределах организации, поэтому пользователь ищется в её каталоге.
 type UserProvider interface {
-	User(ctx context.Context, email string) (models.User, error)
+	User(ctx context.Context, tenantID int64, email string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
 
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
@@ -49,13 +64,66 @@ type AppProvider interface {
 	App(ctx context.Context, appID int) (models.App, error)
 }
 
+// ExchangePolicyProvider интерфейс для получения политик обмена токенов
+type ExchangePolicyProvider interface {
+	ExchangePolicy(ctx context.Context, sourceAppID int, targetAppID int) (models.ExchangePolicy, error)
+}
+
+// AdminManager интерфейс изменения статуса администратора с записью в журнал аудита
+type AdminManager interface {
+	UpdateAdminStatus(ctx context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error
+}
+
+// RoleProvider интерфейс получения ролей пользователя в приложении (попадают в токен)
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+}
+
+// AppMemberProvider интерфейс проверки участия пользователя в приложении
+// (нужен для приложений с ограниченным доступом)
+type AppMemberProvider interface {
+	IsAppMember(ctx context.Context, appID int, userID int64) (bool, error)
+}
+
+// AttributeProvider интерфейс получения атрибутов пользователя
+// (попадают в токен, если так настроено приложение)
+type AttributeProvider interface {
+	UserAttributes(ctx context.Context, userID int64) (map[string]string, error)
+}
+
+// UserDeleter интерфейс удаления пользователя с записью в журнал аудита.
+// Пользователь удаляется окончательно позже, по истечении срока хранения.
+type UserDeleter interface {
+	DeleteUser(ctx context.Context, tenantID int64, actorID int64, userID int64) error
+}
+
+// UserDataProvider интерфейс получения всех данных пользователя для их выгрузки
+type UserDataProvider interface {
+	UserData(ctx context.Context, userID int64) (models.UserData, error)
+}
+
 // Auth структура сервиса авторизации
 type Auth struct {
-	log         *slog.Logger
-	usrSaver    UserSaver
-	usrProvider UserProvider
-	appProvider AppProvider
-	tokenTTL    time.Duration
+	log              *slog.Logger
+	usrSaver         UserSaver
+	usrProvider      UserProvider
+	appProvider      AppProvider
+	exchangeProvider ExchangePolicyProvider
+	adminManager     AdminManager
+	roleProvider     RoleProvider
+	memberProvider   AppMemberProvider
+	attrProvider     AttributeProvider
+	usrDeleter       UserDeleter
+	dataProvider     UserDataProvider
+	verifier         CredentialVerifier
+	tokenTTL         time.Duration
+	// сколько хранятся данные удалённого пользователя до окончательного удаления
+	deletionRetention time.Duration
+	// пользователь выгружает свои данные не чаще раза в exportInterval
+	exportInterval time.Duration
+
+	exportMu    sync.Mutex
+	lastExports map[int64]time.Time // время последней выгрузки по ID пользователя
 }
 
 // New returns a new instane of Auth service
@@ -64,19 +132,48 @@ func New(
 	userSaver UserSaver,
 	userProvider UserProvider,
 	appProvider AppProvider,
+	exchangeProvider ExchangePolicyProvider,
+	adminManager AdminManager,
+	roleProvider RoleProvider,
+	memberProvider AppMemberProvider,
+	attrProvider AttributeProvider,
+	userDeleter UserDeleter,
+	dataProvider UserDataProvider,
+	verifier CredentialVerifier,
 	tokenTTL time.Duration,
+	deletionRetention time.Duration,
+	exportInterval time.Duration,
 ) *Auth {
+	// Если бэкенд проверки паролей не задан, проверяем по локальному хэшу
+	if verifier == nil {
+		verifier = NewLocalVerifier(log, userProvider, nil)
+	}
+
 	return &Auth{
-		log:         log,
-		usrSaver:    userSaver,
-		usrProvider: userProvider,
-		appProvider: appProvider,
-		tokenTTL:    tokenTTL, // Время жизни возвращаемых токенов
+		log:              log,
+		usrSaver:         userSaver,
+		usrProvider:      userProvider,
+		appProvider:      appProvider,
+		exchangeProvider: exchangeProvider,
+		adminManager:     adminManager,
+		roleProvider:     roleProvider,
+		memberProvider:   memberProvider,
+		attrProvider:     attrProvider,
+		usrDeleter:       userDeleter,
+		dataProvider:     dataProvider,
+		verifier:         verifier,
+		tokenTTL:         tokenTTL, // Время жизни токенов для приложений, у которых оно не задано
+
+		deletionRetention: deletionRetention,
+		exportInterval:    exportInterval,
+		lastExports:       make(map[int64]time.Time),
 	}
 }
 
 // RegisterNewUser Регистрация нового пользователя
-func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (int64, error) {
+// Пользователь регистрируется в организации приложения appID,
+// а если приложение не указано (0) - в организации по умолчанию.
+func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string, appID int) (int64, error) {
 	// op (operation) - имя текущей функции и пакета. Такую метку удобно
 	// добавлять в логи и в текст ошибок, чтобы легче было искать хвосты
 	// в случае поломок.
@@ -91,6 +188,16 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (
 
 	log.Info("registering user")
 
+	tenantID := models.DefaultTenantID
+	if appID != 0 {
+		app, err := a.appProvider.App(ctx, appID)
+		if err != nil {
+			log.Warn("failed to get app", sl.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		tenantID = app.TenantID
+	}
+
 	// Генерируем хэш и соль для пароля.
 	passHash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
 	if err != nil {
@@ -99,7 +206,7 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, pass string) (
 	}
 
 	// Сохраняем пользователя в БД
-	id, err := a.usrSaver.SaveUser(ctx, email, passHash)
+	id, err := a.usrSaver.SaveUser(ctx, tenantID, email, passHash)
 	if err != nil {
 		log.Error("failed to save user", sl.Err(err))
 
@@ -130,34 +237,49 @@ func (a *Auth) Login(
 
 	log.Info("attempting to login user")
 
-	//Достаем пользователя из БД
-	user, err := a.usrProvider.User(ctx, email)
+	//получаем информацию о приложении: от него зависит, в каталоге какой организации искать пользователя
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Проверяем пароль: локально по хэшу из БД или во внешнем бэкенде (например, LDAP),
+	// в зависимости от приложения и домена email
+	user, err := a.verifier.VerifyCredentials(ctx, email, password, app)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found", sl.Err(err))
+		if errors.Is(err, ErrInvalidCredentials) {
+			a.log.Info("invalid credentials", sl.Err(err))
 			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
-		a.log.Error("failed to get user", sl.Err(err))
+		a.log.Error("failed to verify credentials", sl.Err(err))
 
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	//провреяем корректность текущего пароля
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", sl.Err(err))
+	// Пароль верный, но в приложение с ограниченным доступом пускаем не всех
+	if err := a.checkAppAccess(ctx, app, user); err != nil {
+		log.Warn("app access denied", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	//получаем информацию о приложении
-	app, err := a.appProvider.App(ctx, appID)
+	// роли пользователя в приложении записываются в токен
+	roles, err := a.roleProvider.UserRoles(ctx, user.ID, app.ID)
 	if err != nil {
+		a.log.Error("failed to get user roles", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	attributes, err := a.claimAttributes(ctx, app, user.ID)
+	if err != nil {
+		a.log.Error("failed to get user attributes", sl.Err(err))
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("user logged in successfully")
 
 	//создаем токен авторизации
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	token, err := jwt.NewToken(user, app, roles, attributes, a.tokenTTL)
 
 	if err != nil {
 		a.log.Error("failed to generate token", sl.Err(err))
@@ -168,8 +290,9 @@ func (a *Auth) Login(
 
 }
 
-// IsAdmin checks if user is Admin
-func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+// IsAdmin checks whether the user of the caller's organization is admin.
+// Вызывающий передаёт свой токен; пользователи других организаций для него не существуют (storage.ErrUserNotFound).
+func (a *Auth) IsAdmin(ctx context.Context, callerToken string, userID int64) (bool, error) {
 	const op = "Auth.IsAdmin"
 	log := a.log.With(
 		slog.String("op", op),
@@ -178,6 +301,23 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	log.Info("checking user is admin")
 
+	caller, _, err := a.authenticate(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authenticated", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID != caller.ID {
+		user, err := a.usrProvider.UserByID(ctx, userID)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if user.TenantID != caller.TenantID {
+			log.Warn("user of another tenant requested", slog.Int64("actor_id", caller.ID))
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+	}
+
 	isAdmin, err := a.usrProvider.IsAdmin(ctx, userID)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
@@ -187,3 +327,445 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	return isAdmin, nil
 }
+
+// SetAdmin grants or revokes admin status of the user.
+// Вызывающий передаёт свой токен: менять статус может только действующий администратор
+// и только пользователям своей организации.
+// Изменение записывается в журнал аудита, последнего администратора разжаловать нельзя.
+func (a *Auth) SetAdmin(ctx context.Context, callerToken string, userID int64, isAdmin bool) error {
+	const op = "Auth.SetAdmin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Bool("is_admin", isAdmin),
+	)
+
+	caller, err := a.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("actor_id", caller.ID))
+
+	if err := a.adminManager.UpdateAdminStatus(ctx, caller.TenantID, caller.ID, userID, isAdmin); err != nil {
+		if errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("attempt to demote the last admin")
+			return fmt.Errorf("%s: %w", op, ErrLastAdmin)
+		}
+
+		log.Error("failed to update admin status", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("admin status changed")
+
+	return nil
+}
+
+// Authenticate checks the caller token and returns the active user it was issued to.
+// Пользователь ограничен своей организацией (caller.TenantID). Невалидный токен - ErrInvalidToken.
+func (a *Auth) Authenticate(ctx context.Context, callerToken string) (models.User, error) {
+	const op = "Auth.Authenticate"
+
+	caller, _, err := a.authenticate(ctx, callerToken)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return caller, nil
+}
+
+// AuthorizeAdmin checks that the caller token belongs to an active admin and returns the admin.
+// Токен мог пережить пользователя (или его отключение), поэтому пользователь перечитывается из хранилища.
+// Администратор управляет только своей организацией (caller.TenantID).
+// Возвращает ErrInvalidToken для невалидного токена и ErrPermissionDenied, если вызывающий не администратор.
+func (a *Auth) AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error) {
+	const op = "Auth.AuthorizeAdmin"
+
+	caller, _, err := a.authenticate(ctx, callerToken)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	isAdmin, err := a.usrProvider.IsAdmin(ctx, caller.ID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !isAdmin {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	return caller, nil
+}
+
+// authenticate возвращает действующего пользователя, которому выдан токен, и claims токена.
+// Токен мог пережить пользователя (или его отключение, удаление), поэтому пользователь перечитывается из хранилища.
+func (a *Auth) authenticate(ctx context.Context, token string) (models.User, jwt.Claims, error) {
+	claims, err := a.parseToken(ctx, token)
+	if err != nil {
+		return models.User{}, jwt.Claims{}, err
+	}
+
+	user, err := a.usrProvider.User(ctx, claims.TenantID, claims.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, jwt.Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+
+		return models.User{}, jwt.Claims{}, err
+	}
+	if user.ID != claims.UserID || user.Disabled {
+		return models.User{}, jwt.Claims{}, fmt.Errorf("%w: token user mismatch or disabled", ErrInvalidToken)
+	}
+
+	return user, claims, nil
+}
+
+// DeleteAccount deletes the account of the caller or, if the caller is an admin, of another user
+// of the caller's organization, and returns when the account data will be purged permanently.
+// userID 0 - своя учётная запись. Свою учётную запись пользователь удаляет, повторно вводя пароль,
+// чтобы её нельзя было удалить одним украденным токеном; неверный пароль - ErrInvalidCredentials.
+// Удалённый пользователь сразу перестаёт существовать для сервиса, а его данные удаляются
+// окончательно через deletionRetention (см. app/purge). Последнего администратора удалить нельзя.
+func (a *Auth) DeleteAccount(ctx context.Context, callerToken string, userID int64, password string) (time.Time, error) {
+	const op = "Auth.DeleteAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	caller, claims, err := a.authenticate(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authenticated", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("actor_id", caller.ID))
+
+	if userID == 0 || userID == caller.ID {
+		userID = caller.ID
+
+		// пароль проверяется так же, как при входе в приложение, для которого выдан токен
+		app, err := a.appProvider.App(ctx, claims.AppID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		user, err := a.verifier.VerifyCredentials(ctx, caller.Email, password, app)
+		if err != nil {
+			if errors.Is(err, ErrInvalidCredentials) {
+				log.Warn("invalid password")
+				return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			}
+
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if user.ID != caller.ID {
+			log.Warn("password belongs to another user")
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+	} else {
+		isAdmin, err := a.usrProvider.IsAdmin(ctx, caller.ID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if !isAdmin {
+			log.Warn("caller is not an admin")
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+		}
+	}
+
+	if err := a.usrDeleter.DeleteUser(ctx, caller.TenantID, caller.ID, userID); err != nil {
+		if errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("attempt to delete the last admin")
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrLastAdmin)
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found")
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to delete user", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purgeAt := time.Now().Add(a.deletionRetention)
+	log.Info("account deleted", slog.Int64("deleted_user_id", userID), slog.Time("purge_at", purgeAt))
+
+	return purgeAt, nil
+}
+
+// ExportMyData returns a JSON archive with all the data stored about the caller (see lib/dataexport).
+// Выгрузка тяжёлая, поэтому пользователь может запросить её не чаще раза в exportInterval,
+// иначе ErrExportRateLimited. Ограничение действует в пределах одного экземпляра сервиса.
+func (a *Auth) ExportMyData(ctx context.Context, callerToken string) ([]byte, error) {
+	const op = "Auth.ExportMyData"
+
+	log := a.log.With(slog.String("op", op))
+
+	caller, _, err := a.authenticate(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authenticated", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", caller.ID))
+
+	startedAt, ok := a.reserveExport(caller.ID, time.Now())
+	if !ok {
+		log.Warn("data export rate limited")
+		return nil, fmt.Errorf("%s: %w", op, ErrExportRateLimited)
+	}
+
+	archive, err := dataexport.Export(ctx, a.dataProvider, caller.ID)
+	if err != nil {
+		// неудачная выгрузка не считается: пользователь может сразу повторить запрос
+		a.releaseExport(caller.ID, startedAt)
+
+		log.Error("failed to export user data", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user data exported", slog.Int("bytes", len(archive)))
+
+	return archive, nil
+}
+
+// reserveExport отмечает выгрузку пользователя в момент now, если с предыдущей прошло не меньше exportInterval.
+// Отметка ставится до выгрузки, чтобы параллельные запросы не прошли ограничение.
+func (a *Auth) reserveExport(userID int64, now time.Time) (time.Time, bool) {
+	a.exportMu.Lock()
+	defer a.exportMu.Unlock()
+
+	if last, ok := a.lastExports[userID]; ok && now.Sub(last) < a.exportInterval {
+		return time.Time{}, false
+	}
+
+	// заодно забываем отметки, которые уже ничего не ограничивают
+	for id, last := range a.lastExports {
+		if now.Sub(last) >= a.exportInterval {
+			delete(a.lastExports, id)
+		}
+	}
+	a.lastExports[userID] = now
+
+	return now, true
+}
+
+// releaseExport снимает отметку выгрузки, поставленную reserveExport в момент at
+func (a *Auth) releaseExport(userID int64, at time.Time) {
+	a.exportMu.Lock()
+	defer a.exportMu.Unlock()
+
+	if a.lastExports[userID].Equal(at) {
+		delete(a.lastExports, userID)
+	}
+}
+
+// ExchangeToken обменивает токен пользователя, выданный приложению appID,
+// на токен для приложения audience (RFC 8693, Token Exchange).
+// Приложение-посредник аутентифицируется своим секретом, а в новый токен
+// записывается claim "act", по которому получатель видит, кто действует от имени пользователя.
+// Если scopes пусты, выдаются все scope'ы, разрешённые политикой.
+func (a *Auth) ExchangeToken(
+	ctx context.Context,
+	subjectToken string,
+	appID int,
+	appSecret string,
+	audience int,
+	scopes []string,
+) (string, []string, error) {
+	const op = "Auth.ExchangeToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.Int("audience", audience),
+	)
+
+	log.Info("exchanging token")
+
+	// Проверяем приложение, которое выполняет обмен
+	actor, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) || errors.Is(err, storage.ErrAppDisabled) {
+			log.Warn("app not found", sl.Err(err))
+			return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidAppCredentials)
+		}
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// в период ротации принимается и прежний секрет приложения
+	validSecret := false
+	for _, secret := range actor.VerificationSecrets(time.Now()) {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(appSecret)) == 1 {
+			validSecret = true
+		}
+	}
+	if !validSecret {
+		log.Warn("invalid app secret")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidAppCredentials)
+	}
+
+	// Проверяем исходный токен: обменять можно только токен, выданный самому посреднику
+	claims, err := a.parseToken(ctx, subjectToken)
+	if err != nil {
+		log.Warn("invalid subject token", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.AppID != appID {
+		log.Warn("subject token issued for another app", slog.Int("token_app_id", claims.AppID))
+		return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	policy, err := a.exchangeProvider.ExchangePolicy(ctx, appID, audience)
+	if err != nil {
+		if errors.Is(err, storage.ErrExchangePolicyNotFound) {
+			log.Warn("exchange policy not found")
+			return "", nil, fmt.Errorf("%s: %w", op, ErrExchangeNotAllowed)
+		}
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Разрешённые scope'ы: из политики, а если исходный токен сам был получен обменом,
+	// то не шире его scope'ов (права при обмене можно только сужать).
+	// Пустой scope исходного токена тоже ограничение: из него нельзя получить права обратно
+	allowed := policy.Scopes
+	if claims.HasScope {
+		allowed = slices.DeleteFunc(slices.Clone(allowed), func(scope string) bool {
+			return !slices.Contains(claims.Scopes, scope)
+		})
+	}
+
+	granted := allowed
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			if !slices.Contains(allowed, scope) {
+				log.Warn("scope not allowed", slog.String("scope", scope))
+				return "", nil, fmt.Errorf("%s: %w", op, ErrExchangeNotAllowed)
+			}
+		}
+		granted = scopes
+	}
+
+	target, err := a.appProvider.App(ctx, audience)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Пользователь не может попасть в приложение чужой организации, даже если политика это разрешает
+	if target.TenantID != claims.TenantID {
+		log.Warn("audience app belongs to another tenant", slog.Int64("tenant_id", target.TenantID))
+		return "", nil, fmt.Errorf("%s: %w", op, ErrExchangeNotAllowed)
+	}
+
+	// Пользователь мог быть изменён после выдачи исходного токена, поэтому перечитываем его
+	user, err := a.usrProvider.User(ctx, claims.TenantID, claims.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.ID != claims.UserID || user.Disabled {
+		log.Warn("subject token user mismatch or disabled")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	// Обмен не должен открывать доступ в приложение, куда пользователь не может войти сам
+	if err := a.checkAppAccess(ctx, target, user); err != nil {
+		log.Warn("audience app access denied", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, ErrExchangeNotAllowed)
+	}
+
+	// claim "act" описывает посредника; при цепочке обменов
+	// предыдущие посредники сохраняются во вложенном "act"
+	act := map[string]any{
+		"sub":    actor.Name,
+		"app_id": actor.ID,
+	}
+	if claims.Actor != nil {
+		act["act"] = claims.Actor
+	}
+
+	// в новый токен попадают роли пользователя в целевом приложении
+	roles, err := a.roleProvider.UserRoles(ctx, user.ID, target.ID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	attributes, err := a.claimAttributes(ctx, target, user.ID)
+	if err != nil {
+		log.Error("failed to get user attributes", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := jwt.NewTokenWithClaims(user, target, roles, attributes, a.tokenTTL, map[string]any{
+		"scope": strings.Join(granted, " "),
+		"act":   act,
+	})
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token exchanged", slog.Any("scopes", granted))
+
+	return token, granted, nil
+}
+
+// checkAppAccess проверяет, что пользователь может войти в приложение с учётом его режима доступа.
+// Возвращает ErrAppAccessDenied, если не может.
+func (a *Auth) checkAppAccess(ctx context.Context, app models.App, user models.User) error {
+	isMember := false
+	if app.AccessMode != models.AppAccessOpen {
+		var err error
+		isMember, err = a.memberProvider.IsAppMember(ctx, app.ID, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !app.Admits(user.Email, isMember) {
+		return ErrAppAccessDenied
+	}
+
+	return nil
+}
+
+// claimAttributes возвращает атрибуты пользователя, если приложение включает их в токен
+func (a *Auth) claimAttributes(ctx context.Context, app models.App, userID int64) (map[string]string, error) {
+	if len(app.Tokens.ClaimAttributes) == 0 {
+		return nil, nil
+	}
+
+	return a.attrProvider.UserAttributes(ctx, userID)
+}
+
+// parseToken проверяет токен, выданный сервисом, секретом приложения из хранилища
+func (a *Auth) parseToken(ctx context.Context, token string) (jwt.Claims, error) {
+	claims, err := jwt.ParseToken(token, func(appID int) (models.App, error) {
+		return a.appProvider.App(ctx, appID)
+	})
+	if err != nil {
+		// токены удалённого или отключённого приложения не принимаются
+		if errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, storage.ErrAppNotFound) ||
+			errors.Is(err, storage.ErrAppDisabled) {
+			return jwt.Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+
+		return jwt.Claims{}, err
+	}
+
+	return claims, nil
+}
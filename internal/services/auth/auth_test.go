@@ -0,0 +1,269 @@
+// internal/services/auth/auth_test.go
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/dataexport"
+	"grpc-service-ref/internal/lib/passhash"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/memory"
+)
+
+// Тесты сервиса на хранилище в памяти: без файла SQLite и миграций.
+
+const (
+	email    = "user@example.com"
+	password = "secret-password"
+
+	deletionRetention = 30 * 24 * time.Hour
+	exportInterval    = 24 * time.Hour
+)
+
+func TestAuth_RegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	a, _, appID := newAuth(t)
+
+	id, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+	assert.NotZero(t, id)
+
+	token, err := a.Login(ctx, email, password, appID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	_, err = a.Login(ctx, email, "wrong-password", appID)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = a.Login(ctx, "unknown@example.com", password, appID)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAuth_Login_ImportedHash(t *testing.T) {
+	ctx := context.Background()
+	a, st, appID := newAuth(t)
+
+	// хэш argon2id, импортированный из другой системы
+	const (
+		importedPassword = "correct horse battery staple"
+		importedHash     = "$argon2id$v=19$m=65536,t=3,p=4$MIIRqgvgQbgj220jfp0MPA$YfwJSVjtjSU0zzV/P3S9nnQ/USre2wvJMjfCIjrTQbg"
+	)
+	_, err := st.SaveUser(ctx, models.DefaultTenantID, email, []byte(importedHash))
+	require.NoError(t, err)
+
+	_, err = a.Login(ctx, email, "wrong-password", appID)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = a.Login(ctx, email, importedPassword, appID)
+	require.NoError(t, err)
+
+	// после входа хэш заменён на bcrypt, и пароль по-прежнему подходит
+	user, err := st.User(ctx, models.DefaultTenantID, email)
+	require.NoError(t, err)
+	format, err := passhash.Identify(user.PassHash)
+	require.NoError(t, err)
+	assert.Equal(t, passhash.FormatBcrypt, format)
+
+	_, err = a.Login(ctx, email, importedPassword, appID)
+	require.NoError(t, err)
+}
+
+func TestAuth_RegisterNewUser_Errors(t *testing.T) {
+	ctx := context.Background()
+	a, _, appID := newAuth(t)
+
+	_, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+
+	_, err = a.RegisterNewUser(ctx, email, password, appID)
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	_, err = a.RegisterNewUser(ctx, "other@example.com", password, appID+1)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+}
+
+func TestAuth_SetAdmin(t *testing.T) {
+	ctx := context.Background()
+	a, st, appID := newAuth(t)
+
+	adminID, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+	require.NoError(t, st.UpdateAdminStatus(ctx, models.DefaultTenantID, models.SystemActorID, adminID, true))
+
+	userID, err := a.RegisterNewUser(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+
+	userToken, err := a.Login(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	err = a.SetAdmin(ctx, userToken, adminID, false)
+	assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+
+	adminToken, err := a.Login(ctx, email, password, appID)
+	require.NoError(t, err)
+
+	// единственного администратора разжаловать нельзя
+	err = a.SetAdmin(ctx, adminToken, adminID, false)
+	assert.ErrorIs(t, err, auth.ErrLastAdmin)
+
+	require.NoError(t, a.SetAdmin(ctx, adminToken, userID, true))
+	isAdmin, err := a.IsAdmin(ctx, adminToken, userID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	// изменение записано в журнал аудита
+	events := st.AuditLog()
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, models.AuditActionSetAdmin, last.Action)
+	assert.Equal(t, adminID, last.ActorID)
+	assert.Equal(t, userID, last.TargetID)
+}
+
+func TestAuth_IsAdmin(t *testing.T) {
+	ctx := context.Background()
+	a, st, appID := newAuth(t)
+
+	adminID, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+	require.NoError(t, st.UpdateAdminStatus(ctx, models.DefaultTenantID, models.SystemActorID, adminID, true))
+
+	_, err = a.RegisterNewUser(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	userToken, err := a.Login(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+
+	_, err = a.IsAdmin(ctx, "", adminID)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// статус пользователей своей организации виден любому её пользователю
+	isAdmin, err := a.IsAdmin(ctx, userToken, adminID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	// пользователя другой организации для вызывающего не существует
+	strangerID, err := st.SaveUser(ctx, models.DefaultTenantID+1, "stranger@example.com", []byte{})
+	require.NoError(t, err)
+	_, err = a.IsAdmin(ctx, userToken, strangerID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func TestAuth_DeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	a, st, appID := newAuth(t)
+
+	adminID, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+	require.NoError(t, st.UpdateAdminStatus(ctx, models.DefaultTenantID, models.SystemActorID, adminID, true))
+
+	userID, err := a.RegisterNewUser(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	_, err = a.RegisterNewUser(ctx, "third@example.com", password, appID)
+	require.NoError(t, err)
+
+	userToken, err := a.Login(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	adminToken, err := a.Login(ctx, email, password, appID)
+	require.NoError(t, err)
+
+	// чужую учётную запись удаляет только администратор
+	_, err = a.DeleteAccount(ctx, userToken, adminID, "")
+	assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+
+	// свою - только с верным паролем
+	_, err = a.DeleteAccount(ctx, userToken, 0, "wrong-password")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	purgeAt, err := a.DeleteAccount(ctx, userToken, 0, password)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(deletionRetention), purgeAt, time.Minute)
+
+	_, err = st.UserByID(ctx, userID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// удалённый пользователь не может войти, а его токен больше не принимается
+	_, err = a.Login(ctx, "other@example.com", password, appID)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = a.DeleteAccount(ctx, userToken, 0, password)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// администратор удаляет других без пароля, но себя - только пока есть другой администратор
+	third, err := st.User(ctx, models.DefaultTenantID, "third@example.com")
+	require.NoError(t, err)
+	_, err = a.DeleteAccount(ctx, adminToken, third.ID, "")
+	require.NoError(t, err)
+	_, err = a.DeleteAccount(ctx, adminToken, third.ID, "")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	_, err = a.DeleteAccount(ctx, adminToken, 0, password)
+	assert.ErrorIs(t, err, auth.ErrLastAdmin)
+
+	events := st.AuditLog()
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, models.AuditActionDeleteUser, last.Action)
+	assert.Equal(t, adminID, last.ActorID)
+	assert.Equal(t, third.ID, last.TargetID)
+}
+
+func TestAuth_ExportMyData(t *testing.T) {
+	ctx := context.Background()
+	a, _, appID := newAuth(t)
+
+	userID, err := a.RegisterNewUser(ctx, email, password, appID)
+	require.NoError(t, err)
+	token, err := a.Login(ctx, email, password, appID)
+	require.NoError(t, err)
+
+	_, err = a.ExportMyData(ctx, "not-a-token")
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	raw, err := a.ExportMyData(ctx, token)
+	require.NoError(t, err)
+
+	var archive dataexport.Archive
+	require.NoError(t, json.Unmarshal(raw, &archive))
+	assert.Equal(t, userID, archive.User.ID)
+	assert.Equal(t, email, archive.User.Email)
+	assert.True(t, archive.User.HasPassword)
+
+	// повторная выгрузка - только через exportInterval
+	_, err = a.ExportMyData(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrExportRateLimited)
+
+	// ограничение у каждого пользователя своё
+	_, err = a.RegisterNewUser(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	otherToken, err := a.Login(ctx, "other@example.com", password, appID)
+	require.NoError(t, err)
+	_, err = a.ExportMyData(ctx, otherToken)
+	assert.NoError(t, err)
+}
+
+// newAuth создаёт сервис на пустом хранилище в памяти с одним приложением
+func newAuth(t *testing.T) (*auth.Auth, *memory.Storage, int) {
+	t.Helper()
+
+	st := memory.New()
+	appID, err := st.SaveApp(context.Background(), models.DefaultTenantID, 0, models.App{
+		Name:   "test",
+		Secret: "test-secret",
+		Kind:   models.AppKindJWT,
+	})
+	require.NoError(t, err)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := auth.New(log, st, st, st, st, st, st, st, st, st, st, auth.NewLocalVerifier(log, st, st), time.Hour,
+		deletionRetention, exportInterval)
+
+	return a, st, appID
+}
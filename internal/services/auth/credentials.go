@@ -0,0 +1,153 @@
+// internal/services/auth/credentials.go
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/lib/passhash"
+	"grpc-service-ref/internal/storage"
+)
+
+// CredentialVerifier проверяет пароль пользователя и возвращает соответствующего ему
+// локального пользователя из организации приложения app.
+// Неизвестный пользователь или неверный пароль - ErrInvalidCredentials.
+// Реализации: LocalVerifier (bcrypt-хэш из хранилища), LDAP (пакет ldapauth) и т.п.
+type CredentialVerifier interface {
+	VerifyCredentials(ctx context.Context, email string, password string, app models.App) (models.User, error)
+}
+
+// PasswordHashUpdater интерфейс замены хэша пароля пользователя
+type PasswordHashUpdater interface {
+	SetPassHash(ctx context.Context, userID int64, passHash []byte) error
+}
+
+// LocalVerifier проверяет пароль по хэшу из хранилища: bcrypt, сохранённому при регистрации,
+// или хэшу другого формата (argon2, scrypt, PBKDF2) у импортированных пользователей, см. lib/passhash.
+// Хэш другого формата после успешного входа заменяется на bcrypt.
+type LocalVerifier struct {
+	log         *slog.Logger
+	usrProvider UserProvider
+	passUpdater PasswordHashUpdater
+}
+
+// NewLocalVerifier returns a new instance of LocalVerifier.
+// Если passwordUpdater не задан, хэши других форматов не заменяются.
+func NewLocalVerifier(log *slog.Logger, userProvider UserProvider, passwordUpdater PasswordHashUpdater) *LocalVerifier {
+	return &LocalVerifier{
+		log:         log,
+		usrProvider: userProvider,
+		passUpdater: passwordUpdater,
+	}
+}
+
+// VerifyCredentials checks password against the local password hash
+func (v *LocalVerifier) VerifyCredentials(ctx context.Context, email string, password string, app models.App) (models.User, error) {
+	const op = "LocalVerifier.VerifyCredentials"
+
+	user, err := v.usrProvider.User(ctx, app.TenantID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// У пользователей, созданных через внешних провайдеров, хэша нет:
+	// пустой хэш не подходит ни к одному формату
+	if err := passhash.Verify(user.PassHash, password); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	// Отключённый (деактивированный через SCIM) пользователь войти не может
+	if user.Disabled {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if v.passUpdater != nil && passhash.NeedsRehash(user.PassHash) {
+		v.rehash(ctx, &user, password)
+	}
+
+	return user, nil
+}
+
+// rehash заменяет импортированный хэш на bcrypt. Вход при ошибке не прерывается:
+// пароль уже проверен, а заменить хэш можно и при следующем входе.
+func (v *LocalVerifier) rehash(ctx context.Context, user *models.User, password string) {
+	log := v.log.With(
+		slog.String("op", "LocalVerifier.rehash"),
+		slog.Int64("user_id", user.ID),
+	)
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return
+	}
+
+	if err := v.passUpdater.SetPassHash(ctx, user.ID, passHash); err != nil {
+		log.Error("failed to replace password hash", sl.Err(err))
+		return
+	}
+
+	log.Info("imported password hash replaced with bcrypt")
+	user.PassHash = passHash
+}
+
+// CredentialRoute правило делегирования проверки пароля внешнему бэкенду:
+// по приложению, в которое выполняется вход, или по домену email пользователя
+type CredentialRoute struct {
+	Verifier CredentialVerifier
+	AppIDs   []int
+	Domains  []string
+}
+
+// CredentialRouter выбирает бэкенд проверки пароля по правилам (первое подходящее),
+// а если ни одно правило не подошло - использует fallback (обычно LocalVerifier)
+type CredentialRouter struct {
+	fallback CredentialVerifier
+	routes   []CredentialRoute
+}
+
+// NewCredentialRouter returns a new instance of CredentialRouter
+func NewCredentialRouter(fallback CredentialVerifier, routes ...CredentialRoute) *CredentialRouter {
+	return &CredentialRouter{
+		fallback: fallback,
+		routes:   routes,
+	}
+}
+
+// VerifyCredentials delegates the check to the backend responsible for the user
+func (r *CredentialRouter) VerifyCredentials(ctx context.Context, email string, password string, app models.App) (models.User, error) {
+	return r.verifier(email, app.ID).VerifyCredentials(ctx, email, password, app)
+}
+
+func (r *CredentialRouter) verifier(email string, appID int) CredentialVerifier {
+	domain := ""
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		domain = strings.ToLower(email[i+1:])
+	}
+
+	for _, route := range r.routes {
+		if slices.Contains(route.AppIDs, appID) {
+			return route.Verifier
+		}
+
+		if domain != "" && slices.ContainsFunc(route.Domains, func(d string) bool {
+			return strings.EqualFold(d, domain)
+		}) {
+			return route.Verifier
+		}
+	}
+
+	return r.fallback
+}
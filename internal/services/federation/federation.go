@@ -0,0 +1,367 @@
+// internal/services/federation/federation.go
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/jwt"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сервис федерации: вход пользователей через внешние OIDC-провайдеры
+// (например, корпоративный IdP) по схеме Authorization Code Flow:
+//  1. LoginURL - перенаправляем пользователя на страницу входа провайдера;
+//  2. Callback - провайдер возвращает пользователя с кодом, меняем код на id_token,
+//     находим (или создаём) локального пользователя и выдаём ему наш токен.
+
+var (
+	ErrProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidState     = errors.New("invalid or expired state")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+	ErrEmailNotVerified = errors.New("email is not verified by identity provider")
+	ErrUserDisabled     = errors.New("user is disabled")
+	ErrAppAccessDenied  = errors.New("app access denied")
+)
+
+// Сколько живёт незавершённый вход (от редиректа до callback)
+const stateTTL = 10 * time.Minute
+
+// UserSaver Интерфейс сохранения пользователя
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		tenantID int64,
+		email string,
+		passHash []byte,
+	) (uid int64, err error)
+}
+
+// UserProvider Интерфейс получения пользователя
+type UserProvider interface {
+	User(ctx context.Context, tenantID int64, email string) (models.User, error)
+}
+
+// AppProvider интерфейс для получения App (приложения) из хранилища
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+// IdentityStorage интерфейс хранилища связей с учётными записями внешних провайдеров.
+// Связи свои у каждой организации.
+type IdentityStorage interface {
+	FederatedUser(ctx context.Context, tenantID int64, provider string, subject string) (models.User, error)
+	SaveFederatedIdentity(ctx context.Context, tenantID int64, provider string, subject string, userID int64) error
+}
+
+// RoleProvider интерфейс получения ролей пользователя в приложении (попадают в токен)
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+}
+
+// AppMemberProvider интерфейс проверки участия пользователя в приложении
+// (нужен для приложений с ограниченным доступом)
+type AppMemberProvider interface {
+	IsAppMember(ctx context.Context, appID int, userID int64) (bool, error)
+}
+
+// AttributeProvider интерфейс получения атрибутов пользователя
+// (попадают в токен, если так настроено приложение)
+type AttributeProvider interface {
+	UserAttributes(ctx context.Context, userID int64) (map[string]string, error)
+}
+
+// Federation структура сервиса федерации
+type Federation struct {
+	log         *slog.Logger
+	usrSaver    UserSaver
+	usrProvider UserProvider
+	appProvider AppProvider
+	identities  IdentityStorage
+	roles       RoleProvider
+	members     AppMemberProvider
+	attributes  AttributeProvider
+	tokenTTL    time.Duration
+	providers   map[string]*provider
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin // незавершённые входы по state
+}
+
+// pendingLogin данные входа, сохраняемые между редиректом и callback
+type pendingLogin struct {
+	provider  string
+	appID     int
+	nonce     string
+	expiresAt time.Time
+}
+
+// New returns a new instance of Federation service
+func New(
+	log *slog.Logger,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	identities IdentityStorage,
+	roles RoleProvider,
+	members AppMemberProvider,
+	attributes AttributeProvider,
+	providers []config.OIDCProviderConfig,
+	tokenTTL time.Duration,
+	client *http.Client,
+) *Federation {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	f := &Federation{
+		log:         log,
+		usrSaver:    userSaver,
+		usrProvider: userProvider,
+		appProvider: appProvider,
+		identities:  identities,
+		roles:       roles,
+		members:     members,
+		attributes:  attributes,
+		tokenTTL:    tokenTTL,
+		providers:   make(map[string]*provider, len(providers)),
+		pending:     make(map[string]pendingLogin),
+	}
+
+	for _, cfg := range providers {
+		f.providers[cfg.Name] = newProvider(cfg, client)
+	}
+
+	return f
+}
+
+// LoginURL returns the address of provider login page to redirect the user to.
+// После входа у провайдера пользователь получит токен для приложения appID.
+func (f *Federation) LoginURL(ctx context.Context, providerName string, appID int) (string, error) {
+	const op = "Federation.LoginURL"
+
+	log := f.log.With(
+		slog.String("op", op),
+		slog.String("provider", providerName),
+		slog.Int("app_id", appID),
+	)
+
+	p, ok := f.providers[providerName]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrProviderNotFound)
+	}
+
+	// Проверяем приложение заранее, чтобы не гонять пользователя к провайдеру зря
+	if _, err := f.appProvider.App(ctx, appID); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	url, err := p.authCodeURL(ctx, state, nonce)
+	if err != nil {
+		log.Error("failed to build provider login url", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	f.mu.Lock()
+	f.cleanupPending()
+	f.pending[state] = pendingLogin{
+		provider:  providerName,
+		appID:     appID,
+		nonce:     nonce,
+		expiresAt: time.Now().Add(stateTTL),
+	}
+	f.mu.Unlock()
+
+	log.Info("redirecting user to identity provider")
+
+	return url, nil
+}
+
+// Callback completes login: exchanges the code for id_token, links the upstream
+// subject to a local user (creating it just-in-time) and returns our token.
+func (f *Federation) Callback(ctx context.Context, state string, code string) (string, error) {
+	const op = "Federation.Callback"
+
+	log := f.log.With(slog.String("op", op))
+
+	// state одноразовый: удаляем его сразу, даже если дальше что-то пойдёт не так
+	f.mu.Lock()
+	login, ok := f.pending[state]
+	delete(f.pending, state)
+	f.mu.Unlock()
+
+	if !ok || time.Now().After(login.expiresAt) {
+		log.Warn("unknown or expired state")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidState)
+	}
+
+	log = log.With(slog.String("provider", login.provider), slog.Int("app_id", login.appID))
+
+	p, ok := f.providers[login.provider]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrProviderNotFound)
+	}
+
+	claims, err := p.exchange(ctx, code, login.nonce)
+	if err != nil {
+		log.Warn("failed to exchange code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	subject, _ := claims[p.cfg.ClaimMapping.Subject].(string)
+	email, _ := claims[p.cfg.ClaimMapping.Email].(string)
+	if subject == "" || email == "" {
+		log.Warn("required claims are missing in id token")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidIDToken)
+	}
+
+	// Email считается подтверждённым, только если провайдер явно это говорит
+	// или ему доверяют в конфиге (trust_email). Иначе через провайдер, не присылающий
+	// email_verified, можно было бы войти в чужую учётную запись с тем же email
+	emailVerified := p.cfg.TrustEmail
+	if v, ok := claims["email_verified"].(bool); ok {
+		emailVerified = v
+	}
+
+	// пользователь ищется (и создаётся) в организации приложения
+	app, err := f.appProvider.App(ctx, login.appID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := f.linkUser(ctx, app.TenantID, login.provider, subject, email, emailVerified)
+	if err != nil {
+		log.Error("failed to link user", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Disabled {
+		log.Warn("user is disabled", slog.Int64("user_id", user.ID))
+		return "", fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	// В приложение с ограниченным доступом пускаем только допущенных пользователей.
+	// Созданный выше пользователь остаётся в организации: доступ ему можно выдать позже.
+	isMember := false
+	if app.AccessMode != models.AppAccessOpen {
+		isMember, err = f.members.IsAppMember(ctx, app.ID, user.ID)
+		if err != nil {
+			log.Error("failed to check app membership", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if !app.Admits(user.Email, isMember) {
+		log.Warn("app access denied", slog.Int64("user_id", user.ID))
+		return "", fmt.Errorf("%s: %w", op, ErrAppAccessDenied)
+	}
+
+	roles, err := f.roles.UserRoles(ctx, user.ID, app.ID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// атрибуты пользователя нужны, только если приложение включает их в токен
+	var attributes map[string]string
+	if len(app.Tokens.ClaimAttributes) > 0 {
+		attributes, err = f.attributes.UserAttributes(ctx, user.ID)
+		if err != nil {
+			log.Error("failed to get user attributes", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	token, err := jwt.NewToken(user, app, roles, attributes, f.tokenTTL)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged in via identity provider", slog.Int64("user_id", user.ID))
+
+	return token, nil
+}
+
+// linkUser находит пользователя организации, связанного с учётной записью провайдера.
+// Если связи ещё нет, связывает учётную запись с пользователем с тем же email
+// или создаёт нового пользователя (just-in-time provisioning).
+func (f *Federation) linkUser(
+	ctx context.Context,
+	tenantID int64,
+	providerName string,
+	subject string,
+	email string,
+	emailVerified bool,
+) (models.User, error) {
+	user, err := f.identities.FederatedUser(ctx, tenantID, providerName, subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return models.User{}, err
+	}
+
+	// Дальше связь определяется только по email, поэтому он должен быть подтверждён
+	if !emailVerified {
+		return models.User{}, ErrEmailNotVerified
+	}
+
+	user, err = f.usrProvider.User(ctx, tenantID, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		// Пароля у такого пользователя нет: пустой хэш не совпадёт ни с одним паролем,
+		// поэтому войти он сможет только через провайдера
+		id, err := f.usrSaver.SaveUser(ctx, tenantID, email, []byte{})
+		if err != nil {
+			return models.User{}, err
+		}
+
+		user = models.User{ID: id, TenantID: tenantID, Email: email}
+		f.log.Info("user created just-in-time", slog.String("provider", providerName), slog.Int64("user_id", id))
+	} else if err != nil {
+		return models.User{}, err
+	}
+
+	if err := f.identities.SaveFederatedIdentity(ctx, tenantID, providerName, subject, user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// cleanupPending удаляет просроченные незавершённые входы. Вызывается под f.mu.
+func (f *Federation) cleanupPending() {
+	now := time.Now()
+	for state, login := range f.pending {
+		if now.After(login.expiresAt) {
+			delete(f.pending, state)
+		}
+	}
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,547 @@
+// internal/services/federation/federation_test.go
+package federation_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/services/federation"
+	"grpc-service-ref/internal/storage"
+)
+
+// Тесты федерации с локальной заглушкой OIDC-провайдера (stubProvider)
+// и хранилищем в памяти (fakeStorage).
+
+const (
+	providerName = "corp"
+	clientID     = "sso-client"
+	clientSecret = "sso-client-secret"
+	appID        = 1
+	appSecret    = "test-secret"
+	tokenTTL     = time.Hour
+)
+
+func TestFederation_Login_CreatesUserJustInTime(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederation(stub, st)
+
+	stub.subject = "upstream-1"
+	stub.email = "new.user@corp.example"
+
+	token := login(t, fed, stub)
+
+	claims := parseToken(t, token)
+	assert.Equal(t, "new.user@corp.example", claims["email"])
+	assert.Equal(t, appID, int(claims["app_id"].(float64)))
+	assert.Equal(t, []any{"viewer"}, claims["roles"])
+
+	// пользователь создан и связан с учётной записью провайдера
+	user, err := st.User(context.Background(), models.DefaultTenantID, "new.user@corp.example")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, int64(claims["uid"].(float64)))
+
+	linked, err := st.FederatedUser(context.Background(), models.DefaultTenantID, providerName, "upstream-1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, linked.ID)
+
+	// повторный вход не создаёт нового пользователя, даже если у провайдера сменился email
+	stub.email = "renamed@corp.example"
+	token = login(t, fed, stub)
+	assert.Equal(t, user.ID, int64(parseToken(t, token)["uid"].(float64)))
+	assert.Len(t, st.users, 1)
+}
+
+func TestFederation_Login_LinksExistingUser(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederation(stub, st)
+
+	uid, err := st.SaveUser(context.Background(), models.DefaultTenantID, "existing@corp.example", []byte("hash"))
+	require.NoError(t, err)
+
+	stub.subject = "upstream-2"
+	stub.email = "existing@corp.example"
+
+	token := login(t, fed, stub)
+	assert.Equal(t, uid, int64(parseToken(t, token)["uid"].(float64)))
+	assert.Len(t, st.users, 1)
+}
+
+// Провайдеру, который не присылает email_verified, можно доверять явно (trust_email)
+func TestFederation_Login_TrustEmail(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederationWithConfig(stub, st, func(cfg *config.OIDCProviderConfig) { cfg.TrustEmail = true })
+
+	uid, err := st.SaveUser(context.Background(), models.DefaultTenantID, "existing@corp.example", []byte("hash"))
+	require.NoError(t, err)
+
+	stub.subject = "upstream-4"
+	stub.email = "existing@corp.example"
+	stub.emailVerified = nil
+
+	token := login(t, fed, stub)
+	assert.Equal(t, uid, int64(parseToken(t, token)["uid"].(float64)))
+
+	// явный отказ провайдера важнее доверия из конфига
+	stub.subject = "upstream-7"
+	stub.emailVerified = false
+
+	loginURL, err := fed.LoginURL(context.Background(), providerName, appID)
+	require.NoError(t, err)
+	state, code := stub.authorize(t, loginURL)
+	_, err = fed.Callback(context.Background(), state, code)
+	require.ErrorIs(t, err, federation.ErrEmailNotVerified)
+}
+
+func TestFederation_Login_FailCases(t *testing.T) {
+	tests := []struct {
+		name        string
+		prepare     func(stub *stubProvider)
+		state       func(state string) string
+		expectedErr error
+	}{
+		{
+			name:        "Unknown state",
+			state:       func(string) string { return "unknown" },
+			expectedErr: federation.ErrInvalidState,
+		},
+		{
+			name:        "Wrong nonce in id token",
+			prepare:     func(stub *stubProvider) { stub.nonce = "other-nonce" },
+			expectedErr: federation.ErrInvalidIDToken,
+		},
+		{
+			name:        "Wrong audience in id token",
+			prepare:     func(stub *stubProvider) { stub.audience = "other-client" },
+			expectedErr: federation.ErrInvalidIDToken,
+		},
+		{
+			name: "Id token signed with unknown key",
+			prepare: func(stub *stubProvider) {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				require.NoError(t, err)
+				stub.signingKey = key
+			},
+			expectedErr: federation.ErrInvalidIDToken,
+		},
+		{
+			name:        "Email not verified",
+			prepare:     func(stub *stubProvider) { stub.emailVerified = false },
+			expectedErr: federation.ErrEmailNotVerified,
+		},
+		{
+			name:        "Email verification not stated",
+			prepare:     func(stub *stubProvider) { stub.emailVerified = nil },
+			expectedErr: federation.ErrEmailNotVerified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newStubProvider(t)
+			st := newFakeStorage()
+			fed := newFederation(stub, st)
+
+			stub.subject = "upstream-3"
+			stub.email = "user@corp.example"
+
+			loginURL, err := fed.LoginURL(context.Background(), providerName, appID)
+			require.NoError(t, err)
+
+			state, code := stub.authorize(t, loginURL)
+			if tt.prepare != nil {
+				tt.prepare(stub)
+			}
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+
+			_, err = fed.Callback(context.Background(), state, code)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Empty(t, st.users)
+		})
+	}
+}
+
+func TestFederation_AppAccess(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederation(stub, st)
+
+	stub.subject = "upstream-5"
+	stub.email = "member@corp.example"
+
+	// в приложение только по приглашениям пользователь без приглашения не попадает
+	st.accessMode = models.AppAccessInviteOnly
+
+	loginURL, err := fed.LoginURL(context.Background(), providerName, appID)
+	require.NoError(t, err)
+
+	state, code := stub.authorize(t, loginURL)
+	_, err = fed.Callback(context.Background(), state, code)
+	require.ErrorIs(t, err, federation.ErrAppAccessDenied)
+
+	// но учётная запись уже создана, и после приглашения вход проходит
+	require.Len(t, st.users, 1)
+	st.members = append(st.members, st.users[0].ID)
+	login(t, fed, stub)
+
+	// пользователи разрешённых доменов входят без приглашения
+	st.accessMode = models.AppAccessDomain
+	st.allowedDomains = []string{"corp.example"}
+	st.members = nil
+	login(t, fed, stub)
+}
+
+func TestFederation_TokenSettings(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederation(stub, st)
+
+	stub.subject = "upstream-6"
+	stub.email = "settings@corp.example"
+
+	st.tokens = models.TokenSettings{
+		AccessTTL:       5 * time.Minute,
+		ClaimAttributes: []string{"department", "cost_center"},
+		StaticClaims:    map[string]string{"env": "prod"},
+	}
+	st.attributes = map[string]string{"department": "sales", "level": "3"}
+
+	claims := parseToken(t, login(t, fed, stub))
+	assert.Equal(t, "sales", claims["department"])
+	assert.Equal(t, "prod", claims["env"])
+	// в токен попадают только перечисленные в настройках атрибуты, и только если они есть у пользователя
+	assert.NotContains(t, claims, "level")
+	assert.NotContains(t, claims, "cost_center")
+
+	exp := int64(claims["exp"].(float64))
+	assert.InDelta(t, time.Now().Add(5*time.Minute).Unix(), exp, 5)
+}
+
+func TestFederation_StateIsSingleUse(t *testing.T) {
+	stub := newStubProvider(t)
+	st := newFakeStorage()
+	fed := newFederation(stub, st)
+
+	stub.subject = "upstream-4"
+	stub.email = "once@corp.example"
+
+	loginURL, err := fed.LoginURL(context.Background(), providerName, appID)
+	require.NoError(t, err)
+
+	state, code := stub.authorize(t, loginURL)
+
+	_, err = fed.Callback(context.Background(), state, code)
+	require.NoError(t, err)
+
+	_, err = fed.Callback(context.Background(), state, code)
+	require.ErrorIs(t, err, federation.ErrInvalidState)
+}
+
+func TestFederation_UnknownProvider(t *testing.T) {
+	stub := newStubProvider(t)
+	fed := newFederation(stub, newFakeStorage())
+
+	_, err := fed.LoginURL(context.Background(), "unknown", appID)
+	require.ErrorIs(t, err, federation.ErrProviderNotFound)
+}
+
+// login проходит весь сценарий входа и возвращает выданный нами токен
+func login(t *testing.T, fed *federation.Federation, stub *stubProvider) string {
+	t.Helper()
+
+	loginURL, err := fed.LoginURL(context.Background(), providerName, appID)
+	require.NoError(t, err)
+
+	state, code := stub.authorize(t, loginURL)
+
+	token, err := fed.Callback(context.Background(), state, code)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	return token
+}
+
+func parseToken(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	return parsed.Claims.(jwt.MapClaims)
+}
+
+func newFederation(stub *stubProvider, st *fakeStorage) *federation.Federation {
+	return newFederationWithConfig(stub, st, nil)
+}
+
+// newFederationWithConfig как newFederation, но configure может изменить настройки провайдера
+func newFederationWithConfig(
+	stub *stubProvider,
+	st *fakeStorage,
+	configure func(cfg *config.OIDCProviderConfig),
+) *federation.Federation {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cfg := config.OIDCProviderConfig{
+		Name:         providerName,
+		Issuer:       stub.server.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  "http://localhost/federation/callback",
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	return federation.New(
+		log,
+		st,
+		st,
+		st,
+		st,
+		st,
+		st,
+		st,
+		[]config.OIDCProviderConfig{cfg},
+		tokenTTL,
+		stub.server.Client(),
+	)
+}
+
+// stubProvider минимальный OIDC-провайдер: discovery, JWKS и token endpoint.
+// Страница входа не нужна: authorize() сразу "входит" и выдаёт код.
+type stubProvider struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey // ключ, опубликованный в JWKS
+	signingKey *rsa.PrivateKey // ключ, которым подписывается id_token
+
+	// данные пользователя у провайдера и параметры выдаваемого id_token
+	subject       string
+	email         string
+	emailVerified any // nil - claim email_verified не передаётся
+	nonce         string
+	audience      string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	stub := &stubProvider{
+		key:           key,
+		signingKey:    key,
+		emailVerified: true,
+		audience:      clientID,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(stub.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(stub.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != clientID || r.FormValue("client_secret") != clientSecret ||
+			r.FormValue("code") != "code-"+stub.subject {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   stub.server.URL,
+			"aud":   stub.audience,
+			"sub":   stub.subject,
+			"email": stub.email,
+			"nonce": stub.nonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+		if stub.emailVerified != nil {
+			claims["email_verified"] = stub.emailVerified
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "key-1"
+
+		signed, err := idToken.SignedString(stub.signingKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+// authorize имитирует вход пользователя у провайдера: запоминает nonce
+// и возвращает state и код авторизации, с которыми провайдер вернул бы пользователя
+func (s *stubProvider) authorize(t *testing.T, loginURL string) (state string, code string) {
+	t.Helper()
+
+	u, err := url.Parse(loginURL)
+	require.NoError(t, err)
+	require.Equal(t, s.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	q := u.Query()
+	require.Equal(t, clientID, q.Get("client_id"))
+	require.Equal(t, "code", q.Get("response_type"))
+
+	s.nonce = q.Get("nonce")
+
+	return q.Get("state"), "code-" + s.subject
+}
+
+// fakeStorage хранилище пользователей и связей с провайдерами в памяти
+type fakeStorage struct {
+	mu         sync.Mutex
+	users      []models.User
+	identities map[string]int64
+
+	// режим доступа к приложению и его участники
+	accessMode     string
+	allowedDomains []string
+	members        []int64
+
+	// настройки токенов приложения и атрибуты пользователей
+	tokens     models.TokenSettings
+	attributes map[string]string
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{identities: make(map[string]int64)}
+}
+
+func (s *fakeStorage) SaveUser(_ context.Context, tenantID int64, email string, passHash []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return 0, storage.ErrUserExists
+		}
+	}
+
+	user := models.User{ID: int64(len(s.users) + 1), TenantID: tenantID, Email: email, PassHash: passHash}
+	s.users = append(s.users, user)
+
+	return user.ID, nil
+}
+
+func (s *fakeStorage) User(_ context.Context, tenantID int64, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) App(_ context.Context, id int) (models.App, error) {
+	if id != appID {
+		return models.App{}, storage.ErrAppNotFound
+	}
+
+	return models.App{
+		ID:             appID,
+		TenantID:       models.DefaultTenantID,
+		Name:           "test",
+		Secret:         appSecret,
+		AccessMode:     s.accessMode,
+		AllowedDomains: s.allowedDomains,
+		Tokens:         s.tokens,
+	}, nil
+}
+
+func (s *fakeStorage) UserAttributes(_ context.Context, _ int64) (map[string]string, error) {
+	return s.attributes, nil
+}
+
+func (s *fakeStorage) IsAppMember(_ context.Context, _ int, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Contains(s.members, userID), nil
+}
+
+func (s *fakeStorage) UserRoles(_ context.Context, _ int64, _ int) ([]string, error) {
+	return []string{"viewer"}, nil
+}
+
+func (s *fakeStorage) FederatedUser(_ context.Context, tenantID int64, provider string, subject string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, ok := s.identities[identityKey(tenantID, provider, subject)]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return s.users[uid-1], nil
+}
+
+func (s *fakeStorage) SaveFederatedIdentity(_ context.Context, tenantID int64, provider string, subject string, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identityKey(tenantID, provider, subject)
+	if _, ok := s.identities[key]; ok {
+		return storage.ErrIdentityExists
+	}
+	s.identities[key] = userID
+
+	return nil
+}
+
+func identityKey(tenantID int64, provider string, subject string) string {
+	return fmt.Sprintf("%d/%s/%s", tenantID, provider, subject)
+}
@@ -0,0 +1,287 @@
+// internal/services/federation/oidc.go
+package federation
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"grpc-service-ref/internal/config"
+)
+
+// provider внешний OIDC-провайдер.
+// Адреса эндпоинтов и ключи подписи загружаются лениво при первом обращении
+// (discovery: {issuer}/.well-known/openid-configuration) и кэшируются.
+type provider struct {
+	cfg    config.OIDCProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey // ключи подписи id_token по kid
+}
+
+// discoveryDocument нужные нам поля из /.well-known/openid-configuration
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func newProvider(cfg config.OIDCProviderConfig, client *http.Client) *provider {
+	if cfg.ClaimMapping.Subject == "" {
+		cfg.ClaimMapping.Subject = "sub"
+	}
+	if cfg.ClaimMapping.Email == "" {
+		cfg.ClaimMapping.Email = "email"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email"}
+	}
+
+	return &provider{cfg: cfg, client: client}
+}
+
+// authCodeURL возвращает адрес страницы входа провайдера (Authorization Code Flow)
+func (p *provider) authCodeURL(ctx context.Context, state string, nonce string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// exchange обменивает код авторизации на id_token и возвращает его проверенные claims
+func (p *provider) exchange(ctx context.Context, code string, nonce string) (jwt.MapClaims, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request: unexpected status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token response: %w", ErrInvalidIDToken)
+	}
+
+	return p.verify(ctx, tokenResp.IDToken, nonce)
+}
+
+// verify проверяет подпись, issuer, audience, срок действия и nonce id_token
+func (p *provider) verify(ctx context.Context, idToken string, nonce string) (jwt.MapClaims, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidIDToken)
+	}
+
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// key возвращает ключ подписи по kid. Если ключ не найден, набор ключей
+// перечитывается один раз: провайдер мог выполнить ротацию ключей.
+func (p *provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	if key, ok := findKey(keys, kid); ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if key, ok := findKey(keys, kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+// findKey ищет ключ по kid; если kid не указан и ключ единственный, возвращает его
+func findKey(keys map[string]*rsa.PublicKey, kid string) (*rsa.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func (p *provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, doc.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		// поддерживаем только RSA-ключи для подписи
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (p *provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+
+	// По спецификации issuer в документе обязан совпадать с тем, по которому его запросили
+	if doc.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer mismatch: %q", doc.Issuer)
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery: incomplete provider metadata")
+	}
+
+	p.discovery = &doc
+
+	return p.discovery, nil
+}
+
+func (p *provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
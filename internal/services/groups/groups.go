@@ -0,0 +1,285 @@
+// internal/services/groups/groups.go
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сервис групп пользователей. Группы могут быть вложенными; роли группы
+// и статус администратора группы действуют для всех её участников,
+// включая участников вложенных групп. Видеть группы может любой пользователь организации,
+// а изменять - только администратор; группы других организаций для них не существуют.
+
+var (
+	ErrInvalidGroup = errors.New("invalid group")
+)
+
+// Authorizer проверяет токен вызывающего и возвращает его (реализован сервисом auth).
+// AuthorizeAdmin дополнительно требует, чтобы вызывающий был администратором.
+type Authorizer interface {
+	Authenticate(ctx context.Context, callerToken string) (models.User, error)
+	AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error)
+}
+
+// GroupStorage интерфейс хранилища групп.
+// Изменения ограничены организацией tenantID: чужие группы, пользователи и роли для неё не существуют.
+type GroupStorage interface {
+	SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error)
+	Group(ctx context.Context, id int64) (models.Group, error)
+	DeleteGroup(ctx context.Context, tenantID int64, id int64) error
+	AddGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error
+	RemoveGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error
+	AddSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error
+	RemoveSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error
+	AssignGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error
+	RevokeGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error
+	SetGroupAdmin(ctx context.Context, tenantID int64, actorID int64, groupID int64, isAdmin bool) error
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// TxManager выполняет вызовы хранилища внутри fn в одной транзакции (реализован хранилищем)
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Groups структура сервиса групп
+type Groups struct {
+	log     *slog.Logger
+	admins  Authorizer
+	storage GroupStorage
+	tx      TxManager
+}
+
+// New returns a new instance of Groups service
+func New(log *slog.Logger, admins Authorizer, storage GroupStorage, tx TxManager) *Groups {
+	return &Groups{
+		log:     log,
+		admins:  admins,
+		storage: storage,
+		tx:      tx,
+	}
+}
+
+// CreateGroup creates a new group.
+func (g *Groups) CreateGroup(ctx context.Context, callerToken string, name string) (int64, error) {
+	const op = "Groups.CreateGroup"
+
+	log := g.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+	)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidGroup)
+	}
+
+	caller, err := g.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := g.storage.SaveGroup(ctx, caller.TenantID, name, "")
+	if err != nil {
+		log.Error("failed to save group", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("group created", slog.Int64("group_id", id), slog.Int64("actor_id", caller.ID))
+
+	return id, nil
+}
+
+// Group returns the group of the caller's organization with its direct members, subgroups and roles.
+func (g *Groups) Group(ctx context.Context, callerToken string, groupID int64) (models.Group, error) {
+	const op = "Groups.Group"
+
+	caller, err := g.admins.Authenticate(ctx, callerToken)
+	if err != nil {
+		g.log.Warn("caller is not authenticated", slog.String("op", op), sl.Err(err))
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := g.storage.Group(ctx, groupID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if group.TenantID != caller.TenantID {
+		return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes the group.
+func (g *Groups) DeleteGroup(ctx context.Context, callerToken string, groupID int64) error {
+	const op = "Groups.DeleteGroup"
+
+	log := g.log.With(
+		slog.String("op", op),
+		slog.Int64("group_id", groupID),
+	)
+
+	caller, err := g.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := g.storage.DeleteGroup(ctx, caller.TenantID, groupID); err != nil {
+		log.Error("failed to delete group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("group deleted", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group.
+func (g *Groups) AddGroupMember(ctx context.Context, callerToken string, groupID int64, userID int64) error {
+	return g.change(ctx, "Groups.AddGroupMember", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.AddGroupMember(ctx, caller.TenantID, caller.ID, groupID, userID)
+		},
+		slog.Int64("user_id", userID),
+	)
+}
+
+// RemoveGroupMember removes the user from the group.
+func (g *Groups) RemoveGroupMember(ctx context.Context, callerToken string, groupID int64, userID int64) error {
+	return g.change(ctx, "Groups.RemoveGroupMember", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.RemoveGroupMember(ctx, caller.TenantID, caller.ID, groupID, userID)
+		},
+		slog.Int64("user_id", userID),
+	)
+}
+
+// AddSubgroup nests the subgroup into the group.
+func (g *Groups) AddSubgroup(ctx context.Context, callerToken string, groupID int64, subgroupID int64) error {
+	return g.change(ctx, "Groups.AddSubgroup", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.AddSubgroup(ctx, caller.TenantID, caller.ID, groupID, subgroupID)
+		},
+		slog.Int64("subgroup_id", subgroupID),
+	)
+}
+
+// RemoveSubgroup removes the subgroup from the group.
+func (g *Groups) RemoveSubgroup(ctx context.Context, callerToken string, groupID int64, subgroupID int64) error {
+	return g.change(ctx, "Groups.RemoveSubgroup", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.RemoveSubgroup(ctx, caller.TenantID, caller.ID, groupID, subgroupID)
+		},
+		slog.Int64("subgroup_id", subgroupID),
+	)
+}
+
+// AssignGroupRole grants the role to the group.
+func (g *Groups) AssignGroupRole(ctx context.Context, callerToken string, groupID int64, roleID int64) error {
+	return g.change(ctx, "Groups.AssignGroupRole", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.AssignGroupRole(ctx, caller.TenantID, caller.ID, groupID, roleID)
+		},
+		slog.Int64("role_id", roleID),
+	)
+}
+
+// RevokeGroupRole revokes the role from the group.
+func (g *Groups) RevokeGroupRole(ctx context.Context, callerToken string, groupID int64, roleID int64) error {
+	return g.change(ctx, "Groups.RevokeGroupRole", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.RevokeGroupRole(ctx, caller.TenantID, caller.ID, groupID, roleID)
+		},
+		slog.Int64("role_id", roleID),
+	)
+}
+
+// SetGroupAdmin grants or revokes admin status of the group.
+func (g *Groups) SetGroupAdmin(ctx context.Context, callerToken string, groupID int64, isAdmin bool) error {
+	return g.change(ctx, "Groups.SetGroupAdmin", callerToken, groupID,
+		func(ctx context.Context, caller models.User) error {
+			return g.storage.SetGroupAdmin(ctx, caller.TenantID, caller.ID, groupID, isAdmin)
+		},
+		slog.Bool("is_admin", isAdmin),
+	)
+}
+
+// UserGroups returns all groups of the user of the caller's organization,
+// including groups inherited via nesting.
+func (g *Groups) UserGroups(ctx context.Context, callerToken string, userID int64) ([]models.Group, error) {
+	const op = "Groups.UserGroups"
+
+	caller, err := g.admins.Authenticate(ctx, callerToken)
+	if err != nil {
+		g.log.Warn("caller is not authenticated", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := g.storage.UserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.TenantID != caller.TenantID {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	groups, err := g.storage.UserGroups(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// change проверяет, что вызывающий - администратор, и выполняет изменение группы в его организации.
+// Проверка и изменение выполняются в одной транзакции: администратора не могут разжаловать между ними.
+// Изменения записываются хранилищем в журнал аудита от имени вызывающего.
+func (g *Groups) change(
+	ctx context.Context,
+	op string,
+	callerToken string,
+	groupID int64,
+	apply func(ctx context.Context, caller models.User) error,
+	attrs ...any,
+) error {
+	log := g.log.With(
+		slog.String("op", op),
+		slog.Int64("group_id", groupID),
+	).With(attrs...)
+
+	var caller models.User
+	err := g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		caller, err = g.admins.AuthorizeAdmin(ctx, callerToken)
+		if err != nil {
+			log.Warn("caller is not authorized", sl.Err(err))
+			return err
+		}
+
+		if err := apply(ctx, caller); err != nil {
+			log.Error("failed to change group", slog.Int64("actor_id", caller.ID), sl.Err(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("group changed", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
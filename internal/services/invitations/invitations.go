@@ -0,0 +1,326 @@
+// internal/services/invitations/invitations.go
+package invitations
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/lib/mail"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сервис приглашений. Администратор приглашает человека по email в приложение своей организации
+// (при желании сразу с ролью в этом приложении). Приглашённый получает письмо с одноразовым токеном
+// и принимает приглашение: если пользователя с таким email в организации ещё нет,
+// он регистрируется, иначе приглашение привязывается к существующему пользователю.
+// В хранилище попадает только хэш токена.
+
+var (
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrInvitationInactive = errors.New("invitation is already accepted, revoked or expired")
+	ErrPasswordRequired   = errors.New("password is required to register a new user")
+)
+
+// AdminAuthorizer проверяет, что токен вызывающего принадлежит администратору,
+// и возвращает его (реализован сервисом auth)
+type AdminAuthorizer interface {
+	AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error)
+}
+
+// UserProvider интерфейс получения пользователя организации по email
+type UserProvider interface {
+	User(ctx context.Context, tenantID int64, email string) (models.User, error)
+}
+
+// UserRegistrar регистрирует нового пользователя в организации приложения (реализован сервисом auth)
+type UserRegistrar interface {
+	RegisterNewUser(ctx context.Context, email string, pass string, appID int) (int64, error)
+}
+
+// AppProvider интерфейс для получения App (приложения) из хранилища
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+// InvitationStorage интерфейс хранилища приглашений
+type InvitationStorage interface {
+	SaveInvitation(ctx context.Context, inv models.Invitation, tokenHash string) (int64, error)
+	Invitations(ctx context.Context, tenantID int64, appID int) ([]models.Invitation, error)
+	InvitationByToken(ctx context.Context, tokenHash string) (models.Invitation, error)
+	RevokeInvitation(ctx context.Context, tenantID int64, actorID int64, id int64) error
+	AcceptInvitation(ctx context.Context, id int64, userID int64) error
+}
+
+// TxManager выполняет вызовы хранилища внутри fn в одной транзакции (реализован хранилищем)
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Sender отправляет письма. Реализации: mail.FileSender (для разработки), SMTP и т.п.
+type Sender interface {
+	Send(ctx context.Context, msg mail.Message) error
+}
+
+// Invitations структура сервиса приглашений
+type Invitations struct {
+	log       *slog.Logger
+	admins    AdminAuthorizer
+	users     UserProvider
+	registrar UserRegistrar
+	apps      AppProvider
+	storage   InvitationStorage
+	tx        TxManager
+	sender    Sender
+	ttl       time.Duration
+	acceptURL string
+}
+
+// New returns a new instance of Invitations service
+func New(
+	log *slog.Logger,
+	admins AdminAuthorizer,
+	users UserProvider,
+	registrar UserRegistrar,
+	apps AppProvider,
+	storage InvitationStorage,
+	tx TxManager,
+	sender Sender,
+	cfg config.InvitationsConfig,
+) *Invitations {
+	return &Invitations{
+		log:       log,
+		admins:    admins,
+		users:     users,
+		registrar: registrar,
+		apps:      apps,
+		storage:   storage,
+		tx:        tx,
+		sender:    sender,
+		ttl:       cfg.TTL,
+		acceptURL: cfg.AcceptURL,
+	}
+}
+
+// Create invites the person with the email into the app and sends them the invitation email.
+// roleID (необязательная, 0 - без роли) выдаётся пользователю при принятии приглашения.
+func (i *Invitations) Create(
+	ctx context.Context,
+	callerToken string,
+	appID int,
+	email string,
+	roleID int64,
+) (models.Invitation, error) {
+	const op = "Invitations.Create"
+
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("email", email),
+	)
+
+	email = strings.TrimSpace(email)
+	if addr, err := netmail.ParseAddress(email); err != nil || addr.Address != email {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	caller, err := i.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("actor_id", caller.ID))
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	inv := models.Invitation{
+		TenantID:  caller.TenantID,
+		AppID:     appID,
+		Email:     email,
+		RoleID:    roleID,
+		CreatedBy: caller.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(i.ttl),
+	}
+
+	inv.ID, err = i.storage.SaveInvitation(ctx, inv, tokenHash)
+	if err != nil {
+		log.Error("failed to save invitation", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := i.apps.App(ctx, appID)
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Если письмо не ушло, приглашение остаётся в списке: его можно отозвать и создать заново
+	if err := i.sender.Send(ctx, i.message(app, inv, token)); err != nil {
+		log.Error("failed to send invitation", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invitation created", slog.Int64("invitation_id", inv.ID))
+
+	return inv, nil
+}
+
+// List returns all invitations to the app.
+func (i *Invitations) List(ctx context.Context, callerToken string, appID int) ([]models.Invitation, error) {
+	const op = "Invitations.List"
+
+	caller, err := i.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitations, err := i.storage.Invitations(ctx, caller.TenantID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitations, nil
+}
+
+// Revoke revokes the pending invitation.
+func (i *Invitations) Revoke(ctx context.Context, callerToken string, invitationID int64) error {
+	const op = "Invitations.Revoke"
+
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("invitation_id", invitationID),
+	)
+
+	caller, err := i.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := i.storage.RevokeInvitation(ctx, caller.TenantID, caller.ID, invitationID); err != nil {
+		log.Warn("failed to revoke invitation", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invitation revoked", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
+
+// Accept accepts the invitation by its token and returns the ID of the invited user.
+// Если пользователя с email приглашения в организации ещё нет, он регистрируется с паролем password,
+// иначе пароль не нужен: владение токеном подтверждает владение email.
+func (i *Invitations) Accept(ctx context.Context, token string, password string) (int64, error) {
+	const op = "Invitations.Accept"
+
+	log := i.log.With(slog.String("op", op))
+
+	inv, err := i.storage.InvitationByToken(ctx, hashToken(token))
+	if err != nil {
+		log.Warn("invitation not found", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("invitation_id", inv.ID))
+
+	if status := inv.Status(time.Now()); status != models.InvitationPending {
+		log.Warn("invitation is not pending", slog.String("status", status))
+		return 0, fmt.Errorf("%s: %w", op, ErrInvitationInactive)
+	}
+
+	// Регистрация и принятие приглашения - одна транзакция: если приглашение успели принять
+	// или отозвать, зарегистрированный по нему пользователь не остаётся в каталоге
+	var userID int64
+	err = i.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := i.users.User(ctx, inv.TenantID, inv.Email)
+		switch {
+		case err == nil:
+			userID = user.ID
+		case errors.Is(err, storage.ErrUserNotFound):
+			if password == "" {
+				return ErrPasswordRequired
+			}
+
+			userID, err = i.registrar.RegisterNewUser(ctx, inv.Email, password, inv.AppID)
+			if err != nil {
+				log.Error("failed to register invited user", sl.Err(err))
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := i.storage.AcceptInvitation(ctx, inv.ID, userID); err != nil {
+			// приглашение успели принять, отозвать или оно истекло
+			if errors.Is(err, storage.ErrInvitationNotFound) {
+				log.Warn("invitation is no longer pending")
+				return ErrInvitationInactive
+			}
+
+			log.Error("failed to accept invitation", sl.Err(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invitation accepted", slog.Int64("user_id", userID))
+
+	return userID, nil
+}
+
+// message формирует письмо с приглашением
+func (i *Invitations) message(app models.App, inv models.Invitation, token string) mail.Message {
+	var body strings.Builder
+
+	fmt.Fprintf(&body, "You have been invited to %s.\r\n\r\n", app.Name)
+	if i.acceptURL != "" {
+		fmt.Fprintf(&body, "Accept the invitation: %s?token=%s\r\n\r\n", i.acceptURL, token)
+	}
+	fmt.Fprintf(&body, "Invitation token: %s\r\n", token)
+	fmt.Fprintf(&body, "The invitation expires at %s.\r\n", inv.ExpiresAt.UTC().Format(time.RFC1123))
+
+	return mail.Message{
+		To:      inv.Email,
+		Subject: "Invitation to " + app.Name,
+		Body:    body.String(),
+	}
+}
+
+// newToken генерирует одноразовый токен приглашения и его хэш для хранилища
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, hashToken(token), nil
+}
+
+// hashToken хэш токена приглашения. Токен случайный и длинный, поэтому соль не нужна.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,352 @@
+// internal/services/ldapauth/ldapauth.go
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/storage"
+)
+
+// Проверка паролей в LDAP / Active Directory.
+// Схема стандартная для LDAP-аутентификации:
+//  1. подключаемся сервисной учётной записью и ищем пользователя по email;
+//  2. выполняем bind от имени найденного пользователя с введённым паролем;
+//  3. по группам пользователя определяем его роли и синхронизируем их
+//     с локальным пользователем, создавая его при первом входе (just-in-time).
+//
+// Роли задаются в role_mapping (см. config.ParseLDAPRole): статус администратора организации
+// и роли RBAC в её приложениях. Роли из role_mapping выдаются и отзываются при каждом входе,
+// а назначенные вручную роли, которых в role_mapping нет, не трогаются.
+
+const (
+	defaultUserFilter     = "(mail=%s)"
+	defaultGroupAttribute = "memberOf"
+	defaultTimeout        = 5 * time.Second
+)
+
+// UserSaver Интерфейс сохранения пользователя
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		tenantID int64,
+		email string,
+		passHash []byte,
+	) (uid int64, err error)
+}
+
+// UserProvider Интерфейс получения пользователя
+type UserProvider interface {
+	User(ctx context.Context, tenantID int64, email string) (models.User, error)
+
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// AdminManager интерфейс изменения статуса администратора (с записью в журнал аудита)
+type AdminManager interface {
+	UpdateAdminStatus(ctx context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error
+}
+
+// RoleManager интерфейс назначения ролей RBAC (с записью в журнал аудита)
+type RoleManager interface {
+	Roles(ctx context.Context, tenantID int64, appID int) ([]models.Role, error)
+	AssignRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+	RevokeRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+}
+
+// Verifier реализация auth.CredentialVerifier для LDAP-каталога
+type Verifier struct {
+	log         *slog.Logger
+	cfg         config.LDAPConfig
+	mapping     map[string]config.LDAPRole // role_mapping с разобранными ролями
+	usrSaver    UserSaver
+	usrProvider UserProvider
+	adminMgr    AdminManager
+	roleMgr     RoleManager
+}
+
+var _ auth.CredentialVerifier = (*Verifier)(nil)
+
+// New returns a new instance of LDAP Verifier
+func New(
+	log *slog.Logger,
+	cfg config.LDAPConfig,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	adminManager AdminManager,
+	roleManager RoleManager,
+) *Verifier {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = defaultUserFilter
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = defaultGroupAttribute
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	// Значения проверены при загрузке конфига (config.MustLoad)
+	mapping := make(map[string]config.LDAPRole, len(cfg.RoleMapping))
+	for group, value := range cfg.RoleMapping {
+		role, err := config.ParseLDAPRole(value)
+		if err != nil {
+			log.Error("invalid ldap role mapping", slog.String("ldap", cfg.Name), slog.String("group", group), sl.Err(err))
+			continue
+		}
+		mapping[strings.TrimSpace(group)] = role
+	}
+
+	return &Verifier{
+		log:         log,
+		cfg:         cfg,
+		mapping:     mapping,
+		usrSaver:    userSaver,
+		usrProvider: userProvider,
+		adminMgr:    adminManager,
+		roleMgr:     roleManager,
+	}
+}
+
+// VerifyCredentials checks password with LDAP bind and returns the local user of the app's tenant
+func (v *Verifier) VerifyCredentials(ctx context.Context, email string, password string, app models.App) (models.User, error) {
+	const op = "ldapauth.VerifyCredentials"
+
+	log := v.log.With(
+		slog.String("op", op),
+		slog.String("ldap", v.cfg.Name),
+		slog.String("email", email),
+	)
+
+	// Bind с пустым паролем в LDAP - это анонимный bind, который обычно успешен.
+	// Такой "вход" нельзя принимать за проверку пароля.
+	if password == "" {
+		return models.User{}, fmt.Errorf("%s: %w", op, auth.ErrInvalidCredentials)
+	}
+
+	conn, err := v.dial()
+	if err != nil {
+		log.Error("failed to connect to ldap", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	entry, err := v.findUser(conn, email)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Info("user not found in ldap")
+		} else {
+			log.Error("failed to search user in ldap", sl.Err(err))
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			log.Info("invalid ldap credentials")
+			return models.User{}, fmt.Errorf("%s: %w", op, auth.ErrInvalidCredentials)
+		}
+
+		log.Error("ldap bind failed", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles := v.roles(entry.GetAttributeValues(v.cfg.GroupAttribute))
+
+	user, err := v.syncUser(ctx, app.TenantID, email, roles)
+	if err != nil {
+		log.Error("failed to sync ldap user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (v *Verifier) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(v.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: v.cfg.Timeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(v.cfg.Timeout)
+
+	if v.cfg.StartTLS {
+		host := v.cfg.URL
+		if i := strings.Index(host, "://"); i >= 0 {
+			host = host[i+3:]
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// findUser ищет пользователя по email от имени сервисной учётной записи.
+// Если пользователь не найден (или найден не однозначно) - ErrInvalidCredentials.
+func (v *Verifier) findUser(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	if v.cfg.BindDN != "" {
+		if err := conn.Bind(v.cfg.BindDN, v.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("service account bind: %w", err)
+		}
+	}
+
+	req := ldap.NewSearchRequest(
+		v.cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // больше одной записи нам всё равно не подходит
+		int(v.cfg.Timeout.Seconds()),
+		false,
+		fmt.Sprintf(v.cfg.UserFilter, ldap.EscapeFilter(email)),
+		[]string{"dn", v.cfg.GroupAttribute},
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, auth.ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if len(res.Entries) != 1 {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	return res.Entries[0], nil
+}
+
+// roles возвращает роли пользователя по его группам согласно role_mapping
+func (v *Verifier) roles(groups []string) map[config.LDAPRole]bool {
+	roles := make(map[config.LDAPRole]bool)
+
+	for group, role := range v.mapping {
+		for _, g := range groups {
+			// DN сравниваются без учёта регистра
+			if strings.EqualFold(strings.TrimSpace(g), group) {
+				roles[role] = true
+			}
+		}
+	}
+
+	return roles
+}
+
+// syncUser находит локального пользователя организации (или создаёт его) и приводит его роли
+// в соответствие с группами в каталоге: для таких пользователей источник истины - LDAP
+func (v *Verifier) syncUser(ctx context.Context, tenantID int64, email string, roles map[config.LDAPRole]bool) (models.User, error) {
+	user, err := v.usrProvider.User(ctx, tenantID, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		// Пароль хранится в каталоге, локальный хэш не нужен
+		id, err := v.usrSaver.SaveUser(ctx, tenantID, email, []byte{})
+		if err != nil {
+			return models.User{}, err
+		}
+
+		user = models.User{ID: id, TenantID: tenantID, Email: email}
+		v.log.Info("user created just-in-time", slog.String("ldap", v.cfg.Name), slog.Int64("user_id", id))
+	} else if err != nil {
+		return models.User{}, err
+	}
+
+	// Отключённый локально пользователь не может войти, даже если он есть в каталоге
+	if user.Disabled {
+		return models.User{}, auth.ErrInvalidCredentials
+	}
+
+	isAdmin, err := v.usrProvider.IsAdmin(ctx, user.ID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// Статус меняет сам сервис: изменение попадает в журнал аудита,
+	// а последнего администратора организации каталог разжаловать не может
+	admin := roles[config.LDAPRole{Admin: true}]
+	if isAdmin != admin {
+		err := v.adminMgr.UpdateAdminStatus(ctx, tenantID, models.SystemActorID, user.ID, admin)
+		if errors.Is(err, storage.ErrLastAdmin) {
+			v.log.Warn("last admin of the tenant is kept despite directory groups",
+				slog.String("ldap", v.cfg.Name), slog.Int64("user_id", user.ID))
+		} else if err != nil {
+			return models.User{}, err
+		}
+	}
+
+	if err := v.syncAppRoles(ctx, tenantID, user.ID, roles); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// syncAppRoles выдаёт пользователю роли RBAC из role_mapping, которые дают его группы, и отзывает остальные.
+// Назначение и отзыв идемпотентны, поэтому в журнал аудита попадают только изменения.
+// Роль, которой нет в приложении организации (не создана или приложение чужое), пропускается:
+// из-за неё пользователь не должен лишаться входа.
+func (v *Verifier) syncAppRoles(ctx context.Context, tenantID int64, userID int64, granted map[config.LDAPRole]bool) error {
+	// ИД ролей по приложениям: одна выборка на приложение
+	roleIDs := make(map[int]map[string]int64)
+	synced := make(map[config.LDAPRole]bool)
+
+	for _, role := range v.mapping {
+		// одна роль может даваться несколькими группами
+		if role.Admin || synced[role] {
+			continue
+		}
+		synced[role] = true
+
+		ids, ok := roleIDs[role.AppID]
+		if !ok {
+			appRoles, err := v.roleMgr.Roles(ctx, tenantID, role.AppID)
+			if err != nil {
+				return err
+			}
+
+			ids = make(map[string]int64, len(appRoles))
+			for _, r := range appRoles {
+				ids[r.Name] = r.ID
+			}
+			roleIDs[role.AppID] = ids
+		}
+
+		roleID, ok := ids[role.Name]
+		if !ok {
+			v.log.Warn("mapped role not found in the app", slog.String("ldap", v.cfg.Name),
+				slog.Int("app_id", role.AppID), slog.String("role", role.Name))
+			continue
+		}
+
+		if granted[role] {
+			if err := v.roleMgr.AssignRole(ctx, tenantID, models.SystemActorID, userID, roleID); err != nil {
+				return err
+			}
+		} else {
+			if err := v.roleMgr.RevokeRole(ctx, tenantID, models.SystemActorID, userID, roleID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
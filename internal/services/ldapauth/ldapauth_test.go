@@ -0,0 +1,507 @@
+// internal/services/ldapauth/ldapauth_test.go
+package ldapauth_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"grpc-service-ref/internal/config"
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/services/auth"
+	"grpc-service-ref/internal/services/ldapauth"
+	"grpc-service-ref/internal/storage"
+)
+
+// Тесты LDAP-бэкенда со встроенным LDAP-сервером (directory) на gldap
+// и хранилищем пользователей в памяти (fakeStorage).
+
+const (
+	baseDN       = "ou=people,dc=corp,dc=example"
+	serviceDN    = "cn=sso,dc=corp,dc=example"
+	servicePass  = "service-secret"
+	adminsGroup  = "cn=admins,ou=groups,dc=corp,dc=example"
+	devsGroup    = "cn=devs,ou=groups,dc=corp,dc=example"
+	opsGroup     = "cn=ops,ou=groups,dc=corp,dc=example"
+	ldapAppID    = 7
+	ldapDomain   = "corp.example"
+	localEmail   = "local@other.example"
+	localPass    = "local-password"
+	aliceEmail   = "alice@corp.example"
+	alicePass    = "alice-password"
+	aliceDN      = "uid=alice," + baseDN
+	bobEmail     = "bob@corp.example"
+	bobPass      = "bob-password"
+	bobDN        = "uid=bob," + baseDN
+	unknownEmail = "nobody@corp.example"
+)
+
+var (
+	defaultApp = models.App{ID: 1, TenantID: models.DefaultTenantID}
+	ldapApp    = models.App{ID: ldapAppID, TenantID: models.DefaultTenantID}
+)
+
+func TestVerifier_CreatesUserWithRoles(t *testing.T) {
+	dir := startDirectory(t)
+	st := newFakeStorage()
+	verifier := newVerifier(dir, st)
+
+	user, err := verifier.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+	assert.Equal(t, aliceEmail, user.Email)
+
+	// пользователь создан локально, а группа admins дала ему роль администратора
+	saved, err := st.User(context.Background(), models.DefaultTenantID, aliceEmail)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, saved.ID)
+	assert.True(t, st.admins[user.ID])
+
+	// bob не в группе администраторов
+	user, err = verifier.VerifyCredentials(context.Background(), bobEmail, bobPass, defaultApp)
+	require.NoError(t, err)
+	assert.False(t, st.admins[user.ID])
+}
+
+func TestVerifier_SyncsRolesOnEveryLogin(t *testing.T) {
+	dir := startDirectory(t)
+	st := newFakeStorage()
+	verifier := newVerifier(dir, st)
+
+	// локальный администратор, чтобы alice не была последней
+	localAdmin, err := st.SaveUser(context.Background(), models.DefaultTenantID, "root@corp.example", []byte("hash"))
+	require.NoError(t, err)
+	st.admins[localAdmin] = true
+
+	user, err := verifier.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+	require.True(t, st.admins[user.ID])
+
+	// alice исключили из группы администраторов в каталоге
+	dir.setGroups(aliceDN, devsGroup)
+
+	again, err := verifier.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+	assert.False(t, st.admins[user.ID])
+	assert.Len(t, st.users, 2)
+
+	// оба изменения записаны в журнал от имени самого сервиса
+	require.Len(t, st.audit, 2)
+	for _, event := range st.audit {
+		assert.Equal(t, models.SystemActorID, event.ActorID)
+		assert.Equal(t, models.AuditActionSetAdmin, event.Action)
+		assert.Equal(t, user.ID, event.TargetID)
+	}
+	assert.Equal(t, "false", st.audit[1].NewValue)
+}
+
+func TestVerifier_KeepsLastAdmin(t *testing.T) {
+	dir := startDirectory(t)
+	st := newFakeStorage()
+	verifier := newVerifier(dir, st)
+
+	user, err := verifier.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+	require.True(t, st.admins[user.ID])
+
+	// alice - единственный администратор: исключение из группы в каталоге не лишает организацию администратора,
+	// но и не мешает ей войти
+	dir.setGroups(aliceDN, devsGroup)
+
+	_, err = verifier.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+	assert.True(t, st.admins[user.ID])
+	assert.Len(t, st.audit, 1)
+}
+
+func TestVerifier_SyncsAppRoles(t *testing.T) {
+	dir := startDirectory(t)
+	st := newFakeStorage()
+	verifier := newVerifier(dir, st)
+
+	developer := st.addRole(ldapAppID, "developer")
+	manual := st.addRole(ldapAppID, "manual")
+
+	// группа devs даёт роль developer
+	user, err := verifier.VerifyCredentials(context.Background(), bobEmail, bobPass, defaultApp)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]bool{developer: true}, st.userRoles[user.ID])
+
+	// роль, назначенную вручную, каталог не отзывает, а роль из role_mapping - отзывает
+	st.userRoles[user.ID][manual] = true
+	dir.setGroups(bobDN)
+
+	_, err = verifier.VerifyCredentials(context.Background(), bobEmail, bobPass, defaultApp)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]bool{manual: true}, st.userRoles[user.ID])
+
+	require.Len(t, st.audit, 2)
+	assert.Equal(t, models.AuditActionAssignRole, st.audit[0].Action)
+	assert.Equal(t, models.AuditActionRevokeRole, st.audit[1].Action)
+	for _, event := range st.audit {
+		assert.Equal(t, models.SystemActorID, event.ActorID)
+		assert.Equal(t, user.ID, event.TargetID)
+	}
+}
+
+func TestVerifier_FailCases(t *testing.T) {
+	dir := startDirectory(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "Wrong password", email: aliceEmail, password: "wrong"},
+		{name: "Empty password", email: aliceEmail, password: ""},
+		{name: "Unknown user", email: unknownEmail, password: alicePass},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newFakeStorage()
+			verifier := newVerifier(dir, st)
+
+			_, err := verifier.VerifyCredentials(context.Background(), tt.email, tt.password, defaultApp)
+			require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+			assert.Empty(t, st.users)
+		})
+	}
+}
+
+func TestCredentialRouter_FallsBackToLocalHash(t *testing.T) {
+	dir := startDirectory(t)
+	st := newFakeStorage()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(localPass), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = st.SaveUser(context.Background(), models.DefaultTenantID, localEmail, hash)
+	require.NoError(t, err)
+
+	router := auth.NewCredentialRouter(auth.NewLocalVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), st, nil), auth.CredentialRoute{
+		Verifier: newVerifier(dir, st),
+		AppIDs:   []int{ldapAppID},
+		Domains:  []string{ldapDomain},
+	})
+
+	// домен corp.example проверяется в LDAP
+	_, err = router.VerifyCredentials(context.Background(), aliceEmail, alicePass, defaultApp)
+	require.NoError(t, err)
+
+	// остальные домены - по локальному хэшу
+	_, err = router.VerifyCredentials(context.Background(), localEmail, localPass, defaultApp)
+	require.NoError(t, err)
+
+	_, err = router.VerifyCredentials(context.Background(), localEmail, "wrong", defaultApp)
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// а в приложении ldapAppID все пароли проверяются в LDAP, где локального пользователя нет
+	_, err = router.VerifyCredentials(context.Background(), localEmail, localPass, ldapApp)
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func newVerifier(dir *directory, st *fakeStorage) *ldapauth.Verifier {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return ldapauth.New(log, config.LDAPConfig{
+		Name:         "corp",
+		URL:          dir.url,
+		BindDN:       serviceDN,
+		BindPassword: servicePass,
+		BaseDN:       baseDN,
+		RoleMapping: map[string]string{
+			adminsGroup: config.LDAPRoleAdmin,
+			devsGroup:   strconv.Itoa(ldapAppID) + ":developer",
+			opsGroup:    strconv.Itoa(ldapAppID) + ":operator", // такой роли в приложении нет
+		},
+	}, st, st, st, st)
+}
+
+// directory встроенный LDAP-сервер с парой пользователей
+type directory struct {
+	url string
+
+	mu    sync.Mutex
+	users []*dirUser
+}
+
+type dirUser struct {
+	dn       string
+	mail     string
+	password string
+	groups   []string
+}
+
+func startDirectory(t *testing.T) *directory {
+	t.Helper()
+
+	dir := &directory{
+		users: []*dirUser{
+			{dn: serviceDN, password: servicePass},
+			{dn: aliceDN, mail: aliceEmail, password: alicePass, groups: []string{adminsGroup, devsGroup}},
+			{dn: bobDN, mail: bobEmail, password: bobPass, groups: []string{devsGroup}},
+		},
+	}
+
+	server, err := gldap.NewServer(gldap.WithLogger(hclog.NewNullLogger()))
+	require.NoError(t, err)
+
+	mux, err := gldap.NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(dir.bind))
+	require.NoError(t, mux.Search(dir.search))
+	require.NoError(t, server.Router(mux))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	go func() { _ = server.Run(addr) }()
+	t.Cleanup(func() { _ = server.Stop() })
+
+	require.Eventually(t, server.Ready, 5*time.Second, 10*time.Millisecond)
+
+	dir.url = "ldap://" + addr
+
+	return dir
+}
+
+func (d *directory) setGroups(dn string, groups ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, u := range d.users {
+		if u.dn == dn {
+			u.groups = groups
+		}
+	}
+}
+
+func (d *directory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer func() { _ = w.Write(resp) }()
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, u := range d.users {
+		if u.dn == m.UserName && u.password == string(m.Password) {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+	}
+}
+
+// search поддерживает только фильтр вида (mail=<email>), которым ищет verifier
+func (d *directory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer func() { _ = w.Write(resp) }()
+
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+
+	if !strings.EqualFold(m.BaseDN, baseDN) {
+		resp.SetResultCode(gldap.ResultNoSuchObject)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, u := range d.users {
+		if u.mail == "" || m.Filter != fmt.Sprintf("(mail=%s)", ldap.EscapeFilter(u.mail)) {
+			continue
+		}
+
+		entry := r.NewSearchResponseEntry(u.dn)
+		entry.AddAttribute("memberOf", u.groups)
+		_ = w.Write(entry)
+	}
+}
+
+// fakeStorage хранилище пользователей в памяти
+type fakeStorage struct {
+	mu        sync.Mutex
+	users     []models.User
+	admins    map[int64]bool
+	roles     []models.Role
+	userRoles map[int64]map[int64]bool
+	audit     []models.AuditEvent
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{admins: make(map[int64]bool), userRoles: make(map[int64]map[int64]bool)}
+}
+
+// addRole создаёт роль в приложении организации по умолчанию
+func (s *fakeStorage) addRole(appID int, name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role := models.Role{ID: int64(len(s.roles) + 1), AppID: appID, Name: name}
+	s.roles = append(s.roles, role)
+
+	return role.ID
+}
+
+func (s *fakeStorage) SaveUser(_ context.Context, tenantID int64, email string, passHash []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return 0, storage.ErrUserExists
+		}
+	}
+
+	user := models.User{ID: int64(len(s.users) + 1), TenantID: tenantID, Email: email, PassHash: passHash}
+	s.users = append(s.users, user)
+
+	return user.ID, nil
+}
+
+func (s *fakeStorage) User(_ context.Context, tenantID int64, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) UserByID(_ context.Context, id int64) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || int(id) > len(s.users) {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return s.users[id-1], nil
+}
+
+func (s *fakeStorage) IsAdmin(_ context.Context, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userID < 1 || int(userID) > len(s.users) {
+		return false, storage.ErrUserNotFound
+	}
+
+	return s.admins[userID], nil
+}
+
+func (s *fakeStorage) UpdateAdminStatus(_ context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userID < 1 || int(userID) > len(s.users) || s.users[userID-1].TenantID != tenantID {
+		return storage.ErrUserNotFound
+	}
+	if s.admins[userID] == isAdmin {
+		return nil
+	}
+
+	if !isAdmin {
+		admins := 0
+		for _, u := range s.users {
+			if u.TenantID == tenantID && s.admins[u.ID] {
+				admins++
+			}
+		}
+		if admins == 1 {
+			return storage.ErrLastAdmin
+		}
+	}
+
+	s.admins[userID] = isAdmin
+	s.audit = append(s.audit, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAdmin,
+		TargetID: userID,
+		OldValue: strconv.FormatBool(!isAdmin),
+		NewValue: strconv.FormatBool(isAdmin),
+	})
+
+	return nil
+}
+
+func (s *fakeStorage) Roles(_ context.Context, tenantID int64, appID int) ([]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var roles []models.Role
+	for _, r := range s.roles {
+		if r.AppID == appID && tenantID == models.DefaultTenantID {
+			roles = append(roles, r)
+		}
+	}
+
+	return roles, nil
+}
+
+func (s *fakeStorage) AssignRole(_ context.Context, _ int64, actorID int64, userID int64, roleID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userRoles[userID] == nil {
+		s.userRoles[userID] = make(map[int64]bool)
+	}
+	if s.userRoles[userID][roleID] {
+		return nil
+	}
+	s.userRoles[userID][roleID] = true
+	s.audit = append(s.audit, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAssignRole,
+		TargetID: userID,
+		NewValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
+
+func (s *fakeStorage) RevokeRole(_ context.Context, _ int64, actorID int64, userID int64, roleID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userRoles[userID][roleID] {
+		return nil
+	}
+	delete(s.userRoles[userID], roleID)
+	s.audit = append(s.audit, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeRole,
+		TargetID: userID,
+		OldValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
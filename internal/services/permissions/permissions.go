@@ -0,0 +1,311 @@
+// internal/services/permissions/permissions.go
+package permissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сервис ролей и прав (RBAC). Роли задаются для каждого приложения отдельно,
+// роль даёт набор прав, пользователю можно назначить несколько ролей.
+// Видеть роли может любой пользователь организации, а изменять роли и назначения -
+// только администратор; роли и пользователи других организаций для них не существуют.
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// Authorizer проверяет токен вызывающего и возвращает его (реализован сервисом auth).
+// AuthorizeAdmin дополнительно требует, чтобы вызывающий был администратором.
+type Authorizer interface {
+	Authenticate(ctx context.Context, callerToken string) (models.User, error)
+	AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error)
+}
+
+// RoleStorage интерфейс хранилища ролей и их назначений.
+// Изменения ограничены организацией tenantID: чужие роли, приложения и пользователи для неё не существуют.
+type RoleStorage interface {
+	SaveRole(ctx context.Context, tenantID int64, appID int, name string, permissions []string) (int64, error)
+	Roles(ctx context.Context, tenantID int64, appID int) ([]models.Role, error)
+	SetRolePermissions(ctx context.Context, tenantID int64, roleID int64, permissions []string) error
+	DeleteRole(ctx context.Context, tenantID int64, id int64) error
+	AssignRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+	RevokeRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+	HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// Permissions структура сервиса ролей и прав
+type Permissions struct {
+	log     *slog.Logger
+	admins  Authorizer
+	storage RoleStorage
+}
+
+// New returns a new instance of Permissions service
+func New(log *slog.Logger, admins Authorizer, storage RoleStorage) *Permissions {
+	return &Permissions{
+		log:     log,
+		admins:  admins,
+		storage: storage,
+	}
+}
+
+// CreateRole creates a role of the app with the given permissions.
+func (p *Permissions) CreateRole(
+	ctx context.Context,
+	callerToken string,
+	appID int,
+	name string,
+	permissions []string,
+) (int64, error) {
+	const op = "Permissions.CreateRole"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("role", name),
+	)
+
+	name = strings.TrimSpace(name)
+	permissions, err := normalizePermissions(permissions)
+	if err != nil || name == "" {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := p.storage.SaveRole(ctx, caller.TenantID, appID, name, permissions)
+	if err != nil {
+		log.Error("failed to save role", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role created", slog.Int64("role_id", id), slog.Int64("actor_id", caller.ID))
+
+	return id, nil
+}
+
+// SetRolePermissions replaces permissions of the role.
+func (p *Permissions) SetRolePermissions(ctx context.Context, callerToken string, roleID int64, permissions []string) error {
+	const op = "Permissions.SetRolePermissions"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+	)
+
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.storage.SetRolePermissions(ctx, caller.TenantID, roleID, permissions); err != nil {
+		log.Error("failed to set role permissions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role permissions changed", slog.Int64("actor_id", caller.ID), slog.Any("permissions", permissions))
+
+	return nil
+}
+
+// DeleteRole deletes the role and all its assignments.
+func (p *Permissions) DeleteRole(ctx context.Context, callerToken string, roleID int64) error {
+	const op = "Permissions.DeleteRole"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+	)
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.storage.DeleteRole(ctx, caller.TenantID, roleID); err != nil {
+		log.Error("failed to delete role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role deleted", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
+
+// Roles returns all roles of the app of the caller's organization.
+func (p *Permissions) Roles(ctx context.Context, callerToken string, appID int) ([]models.Role, error) {
+	const op = "Permissions.Roles"
+
+	caller, err := p.admins.Authenticate(ctx, callerToken)
+	if err != nil {
+		p.log.Warn("caller is not authenticated", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := p.storage.Roles(ctx, caller.TenantID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole assigns the role to the user. Назначение записывается в журнал аудита.
+func (p *Permissions) AssignRole(ctx context.Context, callerToken string, userID int64, roleID int64) error {
+	const op = "Permissions.AssignRole"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+	)
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.storage.AssignRole(ctx, caller.TenantID, caller.ID, userID, roleID); err != nil {
+		log.Error("failed to assign role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role assigned", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
+
+// RevokeRole revokes the role from the user. Отзыв записывается в журнал аудита.
+func (p *Permissions) RevokeRole(ctx context.Context, callerToken string, userID int64, roleID int64) error {
+	const op = "Permissions.RevokeRole"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+	)
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authorized", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.storage.RevokeRole(ctx, caller.TenantID, caller.ID, userID, roleID); err != nil {
+		log.Error("failed to revoke role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role revoked", slog.Int64("actor_id", caller.ID))
+
+	return nil
+}
+
+// UserRoles returns names of the roles in the app of the user of the caller's organization.
+func (p *Permissions) UserRoles(ctx context.Context, callerToken string, userID int64, appID int) ([]string, error) {
+	const op = "Permissions.UserRoles"
+
+	caller, err := p.admins.Authenticate(ctx, callerToken)
+	if err != nil {
+		p.log.Warn("caller is not authenticated", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// роли выдаются только в приложениях организации пользователя, поэтому достаточно проверить его самого
+	user, err := p.storage.UserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.TenantID != caller.TenantID {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	roles, err := p.storage.UserRoles(ctx, userID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// CheckPermission checks whether the user of the caller's organization has the permission in the app.
+func (p *Permissions) CheckPermission(
+	ctx context.Context,
+	callerToken string,
+	userID int64,
+	appID int,
+	permission string,
+) (bool, error) {
+	const op = "Permissions.CheckPermission"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+		slog.String("permission", permission),
+	)
+
+	caller, err := p.admins.Authenticate(ctx, callerToken)
+	if err != nil {
+		log.Warn("caller is not authenticated", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// как и в UserRoles, права выдаются только в приложениях организации пользователя
+	user, err := p.storage.UserByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.TenantID != caller.TenantID {
+		log.Warn("user of another tenant requested", slog.Int64("actor_id", caller.ID))
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	allowed, err := p.storage.HasPermission(ctx, userID, appID, permission)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("checked permission", slog.Bool("allowed", allowed))
+
+	return allowed, nil
+}
+
+// normalizePermissions убирает пробелы и повторы; пустое право - ошибка
+func normalizePermissions(permissions []string) ([]string, error) {
+	result := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if permission == "" {
+			return nil, ErrInvalidRole
+		}
+		if !slices.Contains(result, permission) {
+			result = append(result, permission)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,389 @@
+// internal/services/policies/policies.go
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/logger/sl"
+	"grpc-service-ref/internal/lib/policy"
+	"grpc-service-ref/internal/storage"
+)
+
+// Сервис политик доступа (ABAC). У каждого приложения своя версионируемая политика
+// (формат описан в пакете lib/policy), которая вычисляется над атрибутами пользователя,
+// ресурса и контекста запроса. Изменять политики и атрибуты пользователей может только администратор.
+//
+// Атрибуты субъекта: заданные администратором атрибуты пользователя и встроенные
+// id, email, email_domain, roles (роли в приложении) и groups (имена групп, включая вложенность).
+// Атрибуты контекста по умолчанию: time (RFC 3339), hour и weekday (по UTC);
+// клиент может передать свои значения, например час в часовом поясе пользователя.
+
+var (
+	ErrInvalidAttributes = errors.New("invalid user attributes")
+)
+
+// Встроенные атрибуты субъекта, их нельзя задать администратору
+var reservedAttributes = []string{"id", "email", "email_domain", "roles", "groups"}
+
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AdminAuthorizer проверяет, что токен вызывающего принадлежит администратору,
+// и возвращает его (реализован сервисом auth)
+type AdminAuthorizer interface {
+	AuthorizeAdmin(ctx context.Context, callerToken string) (models.User, error)
+}
+
+// PolicyStorage интерфейс хранилища политик и атрибутов пользователей.
+// Изменения ограничены организацией tenantID: чужие приложения и пользователи для неё не существуют.
+type PolicyStorage interface {
+	SavePolicy(ctx context.Context, tenantID int64, actorID int64, appID int, document string) (int, error)
+	Policy(ctx context.Context, appID int, version int) (models.Policy, error)
+	SetUserAttributes(ctx context.Context, tenantID int64, actorID int64, userID int64, attributes map[string]string) error
+	UserAttributes(ctx context.Context, userID int64) (map[string]string, error)
+}
+
+// UserProvider интерфейс получения пользователя, его ролей и групп
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+}
+
+// AppProvider интерфейс для получения App (приложения) из хранилища
+type AppProvider interface {
+	App(ctx context.Context, appID int) (models.App, error)
+}
+
+// TxManager выполняет вызовы хранилища внутри fn в одной транзакции (реализован хранилищем)
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Request запрос на авторизацию действия пользователя в приложении
+type Request struct {
+	UserID   int64
+	AppID    int
+	Action   string
+	Resource map[string]string // Атрибуты ресурса
+	Context  map[string]string // Атрибуты контекста, дополняют и переопределяют значения по умолчанию
+	// Черновик политики для пробного вычисления (dry-run) вместо действующей политики приложения.
+	// Пробное вычисление доступно только администратору.
+	DryRunPolicy string
+}
+
+// Decision решение по запросу на авторизацию
+type Decision struct {
+	policy.Decision
+	PolicyVersion int  // Версия политики, по которой принято решение (0 - политики нет или dry-run)
+	DryRun        bool // Решение принято по черновику политики
+}
+
+// Policies структура сервиса политик доступа
+type Policies struct {
+	log     *slog.Logger
+	admins  AdminAuthorizer
+	users   UserProvider
+	apps    AppProvider
+	storage PolicyStorage
+	tx      TxManager
+}
+
+// New returns a new instance of Policies service
+func New(
+	log *slog.Logger,
+	admins AdminAuthorizer,
+	users UserProvider,
+	apps AppProvider,
+	storage PolicyStorage,
+	tx TxManager,
+) *Policies {
+	return &Policies{
+		log:     log,
+		admins:  admins,
+		users:   users,
+		apps:    apps,
+		storage: storage,
+		tx:      tx,
+	}
+}
+
+// SetPolicy validates the document and saves it as a new version of the app policy.
+func (p *Policies) SetPolicy(ctx context.Context, callerToken string, appID int, document string) (int, error) {
+	const op = "Policies.SetPolicy"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if _, err := policy.Parse([]byte(document)); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Проверка администратора и сохранение - в одной транзакции: его не могут разжаловать между ними
+	var caller models.User
+	var version int
+	err := p.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		caller, err = p.admins.AuthorizeAdmin(ctx, callerToken)
+		if err != nil {
+			log.Warn("caller is not authorized", sl.Err(err))
+			return err
+		}
+
+		version, err = p.storage.SavePolicy(ctx, caller.TenantID, caller.ID, appID, document)
+		if err != nil {
+			log.Error("failed to save policy", sl.Err(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("policy saved", slog.Int("version", version), slog.Int64("actor_id", caller.ID))
+
+	return version, nil
+}
+
+// Policy returns the version of the app policy; version 0 means the current one.
+func (p *Policies) Policy(ctx context.Context, callerToken string, appID int, version int) (models.Policy, error) {
+	const op = "Policies.Policy"
+
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.appInTenant(ctx, caller.TenantID, appID); err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pol, err := p.storage.Policy(ctx, appID, version)
+	if err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pol, nil
+}
+
+// SetUserAttributes replaces the attributes of the user used by policies.
+func (p *Policies) SetUserAttributes(
+	ctx context.Context,
+	callerToken string,
+	userID int64,
+	attributes map[string]string,
+) error {
+	const op = "Policies.SetUserAttributes"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	for name := range attributes {
+		if !attributeName.MatchString(name) || slices.Contains(reservedAttributes, name) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidAttributes)
+		}
+	}
+
+	var caller models.User
+	err := p.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		caller, err = p.admins.AuthorizeAdmin(ctx, callerToken)
+		if err != nil {
+			log.Warn("caller is not authorized", sl.Err(err))
+			return err
+		}
+
+		if err := p.storage.SetUserAttributes(ctx, caller.TenantID, caller.ID, userID, attributes); err != nil {
+			log.Error("failed to set user attributes", sl.Err(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user attributes changed", slog.Int64("actor_id", caller.ID), slog.Any("attributes", attributes))
+
+	return nil
+}
+
+// Authorize decides whether the user may perform the action on the resource in the app.
+// Без политики приложения любое действие запрещено. Для пробного вычисления (req.DryRunPolicy)
+// нужен токен администратора: черновик позволяет проверить любые атрибуты пользователя.
+func (p *Policies) Authorize(ctx context.Context, callerToken string, req Request) (Decision, error) {
+	const op = "Policies.Authorize"
+
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", req.UserID),
+		slog.Int("app_id", req.AppID),
+		slog.String("action", req.Action),
+	)
+
+	app, err := p.apps.App(ctx, req.AppID)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := p.users.UserByID(ctx, req.UserID)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// пользователи другой организации для приложения не существуют
+	if user.TenantID != app.TenantID {
+		return Decision{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	var pol *policy.Policy
+	var decision Decision
+	if req.DryRunPolicy != "" {
+		pol, err = p.dryRunPolicy(ctx, callerToken, app, req.DryRunPolicy)
+		if err != nil {
+			return Decision{}, fmt.Errorf("%s: %w", op, err)
+		}
+		decision.DryRun = true
+	} else {
+		stored, err := p.storage.Policy(ctx, app.ID, 0)
+		if errors.Is(err, storage.ErrPolicyNotFound) {
+			decision.Reasons = []string{"denied: the app has no policy"}
+			log.Info("authorization decided", slog.Bool("allowed", false))
+			return decision, nil
+		}
+		if err != nil {
+			return Decision{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// сохранённая политика прошла проверку при сохранении
+		pol, err = policy.Parse([]byte(stored.Document))
+		if err != nil {
+			log.Error("stored policy is invalid", slog.Int("version", stored.Version), sl.Err(err))
+			return Decision{}, fmt.Errorf("%s: %w", op, err)
+		}
+		decision.PolicyVersion = stored.Version
+	}
+
+	if user.Disabled {
+		decision.Reasons = []string{"denied: the user is disabled"}
+		log.Info("authorization decided", slog.Bool("allowed", false))
+		return decision, nil
+	}
+
+	subject, err := p.subject(ctx, user, app.ID)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	decision.Decision = pol.Evaluate(policy.Request{
+		Action:   req.Action,
+		Subject:  subject,
+		Resource: attributes(req.Resource),
+		Context:  requestContext(time.Now(), req.Context),
+	})
+
+	log.Info("authorization decided",
+		slog.Bool("allowed", decision.Allowed),
+		slog.Any("rules", decision.Rules),
+		slog.Bool("dry_run", decision.DryRun),
+	)
+
+	return decision, nil
+}
+
+// dryRunPolicy проверяет, что вызывающий - администратор организации приложения, и разбирает черновик
+func (p *Policies) dryRunPolicy(ctx context.Context, callerToken string, app models.App, document string) (*policy.Policy, error) {
+	caller, err := p.admins.AuthorizeAdmin(ctx, callerToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller.TenantID != app.TenantID {
+		return nil, storage.ErrAppNotFound
+	}
+
+	return policy.Parse([]byte(document))
+}
+
+// subject собирает атрибуты пользователя: заданные администратором и встроенные
+func (p *Policies) subject(ctx context.Context, user models.User, appID int) (policy.Attributes, error) {
+	custom, err := p.storage.UserAttributes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := p.users.UserRoles(ctx, user.ID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := p.users.UserGroups(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := attributes(custom)
+	subject.Set("id", strconv.FormatInt(user.ID, 10))
+	subject.Set("email", user.Email)
+	if _, domain, ok := strings.Cut(user.Email, "@"); ok {
+		subject.Set("email_domain", strings.ToLower(domain))
+	}
+	subject["roles"] = roles
+	for _, group := range groups {
+		subject["groups"] = append(subject["groups"], group.Name)
+	}
+
+	return subject, nil
+}
+
+func (p *Policies) appInTenant(ctx context.Context, tenantID int64, appID int) error {
+	app, err := p.apps.App(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	if app.TenantID != tenantID {
+		return storage.ErrAppNotFound
+	}
+
+	return nil
+}
+
+// requestContext атрибуты контекста по умолчанию, дополненные переданными клиентом
+func requestContext(now time.Time, values map[string]string) policy.Attributes {
+	now = now.UTC()
+
+	ctx := policy.Attributes{}
+	ctx.Set("time", now.Format(time.RFC3339))
+	ctx.Set("hour", strconv.Itoa(now.Hour()))
+	ctx.Set("weekday", strings.ToLower(now.Weekday().String()))
+
+	for name, value := range values {
+		ctx.Set(name, value)
+	}
+
+	return ctx
+}
+
+func attributes(values map[string]string) policy.Attributes {
+	attrs := make(policy.Attributes, len(values))
+	for name, value := range values {
+		attrs.Set(name, value)
+	}
+
+	return attrs
+}
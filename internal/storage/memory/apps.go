@@ -0,0 +1,435 @@
+// internal/storage/memory/apps.go
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// App returns app by id. Для отключённого приложения возвращается storage.ErrAppDisabled.
+func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
+	const op = "storage.memory.App"
+
+	defer s.rlock(ctx)()
+
+	app, ok := s.apps[id]
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	if app.Disabled {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+	}
+
+	return copyApp(app), nil
+}
+
+// SAMLApp returns SAML service provider app by its entity ID.
+// Для отключённого приложения возвращается storage.ErrAppDisabled.
+func (s *Storage) SAMLApp(ctx context.Context, entityID string) (models.App, error) {
+	const op = "storage.memory.SAMLApp"
+
+	defer s.rlock(ctx)()
+
+	for _, id := range sortedKeys(s.apps) {
+		app := s.apps[id]
+		if app.Kind != models.AppKindSAML || app.SAML.EntityID != entityID {
+			continue
+		}
+		if app.Disabled {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+		}
+
+		return copyApp(app), nil
+	}
+
+	return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+}
+
+// SetAppAccess changes the access mode and allowed email domains of the app
+// and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppAccess(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	mode string,
+	domains []string,
+) error {
+	const op = "storage.memory.SetAppAccess"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// домены сравниваем и пишем в журнал так же, как их хранят SQL-реализации: строкой через пробел
+	oldDomains := strings.Join(app.AllowedDomains, " ")
+	newDomains := strings.Join(domains, " ")
+	if app.AccessMode == mode && oldDomains == newDomains {
+		return nil
+	}
+
+	oldMode := app.AccessMode
+	app.AccessMode = mode
+	app.AllowedDomains = strings.Fields(newDomains)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAppAccess,
+		TargetID: int64(appID),
+		OldValue: strings.TrimSpace(oldMode + " " + oldDomains),
+		NewValue: strings.TrimSpace(mode + " " + newDomains),
+	})
+
+	return nil
+}
+
+// IsAppMember checks whether the user is a member of the app.
+func (s *Storage) IsAppMember(ctx context.Context, appID int, userID int64) (bool, error) {
+	defer s.rlock(ctx)()
+
+	return s.appMembers[appID][userID], nil
+}
+
+// AppMembers returns IDs of the app members.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) AppMembers(ctx context.Context, tenantID int64, appID int) ([]int64, error) {
+	const op = "storage.memory.AppMembers"
+
+	defer s.rlock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return setIDs(s.appMembers[appID]), nil
+}
+
+// AddAppMember adds the user to the app members and writes the change to the audit log.
+// Повторное добавление ничего не меняет.
+// Приложение и пользователь должны быть из организации tenantID.
+func (s *Storage) AddAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error {
+	const op = "storage.memory.AddAppMember"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.userInTenant(tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	members := setOf(s.appMembers, appID)
+	if members[userID] {
+		return nil
+	}
+	members[userID] = true
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddAppMember,
+		TargetID: int64(appID),
+		NewValue: strconv.FormatInt(userID, 10),
+	})
+
+	return nil
+}
+
+// RemoveAppMember removes the user from the app members and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) RemoveAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error {
+	const op = "storage.memory.RemoveAppMember"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !s.appMembers[appID][userID] {
+		return nil
+	}
+	delete(s.appMembers[appID], userID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveAppMember,
+		TargetID: int64(appID),
+		OldValue: strconv.FormatInt(userID, 10),
+	})
+
+	return nil
+}
+
+// SaveApp saves a new app of the organization, writes it to the audit log and returns app id.
+// Сохраняются имя, секрет, вид и настройки SAML; остальное - по умолчанию, как в SQL-реализациях.
+func (s *Storage) SaveApp(ctx context.Context, tenantID int64, actorID int64, app models.App) (int, error) {
+	const op = "storage.memory.SaveApp"
+
+	defer s.lock(ctx)()
+
+	saved := &models.App{
+		ID:             s.lastAppID + 1,
+		TenantID:       tenantID,
+		Name:           app.Name,
+		Secret:         app.Secret,
+		Kind:           app.Kind,
+		AccessMode:     models.AppAccessOpen,
+		AllowedDomains: []string{},
+		SAML:           app.SAML,
+		Tokens: models.TokenSettings{
+			Algorithm:       models.TokenAlgHS256,
+			ClaimAttributes: []string{},
+			StaticClaims:    map[string]string{},
+		},
+	}
+
+	// имя в организации или entity ID SAML-приложения уже заняты
+	if s.appConflicts(saved) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+
+	s.lastAppID = saved.ID
+	s.apps[saved.ID] = saved
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionCreateApp,
+		TargetID: int64(saved.ID),
+		NewValue: app.Name,
+	})
+
+	return saved.ID, nil
+}
+
+// Apps returns a page of the organization apps (including disabled ones) and the total number of its apps.
+func (s *Storage) Apps(ctx context.Context, tenantID int64, offset int, limit int) ([]models.App, int, error) {
+	defer s.rlock(ctx)()
+
+	var apps []models.App
+	for _, id := range sortedKeys(s.apps) {
+		if app := s.apps[id]; app.TenantID == tenantID {
+			apps = append(apps, copyApp(app))
+		}
+	}
+
+	return page(apps, offset, limit), len(apps), nil
+}
+
+// UpdateApp updates name, kind and SAML settings of the app and writes the change to the audit log.
+// Секрет, режим доступа и отключение здесь не меняются. Приложение должно принадлежать организации tenantID.
+func (s *Storage) UpdateApp(ctx context.Context, tenantID int64, actorID int64, updated models.App) error {
+	const op = "storage.memory.UpdateApp"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, updated.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	candidate := *app
+	candidate.Name = updated.Name
+	candidate.Kind = updated.Kind
+	candidate.SAML = updated.SAML
+	if s.appConflicts(&candidate) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+
+	oldName, oldKind := app.Name, app.Kind
+	*app = candidate
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionUpdateApp,
+		TargetID: int64(app.ID),
+		OldValue: oldName + " " + oldKind,
+		NewValue: app.Name + " " + app.Kind,
+	})
+
+	return nil
+}
+
+// SetAppDisabled disables or enables the app and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppDisabled(ctx context.Context, tenantID int64, actorID int64, appID int, disabled bool) error {
+	const op = "storage.memory.SetAppDisabled"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	old := app.Disabled
+	if old == disabled {
+		return nil
+	}
+	app.Disabled = disabled
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAppDisabled,
+		TargetID: int64(appID),
+		OldValue: strconv.FormatBool(old),
+		NewValue: strconv.FormatBool(disabled),
+	})
+
+	return nil
+}
+
+// SetAppTokenSettings replaces the token settings of the app and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppTokenSettings(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	settings models.TokenSettings,
+) error {
+	const op = "storage.memory.SetAppTokenSettings"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	old := app.Tokens
+
+	// SQL-реализации хранят время жизни в целых секундах, атрибуты - строкой через пробел
+	app.Tokens = models.TokenSettings{
+		AccessTTL:       settings.AccessTTL / time.Second * time.Second,
+		Algorithm:       settings.Algorithm,
+		ClaimAttributes: strings.Fields(strings.Join(settings.ClaimAttributes, " ")),
+		StaticClaims:    maps.Clone(settings.StaticClaims),
+	}
+	if app.Tokens.StaticClaims == nil {
+		app.Tokens.StaticClaims = map[string]string{}
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetTokenSettings,
+		TargetID: int64(appID),
+		OldValue: formatTokenSettings(old),
+		NewValue: formatTokenSettings(settings),
+	})
+
+	return nil
+}
+
+// DeleteApp deletes the app with its roles, members, invitations, policies and token exchange policies
+// and writes the deletion to the audit log. Приложение должно принадлежать организации tenantID.
+func (s *Storage) DeleteApp(ctx context.Context, tenantID int64, actorID int64, appID int) error {
+	const op = "storage.memory.DeleteApp"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for id, r := range s.roles {
+		if r.appID == appID {
+			s.deleteRole(id)
+		}
+	}
+	for id, inv := range s.invitations {
+		if inv.AppID == appID {
+			delete(s.invitations, id)
+		}
+	}
+	for key := range s.exchangePolicies {
+		if key[0] == appID || key[1] == appID {
+			delete(s.exchangePolicies, key)
+		}
+	}
+	delete(s.appMembers, appID)
+	delete(s.policies, appID)
+	delete(s.apps, appID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionDeleteApp,
+		TargetID: int64(appID),
+		OldValue: app.Name,
+	})
+
+	return nil
+}
+
+// appInTenant возвращает приложение, если оно принадлежит организации.
+// Приложения других организаций для неё не существуют: storage.ErrAppNotFound.
+func (s *Storage) appInTenant(tenantID int64, appID int) (*models.App, error) {
+	app, ok := s.apps[appID]
+	if !ok || app.TenantID != tenantID {
+		return nil, storage.ErrAppNotFound
+	}
+
+	return app, nil
+}
+
+// appConflicts занято ли другим приложением имя в организации или entity ID SAML-приложения
+// (ограничения UNIQUE в SQL-реализациях)
+func (s *Storage) appConflicts(app *models.App) bool {
+	for id, other := range s.apps {
+		if id == app.ID {
+			continue
+		}
+		if other.TenantID == app.TenantID && other.Name == app.Name {
+			return true
+		}
+		if app.SAML.EntityID != "" && other.SAML.EntityID == app.SAML.EntityID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyApp копия приложения, не разделяющая с хранилищем слайсы и map
+func copyApp(app *models.App) models.App {
+	c := *app
+	c.AllowedDomains = slices.Clone(app.AllowedDomains)
+	c.Tokens.ClaimAttributes = slices.Clone(app.Tokens.ClaimAttributes)
+	c.Tokens.StaticClaims = maps.Clone(app.Tokens.StaticClaims)
+
+	return c
+}
+
+// formatTokenSettings настройки токенов в виде строки для журнала аудита (как в sqlite)
+func formatTokenSettings(settings models.TokenSettings) string {
+	// ключи map json.Marshal сортирует сам, так что запись детерминирована
+	b, _ := json.Marshal(struct {
+		AccessTTL       string            `json:"access_ttl"`
+		Algorithm       string            `json:"algorithm"`
+		ClaimAttributes []string          `json:"claim_attributes"`
+		StaticClaims    map[string]string `json:"static_claims"`
+	}{
+		AccessTTL:       settings.AccessTTL.String(),
+		Algorithm:       settings.Algorithm,
+		ClaimAttributes: settings.ClaimAttributes,
+		StaticClaims:    settings.StaticClaims,
+	})
+
+	return string(b)
+}
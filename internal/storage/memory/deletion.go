@@ -0,0 +1,115 @@
+// internal/storage/memory/deletion.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+)
+
+// DeleteUser marks the user of the tenant as deleted on behalf of the actor
+// and records the deletion in the audit log.
+// Последнего администратора организации удалить нельзя: storage.ErrLastAdmin.
+func (s *Storage) DeleteUser(ctx context.Context, tenantID int64, actorID int64, userID int64) error {
+	const op = "storage.memory.DeleteUser"
+
+	defer s.lock(ctx)()
+
+	u, err := s.userInTenant(tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins := s.adminCount(tenantID)
+	u.deletedAt = time.Now().UTC()
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		u.deletedAt = time.Time{}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionDeleteUser,
+		TargetID: userID,
+	})
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users marked as deleted before deletedBefore
+// and returns how many users were purged.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer s.lock(ctx)()
+
+	purged := 0
+	for _, id := range sortedKeys(s.users) {
+		u := s.users[id]
+		if !u.deleted() || !u.deletedAt.Before(deletedBefore) {
+			continue
+		}
+
+		s.purgeUser(u)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purgeUser удаляет пользователя со всеми связями и обезличивает его данные
+// в приглашениях и журнале аудита, как PurgeDeletedUsers в SQL-реализациях
+func (s *Storage) purgeUser(u *user) {
+	now := time.Now().UTC()
+	invitations := make(map[int64]bool)
+	for id, inv := range s.invitations {
+		if inv.TenantID != u.TenantID || (inv.Email != u.Email && inv.AcceptedBy != u.ID) {
+			continue
+		}
+
+		invitations[id] = true
+		inv.Email = ""
+		inv.AcceptedBy = 0
+		if inv.AcceptedAt.IsZero() && inv.RevokedAt.IsZero() {
+			inv.RevokedAt = now
+		}
+	}
+
+	for i, event := range s.audit {
+		switch {
+		case event.Action == models.AuditActionCreateInvitation && invitations[event.TargetID]:
+			s.audit[i].NewValue = ""
+		case event.Action == models.AuditActionSetUserAttributes && event.TargetID == u.ID:
+			s.audit[i].OldValue, s.audit[i].NewValue = "", ""
+		}
+	}
+
+	for _, versions := range s.policies {
+		for i := range versions {
+			if versions[i].CreatedBy == u.ID {
+				versions[i].CreatedBy = 0
+			}
+		}
+	}
+
+	for key, userID := range s.identities {
+		if userID == u.ID {
+			delete(s.identities, key)
+		}
+	}
+	for _, g := range s.groups {
+		delete(g.members, u.ID)
+	}
+	for _, members := range s.appMembers {
+		delete(members, u.ID)
+	}
+	delete(s.userRoles, u.ID)
+	delete(s.attributes, u.ID)
+	delete(s.users, u.ID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		Action:   models.AuditActionPurgeUser,
+		TargetID: u.ID,
+	})
+}
@@ -0,0 +1,464 @@
+// internal/storage/memory/groups.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// group группа со своими связями: участниками и вложенными группами
+type group struct {
+	id         int64
+	tenantID   int64
+	name       string
+	externalID string
+	isAdmin    bool
+	members    map[int64]bool
+	children   map[int64]bool
+}
+
+// SaveGroup saves a new group of the tenant and returns its id.
+func (s *Storage) SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error) {
+	const op = "storage.memory.SaveGroup"
+
+	defer s.lock(ctx)()
+
+	if s.groupByName(tenantID, name) != nil {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+	}
+
+	s.lastGroupID++
+	s.groups[s.lastGroupID] = &group{
+		id:         s.lastGroupID,
+		tenantID:   tenantID,
+		name:       name,
+		externalID: externalID,
+		members:    make(map[int64]bool),
+		children:   make(map[int64]bool),
+	}
+
+	return s.lastGroupID, nil
+}
+
+// Group returns group by id with its members.
+func (s *Storage) Group(ctx context.Context, id int64) (models.Group, error) {
+	const op = "storage.memory.Group"
+
+	defer s.rlock(ctx)()
+
+	g, ok := s.groups[id]
+	if !ok {
+		return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	group := g.model()
+	group.Members = setIDs(g.members)
+	group.Subgroups = setIDs(g.children)
+	group.Roles = setIDs(s.groupRoles[id])
+
+	return group, nil
+}
+
+// Groups returns a page of groups matching the filter and the total number of matching groups.
+func (s *Storage) Groups(ctx context.Context, filter storage.GroupFilter, offset int, limit int) ([]models.Group, int, error) {
+	defer s.rlock(ctx)()
+
+	var groups []models.Group
+	for _, id := range sortedKeys(s.groups) {
+		g := s.groups[id]
+		if g.tenantID != filter.TenantID ||
+			(filter.Name != "" && g.name != filter.Name) ||
+			(filter.ExternalID != "" && g.externalID != filter.ExternalID) {
+			continue
+		}
+
+		group := g.model()
+		group.Members = setIDs(g.members)
+		groups = append(groups, group)
+	}
+
+	return page(groups, offset, limit), len(groups), nil
+}
+
+// UpdateGroup updates name and external id of the group.
+func (s *Storage) UpdateGroup(ctx context.Context, updated models.Group) error {
+	const op = "storage.memory.UpdateGroup"
+
+	defer s.lock(ctx)()
+
+	g, ok := s.groups[updated.ID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	if other := s.groupByName(g.tenantID, updated.Name); other != nil && other.id != g.id {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+	}
+
+	g.name = updated.Name
+	g.externalID = updated.ExternalID
+
+	return nil
+}
+
+// SetGroupMembers replaces members of the group of the tenant.
+// Если кого-то из пользователей нет в организации - возвращается storage.ErrUserNotFound
+// и состав группы не меняется.
+func (s *Storage) SetGroupMembers(ctx context.Context, tenantID int64, groupID int64, userIDs []int64) error {
+	const op = "storage.memory.SetGroupMembers"
+
+	defer s.lock(ctx)()
+
+	g, err := s.groupInTenant(tenantID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	members := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if _, err := s.userInTenant(tenantID, userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		members[userID] = true
+	}
+
+	// из группы администраторов нельзя убрать последнего администратора
+	admins := s.adminCount(tenantID)
+	old := g.members
+	g.members = members
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		g.members = old
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes the group of the tenant and its memberships.
+func (s *Storage) DeleteGroup(ctx context.Context, tenantID int64, id int64) error {
+	const op = "storage.memory.DeleteGroup"
+
+	defer s.lock(ctx)()
+
+	g, err := s.groupInTenant(tenantID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins := s.adminCount(tenantID)
+
+	// группы, в которые вложена удаляемая, - чтобы вернуть связи при откате
+	var parents []*group
+	for _, parent := range s.groups {
+		if parent.children[id] {
+			parents = append(parents, parent)
+			delete(parent.children, id)
+		}
+	}
+	delete(s.groups, id)
+
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		s.groups[id] = g
+		for _, parent := range parents {
+			parent.children[id] = true
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	delete(s.groupRoles, id)
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group and writes the change to the audit log.
+// Повторное добавление ничего не меняет.
+// Группа и пользователь должны быть из организации tenantID.
+func (s *Storage) AddGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.memory.AddGroupMember"
+
+	defer s.lock(ctx)()
+
+	g, err := s.groupInTenant(tenantID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.userInTenant(tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if g.members[userID] {
+		return nil
+	}
+	g.members[userID] = true
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddGroupMember,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(userID, 10),
+	})
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.memory.RemoveGroupMember"
+
+	defer s.lock(ctx)()
+
+	err := s.removeGroupLink(tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveGroupMember,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(userID, 10),
+	}, func(g *group) map[int64]bool { return g.members }, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddSubgroup nests the child group into the group and writes the change to the audit log.
+// Обе группы должны быть из организации tenantID.
+// Если группа уже вложена в child (напрямую или через другие группы), возвращается storage.ErrGroupCycle.
+func (s *Storage) AddSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.memory.AddSubgroup"
+
+	defer s.lock(ctx)()
+
+	g, err := s.groupInTenant(tenantID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := s.groupInTenant(tenantID, childID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Цикл появится, если group - это сама child или одна из её вложенных групп
+	if s.descendants(childID)[groupID] {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupCycle)
+	}
+
+	if g.children[childID] {
+		return nil
+	}
+	g.children[childID] = true
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddSubgroup,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(childID, 10),
+	})
+
+	return nil
+}
+
+// RemoveSubgroup removes the child group from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.memory.RemoveSubgroup"
+
+	defer s.lock(ctx)()
+
+	err := s.removeGroupLink(tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveSubgroup,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(childID, 10),
+	}, func(g *group) map[int64]bool { return g.children }, childID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetGroupAdmin grants or revokes admin status of the group and writes the change to the audit log.
+// Последнего администратора разжаловать нельзя (storage.ErrLastAdmin).
+func (s *Storage) SetGroupAdmin(ctx context.Context, tenantID int64, actorID int64, groupID int64, isAdmin bool) error {
+	const op = "storage.memory.SetGroupAdmin"
+
+	defer s.lock(ctx)()
+
+	g, err := s.groupInTenant(tenantID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	wasAdmin := g.isAdmin
+	if wasAdmin == isAdmin {
+		return nil
+	}
+
+	admins := s.adminCount(tenantID)
+	g.isAdmin = isAdmin
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		g.isAdmin = wasAdmin
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetGroupAdmin,
+		TargetID: groupID,
+		OldValue: strconv.FormatBool(wasAdmin),
+		NewValue: strconv.FormatBool(isAdmin),
+	})
+
+	return nil
+}
+
+// UserGroups returns all groups of the user: direct ones and the groups they are nested into.
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	defer s.rlock(ctx)()
+
+	var groups []models.Group
+	for _, id := range setIDs(s.userGroups(userID)) {
+		groups = append(groups, s.groups[id].model())
+	}
+
+	return groups, nil
+}
+
+// removeGroupLink удаляет связь группы (участника или вложенную группу, links выбирает множество)
+// с проверкой последнего администратора и записью в журнал аудита
+func (s *Storage) removeGroupLink(
+	tenantID int64,
+	event models.AuditEvent,
+	links func(g *group) map[int64]bool,
+	id int64,
+) error {
+	g, err := s.groupInTenant(tenantID, event.TargetID)
+	if err != nil {
+		return err
+	}
+
+	set := links(g)
+	if !set[id] {
+		return nil
+	}
+
+	admins := s.adminCount(tenantID)
+	delete(set, id)
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		set[id] = true
+		return err
+	}
+
+	s.insertAuditEvent(event)
+
+	return nil
+}
+
+// model группа без связей (их заполняет вызывающий)
+func (g *group) model() models.Group {
+	return models.Group{
+		ID:         g.id,
+		TenantID:   g.tenantID,
+		Name:       g.name,
+		ExternalID: g.externalID,
+		IsAdmin:    g.isAdmin,
+	}
+}
+
+func (s *Storage) groupByName(tenantID int64, name string) *group {
+	for _, g := range s.groups {
+		if g.tenantID == tenantID && g.name == name {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// groupInTenant возвращает группу, если она есть в организации:
+// группы других организаций для неё не существуют
+func (s *Storage) groupInTenant(tenantID int64, groupID int64) (*group, error) {
+	g, ok := s.groups[groupID]
+	if !ok || g.tenantID != tenantID {
+		return nil, storage.ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// userGroups все группы пользователя: где он состоит напрямую и все группы, в которые они вложены
+func (s *Storage) userGroups(userID int64) map[int64]bool {
+	found := make(map[int64]bool)
+	for id, g := range s.groups {
+		if g.members[userID] {
+			found[id] = true
+		}
+	}
+
+	// поднимаемся по вложенности, пока находятся новые группы
+	for changed := true; changed; {
+		changed = false
+		for id, g := range s.groups {
+			if found[id] {
+				continue
+			}
+			for child := range g.children {
+				if found[child] {
+					found[id] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// descendants группа и все вложенные в неё группы
+func (s *Storage) descendants(groupID int64) map[int64]bool {
+	found := map[int64]bool{groupID: true}
+	queue := []int64{groupID}
+	for len(queue) > 0 {
+		g, ok := s.groups[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for child := range g.children {
+			if !found[child] {
+				found[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return found
+}
+
+// inAdminGroup состоит ли пользователь (в том числе через вложенные группы) в группе администраторов
+func (s *Storage) inAdminGroup(userID int64) bool {
+	for id := range s.userGroups(userID) {
+		if s.groups[id].isAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setIDs элементы множества по возрастанию; для пустого множества - nil
+func setIDs(set map[int64]bool) []int64 {
+	if len(set) == 0 {
+		return nil
+	}
+
+	return sortedKeys(set)
+}
@@ -0,0 +1,168 @@
+// internal/storage/memory/invitations.go
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// invitation приглашение и хэш его токена (сам токен не хранится)
+type invitation struct {
+	models.Invitation
+	tokenHash string
+}
+
+// SaveInvitation saves a new invitation with the hash of its token and writes it to the audit log.
+// Приложение должно принадлежать организации приглашения, а роль (если задана) - приложению.
+func (s *Storage) SaveInvitation(ctx context.Context, inv models.Invitation, tokenHash string) (int64, error) {
+	const op = "storage.memory.SaveInvitation"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.appInTenant(inv.TenantID, inv.AppID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if inv.RoleID != 0 {
+		if r, ok := s.roles[inv.RoleID]; !ok || r.appID != inv.AppID {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+	}
+
+	// в SQL-реализациях хэш токена уникален; совпадение - ошибка генерации токена, а не ситуация для клиента
+	if s.invitationByToken(tokenHash) != nil {
+		return 0, fmt.Errorf("%s: %w", op, errors.New("duplicate invitation token"))
+	}
+
+	s.lastInvitationID++
+	s.invitations[s.lastInvitationID] = &invitation{
+		Invitation: models.Invitation{
+			ID:        s.lastInvitationID,
+			TenantID:  inv.TenantID,
+			AppID:     inv.AppID,
+			Email:     inv.Email,
+			RoleID:    inv.RoleID,
+			CreatedBy: inv.CreatedBy,
+			CreatedAt: inv.CreatedAt.UTC(),
+			ExpiresAt: inv.ExpiresAt.UTC(),
+		},
+		tokenHash: tokenHash,
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  inv.CreatedBy,
+		Action:   models.AuditActionCreateInvitation,
+		TargetID: s.lastInvitationID,
+		NewValue: inv.Email,
+	})
+
+	return s.lastInvitationID, nil
+}
+
+// Invitations returns all invitations to the app, newest first.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) Invitations(ctx context.Context, tenantID int64, appID int) ([]models.Invitation, error) {
+	const op = "storage.memory.Invitations"
+
+	defer s.rlock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids := sortedKeys(s.invitations)
+
+	var invitations []models.Invitation
+	for i := len(ids) - 1; i >= 0; i-- {
+		if inv := s.invitations[ids[i]]; inv.AppID == appID {
+			invitations = append(invitations, inv.Invitation)
+		}
+	}
+
+	return invitations, nil
+}
+
+// InvitationByToken returns the invitation by the hash of its token.
+func (s *Storage) InvitationByToken(ctx context.Context, tokenHash string) (models.Invitation, error) {
+	const op = "storage.memory.InvitationByToken"
+
+	defer s.rlock(ctx)()
+
+	inv := s.invitationByToken(tokenHash)
+	if inv == nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	return inv.Invitation, nil
+}
+
+// RevokeInvitation revokes the pending invitation and writes the change to the audit log.
+// Принятое или уже отозванное приглашение, как и приглашение другой организации,
+// считается ненайденным (storage.ErrInvitationNotFound).
+func (s *Storage) RevokeInvitation(ctx context.Context, tenantID int64, actorID int64, id int64) error {
+	const op = "storage.memory.RevokeInvitation"
+
+	defer s.lock(ctx)()
+
+	inv, ok := s.invitations[id]
+	if !ok || inv.TenantID != tenantID || !inv.AcceptedAt.IsZero() || !inv.RevokedAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+	inv.RevokedAt = time.Now().UTC()
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeInvitation,
+		TargetID: id,
+	})
+
+	return nil
+}
+
+// AcceptInvitation marks the invitation as accepted by the user, makes the user a member
+// of the app and grants the role of the invitation. Всё выполняется под одной блокировкой,
+// поэтому приглашение нельзя принять дважды.
+// Если приглашение уже принято, отозвано или истекло, возвращается storage.ErrInvitationNotFound.
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, userID int64) error {
+	const op = "storage.memory.AcceptInvitation"
+
+	defer s.lock(ctx)()
+
+	now := time.Now().UTC()
+
+	inv, ok := s.invitations[id]
+	if !ok || !inv.AcceptedAt.IsZero() || !inv.RevokedAt.IsZero() || !inv.ExpiresAt.After(now) {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+	inv.AcceptedBy = userID
+	inv.AcceptedAt = now
+
+	setOf(s.appMembers, inv.AppID)[userID] = true
+	if inv.RoleID != 0 {
+		setOf(s.userRoles, userID)[inv.RoleID] = true
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  userID,
+		Action:   models.AuditActionAcceptInvitation,
+		TargetID: id,
+	})
+
+	return nil
+}
+
+func (s *Storage) invitationByToken(tokenHash string) *invitation {
+	for _, inv := range s.invitations {
+		if inv.tokenHash == tokenHash {
+			return inv
+		}
+	}
+
+	return nil
+}
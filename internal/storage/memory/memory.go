@@ -0,0 +1,409 @@
+// internal/storage/memory/memory.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Хранилище в памяти процесса для unit-тестов сервисов. Сервису его не выбрать в конфиге:
+// пустое хранилище без приложений и администратора заполняют сами тесты.
+// Ведёт себя так же, как sqlite.Storage (это проверяют общие тесты storagetest),
+// но данные пропадают при остановке, а секреты приложений не шифруются - они не попадают на диск.
+// Каждый метод выполняется целиком под блокировкой, а изменение, после которого в организации
+// не остаётся администраторов, откатывается вручную. Несколько методов объединяет WithinTx (см. tx.go).
+type Storage struct {
+	mu sync.RWMutex
+
+	users      map[int64]*user
+	identities map[identityKey]int64 // учётная запись внешнего провайдера -> ID пользователя
+	attributes map[int64]map[string]string
+
+	apps             map[int]*models.App
+	appMembers       map[int]map[int64]bool
+	exchangePolicies map[[2]int]models.ExchangePolicy // [source, target]
+
+	groups      map[int64]*group
+	groupRoles  map[int64]map[int64]bool
+	roles       map[int64]*role
+	userRoles   map[int64]map[int64]bool
+	invitations map[int64]*invitation
+	policies    map[int][]models.Policy // версии политики приложения по возрастанию
+
+	audit []models.AuditEvent
+
+	// последние выданные ID, как у AUTOINCREMENT
+	lastUserID, lastGroupID, lastRoleID, lastInvitationID int64
+	lastAppID                                             int
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+// user пользователь и его статус администратора (в models.User его нет)
+type user struct {
+	models.User
+	isAdmin bool
+	// помечен удалённым (DeleteUser) и ждёт окончательного удаления (PurgeDeletedUsers)
+	deletedAt time.Time
+}
+
+type identityKey struct {
+	tenantID int64
+	provider string
+	subject  string
+}
+
+// New создаёт пустое хранилище
+func New() *Storage {
+	return &Storage{
+		users:            make(map[int64]*user),
+		identities:       make(map[identityKey]int64),
+		attributes:       make(map[int64]map[string]string),
+		apps:             make(map[int]*models.App),
+		appMembers:       make(map[int]map[int64]bool),
+		exchangePolicies: make(map[[2]int]models.ExchangePolicy),
+		groups:           make(map[int64]*group),
+		groupRoles:       make(map[int64]map[int64]bool),
+		roles:            make(map[int64]*role),
+		userRoles:        make(map[int64]map[int64]bool),
+		invitations:      make(map[int64]*invitation),
+		policies:         make(map[int][]models.Policy),
+	}
+}
+
+// Close ничего не делает: закрывать нечего
+func (s *Storage) Close() error {
+	return nil
+}
+
+// SaveUser saves a new user of the tenant and returns its id.
+// Email уникален в пределах организации.
+func (s *Storage) SaveUser(ctx context.Context, tenantID int64, email string, passHash []byte) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	defer s.lock(ctx)()
+
+	if s.userByEmail(tenantID, email) != nil {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	s.lastUserID++
+	s.users[s.lastUserID] = &user{User: models.User{
+		ID:       s.lastUserID,
+		TenantID: tenantID,
+		Email:    email,
+		PassHash: slices.Clone(passHash),
+	}}
+
+	return s.lastUserID, nil
+}
+
+// User returns user of the tenant by email.
+func (s *Storage) User(ctx context.Context, tenantID int64, email string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	defer s.rlock(ctx)()
+
+	u := s.userByEmail(tenantID, email)
+	if u == nil || u.deleted() {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.copy(), nil
+}
+
+// UserByID returns user by id.
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.memory.UserByID"
+
+	defer s.rlock(ctx)()
+
+	u, ok := s.activeUser(id)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.copy(), nil
+}
+
+// Users returns a page of users matching the filter and the total number of matching users.
+func (s *Storage) Users(ctx context.Context, filter storage.UserFilter, offset int, limit int) ([]models.User, int, error) {
+	defer s.rlock(ctx)()
+
+	var users []models.User
+	for _, id := range sortedKeys(s.users) {
+		u := s.users[id]
+		if u.deleted() || u.TenantID != filter.TenantID ||
+			(filter.Email != "" && u.Email != filter.Email) ||
+			(filter.ExternalID != "" && u.ExternalID != filter.ExternalID) {
+			continue
+		}
+		users = append(users, u.copy())
+	}
+
+	return page(users, offset, limit), len(users), nil
+}
+
+// UpdateUser updates email, external id and disabled flag of the user.
+// Пароль здесь не меняется.
+func (s *Storage) UpdateUser(ctx context.Context, updated models.User) error {
+	const op = "storage.memory.UpdateUser"
+
+	defer s.lock(ctx)()
+
+	u, ok := s.activeUser(updated.ID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if other := s.userByEmail(u.TenantID, updated.Email); other != nil && other.ID != u.ID {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	u.Email = updated.Email
+	u.ExternalID = updated.ExternalID
+	u.Disabled = updated.Disabled
+
+	return nil
+}
+
+// SetPassHash replaces the password hash of the user
+// (например, хэш импортированного пользователя на bcrypt после входа).
+func (s *Storage) SetPassHash(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.memory.SetPassHash"
+
+	defer s.lock(ctx)()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.PassHash = slices.Clone(passHash)
+
+	return nil
+}
+
+// IsAdmin checks whether the user is admin directly or via membership in an admin group
+// (в том числе через вложенные группы).
+func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	defer s.rlock(ctx)()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.isAdmin || s.inAdminGroup(userID), nil
+}
+
+// ExchangePolicy returns token exchange policy for given pair of apps.
+func (s *Storage) ExchangePolicy(ctx context.Context, sourceAppID int, targetAppID int) (models.ExchangePolicy, error) {
+	const op = "storage.memory.ExchangePolicy"
+
+	defer s.rlock(ctx)()
+
+	// Отсутствие политики означает, что обмен между этими приложениями запрещён
+	policy, ok := s.exchangePolicies[[2]int{sourceAppID, targetAppID}]
+	if !ok {
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyNotFound)
+	}
+	policy.Scopes = slices.Clone(policy.Scopes)
+
+	return policy, nil
+}
+
+// FederatedUser returns user of the tenant linked to the subject of external provider.
+func (s *Storage) FederatedUser(ctx context.Context, tenantID int64, provider string, subject string) (models.User, error) {
+	const op = "storage.memory.FederatedUser"
+
+	defer s.rlock(ctx)()
+
+	u, ok := s.activeUser(s.identities[identityKey{tenantID, provider, subject}])
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.copy(), nil
+}
+
+// SaveFederatedIdentity links the subject of external provider to the user of the tenant.
+func (s *Storage) SaveFederatedIdentity(ctx context.Context, tenantID int64, provider string, subject string, userID int64) error {
+	const op = "storage.memory.SaveFederatedIdentity"
+
+	defer s.lock(ctx)()
+
+	key := identityKey{tenantID, provider, subject}
+	if _, ok := s.identities[key]; ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrIdentityExists)
+	}
+	s.identities[key] = userID
+
+	return nil
+}
+
+// UpdateAdminStatus sets admin status of the user of the tenant on behalf of the actor
+// and records the change in the audit log.
+// Последнего администратора организации разжаловать нельзя: storage.ErrLastAdmin.
+func (s *Storage) UpdateAdminStatus(ctx context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error {
+	const op = "storage.memory.UpdateAdminStatus"
+
+	defer s.lock(ctx)()
+
+	u, err := s.userInTenant(tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Ничего не меняется - и в журнал писать нечего
+	wasAdmin := u.isAdmin
+	if wasAdmin == isAdmin {
+		return nil
+	}
+
+	admins := s.adminCount(tenantID)
+	u.isAdmin = isAdmin
+	if err := s.checkAdminsLeft(tenantID, admins); err != nil {
+		u.isAdmin = wasAdmin
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAdmin,
+		TargetID: userID,
+		OldValue: strconv.FormatBool(wasAdmin),
+		NewValue: strconv.FormatBool(isAdmin),
+	})
+
+	return nil
+}
+
+// AuditLog returns the audit log in the order of recording.
+// В sqlite.Storage журнал только пишется; здесь его можно прочитать, например, в тестах.
+func (s *Storage) AuditLog() []models.AuditEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.audit)
+}
+
+func (u *user) copy() models.User {
+	c := u.User
+	c.PassHash = slices.Clone(u.PassHash)
+
+	return c
+}
+
+func (u *user) deleted() bool {
+	return !u.deletedAt.IsZero()
+}
+
+// activeUser возвращает пользователя, если он есть и не помечен удалённым
+func (s *Storage) activeUser(id int64) (*user, bool) {
+	u, ok := s.users[id]
+	if !ok || u.deleted() {
+		return nil, false
+	}
+
+	return u, true
+}
+
+// userByEmail ищет и среди удалённых: до окончательного удаления их email занят
+func (s *Storage) userByEmail(tenantID int64, email string) *user {
+	for _, u := range s.users {
+		if u.TenantID == tenantID && u.Email == email {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// userInTenant возвращает пользователя, если он есть в каталоге организации.
+// Пользователи других организаций для неё не существуют: storage.ErrUserNotFound.
+func (s *Storage) userInTenant(tenantID int64, userID int64) (*user, error) {
+	u, ok := s.activeUser(userID)
+	if !ok || u.TenantID != tenantID {
+		return nil, storage.ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+// adminCount считает администраторов организации: назначенных напрямую и участников групп администраторов.
+// Группы и их участники всегда из одной организации, поэтому достаточно отбора по пользователям.
+func (s *Storage) adminCount(tenantID int64) int {
+	count := 0
+	for id, u := range s.users {
+		if u.TenantID == tenantID && !u.deleted() && (u.isAdmin || s.inAdminGroup(id)) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// checkAdminsLeft проверяет после изменения, что в организации остался хотя бы один администратор.
+// before - число администраторов до изменения: если их не было, то и проверять нечего.
+func (s *Storage) checkAdminsLeft(tenantID int64, before int) error {
+	if before > 0 && s.adminCount(tenantID) == 0 {
+		return storage.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// insertAuditEvent записывает событие в журнал аудита
+func (s *Storage) insertAuditEvent(event models.AuditEvent) {
+	event.ID = int64(len(s.audit) + 1)
+	event.CreatedAt = time.Now().UTC()
+	s.audit = append(s.audit, event)
+}
+
+// sortedKeys ключи map по возрастанию: выборки упорядочены по ID, как в SQL-реализациях
+func sortedKeys[K int | int64, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// page страница выборки, как LIMIT/OFFSET (отрицательный limit - без ограничения)
+func page[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[max(offset, 0):]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	return items
+}
+
+// setOf множество ID, при необходимости создавая его в m
+func setOf[K comparable](m map[K]map[int64]bool, key K) map[int64]bool {
+	set, ok := m[key]
+	if !ok {
+		set = make(map[int64]bool)
+		m[key] = set
+	}
+
+	return set
+}
@@ -0,0 +1,28 @@
+// internal/storage/memory/memory_test.go
+package memory
+
+import (
+	"testing"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		return backend{New()}
+	})
+}
+
+// backend доступ общих тестов к журналу аудита
+type backend struct {
+	*Storage
+}
+
+// AddTenant ничего не делает: организации здесь не хранятся,
+// а их существование, как и в SQLite, не проверяется
+func (b backend) AddTenant(*testing.T, int64, string) {}
+
+func (b backend) AuditLog(*testing.T) []models.AuditEvent {
+	return b.Storage.AuditLog()
+}
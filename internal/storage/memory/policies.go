@@ -0,0 +1,123 @@
+// internal/storage/memory/policies.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SavePolicy saves the document as a new version of the app policy, writes it to the audit log
+// and returns the version. Приложение должно принадлежать организации tenantID.
+func (s *Storage) SavePolicy(ctx context.Context, tenantID int64, actorID int64, appID int, document string) (int, error) {
+	const op = "storage.memory.SavePolicy"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	version := len(s.policies[appID]) + 1
+	s.policies[appID] = append(s.policies[appID], models.Policy{
+		AppID:     appID,
+		Version:   version,
+		Document:  document,
+		CreatedBy: actorID,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetPolicy,
+		TargetID: int64(appID),
+		OldValue: strconv.Itoa(version - 1),
+		NewValue: strconv.Itoa(version),
+	})
+
+	return version, nil
+}
+
+// Policy returns the version of the app policy; version 0 means the latest one.
+// Если у приложения нет политики (или такой версии), возвращается storage.ErrPolicyNotFound.
+func (s *Storage) Policy(ctx context.Context, appID int, version int) (models.Policy, error) {
+	const op = "storage.memory.Policy"
+
+	defer s.rlock(ctx)()
+
+	// версии идут подряд с 1, так что версия - это номер в списке
+	versions := s.policies[appID]
+	if version == 0 {
+		version = len(versions)
+	}
+	if version < 1 || version > len(versions) {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, storage.ErrPolicyNotFound)
+	}
+
+	return versions[version-1], nil
+}
+
+// SetUserAttributes replaces the attributes of the user and writes the change to the audit log.
+// Пользователь должен быть в каталоге организации tenantID.
+func (s *Storage) SetUserAttributes(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	userID int64,
+	attributes map[string]string,
+) error {
+	const op = "storage.memory.SetUserAttributes"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.userInTenant(tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	old := s.attributes[userID]
+	if maps.Equal(old, attributes) {
+		return nil
+	}
+	s.attributes[userID] = maps.Clone(attributes)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetUserAttributes,
+		TargetID: userID,
+		OldValue: formatAttributes(old),
+		NewValue: formatAttributes(attributes),
+	})
+
+	return nil
+}
+
+// UserAttributes returns the attributes of the user.
+func (s *Storage) UserAttributes(ctx context.Context, userID int64) (map[string]string, error) {
+	defer s.rlock(ctx)()
+
+	attributes := maps.Clone(s.attributes[userID])
+	if attributes == nil {
+		attributes = make(map[string]string)
+	}
+
+	return attributes, nil
+}
+
+// formatAttributes атрибуты для журнала аудита: name=value через пробел, по алфавиту
+func formatAttributes(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, name+"="+value)
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, " ")
+}
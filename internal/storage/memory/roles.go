@@ -0,0 +1,337 @@
+// internal/storage/memory/roles.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// role роль приложения и множество её прав
+type role struct {
+	id          int64
+	appID       int
+	name        string
+	permissions map[string]bool
+}
+
+// SaveRole saves a new role of the app with its permissions and returns role id.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SaveRole(ctx context.Context, tenantID int64, appID int, name string, permissions []string) (int64, error) {
+	const op = "storage.memory.SaveRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.appInTenant(tenantID, appID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, r := range s.roles {
+		if r.appID == appID && r.name == name {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+	}
+
+	s.lastRoleID++
+	s.roles[s.lastRoleID] = &role{
+		id:          s.lastRoleID,
+		appID:       appID,
+		name:        name,
+		permissions: permissionSet(permissions),
+	}
+
+	return s.lastRoleID, nil
+}
+
+// Role returns role by id with its permissions.
+func (s *Storage) Role(ctx context.Context, id int64) (models.Role, error) {
+	const op = "storage.memory.Role"
+
+	defer s.rlock(ctx)()
+
+	r, ok := s.roles[id]
+	if !ok {
+		return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+
+	return r.model(), nil
+}
+
+// Roles returns all roles of the app of the tenant with their permissions.
+// У приложения другой организации ролей нет.
+func (s *Storage) Roles(ctx context.Context, tenantID int64, appID int) ([]models.Role, error) {
+	defer s.rlock(ctx)()
+
+	if app, ok := s.apps[appID]; !ok || app.TenantID != tenantID {
+		return nil, nil
+	}
+
+	var roles []models.Role
+	for _, r := range s.roles {
+		if r.appID == appID {
+			roles = append(roles, r.model())
+		}
+	}
+	// имя роли уникально в приложении
+	slices.SortFunc(roles, func(a, b models.Role) int { return strings.Compare(a.Name, b.Name) })
+
+	return roles, nil
+}
+
+// SetRolePermissions replaces permissions of the role of the tenant.
+func (s *Storage) SetRolePermissions(ctx context.Context, tenantID int64, roleID int64, permissions []string) error {
+	const op = "storage.memory.SetRolePermissions"
+
+	defer s.lock(ctx)()
+
+	r, err := s.roleInTenant(tenantID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	r.permissions = permissionSet(permissions)
+
+	return nil
+}
+
+// DeleteRole deletes the role of the tenant with its permissions and assignments.
+// Приглашения с этой ролью остаются в силе, но роль при принятии уже не выдаётся.
+func (s *Storage) DeleteRole(ctx context.Context, tenantID int64, id int64) error {
+	const op = "storage.memory.DeleteRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.roleInTenant(tenantID, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.deleteRole(id)
+
+	return nil
+}
+
+// AssignRole assigns the role to the user and writes the change to the audit log.
+// Роль и пользователь должны быть из организации tenantID. Повторное назначение ничего не меняет.
+func (s *Storage) AssignRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error {
+	const op = "storage.memory.AssignRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.roleInTenant(tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.userInTenant(tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles := setOf(s.userRoles, userID)
+	if roles[roleID] {
+		return nil
+	}
+	roles[roleID] = true
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAssignRole,
+		TargetID: userID,
+		NewValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
+
+// RevokeRole revokes the role from the user and writes the change to the audit log.
+// Если роль не была назначена, ничего не меняется.
+func (s *Storage) RevokeRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error {
+	const op = "storage.memory.RevokeRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.roleInTenant(tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !s.userRoles[userID][roleID] {
+		return nil
+	}
+	delete(s.userRoles[userID], roleID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeRole,
+		TargetID: userID,
+		OldValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
+
+// AssignGroupRole grants the role to the group and writes the change to the audit log.
+// Роль и группа должны быть из организации tenantID.
+func (s *Storage) AssignGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error {
+	const op = "storage.memory.AssignGroupRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.roleInTenant(tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.groupInTenant(tenantID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles := setOf(s.groupRoles, groupID)
+	if roles[roleID] {
+		return nil
+	}
+	roles[roleID] = true
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAssignGroupRole,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
+
+// RevokeGroupRole revokes the role from the group and writes the change to the audit log.
+func (s *Storage) RevokeGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error {
+	const op = "storage.memory.RevokeGroupRole"
+
+	defer s.lock(ctx)()
+
+	if _, err := s.roleInTenant(tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !s.groupRoles[groupID][roleID] {
+		return nil
+	}
+	delete(s.groupRoles[groupID], roleID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeGroupRole,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(roleID, 10),
+	})
+
+	return nil
+}
+
+// UserRoles returns names of the user's roles in the app,
+// including roles granted to the user's groups.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int) ([]string, error) {
+	defer s.rlock(ctx)()
+
+	var names []string
+	for _, r := range s.userRolesInApp(userID, appID) {
+		names = append(names, r.name)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// HasPermission checks whether one of the user's roles in the app grants the permission.
+// Учитываются и роли, выданные группам пользователя. У отключённого пользователя прав нет.
+func (s *Storage) HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error) {
+	defer s.rlock(ctx)()
+
+	if u, ok := s.users[userID]; ok && (u.Disabled || u.deleted()) {
+		return false, nil
+	}
+
+	for _, r := range s.userRolesInApp(userID, appID) {
+		if r.permissions[permission] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// userRolesInApp все роли пользователя в приложении:
+// назначенные ему напрямую и выданные его группам, с учётом вложенности
+func (s *Storage) userRolesInApp(userID int64, appID int) []*role {
+	ids := make(map[int64]bool)
+	for id := range s.userRoles[userID] {
+		ids[id] = true
+	}
+	for groupID := range s.userGroups(userID) {
+		for id := range s.groupRoles[groupID] {
+			ids[id] = true
+		}
+	}
+
+	var roles []*role
+	for id := range ids {
+		if r, ok := s.roles[id]; ok && r.appID == appID {
+			roles = append(roles, r)
+		}
+	}
+
+	return roles
+}
+
+// roleInTenant возвращает роль, если она есть в одном из приложений организации
+func (s *Storage) roleInTenant(tenantID int64, roleID int64) (*role, error) {
+	r, ok := s.roles[roleID]
+	if !ok {
+		return nil, storage.ErrRoleNotFound
+	}
+	if app, ok := s.apps[r.appID]; !ok || app.TenantID != tenantID {
+		return nil, storage.ErrRoleNotFound
+	}
+
+	return r, nil
+}
+
+// deleteRole удаляет роль вместе с её назначениями пользователям и группам.
+// Приглашения с этой ролью остаются, но уже без неё (как ON DELETE SET NULL в PostgreSQL)
+func (s *Storage) deleteRole(id int64) {
+	for _, inv := range s.invitations {
+		if inv.RoleID == id {
+			inv.RoleID = 0
+		}
+	}
+	for _, roles := range s.userRoles {
+		delete(roles, id)
+	}
+	for _, roles := range s.groupRoles {
+		delete(roles, id)
+	}
+	delete(s.roles, id)
+}
+
+func (r *role) model() models.Role {
+	var permissions []string
+	for permission := range r.permissions {
+		permissions = append(permissions, permission)
+	}
+	slices.Sort(permissions)
+
+	return models.Role{
+		ID:          r.id,
+		AppID:       r.appID,
+		Name:        r.name,
+		Permissions: permissions,
+	}
+}
+
+func permissionSet(permissions []string) map[string]bool {
+	set := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		set[permission] = true
+	}
+
+	return set
+}
@@ -0,0 +1,53 @@
+// internal/storage/memory/secrets.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+)
+
+// RotateAppSecret replaces the app secret with a new one and writes the rotation to the audit log.
+// Прежний секрет сохраняется и принимается для проверки токенов до previousExpiresAt;
+// секрет, оставшийся от предыдущей ротации, при этом перестаёт действовать сразу.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) RotateAppSecret(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	secret string,
+	previousExpiresAt time.Time,
+) error {
+	const op = "storage.memory.RotateAppSecret"
+
+	defer s.lock(ctx)()
+
+	app, err := s.appInTenant(tenantID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.PreviousSecret = app.Secret
+	app.PreviousSecretExpiresAt = previousExpiresAt.UTC()
+	app.Secret = secret
+
+	// сами секреты в журнал не попадают
+	s.insertAuditEvent(models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRotateAppSecret,
+		TargetID: int64(appID),
+		NewValue: "previous secret valid until " + previousExpiresAt.UTC().Format(time.RFC3339),
+	})
+
+	return nil
+}
+
+// EncryptAppSecrets does nothing and returns 0: секреты в памяти не шифруются,
+// на диск они не попадают.
+func (s *Storage) EncryptAppSecrets(ctx context.Context) (int, error) {
+	return 0, nil
+}
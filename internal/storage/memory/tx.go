@@ -0,0 +1,161 @@
+// internal/storage/memory/tx.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"grpc-service-ref/internal/domain/models"
+)
+
+// txKey ключ транзакции WithinTx в контексте: значение - хранилище, блокировку которого держит транзакция
+type txKey struct{}
+
+// WithinTx runs fn in a single transaction and commits it if fn returns nil.
+// Транзакция держит блокировку хранилища, пока выполняется fn: методы, вызванные внутри fn
+// с переданным ей ctx, блокировку не берут, а остальные вызовы ждут конца транзакции.
+// Если fn вернула ошибку, хранилище возвращается к состоянию на начало транзакции.
+// Вложенный WithinTx присоединяется к внешней транзакции и при ошибке откатывает только свои изменения.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.memory.WithinTx"
+
+	if s.inTx(ctx) {
+		saved := s.snapshot()
+		if err := fn(ctx); err != nil {
+			s.restore(saved)
+			return err
+		}
+
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := s.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.restore(saved)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// lock берёт блокировку на запись (если её уже не держит транзакция из ctx) и возвращает функцию её снятия
+func (s *Storage) lock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+
+	return s.mu.Unlock
+}
+
+// rlock как lock, но блокировка на чтение
+func (s *Storage) rlock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.RLock()
+
+	return s.mu.RUnlock
+}
+
+// inTx ctx принадлежит транзакции WithinTx этого хранилища
+func (s *Storage) inTx(ctx context.Context) bool {
+	tx, _ := ctx.Value(txKey{}).(*Storage)
+	return tx == s
+}
+
+// snapshot глубокая копия данных хранилища для отката транзакции (блокировка не копируется)
+func (s *Storage) snapshot() *Storage {
+	c := &Storage{
+		users:            make(map[int64]*user, len(s.users)),
+		identities:       maps.Clone(s.identities),
+		attributes:       cloneNested(s.attributes),
+		apps:             make(map[int]*models.App, len(s.apps)),
+		appMembers:       cloneNested(s.appMembers),
+		exchangePolicies: make(map[[2]int]models.ExchangePolicy, len(s.exchangePolicies)),
+		groups:           make(map[int64]*group, len(s.groups)),
+		groupRoles:       cloneNested(s.groupRoles),
+		roles:            make(map[int64]*role, len(s.roles)),
+		userRoles:        cloneNested(s.userRoles),
+		invitations:      make(map[int64]*invitation, len(s.invitations)),
+		policies:         make(map[int][]models.Policy, len(s.policies)),
+		audit:            slices.Clone(s.audit),
+		lastUserID:       s.lastUserID,
+		lastGroupID:      s.lastGroupID,
+		lastRoleID:       s.lastRoleID,
+		lastInvitationID: s.lastInvitationID,
+		lastAppID:        s.lastAppID,
+	}
+
+	for id, u := range s.users {
+		uc := *u
+		uc.User = u.copy()
+		c.users[id] = &uc
+	}
+	for id, app := range s.apps {
+		ac := copyApp(app)
+		c.apps[id] = &ac
+	}
+	for key, p := range s.exchangePolicies {
+		p.Scopes = slices.Clone(p.Scopes)
+		c.exchangePolicies[key] = p
+	}
+	for id, g := range s.groups {
+		gc := *g
+		gc.members = maps.Clone(g.members)
+		gc.children = maps.Clone(g.children)
+		c.groups[id] = &gc
+	}
+	for id, r := range s.roles {
+		rc := *r
+		rc.permissions = maps.Clone(r.permissions)
+		c.roles[id] = &rc
+	}
+	for id, inv := range s.invitations {
+		ic := *inv
+		c.invitations[id] = &ic
+	}
+	for id, versions := range s.policies {
+		c.policies[id] = slices.Clone(versions)
+	}
+
+	return c
+}
+
+// restore возвращает данные хранилища к снимку snapshot
+func (s *Storage) restore(saved *Storage) {
+	s.users = saved.users
+	s.identities = saved.identities
+	s.attributes = saved.attributes
+	s.apps = saved.apps
+	s.appMembers = saved.appMembers
+	s.exchangePolicies = saved.exchangePolicies
+	s.groups = saved.groups
+	s.groupRoles = saved.groupRoles
+	s.roles = saved.roles
+	s.userRoles = saved.userRoles
+	s.invitations = saved.invitations
+	s.policies = saved.policies
+	s.audit = saved.audit
+	s.lastUserID = saved.lastUserID
+	s.lastGroupID = saved.lastGroupID
+	s.lastRoleID = saved.lastRoleID
+	s.lastInvitationID = saved.lastInvitationID
+	s.lastAppID = saved.lastAppID
+}
+
+// cloneNested копия map со вложенными map
+func cloneNested[K, K2 comparable, V any](m map[K]map[K2]V) map[K]map[K2]V {
+	c := make(map[K]map[K2]V, len(m))
+	for k, inner := range m {
+		c[k] = maps.Clone(inner)
+	}
+
+	return c
+}
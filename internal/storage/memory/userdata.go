@@ -0,0 +1,98 @@
+// internal/storage/memory/userdata.go
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// UserData returns everything stored about the user: the users row and the records keyed by its ID.
+// Пользователь, помеченный удалённым, тоже выгружается: его данные ещё хранятся.
+func (s *Storage) UserData(ctx context.Context, userID int64) (models.UserData, error) {
+	const op = "storage.memory.UserData"
+
+	defer s.rlock(ctx)()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	data := models.UserData{
+		User:       u.User,
+		IsAdmin:    u.isAdmin,
+		DeletedAt:  u.deletedAt,
+		Attributes: maps.Clone(s.attributes[userID]),
+	}
+	data.User.PassHash = slices.Clone(u.PassHash)
+	if data.Attributes == nil {
+		data.Attributes = make(map[string]string)
+	}
+
+	for key, id := range s.identities {
+		if id == userID {
+			data.Identities = append(data.Identities, models.FederatedIdentity{Provider: key.provider, Subject: key.subject})
+		}
+	}
+	slices.SortFunc(data.Identities, func(a, b models.FederatedIdentity) int {
+		return strings.Compare(a.Provider+"\x00"+a.Subject, b.Provider+"\x00"+b.Subject)
+	})
+
+	for _, id := range sortedKeys(s.groups) {
+		if s.groups[id].members[userID] {
+			data.Groups = append(data.Groups, s.groups[id].model())
+		}
+	}
+
+	for _, id := range setIDs(s.userRoles[userID]) {
+		r := s.roles[id]
+		data.Roles = append(data.Roles, models.Role{ID: r.id, AppID: r.appID, Name: r.name})
+	}
+
+	for _, appID := range sortedKeys(s.appMembers) {
+		if s.appMembers[appID][userID] {
+			data.Apps = append(data.Apps, appID)
+		}
+	}
+
+	// приглашения на email пользователя или принятые им
+	addressed := make(map[int64]bool)
+	for _, id := range sortedKeys(s.invitations) {
+		inv := s.invitations[id].Invitation
+		if inv.AcceptedBy == userID || (inv.TenantID == u.TenantID && inv.Email == u.Email) {
+			addressed[id] = true
+		}
+		if addressed[id] || inv.CreatedBy == userID {
+			data.Invitations = append(data.Invitations, inv)
+		}
+	}
+
+	for _, appID := range sortedKeys(s.policies) {
+		for _, p := range s.policies[appID] {
+			if p.CreatedBy == userID {
+				data.Policies = append(data.Policies, p)
+			}
+		}
+	}
+
+	id := strconv.FormatInt(userID, 10)
+	for _, event := range s.audit {
+		switch {
+		case event.ActorID == userID,
+			event.TargetID == userID && slices.Contains(models.AuditUserTargetActions, event.Action),
+			slices.Contains(models.AuditUserValueActions, event.Action) && (event.OldValue == id || event.NewValue == id),
+			slices.Contains(models.AuditInvitationActions, event.Action) && addressed[event.TargetID]:
+			data.Audit = append(data.Audit, event)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,572 @@
+// internal/storage/postgres/apps.go
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SetAppAccess changes the access mode and allowed email domains of the app
+// and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppAccess(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	mode string,
+	domains []string,
+) error {
+	const op = "storage.postgres.SetAppAccess"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var oldMode, oldDomains string
+	err = tx.QueryRowContext(ctx,
+		"SELECT access_mode, allowed_domains FROM apps WHERE id = $1 AND tenant_id = $2 FOR UPDATE", appID, tenantID,
+	).Scan(&oldMode, &oldDomains)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	newDomains := strings.Join(domains, " ")
+	if oldMode == mode && oldDomains == newDomains {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE apps SET access_mode = $1, allowed_domains = $2 WHERE id = $3", mode, newDomains, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAppAccess,
+		TargetID: int64(appID),
+		OldValue: strings.TrimSpace(oldMode + " " + oldDomains),
+		NewValue: strings.TrimSpace(mode + " " + newDomains),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// IsAppMember checks whether the user is a member of the app.
+func (s *Storage) IsAppMember(ctx context.Context, appID int, userID int64) (bool, error) {
+	const op = "storage.postgres.IsAppMember"
+
+	var isMember bool
+	err := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM app_members WHERE app_id = $1 AND user_id = $2)", appID, userID,
+	).Scan(&isMember)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isMember, nil
+}
+
+// AppMembers returns IDs of the app members.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) AppMembers(ctx context.Context, tenantID int64, appID int) ([]int64, error) {
+	const op = "storage.postgres.AppMembers"
+
+	tx, err := s.beginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT user_id FROM app_members WHERE app_id = $1 ORDER BY user_id", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// AddAppMember adds the user to the app members and writes the change to the audit log.
+// Повторное добавление ничего не меняет.
+// Приложение и пользователь должны быть из организации tenantID.
+func (s *Storage) AddAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error {
+	const op = "storage.postgres.AddAppMember"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO app_members(app_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", appID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddAppMember,
+		TargetID: int64(appID),
+		NewValue: strconv.FormatInt(userID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveAppMember removes the user from the app members and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) RemoveAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error {
+	const op = "storage.postgres.RemoveAppMember"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"DELETE FROM app_members WHERE app_id = $1 AND user_id = $2", appID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveAppMember,
+		TargetID: int64(appID),
+		OldValue: strconv.FormatInt(userID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveApp saves a new app of the organization, writes it to the audit log and returns app id.
+// Секрет приложения сохраняется зашифрованным активным мастер-ключом.
+func (s *Storage) SaveApp(ctx context.Context, tenantID int64, actorID int64, app models.App) (int, error) {
+	const op = "storage.postgres.SaveApp"
+
+	sealed, keyID, err := s.keyring.Seal(app.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO apps
+		(tenant_id, name, secret, secret_key_id, kind, saml_entity_id, saml_acs_url, saml_certificate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		tenantID, app.Name, sealed, keyID, app.Kind,
+		nullString(app.SAML.EntityID), nullString(app.SAML.ACSURL), nullString(app.SAML.Certificate),
+	).Scan(&id)
+	if err != nil {
+		// имя в организации или entity ID SAML-приложения уже заняты
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionCreateApp,
+		TargetID: id,
+		NewValue: app.Name,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(id), nil
+}
+
+// Apps returns a page of the organization apps (including disabled ones) and the total number of its apps.
+func (s *Storage) Apps(ctx context.Context, tenantID int64, offset int, limit int) ([]models.App, int, error) {
+	const op = "storage.postgres.Apps"
+
+	var total int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM apps WHERE tenant_id = $1", tenantID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+appColumns+" FROM apps WHERE tenant_id = $1 ORDER BY id LIMIT $2 OFFSET $3",
+		tenantID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := s.scanApp(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, total, nil
+}
+
+// UpdateApp updates name, kind and SAML settings of the app and writes the change to the audit log.
+// Секрет, режим доступа и отключение здесь не меняются. Приложение должно принадлежать организации tenantID.
+func (s *Storage) UpdateApp(ctx context.Context, tenantID int64, actorID int64, app models.App) error {
+	const op = "storage.postgres.UpdateApp"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var oldName, oldKind string
+	err = tx.QueryRowContext(ctx,
+		"SELECT name, kind FROM apps WHERE id = $1 AND tenant_id = $2 FOR UPDATE", app.ID, tenantID,
+	).Scan(&oldName, &oldKind)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE apps
+		SET name = $1, kind = $2, saml_entity_id = $3, saml_acs_url = $4, saml_certificate = $5
+		WHERE id = $6`,
+		app.Name, app.Kind,
+		nullString(app.SAML.EntityID), nullString(app.SAML.ACSURL), nullString(app.SAML.Certificate),
+		app.ID,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionUpdateApp,
+		TargetID: int64(app.ID),
+		OldValue: oldName + " " + oldKind,
+		NewValue: app.Name + " " + app.Kind,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAppDisabled disables or enables the app and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppDisabled(ctx context.Context, tenantID int64, actorID int64, appID int, disabled bool) error {
+	const op = "storage.postgres.SetAppDisabled"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var old bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT disabled FROM apps WHERE id = $1 AND tenant_id = $2 FOR UPDATE", appID, tenantID,
+	).Scan(&old)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if old == disabled {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE apps SET disabled = $1 WHERE id = $2", disabled, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAppDisabled,
+		TargetID: int64(appID),
+		OldValue: strconv.FormatBool(old),
+		NewValue: strconv.FormatBool(disabled),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAppTokenSettings replaces the token settings of the app and writes the change to the audit log.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SetAppTokenSettings(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	settings models.TokenSettings,
+) error {
+	const op = "storage.postgres.SetAppTokenSettings"
+
+	staticClaims, err := json.Marshal(settings.StaticClaims)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// пустые claims храним как {}, а не null
+	if settings.StaticClaims == nil {
+		staticClaims = []byte("{}")
+	}
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+appColumns+" FROM apps WHERE id = $1 AND tenant_id = $2 FOR UPDATE", appID, tenantID)
+	old, err := s.scanApp(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE apps
+		SET access_token_ttl = $1, token_algorithm = $2, claim_attributes = $3, static_claims = $4
+		WHERE id = $5`,
+		int64(settings.AccessTTL/time.Second), settings.Algorithm,
+		strings.Join(settings.ClaimAttributes, " "), string(staticClaims), appID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetTokenSettings,
+		TargetID: int64(appID),
+		OldValue: formatTokenSettings(old.Tokens),
+		NewValue: formatTokenSettings(settings),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteApp deletes the app with its roles, members, invitations, policies and token exchange policies
+// and writes the deletion to the audit log. Приложение должно принадлежать организации tenantID.
+func (s *Storage) DeleteApp(ctx context.Context, tenantID int64, actorID int64, appID int) error {
+	const op = "storage.postgres.DeleteApp"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var name string
+	err = tx.QueryRowContext(ctx,
+		"SELECT name FROM apps WHERE id = $1 AND tenant_id = $2 FOR UPDATE", appID, tenantID,
+	).Scan(&name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Порядок важен: внешние ключи проверяются, и приглашения ссылаются на роли
+	queries := []string{
+		"DELETE FROM invitations WHERE app_id = $1",
+		"DELETE FROM role_permissions WHERE role_id IN (SELECT id FROM roles WHERE app_id = $1)",
+		"DELETE FROM user_roles WHERE role_id IN (SELECT id FROM roles WHERE app_id = $1)",
+		"DELETE FROM group_roles WHERE role_id IN (SELECT id FROM roles WHERE app_id = $1)",
+		"DELETE FROM roles WHERE app_id = $1",
+		"DELETE FROM app_members WHERE app_id = $1",
+		"DELETE FROM policies WHERE app_id = $1",
+		"DELETE FROM token_exchange_policies WHERE source_app_id = $1 OR target_app_id = $1",
+		"DELETE FROM apps WHERE id = $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, appID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionDeleteApp,
+		TargetID: int64(appID),
+		OldValue: name,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// appInTenant проверяет, что приложение принадлежит организации.
+// Приложения других организаций для неё не существуют: storage.ErrAppNotFound.
+func appInTenant(ctx context.Context, tx querier, tenantID int64, appID int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM apps WHERE id = $1 AND tenant_id = $2)", appID, tenantID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrAppNotFound
+	}
+
+	return nil
+}
+
+// formatTokenSettings настройки токенов в виде строки для журнала аудита (тот же формат, что у SQLite)
+func formatTokenSettings(settings models.TokenSettings) string {
+	// ключи map json.Marshal сортирует сам, так что запись детерминирована
+	b, _ := json.Marshal(struct {
+		AccessTTL       string            `json:"access_ttl"`
+		Algorithm       string            `json:"algorithm"`
+		ClaimAttributes []string          `json:"claim_attributes"`
+		StaticClaims    map[string]string `json:"static_claims"`
+	}{
+		AccessTTL:       settings.AccessTTL.String(),
+		Algorithm:       settings.Algorithm,
+		ClaimAttributes: settings.ClaimAttributes,
+		StaticClaims:    settings.StaticClaims,
+	})
+
+	return string(b)
+}
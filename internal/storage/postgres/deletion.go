@@ -0,0 +1,168 @@
+// internal/storage/postgres/deletion.go
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Удаление пользователей в два шага, как в sqlite.Storage: DeleteUser помечает пользователя
+// удалённым (deleted_at), PurgeDeletedUsers после срока хранения удаляет его окончательно
+// и обезличивает персональные данные в приглашениях и журнале аудита.
+
+// DeleteUser marks the user of the tenant as deleted on behalf of the actor
+// and records the deletion in the audit log.
+// Последнего администратора организации удалить нельзя: storage.ErrLastAdmin.
+func (s *Storage) DeleteUser(ctx context.Context, tenantID int64, actorID int64, userID int64) error {
+	const op = "storage.postgres.DeleteUser"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		admins, err := adminCount(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx,
+			"UPDATE users SET deleted_at = now() WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL",
+			userID, tenantID,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrUserNotFound
+		}
+
+		if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+			return err
+		}
+
+		return insertAuditEvent(ctx, tx, models.AuditEvent{
+			ActorID:  actorID,
+			Action:   models.AuditActionDeleteUser,
+			TargetID: userID,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users marked as deleted before deletedBefore
+// and returns how many users were purged.
+// Каждый пользователь удаляется в своей транзакции: ошибка на одном не откатывает уже удалённых.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	const op = "storage.postgres.PurgeDeletedUsers"
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT id, tenant_id, email FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 ORDER BY id",
+		deletedBefore.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.TenantID, &user.Email); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, user := range users {
+		if err := s.purgeUser(ctx, user); err != nil {
+			return i, fmt.Errorf("%s: user %d: %w", op, user.ID, err)
+		}
+	}
+
+	return len(users), nil
+}
+
+// purgeUser окончательно удаляет пользователя. Внешние ключи проверяются,
+// поэтому сначала удаляются связи, затем сам пользователь.
+func (s *Storage) purgeUser(ctx context.Context, user models.User) error {
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := anonymizeInvitations(ctx, tx, user); err != nil {
+		return err
+	}
+
+	// атрибуты в журнале - персональные данные (отдел, уровень и т.п.)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE audit_log SET old_value = '', new_value = '' WHERE action = $1 AND target_id = $2",
+		models.AuditActionSetUserAttributes, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// политики остаются, но без автора
+	if _, err := tx.ExecContext(ctx, "UPDATE policies SET created_by = NULL WHERE created_by = $1", user.ID); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"federated_identities", "group_members", "user_roles", "app_members", "user_attributes"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = $1", user.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", user.ID); err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		Action:   models.AuditActionPurgeUser,
+		TargetID: user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// anonymizeInvitations стирает email пользователя в его приглашениях (выданных на его адрес
+// или принятых им) и в записях журнала об их создании. Неиспользованные приглашения отзываются.
+func anonymizeInvitations(ctx context.Context, tx querier, user models.User) error {
+	_, err := tx.ExecContext(ctx, `UPDATE audit_log SET new_value = ''
+		WHERE action = $1 AND target_id IN (
+			SELECT id FROM invitations WHERE tenant_id = $2 AND (email = $3 OR accepted_by = $4)
+		)`,
+		models.AuditActionCreateInvitation, user.TenantID, user.Email, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE invitations SET email = '', accepted_by = NULL,
+		revoked_at = CASE WHEN accepted_at IS NULL AND revoked_at IS NULL THEN now() ELSE revoked_at END
+		WHERE tenant_id = $1 AND (email = $2 OR accepted_by = $3)`,
+		user.TenantID, user.Email, user.ID,
+	)
+
+	return err
+}
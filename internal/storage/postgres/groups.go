@@ -0,0 +1,539 @@
+// internal/storage/postgres/groups.go
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SaveGroup saves a new group of the tenant and returns its id.
+func (s *Storage) SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error) {
+	const op = "storage.postgres.SaveGroup"
+
+	var id int64
+	err := s.conn(ctx).QueryRowContext(ctx,
+		"INSERT INTO groups(tenant_id, name, external_id) VALUES ($1, $2, $3) RETURNING id",
+		tenantID, name, nullString(externalID),
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Group returns group by id with its members.
+func (s *Storage) Group(ctx context.Context, id int64) (models.Group, error) {
+	const op = "storage.postgres.Group"
+
+	group, err := scanGroup(s.conn(ctx).QueryRowContext(ctx, "SELECT "+groupColumns+" FROM groups WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Members, err = s.groupMembers(ctx, group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Subgroups, err = s.groupIDs(ctx, "SELECT child_id FROM group_children WHERE group_id = $1 ORDER BY child_id", group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Roles, err = s.groupIDs(ctx, "SELECT role_id FROM group_roles WHERE group_id = $1 ORDER BY role_id", group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// Groups returns a page of groups matching the filter and the total number of matching groups.
+func (s *Storage) Groups(ctx context.Context, filter storage.GroupFilter, offset int, limit int) ([]models.Group, int, error) {
+	const op = "storage.postgres.Groups"
+
+	where, args := filterClause(filter.TenantID, map[string]string{
+		"name":        filter.Name,
+		"external_id": filter.ExternalID,
+	})
+
+	var total int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM groups"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+groupColumns+" FROM groups"+where+
+			fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Участников читаем после того, как закрыли выборку групп
+	for i := range groups {
+		groups[i].Members, err = s.groupMembers(ctx, groups[i].ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return groups, total, nil
+}
+
+// UpdateGroup updates name and external id of the group.
+func (s *Storage) UpdateGroup(ctx context.Context, group models.Group) error {
+	const op = "storage.postgres.UpdateGroup"
+
+	res, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE groups SET name = $1, external_id = $2 WHERE id = $3", group.Name, nullString(group.ExternalID), group.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return nil
+}
+
+// SetGroupMembers replaces members of the group of the tenant.
+// Если кого-то из пользователей нет в организации - возвращается storage.ErrUserNotFound
+// и состав группы не меняется.
+func (s *Storage) SetGroupMembers(ctx context.Context, tenantID int64, groupID int64, userIDs []int64) error {
+	const op = "storage.postgres.SetGroupMembers"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		if err := groupExists(ctx, tx, tenantID, groupID); err != nil {
+			return err
+		}
+
+		admins, err := adminCount(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = $1", groupID); err != nil {
+			return err
+		}
+
+		// Добавляем участника, только если такой пользователь есть в организации
+		for _, userID := range userIDs {
+			res, err := tx.ExecContext(ctx, insertGroupMember, groupID, userID, tenantID)
+			if err != nil {
+				return err
+			}
+
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			// пользователь есть - значит, он просто указан дважды
+			if affected == 0 {
+				if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// из группы администраторов нельзя убрать последнего администратора
+		return checkAdminsLeft(ctx, tx, tenantID, admins)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes the group of the tenant and its memberships.
+func (s *Storage) DeleteGroup(ctx context.Context, tenantID int64, id int64) error {
+	const op = "storage.postgres.DeleteGroup"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		if err := groupExists(ctx, tx, tenantID, id); err != nil {
+			return err
+		}
+
+		admins, err := adminCount(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		for _, query := range []string{
+			"DELETE FROM group_members WHERE group_id = $1",
+			"DELETE FROM group_children WHERE group_id = $1 OR child_id = $1",
+			"DELETE FROM group_roles WHERE group_id = $1",
+		} {
+			if _, err := tx.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM groups WHERE id = $1", id); err != nil {
+			return err
+		}
+
+		return checkAdminsLeft(ctx, tx, tenantID, admins)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) groupMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	return s.groupIDs(ctx, "SELECT user_id FROM group_members WHERE group_id = $1 ORDER BY user_id", groupID)
+}
+
+// groupIDs читает список ID (участников, вложенных групп, ролей) группы
+func (s *Storage) groupIDs(ctx context.Context, query string, groupID int64) ([]int64, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// groupColumns колонки группы в порядке, который ожидает scanGroup
+const groupColumns = "id, tenant_id, name, external_id, is_admin"
+
+func scanGroup(row scanner) (models.Group, error) {
+	var group models.Group
+	var externalID sql.NullString
+
+	if err := row.Scan(&group.ID, &group.TenantID, &group.Name, &externalID, &group.IsAdmin); err != nil {
+		return models.Group{}, err
+	}
+	group.ExternalID = externalID.String
+
+	return group, nil
+}
+
+// insertGroupMember добавляет участника, только если пользователь есть в организации
+// (параметры: ID группы, ID пользователя, ID организации)
+const insertGroupMember = `INSERT INTO group_members(group_id, user_id)
+	SELECT $1::bigint, id FROM users WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NULL
+	ON CONFLICT DO NOTHING`
+
+// groupExists проверяет, что группа есть в организации: группы других организаций для неё не существуют
+func groupExists(ctx context.Context, tx querier, tenantID int64, groupID int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM groups WHERE id = $1 AND tenant_id = $2)", groupID, tenantID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group and writes the change to the audit log.
+// Повторное добавление ничего не меняет.
+// Группа и пользователь должны быть из организации tenantID.
+func (s *Storage) AddGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.postgres.AddGroupMember"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := groupExists(ctx, tx, tenantID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, insertGroupMember, groupID, userID, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddGroupMember,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(userID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.postgres.RemoveGroupMember"
+
+	return s.removeGroupLink(ctx, op, tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveGroupMember,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(userID, 10),
+	}, "DELETE FROM group_members WHERE group_id = $1 AND user_id = $2", groupID, userID)
+}
+
+// AddSubgroup nests the child group into the group and writes the change to the audit log.
+// Обе группы должны быть из организации tenantID.
+// Если группа уже вложена в child (напрямую или через другие группы), возвращается storage.ErrGroupCycle.
+func (s *Storage) AddSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.postgres.AddSubgroup"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		for _, id := range []int64{groupID, childID} {
+			if err := groupExists(ctx, tx, tenantID, id); err != nil {
+				return err
+			}
+		}
+
+		// Цикл появится, если group - это сама child или одна из её вложенных групп
+		var cycle bool
+		err := tx.QueryRowContext(ctx, `WITH RECURSIVE descendants(id) AS (
+				SELECT $1::bigint
+				UNION
+				SELECT gc.child_id FROM group_children gc JOIN descendants d ON gc.group_id = d.id
+			)
+			SELECT EXISTS(SELECT 1 FROM descendants WHERE id = $2)`, childID, groupID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return storage.ErrGroupCycle
+		}
+
+		res, err := tx.ExecContext(ctx,
+			"INSERT INTO group_children(group_id, child_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", groupID, childID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return nil
+		}
+
+		return insertAuditEvent(ctx, tx, models.AuditEvent{
+			ActorID:  actorID,
+			Action:   models.AuditActionAddSubgroup,
+			TargetID: groupID,
+			NewValue: strconv.FormatInt(childID, 10),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveSubgroup removes the child group from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.postgres.RemoveSubgroup"
+
+	return s.removeGroupLink(ctx, op, tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveSubgroup,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(childID, 10),
+	}, "DELETE FROM group_children WHERE group_id = $1 AND child_id = $2", groupID, childID)
+}
+
+// SetGroupAdmin grants or revokes admin status of the group and writes the change to the audit log.
+// Последнего администратора разжаловать нельзя (storage.ErrLastAdmin).
+func (s *Storage) SetGroupAdmin(ctx context.Context, tenantID int64, actorID int64, groupID int64, isAdmin bool) error {
+	const op = "storage.postgres.SetGroupAdmin"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		var wasAdmin bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT is_admin FROM groups WHERE id = $1 AND tenant_id = $2", groupID, tenantID,
+		).Scan(&wasAdmin)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrGroupNotFound
+			}
+
+			return err
+		}
+
+		if wasAdmin == isAdmin {
+			return nil
+		}
+
+		admins, err := adminCount(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE groups SET is_admin = $1 WHERE id = $2", isAdmin, groupID); err != nil {
+			return err
+		}
+
+		if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+			return err
+		}
+
+		return insertAuditEvent(ctx, tx, models.AuditEvent{
+			ActorID:  actorID,
+			Action:   models.AuditActionSetGroupAdmin,
+			TargetID: groupID,
+			OldValue: strconv.FormatBool(wasAdmin),
+			NewValue: strconv.FormatBool(isAdmin),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserGroups returns all groups of the user: direct ones and the groups they are nested into.
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "storage.postgres.UserGroups"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, userGroupsCTE+`
+		SELECT `+groupColumns+` FROM groups WHERE id IN (SELECT id FROM user_groups) ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// removeGroupLink удаляет связь группы (участника или вложенную группу) в транзакции
+// с проверкой последнего администратора и записью в журнал аудита
+func (s *Storage) removeGroupLink(
+	ctx context.Context,
+	op string,
+	tenantID int64,
+	event models.AuditEvent,
+	query string,
+	args ...any,
+) error {
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		if err := groupExists(ctx, tx, tenantID, event.TargetID); err != nil {
+			return err
+		}
+
+		admins, err := adminCount(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return nil
+		}
+
+		if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+			return err
+		}
+
+		return insertAuditEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,469 @@
+// internal/storage/postgres/roles.go
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SaveRole saves a new role of the app with its permissions and returns role id.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) SaveRole(ctx context.Context, tenantID int64, appID int, name string, permissions []string) (int64, error) {
+	const op = "storage.postgres.SaveRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "INSERT INTO roles(app_id, name) VALUES ($1, $2) RETURNING id", appID, name).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertRolePermissions(ctx, tx, id, permissions); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Role returns role by id with its permissions.
+func (s *Storage) Role(ctx context.Context, id int64) (models.Role, error) {
+	const op = "storage.postgres.Role"
+
+	var role models.Role
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, app_id, name FROM roles WHERE id = $1", id).
+		Scan(&role.ID, &role.AppID, &role.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT permission FROM role_permissions WHERE role_id = $1 ORDER BY permission", id)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return models.Role{}, fmt.Errorf("%s: %w", op, err)
+		}
+		role.Permissions = append(role.Permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// Roles returns all roles of the app of the tenant with their permissions.
+// У приложения другой организации ролей нет.
+func (s *Storage) Roles(ctx context.Context, tenantID int64, appID int) ([]models.Role, error) {
+	const op = "storage.postgres.Roles"
+
+	// Роли и их права одним запросом: у роли без прав permission будет NULL
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT r.id, r.name, rp.permission
+		FROM roles r JOIN apps a ON a.id = r.app_id LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE r.app_id = $1 AND a.tenant_id = $2
+		ORDER BY r.name, rp.permission`, appID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var id int64
+		var name string
+		var permission sql.NullString
+		if err := rows.Scan(&id, &name, &permission); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].ID != id {
+			roles = append(roles, models.Role{ID: id, AppID: appID, Name: name})
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// SetRolePermissions replaces permissions of the role of the tenant.
+func (s *Storage) SetRolePermissions(ctx context.Context, tenantID int64, roleID int64, permissions []string) error {
+	const op = "storage.postgres.SetRolePermissions"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertRolePermissions(ctx, tx, roleID, permissions); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteRole deletes the role of the tenant with its permissions and assignments.
+// Приглашения с этой ролью остаются в силе, но роль при принятии уже не выдаётся.
+func (s *Storage) DeleteRole(ctx context.Context, tenantID int64, id int64) error {
+	const op = "storage.postgres.DeleteRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE role_id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM group_roles WHERE role_id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM roles WHERE id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AssignRole assigns the role to the user and writes the change to the audit log.
+// Роль и пользователь должны быть из организации tenantID. Повторное назначение ничего не меняет.
+func (s *Storage) AssignRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error {
+	const op = "storage.postgres.AssignRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO user_roles(user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAssignRole,
+		TargetID: userID,
+		NewValue: strconv.FormatInt(roleID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRole revokes the role from the user and writes the change to the audit log.
+// Если роль не была назначена, ничего не меняется.
+func (s *Storage) RevokeRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error {
+	const op = "storage.postgres.RevokeRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", userID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeRole,
+		TargetID: userID,
+		OldValue: strconv.FormatInt(roleID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserRoles returns names of the user's roles in the app,
+// including roles granted to the user's groups.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int) ([]string, error) {
+	const op = "storage.postgres.UserRoles"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, userRolesCTE+`
+		SELECT r.name
+		FROM user_roles_all ur JOIN roles r ON r.id = ur.role_id
+		WHERE r.app_id = $2
+		ORDER BY r.name`, userID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		roles = append(roles, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// HasPermission checks whether one of the user's roles in the app grants the permission.
+// Учитываются и роли, выданные группам пользователя. У отключённого пользователя прав нет.
+func (s *Storage) HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error) {
+	const op = "storage.postgres.HasPermission"
+
+	var allowed bool
+	err := s.conn(ctx).QueryRowContext(ctx, userRolesCTE+`
+		SELECT EXISTS(
+			SELECT 1
+			FROM user_roles_all ur
+			JOIN roles r ON r.id = ur.role_id
+			JOIN role_permissions rp ON rp.role_id = r.id
+			WHERE r.app_id = $2 AND rp.permission = $3
+		) AND NOT EXISTS(SELECT 1 FROM users WHERE id = $1 AND (disabled OR deleted_at IS NOT NULL))`,
+		userID, appID, permission,
+	).Scan(&allowed)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+// AssignGroupRole grants the role to the group and writes the change to the audit log.
+// Роль и группа должны быть из организации tenantID.
+func (s *Storage) AssignGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error {
+	const op = "storage.postgres.AssignGroupRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := groupExists(ctx, tx, tenantID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO group_roles(group_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", groupID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAssignGroupRole,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(roleID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeGroupRole revokes the role from the group and writes the change to the audit log.
+func (s *Storage) RevokeGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error {
+	const op = "storage.postgres.RevokeGroupRole"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := roleExists(ctx, tx, tenantID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2", groupID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeGroupRole,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(roleID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// userRolesCTE все роли пользователя (параметр $1 - ID пользователя):
+// назначенные ему напрямую и выданные его группам, с учётом вложенности
+const userRolesCTE = userGroupsCTE + `,
+	user_roles_all(role_id) AS (
+		SELECT role_id FROM user_roles WHERE user_id = $1
+		UNION
+		SELECT gr.role_id FROM group_roles gr JOIN user_groups ug ON ug.id = gr.group_id
+	)`
+
+// roleExists проверяет, что роль есть в одном из приложений организации
+func roleExists(ctx context.Context, tx querier, tenantID int64, roleID int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(
+			SELECT 1 FROM roles r JOIN apps a ON a.id = r.app_id WHERE r.id = $1 AND a.tenant_id = $2
+		)`, roleID, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func insertRolePermissions(ctx context.Context, tx querier, roleID int64, permissions []string) error {
+	for _, permission := range permissions {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO role_permissions(role_id, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING", roleID, permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
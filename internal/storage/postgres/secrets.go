@@ -0,0 +1,168 @@
+// internal/storage/postgres/secrets.go
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+)
+
+// RotateAppSecret replaces the app secret with a new one and writes the rotation to the audit log.
+// Прежний секрет сохраняется и принимается для проверки токенов до previousExpiresAt;
+// секрет, оставшийся от предыдущей ротации, при этом перестаёт действовать сразу.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) RotateAppSecret(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	appID int,
+	secret string,
+	previousExpiresAt time.Time,
+) error {
+	const op = "storage.postgres.RotateAppSecret"
+
+	sealed, keyID, err := s.keyring.Seal(secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// текущий секрет переносим как есть, без расшифровки
+	_, err = tx.ExecContext(ctx, `UPDATE apps
+		SET previous_secret = secret, previous_secret_key_id = secret_key_id, previous_secret_expires_at = $1,
+			secret = $2, secret_key_id = $3
+		WHERE id = $4`,
+		previousExpiresAt.UTC(), sealed, keyID, appID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// сами секреты в журнал не попадают
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRotateAppSecret,
+		TargetID: int64(appID),
+		NewValue: "previous secret valid until " + previousExpiresAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EncryptAppSecrets encrypts with the active master key the app secrets stored in plaintext
+// or encrypted with another master key and returns the number of updated apps.
+// Вызывается при запуске сервиса: так шифруются секреты, записанные до появления шифрования,
+// и перешифровываются секреты после смены активного мастер-ключа.
+func (s *Storage) EncryptAppSecrets(ctx context.Context) (int, error) {
+	const op = "storage.postgres.EncryptAppSecrets"
+
+	active := s.keyring.ActiveKeyID()
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	type appSecrets struct {
+		id            int
+		secret, keyID string
+		previous      sql.NullString
+		previousKeyID string
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, secret, secret_key_id, previous_secret, previous_secret_key_id
+		FROM apps
+		WHERE secret_key_id != $1 OR (previous_secret IS NOT NULL AND previous_secret_key_id != $1)
+		FOR UPDATE`,
+		active,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// сначала читаем все строки, чтобы не обновлять таблицу при открытом курсоре
+	var stale []appSecrets
+	for rows.Next() {
+		var app appSecrets
+		if err := rows.Scan(&app.id, &app.secret, &app.keyID, &app.previous, &app.previousKeyID); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		stale = append(stale, app)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, app := range stale {
+		secret, keyID, err := s.reseal(app.secret, app.keyID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: app %d: %w", op, app.id, err)
+		}
+
+		previous, previousKeyID := app.previous, ""
+		if previous.Valid {
+			previous.String, previousKeyID, err = s.reseal(previous.String, app.previousKeyID)
+			if err != nil {
+				return 0, fmt.Errorf("%s: app %d: %w", op, app.id, err)
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE apps
+			SET secret = $1, secret_key_id = $2, previous_secret = $3, previous_secret_key_id = $4
+			WHERE id = $5`,
+			secret, keyID, previous, previousKeyID, app.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: app %d: %w", op, app.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(stale), nil
+}
+
+// reseal расшифровывает секрет и шифрует его заново активным мастер-ключом
+func (s *Storage) reseal(stored string, keyID string) (string, string, error) {
+	secret, err := s.openSecret(stored, keyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.keyring.Seal(secret)
+}
+
+// openSecret расшифровывает секрет приложения.
+// Пустой keyID - секрет записан открытым текстом (до шифрования при запуске, см. EncryptAppSecrets).
+func (s *Storage) openSecret(stored string, keyID string) (string, error) {
+	if keyID == "" {
+		return stored, nil
+	}
+
+	return s.keyring.Open(stored, keyID)
+}
@@ -0,0 +1,186 @@
+// internal/storage/postgres/tx.go
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Сколько раз WithinTx и serializableTx повторяют транзакцию, если PostgreSQL отменил её из-за конфликта
+// с конкурентной транзакцией (SQLSTATE 40001), и пауза перед первым повтором (дальше она удваивается)
+const (
+	txAttempts   = 5
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// serializationFailure код ошибки PostgreSQL, когда SERIALIZABLE транзакцию нельзя
+// упорядочить с конкурентными: её нужно повторить целиком
+const serializationFailure = "40001"
+
+// txKey ключ транзакции WithinTx в контексте
+type txKey struct{}
+
+// querier общие методы *sql.DB, *sql.Tx и txn: через него работают вспомогательные функции,
+// которым всё равно, в транзакции они выполняются или нет
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithinTx runs fn in a single transaction and commits it if fn returns nil.
+// Методы хранилища, вызванные внутри fn с переданным ей ctx, выполняются в этой же транзакции;
+// вложенный WithinTx тоже присоединяется к ней.
+// Транзакция выполняется с уровнем изоляции SERIALIZABLE: уровень нельзя поднять посреди транзакции,
+// а некоторым методам он нужен (см. serializable). При конфликте с конкурентной транзакцией
+// она откатывается и fn вызывается заново, поэтому fn не должна делать ничего, кроме работы с хранилищем.
+// Ошибка запроса в PostgreSQL прерывает всю транзакцию: методы с собственной транзакцией
+// откатывают свои изменения до точки сохранения, а после ошибки одиночного запроса fn должна вернуть ошибку.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.postgres.WithinTx"
+
+	err := s.serializableTx(ctx, func(tx *txn) error {
+		return fn(context.WithValue(ctx, txKey{}, tx.Tx))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// serializableTx выполняет fn в транзакции с уровнем изоляции SERIALIZABLE и фиксирует её.
+// При конфликте с конкурентной транзакцией (он может обнаружиться и на COMMIT) транзакция
+// откатывается и fn вызывается заново, поэтому fn не должна делать ничего, кроме работы с tx.
+// Внутри WithinTx fn выполняется в точке сохранения внешней транзакции, а повторяет её целиком внешний WithinTx.
+func (s *Storage) serializableTx(ctx context.Context, fn func(tx *txn) error) error {
+	if txFromContext(ctx) != nil {
+		return s.runTx(ctx, serializable, fn)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, serializable, fn)
+		if err == nil {
+			return nil
+		}
+		if !isSerializationFailure(err) || attempt == txAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runTx выполняет fn в транзакции (или точке сохранения внешней транзакции) и фиксирует её
+func (s *Storage) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *txn) error) error {
+	tx, err := s.beginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// txn транзакция метода хранилища.
+// Вне WithinTx это обычная транзакция. Внутри WithinTx метод работает в точке сохранения
+// внешней транзакции: при ошибке откатываются только его изменения, а фиксирует всё внешний WithinTx.
+type txn struct {
+	*sql.Tx
+	savepoint bool
+	done      bool
+}
+
+// savepointName имя точки сохранения метода. Имена могут повторяться:
+// RELEASE и ROLLBACK TO в PostgreSQL относятся к последней точке с этим именем
+const savepointName = "storage_method"
+
+// beginTx начинает транзакцию метода. opts учитываются только для собственной транзакции
+func (s *Storage) beginTx(ctx context.Context, opts *sql.TxOptions) (*txn, error) {
+	if outer := txFromContext(ctx); outer != nil {
+		if _, err := outer.ExecContext(ctx, "SAVEPOINT "+savepointName); err != nil {
+			return nil, err
+		}
+
+		return &txn{Tx: outer, savepoint: true}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &txn{Tx: tx}, nil
+}
+
+// Commit фиксирует транзакцию или отпускает точку сохранения
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	_, err := t.Tx.Exec("RELEASE SAVEPOINT " + savepointName)
+
+	return err
+}
+
+// Rollback откатывает транзакцию или изменения с начала точки сохранения.
+// Как и у sql.Tx, после Commit ничего не делает, поэтому его можно откладывать через defer.
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	// ROLLBACK TO не закрывает точку сохранения, её нужно отпустить отдельно
+	if _, err := t.Tx.Exec("ROLLBACK TO SAVEPOINT " + savepointName); err != nil {
+		return err
+	}
+	_, err := t.Tx.Exec("RELEASE SAVEPOINT " + savepointName)
+
+	return err
+}
+
+// conn возвращает транзакцию WithinTx из ctx, а вне её - саму базу
+func (s *Storage) conn(ctx context.Context) querier {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+
+	return s.db
+}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// isSerializationFailure транзакция отменена из-за конфликта с конкурентной (аналог SQLITE_BUSY в sqlite.Storage)
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == serializationFailure
+}
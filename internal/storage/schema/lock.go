@@ -0,0 +1,58 @@
+// internal/storage/schema/lock.go
+
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Драйвер SQLite для файла-замка
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// lockTimeout сколько ждать, пока схему обновляет другой процесс
+const lockTimeout = time.Minute
+
+// Lock takes the cross-process lock on migrations of the database and returns the function releasing it.
+// Не даёт двум процессам (например, двум экземплярам сервиса или сервису и мигратору)
+// обновлять схему одновременно. Для PostgreSQL это уже делает migrate (pg_advisory_lock).
+// Драйвер sqlite3 в migrate блокирует только внутри процесса, поэтому рядом с базой заводим
+// файл-замок <база>.migrate-lock: это тоже база SQLite, и на время миграции на неё берётся
+// эксклюзивная блокировка. Если процесс упадёт, блокировку снимет ОС.
+func Lock(ctx context.Context, driver string, target string) (unlock func() error, err error) {
+	if driver != "sqlite" {
+		return func() error { return nil }, nil
+	}
+
+	// параметры драйвера из пути базы к замку не относятся
+	path, _, _ := strings.Cut(target, "?")
+	db, err := sql.Open("sqlite3", path+".migrate-lock?_busy_timeout="+strconv.FormatInt(lockTimeout.Milliseconds(), 10))
+	if err != nil {
+		return nil, fmt.Errorf("open migration lock: %w", err)
+	}
+
+	// блокировка принадлежит соединению, поэтому держим одно и то же до конца миграции
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open migration lock: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		_ = conn.Close()
+		_ = db.Close()
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "ROLLBACK")
+		_ = conn.Close()
+		_ = db.Close()
+
+		return err
+	}, nil
+}
@@ -0,0 +1,236 @@
+// internal/storage/schema/schema.go
+
+// Package schema применяет к базе хранилища миграции, встроенные в бинарник (см. пакет migrations),
+// и проверяет, что схема базы подходит этой сборке.
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"grpc-service-ref/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	// Драйверы баз для migrate
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+var (
+	// ErrSchemaTooNew база уже обновлена более новой сборкой: эта может её не понять
+	ErrSchemaTooNew = errors.New("database schema is newer than this build supports")
+	// ErrDirtySchema миграция упала посередине: схему нужно поправить вручную (migrator force)
+	ErrDirtySchema = errors.New("database schema is dirty")
+)
+
+// DefaultTable таблица migrate с версией схемы
+const DefaultTable = "migrations"
+
+// Status версия схемы базы и последняя версия, которую знает сборка
+type Status struct {
+	Version int // -1 - миграции ещё не применялись
+	Dirty   bool
+	Latest  int
+}
+
+// Pending есть миграции, которые ещё не применены к базе
+func (s Status) Pending() bool {
+	return s.Version < s.Latest
+}
+
+// Source returns embedded migrations of the storage driver: sqlite or postgres.
+func Source(driver string) (source.Driver, error) {
+	const op = "storage.schema.Source"
+
+	dir := "."
+	switch driver {
+	case "sqlite":
+	case "postgres":
+		dir = "postgres"
+	default:
+		return nil, fmt.Errorf("%s: unknown driver %q", op, driver)
+	}
+
+	src, err := iofs.New(migrations.FS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return src, nil
+}
+
+// DatabaseURL returns migrate URL of the database: target is the file for sqlite
+// and the connection URL for postgres. Версия схемы хранится в таблице table.
+func DatabaseURL(driver string, target string, table string) (string, error) {
+	const op = "storage.schema.DatabaseURL"
+
+	if target == "" {
+		return "", fmt.Errorf("%s: database is not set", op)
+	}
+
+	var url string
+	switch driver {
+	case "sqlite":
+		url = "sqlite3://" + target
+	case "postgres":
+		url = target
+	default:
+		return "", fmt.Errorf("%s: unknown driver %q", op, driver)
+	}
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+
+	return url + separator + "x-migrations-table=" + table, nil
+}
+
+// Migrate applies pending embedded migrations and returns the resulting status.
+// Одновременно схему обновляет только один процесс (см. Lock). Базу новее сборки или
+// с упавшей миграцией не трогает: ErrSchemaTooNew или ErrDirtySchema.
+func Migrate(ctx context.Context, driver string, target string) (Status, error) {
+	const op = "storage.schema.Migrate"
+
+	unlock, err := Lock(ctx, driver, target)
+	if err != nil {
+		return Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = unlock() }()
+
+	m, latest, err := open(driver, target)
+	if err != nil {
+		return Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	status, err := check(m, latest)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+
+	status, err = check(m, latest)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return status, nil
+}
+
+// Check returns status of the database schema without migrating it.
+// Как и Migrate, возвращает ErrSchemaTooNew и ErrDirtySchema.
+func Check(driver string, target string) (Status, error) {
+	const op = "storage.schema.Check"
+
+	m, latest, err := open(driver, target)
+	if err != nil {
+		return Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	status, err := check(m, latest)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return status, nil
+}
+
+// Latest returns the latest version of embedded migrations of the driver.
+func Latest(driver string) (int, error) {
+	const op = "storage.schema.Latest"
+
+	src, err := Source(driver)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer src.Close()
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return latest, nil
+}
+
+// open создаёт migrate со встроенными миграциями и узнаёт последнюю их версию
+func open(driver string, target string) (*migrate.Migrate, int, error) {
+	latest, err := Latest(driver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	src, err := Source(driver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	url, err := DatabaseURL(driver, target, DefaultTable)
+	if err != nil {
+		_ = src.Close()
+		return nil, 0, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, url)
+	if err != nil {
+		_ = src.Close()
+		return nil, 0, err
+	}
+
+	return m, latest, nil
+}
+
+// check читает версию схемы и сравнивает её с последней известной
+func check(m *migrate.Migrate, latest int) (Status, error) {
+	status := Status{Version: -1, Latest: latest}
+
+	v, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		return status, nil
+	case err != nil:
+		return status, err
+	}
+	status.Version, status.Dirty = int(v), dirty
+
+	if status.Version > latest {
+		return status, fmt.Errorf("%w: version %d, latest known %d", ErrSchemaTooNew, status.Version, latest)
+	}
+	if dirty {
+		return status, fmt.Errorf("%w: version %d", ErrDirtySchema, status.Version)
+	}
+
+	return status, nil
+}
+
+// latestVersion последняя версия миграций источника; -1, если миграций нет
+func latestVersion(src source.Driver) (int, error) {
+	v, err := src.First()
+	if errors.Is(err, fs.ErrNotExist) {
+		return -1, nil
+	}
+
+	for err == nil {
+		var next uint
+		next, err = src.Next(v)
+		if err == nil {
+			v = next
+		}
+	}
+	// источник сообщает о конце списка через fs.ErrNotExist
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	return int(v), nil
+}
@@ -0,0 +1,116 @@
+// internal/storage/schema/schema_test.go
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.db")
+	ctx := context.Background()
+
+	latest, err := Latest("sqlite")
+	require.NoError(t, err)
+	require.Positive(t, latest)
+
+	status, err := Check("sqlite", path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Version: -1, Latest: latest}, status)
+	assert.True(t, status.Pending())
+
+	status, err = Migrate(ctx, "sqlite", path)
+	require.NoError(t, err)
+	assert.Equal(t, Status{Version: latest, Latest: latest}, status)
+	assert.False(t, status.Pending())
+
+	// повторный запуск ничего не меняет
+	status, err = Migrate(ctx, "sqlite", path)
+	require.NoError(t, err)
+	assert.Equal(t, latest, status.Version)
+}
+
+// Несколько экземпляров сервиса стартуют одновременно: схему обновляет один, остальные ждут
+func TestMigrate_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.db")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = Migrate(context.Background(), "sqlite", path)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	status, err := Check("sqlite", path)
+	require.NoError(t, err)
+	assert.False(t, status.Pending())
+}
+
+func TestMigrate_RefusesSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		force   func(latest int) int
+		wantErr error
+	}{
+		{name: "newer", force: func(latest int) int { return latest + 1 }, wantErr: ErrSchemaTooNew},
+		{name: "dirty", force: func(latest int) int { return latest }, wantErr: ErrDirtySchema},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sso.db")
+			ctx := context.Background()
+
+			status, err := Migrate(ctx, "sqlite", path)
+			require.NoError(t, err)
+			setVersion(t, path, tt.force(status.Latest), tt.wantErr == ErrDirtySchema)
+
+			_, err = Check("sqlite", path)
+			assert.ErrorIs(t, err, tt.wantErr)
+			_, err = Migrate(ctx, "sqlite", path)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDatabaseURL(t *testing.T) {
+	url, err := DatabaseURL("sqlite", "./storage/sso.db", "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite3://./storage/sso.db?x-migrations-table=migrations", url)
+
+	url, err = DatabaseURL("postgres", "postgres://localhost/sso?sslmode=disable", "migrations_test")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://localhost/sso?sslmode=disable&x-migrations-table=migrations_test", url)
+
+	_, err = DatabaseURL("sqlite", "", "migrations")
+	assert.Error(t, err)
+	_, err = DatabaseURL("mysql", "db", "migrations")
+	assert.Error(t, err)
+}
+
+// setVersion записывает версию схемы в обход миграций: так её оставила бы более новая сборка
+// или миграция, упавшая посередине (dirty)
+func setVersion(t *testing.T, path string, version int, dirty bool) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE "+DefaultTable+" SET version = ?, dirty = ?", version, dirty)
+	require.NoError(t, err)
+}
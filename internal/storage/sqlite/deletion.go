@@ -0,0 +1,177 @@
+// internal/storage/sqlite/deletion.go
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Удаление пользователей в два шага. DeleteUser только помечает пользователя удалённым (deleted_at):
+// для всех методов хранилища его больше нет, но данные остаются до конца срока хранения.
+// PurgeDeletedUsers после этого срока удаляет пользователя окончательно вместе со связями
+// и обезличивает его персональные данные в приглашениях и журнале аудита.
+
+// DeleteUser marks the user of the tenant as deleted on behalf of the actor
+// and records the deletion in the audit log.
+// Последнего администратора организации удалить нельзя: storage.ErrLastAdmin.
+func (s *Storage) DeleteUser(ctx context.Context, tenantID int64, actorID int64, userID int64) error {
+	const op = "storage.sqlite.DeleteUser"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET deleted_at = ? WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL",
+		time.Now().UTC(), userID, tenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionDeleteUser,
+		TargetID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users marked as deleted before deletedBefore
+// and returns how many users were purged.
+// Каждый пользователь удаляется в своей транзакции: ошибка на одном не откатывает уже удалённых.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	const op = "storage.sqlite.PurgeDeletedUsers"
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT id, tenant_id, email FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id",
+		deletedBefore.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.TenantID, &user.Email); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, user := range users {
+		if err := s.purgeUser(ctx, user); err != nil {
+			return i, fmt.Errorf("%s: user %d: %w", op, user.ID, err)
+		}
+	}
+
+	return len(users), nil
+}
+
+// purgeUser окончательно удаляет пользователя. Порядок такой, чтобы запросы проходили
+// и с включённым foreign_keys: сначала связи, затем сам пользователь.
+func (s *Storage) purgeUser(ctx context.Context, user models.User) error {
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := anonymizeInvitations(ctx, tx, user); err != nil {
+		return err
+	}
+
+	// атрибуты в журнале - персональные данные (отдел, уровень и т.п.)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE audit_log SET old_value = '', new_value = '' WHERE action = ? AND target_id = ?",
+		models.AuditActionSetUserAttributes, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// политики остаются, но без автора
+	if _, err := tx.ExecContext(ctx, "UPDATE policies SET created_by = NULL WHERE created_by = ?", user.ID); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"federated_identities", "group_members", "user_roles", "app_members", "user_attributes"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", user.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", user.ID); err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		Action:   models.AuditActionPurgeUser,
+		TargetID: user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// anonymizeInvitations стирает email пользователя в его приглашениях (выданных на его адрес
+// или принятых им) и в записях журнала об их создании. Неиспользованные приглашения отзываются.
+func anonymizeInvitations(ctx context.Context, tx querier, user models.User) error {
+	const invitations = "SELECT id FROM invitations WHERE tenant_id = ? AND (email = ? OR accepted_by = ?)"
+
+	_, err := tx.ExecContext(ctx,
+		"UPDATE audit_log SET new_value = '' WHERE action = ? AND target_id IN ("+invitations+")",
+		models.AuditActionCreateInvitation, user.TenantID, user.Email, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE invitations SET email = '', accepted_by = NULL,
+		revoked_at = CASE WHEN accepted_at IS NULL AND revoked_at IS NULL THEN ? ELSE revoked_at END
+		WHERE id IN (`+invitations+")",
+		time.Now().UTC(), user.TenantID, user.Email, user.ID,
+	)
+
+	return err
+}
@@ -0,0 +1,581 @@
+// internal/storage/sqlite/groups.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveGroup saves a new group of the tenant and returns its id.
+func (s *Storage) SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error) {
+	const op = "storage.sqlite.SaveGroup"
+
+	res, err := stmt(ctx, s.stmts.saveGroup).ExecContext(ctx, tenantID, name, nullString(externalID))
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Group returns group by id with its members.
+func (s *Storage) Group(ctx context.Context, id int64) (models.Group, error) {
+	const op = "storage.sqlite.Group"
+
+	group, err := scanGroup(stmt(ctx, s.stmts.group).QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Members, err = s.groupMembers(ctx, group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Subgroups, err = s.groupIDs(ctx, "SELECT child_id FROM group_children WHERE group_id = ? ORDER BY child_id", group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.Roles, err = s.groupIDs(ctx, "SELECT role_id FROM group_roles WHERE group_id = ? ORDER BY role_id", group.ID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// Groups returns a page of groups matching the filter and the total number of matching groups.
+func (s *Storage) Groups(ctx context.Context, filter storage.GroupFilter, offset int, limit int) ([]models.Group, int, error) {
+	const op = "storage.sqlite.Groups"
+
+	where, args := filterClause(filter.TenantID, map[string]string{
+		"name":        filter.Name,
+		"external_id": filter.ExternalID,
+	})
+
+	var total int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM groups"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+groupColumns+" FROM groups"+where+" ORDER BY id LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Участников читаем после того, как закрыли выборку групп
+	for i := range groups {
+		groups[i].Members, err = s.groupMembers(ctx, groups[i].ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return groups, total, nil
+}
+
+// UpdateGroup updates name and external id of the group.
+func (s *Storage) UpdateGroup(ctx context.Context, group models.Group) error {
+	const op = "storage.sqlite.UpdateGroup"
+
+	res, err := stmt(ctx, s.stmts.updateGroup).ExecContext(ctx, group.Name, nullString(group.ExternalID), group.ID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return nil
+}
+
+// SetGroupMembers replaces members of the group of the tenant.
+// Если кого-то из пользователей нет в организации - возвращается storage.ErrUserNotFound
+// и состав группы не меняется.
+func (s *Storage) SetGroupMembers(ctx context.Context, tenantID int64, groupID int64, userIDs []int64) error {
+	const op = "storage.sqlite.SetGroupMembers"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := groupExists(ctx, tx, tenantID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ?", groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Внешние ключи в SQLite по умолчанию не проверяются,
+	// поэтому добавляем участника, только если такой пользователь есть в организации
+	for _, userID := range userIDs {
+		res, err := tx.ExecContext(ctx, insertGroupMember, groupID, userID, tenantID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		// пользователь есть - значит, он просто указан дважды
+		if affected == 0 {
+			if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	// из группы администраторов нельзя убрать последнего администратора
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes the group of the tenant and its memberships.
+func (s *Storage) DeleteGroup(ctx context.Context, tenantID int64, id int64) error {
+	const op = "storage.sqlite.DeleteGroup"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := groupExists(ctx, tx, tenantID, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM group_members WHERE group_id = ?",
+		"DELETE FROM group_children WHERE group_id = ?1 OR child_id = ?1",
+		"DELETE FROM group_roles WHERE group_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM groups WHERE id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) groupMembers(ctx context.Context, groupID int64) ([]int64, error) {
+	return s.groupIDs(ctx, "SELECT user_id FROM group_members WHERE group_id = ? ORDER BY user_id", groupID)
+}
+
+// groupIDs читает список ID (участников, вложенных групп, ролей) группы
+func (s *Storage) groupIDs(ctx context.Context, query string, groupID int64) ([]int64, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// groupColumns колонки группы в порядке, который ожидает scanGroup
+const groupColumns = "id, tenant_id, name, external_id, is_admin"
+
+func scanGroup(row scanner) (models.Group, error) {
+	var group models.Group
+	var externalID sql.NullString
+
+	if err := row.Scan(&group.ID, &group.TenantID, &group.Name, &externalID, &group.IsAdmin); err != nil {
+		return models.Group{}, err
+	}
+	group.ExternalID = externalID.String
+
+	return group, nil
+}
+
+// insertGroupMember добавляет участника, только если пользователь есть в организации
+// (параметры: ID группы, ID пользователя, ID организации)
+const insertGroupMember = `INSERT OR IGNORE INTO group_members(group_id, user_id)
+	SELECT ?, id FROM users WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`
+
+// groupExists проверяет, что группа есть в организации: группы других организаций для неё не существуют
+func groupExists(ctx context.Context, tx querier, tenantID int64, groupID int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM groups WHERE id = ? AND tenant_id = ?)", groupID, tenantID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrGroupNotFound
+	}
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group and writes the change to the audit log.
+// Повторное добавление ничего не меняет.
+// Группа и пользователь должны быть из организации tenantID.
+func (s *Storage) AddGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.sqlite.AddGroupMember"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := groupExists(ctx, tx, tenantID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, insertGroupMember, groupID, userID, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddGroupMember,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(userID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error {
+	const op = "storage.sqlite.RemoveGroupMember"
+
+	return s.removeGroupLink(ctx, op, tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveGroupMember,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(userID, 10),
+	}, "DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID)
+}
+
+// AddSubgroup nests the child group into the group and writes the change to the audit log.
+// Обе группы должны быть из организации tenantID.
+// Если группа уже вложена в child (напрямую или через другие группы), возвращается storage.ErrGroupCycle.
+func (s *Storage) AddSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.sqlite.AddSubgroup"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, id := range []int64{groupID, childID} {
+		if err := groupExists(ctx, tx, tenantID, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// Цикл появится, если group - это сама child или одна из её вложенных групп
+	var cycle bool
+	err = tx.QueryRowContext(ctx, `WITH RECURSIVE descendants(id) AS (
+			SELECT ?
+			UNION
+			SELECT gc.child_id FROM group_children gc JOIN descendants d ON gc.group_id = d.id
+		)
+		SELECT EXISTS(SELECT 1 FROM descendants WHERE id = ?)`, childID, groupID).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if cycle {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupCycle)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO group_children(group_id, child_id) VALUES (?, ?)", groupID, childID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionAddSubgroup,
+		TargetID: groupID,
+		NewValue: strconv.FormatInt(childID, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveSubgroup removes the child group from the group and writes the change to the audit log.
+// Последнего администратора исключить нельзя (storage.ErrLastAdmin).
+func (s *Storage) RemoveSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error {
+	const op = "storage.sqlite.RemoveSubgroup"
+
+	return s.removeGroupLink(ctx, op, tenantID, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRemoveSubgroup,
+		TargetID: groupID,
+		OldValue: strconv.FormatInt(childID, 10),
+	}, "DELETE FROM group_children WHERE group_id = ? AND child_id = ?", groupID, childID)
+}
+
+// SetGroupAdmin grants or revokes admin status of the group and writes the change to the audit log.
+// Последнего администратора разжаловать нельзя (storage.ErrLastAdmin).
+func (s *Storage) SetGroupAdmin(ctx context.Context, tenantID int64, actorID int64, groupID int64, isAdmin bool) error {
+	const op = "storage.sqlite.SetGroupAdmin"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var wasAdmin bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT is_admin FROM groups WHERE id = ? AND tenant_id = ?", groupID, tenantID,
+	).Scan(&wasAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if wasAdmin == isAdmin {
+		return nil
+	}
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE groups SET is_admin = ? WHERE id = ?", isAdmin, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetGroupAdmin,
+		TargetID: groupID,
+		OldValue: strconv.FormatBool(wasAdmin),
+		NewValue: strconv.FormatBool(isAdmin),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserGroups returns all groups of the user: direct ones and the groups they are nested into.
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "storage.sqlite.UserGroups"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, userGroupsCTE+`
+		SELECT `+groupColumns+` FROM groups WHERE id IN (SELECT id FROM user_groups) ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// removeGroupLink удаляет связь группы (участника или вложенную группу) в транзакции
+// с проверкой последнего администратора и записью в журнал аудита
+func (s *Storage) removeGroupLink(
+	ctx context.Context,
+	op string,
+	tenantID int64,
+	event models.AuditEvent,
+	query string,
+	args ...any,
+) error {
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := groupExists(ctx, tx, tenantID, event.TargetID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+// internal/storage/sqlite/invitations.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SaveInvitation saves a new invitation with the hash of its token and writes it to the audit log.
+// Приложение должно принадлежать организации приглашения, а роль (если задана) - приложению.
+func (s *Storage) SaveInvitation(ctx context.Context, inv models.Invitation, tokenHash string) (int64, error) {
+	const op = "storage.sqlite.SaveInvitation"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, inv.TenantID, inv.AppID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var roleID sql.NullInt64
+	if inv.RoleID != 0 {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM roles WHERE id = ? AND app_id = ?)", inv.RoleID, inv.AppID,
+		).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+
+		roleID = sql.NullInt64{Int64: inv.RoleID, Valid: true}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO invitations
+		(tenant_id, app_id, email, role_id, token_hash, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.TenantID, inv.AppID, inv.Email, roleID, tokenHash, inv.CreatedBy, inv.CreatedAt.UTC(), inv.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  inv.CreatedBy,
+		Action:   models.AuditActionCreateInvitation,
+		TargetID: id,
+		NewValue: inv.Email,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Invitations returns all invitations to the app, newest first.
+// Приложение должно принадлежать организации tenantID.
+func (s *Storage) Invitations(ctx context.Context, tenantID int64, appID int) ([]models.Invitation, error) {
+	const op = "storage.sqlite.Invitations"
+
+	tx, err := s.beginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE app_id = ? ORDER BY id DESC", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invitations []models.Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitations, nil
+}
+
+// InvitationByToken returns the invitation by the hash of its token.
+func (s *Storage) InvitationByToken(ctx context.Context, tokenHash string) (models.Invitation, error) {
+	const op = "storage.sqlite.InvitationByToken"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM invitations WHERE token_hash = ?", tokenHash)
+
+	inv, err := scanInvitation(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inv, nil
+}
+
+// RevokeInvitation revokes the pending invitation and writes the change to the audit log.
+// Принятое или уже отозванное приглашение, как и приглашение другой организации,
+// считается ненайденным (storage.ErrInvitationNotFound).
+func (s *Storage) RevokeInvitation(ctx context.Context, tenantID int64, actorID int64, id int64) error {
+	const op = "storage.sqlite.RevokeInvitation"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE invitations SET revoked_at = ?
+		WHERE id = ? AND tenant_id = ? AND accepted_at IS NULL AND revoked_at IS NULL`,
+		time.Now().UTC(), id, tenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionRevokeInvitation,
+		TargetID: id,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AcceptInvitation marks the invitation as accepted by the user, makes the user a member
+// of the app and grants the role of the invitation. Всё выполняется в одной транзакции,
+// поэтому приглашение нельзя принять дважды.
+// Если приглашение уже принято, отозвано или истекло, возвращается storage.ErrInvitationNotFound.
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, userID int64) error {
+	const op = "storage.sqlite.AcceptInvitation"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+
+	res, err := tx.ExecContext(ctx, `UPDATE invitations SET accepted_by = ?, accepted_at = ?
+		WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?`,
+		userID, now, id, now,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	var appID int
+	var roleID sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT app_id, role_id FROM invitations WHERE id = ?", id).Scan(&appID, &roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO app_members(app_id, user_id) VALUES (?, ?)", appID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if roleID.Valid {
+		_, err = tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO user_roles(user_id, role_id) VALUES (?, ?)", userID, roleID.Int64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  userID,
+		Action:   models.AuditActionAcceptInvitation,
+		TargetID: id,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const invitationColumns = `id, tenant_id, app_id, email, role_id, created_by, created_at, expires_at,
+	accepted_by, accepted_at, revoked_at`
+
+func scanInvitation(row scanner) (models.Invitation, error) {
+	var inv models.Invitation
+	var roleID, acceptedBy sql.NullInt64
+	var acceptedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&inv.ID, &inv.TenantID, &inv.AppID, &inv.Email, &roleID, &inv.CreatedBy, &inv.CreatedAt, &inv.ExpiresAt,
+		&acceptedBy, &acceptedAt, &revokedAt,
+	)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	inv.RoleID = roleID.Int64
+	inv.AcceptedBy = acceptedBy.Int64
+	inv.AcceptedAt = acceptedAt.Time
+	inv.RevokedAt = revokedAt.Time
+
+	return inv, nil
+}
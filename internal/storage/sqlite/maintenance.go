@@ -0,0 +1,194 @@
+// internal/storage/sqlite/maintenance.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Обслуживание файла базы (резервные копии, проверка целостности, сжатие) для утилиты ssoctl.
+// Всё это можно делать, пока работает сервис: SQLite сам согласует доступ разных процессов к файлу.
+
+// backupRetryDelay пауза перед повтором шага копирования, если база занята другим соединением
+const backupRetryDelay = 10 * time.Millisecond
+
+// Backup copies the database srcPath to destPath with the SQLite online backup API.
+// Копия согласована: в неё попадают только зафиксированные транзакции. destPath заменяется целиком,
+// поэтому так же выполняется и восстановление из копии в рабочую базу.
+func Backup(ctx context.Context, srcPath string, destPath string) error {
+	const op = "storage.sqlite.Backup"
+
+	if err := copyDatabase(ctx, srcPath, destPath); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func copyDatabase(ctx context.Context, srcPath string, destPath string) error {
+	src, err := openExisting(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	// API копирования работает с соединениями драйвера, а не с пулом database/sql
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			return backupPages(ctx, destDriverConn.(*sqlite3.SQLiteConn), srcDriverConn.(*sqlite3.SQLiteConn))
+		})
+	})
+}
+
+// backupPages копирует все страницы базы за один шаг: так копирование не начинается заново,
+// если сервис успеет что-то записать между шагами. Пока база занята, шаг повторяется.
+func backupPages(ctx context.Context, dest *sqlite3.SQLiteConn, src *sqlite3.SQLiteConn) error {
+	backup, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+
+	for {
+		done, err := backup.Step(-1)
+		if err != nil {
+			_ = backup.Finish()
+			return err
+		}
+		if done {
+			return backup.Finish()
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = backup.Finish()
+			return ctx.Err()
+		case <-time.After(backupRetryDelay):
+		}
+	}
+}
+
+// IntegrityCheck runs PRAGMA integrity_check on the database and returns the problems found.
+// Пустой результат - база в порядке.
+func IntegrityCheck(ctx context.Context, path string) ([]string, error) {
+	const op = "storage.sqlite.IntegrityCheck"
+
+	db, err := openExisting(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		// если ошибок нет, SQLite возвращает одну строку "ok"
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return problems, nil
+}
+
+// VacuumInto writes a compacted copy of the database to destPath (VACUUM INTO).
+// Рабочая база не меняется; сжатую копию можно восстановить на место рабочей.
+func VacuumInto(ctx context.Context, path string, destPath string) error {
+	const op = "storage.sqlite.VacuumInto"
+
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("%s: %s already exists", op, destPath)
+	}
+
+	db, err := openExisting(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", destPath); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the migration version of the database recorded in the table of migrate.
+// Если миграции не применялись, version = -1.
+func SchemaVersion(ctx context.Context, path string, table string) (version int, dirty bool, err error) {
+	const op = "storage.sqlite.SchemaVersion"
+
+	db, err := openExisting(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer db.Close()
+
+	// таблицы нет - мигратор к базе не подключался
+	var exists bool
+	err = db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", table,
+	).Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return -1, false, nil
+	}
+
+	// имя таблицы не может быть параметром запроса, но его существование уже проверено выше
+	err = db.QueryRowContext(ctx, `SELECT version, dirty FROM "`+table+`" LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, dirty, nil
+}
+
+// openExisting открывает существующий файл базы: sql.Open создал бы вместо опечатки пустую базу
+func openExisting(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	return sql.Open("sqlite3", path)
+}
@@ -0,0 +1,206 @@
+// internal/storage/sqlite/policies.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// SavePolicy saves the document as a new version of the app policy, writes it to the audit log
+// and returns the version. Приложение должно принадлежать организации tenantID.
+func (s *Storage) SavePolicy(ctx context.Context, tenantID int64, actorID int64, appID int, document string) (int, error) {
+	const op = "storage.sqlite.SavePolicy"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := appInTenant(ctx, tx, tenantID, appID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version), 0) + 1 FROM policies WHERE app_id = ?", appID,
+	).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO policies(app_id, version, document, created_by, created_at) VALUES (?, ?, ?, ?, ?)",
+		appID, version, document, actorID, time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetPolicy,
+		TargetID: int64(appID),
+		OldValue: strconv.Itoa(version - 1),
+		NewValue: strconv.Itoa(version),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// Policy returns the version of the app policy; version 0 means the latest one.
+// Если у приложения нет политики (или такой версии), возвращается storage.ErrPolicyNotFound.
+func (s *Storage) Policy(ctx context.Context, appID int, version int) (models.Policy, error) {
+	const op = "storage.sqlite.Policy"
+
+	query := `SELECT app_id, version, document, created_by, created_at FROM policies
+		WHERE app_id = ? AND version = ?`
+	args := []any{appID, version}
+	if version == 0 {
+		query = `SELECT app_id, version, document, created_by, created_at FROM policies
+			WHERE app_id = ? ORDER BY version DESC LIMIT 1`
+		args = args[:1]
+	}
+
+	var p models.Policy
+	var createdBy sql.NullInt64
+	err := s.conn(ctx).QueryRowContext(ctx, query, args...).
+		Scan(&p.AppID, &p.Version, &p.Document, &createdBy, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Policy{}, fmt.Errorf("%s: %w", op, storage.ErrPolicyNotFound)
+		}
+
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// автор, удалённый окончательно, - NULL
+	p.CreatedBy = createdBy.Int64
+
+	return p, nil
+}
+
+// SetUserAttributes replaces the attributes of the user and writes the change to the audit log.
+// Пользователь должен быть в каталоге организации tenantID.
+func (s *Storage) SetUserAttributes(
+	ctx context.Context,
+	tenantID int64,
+	actorID int64,
+	userID int64,
+	attributes map[string]string,
+) error {
+	const op = "storage.sqlite.SetUserAttributes"
+
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := userInTenant(ctx, tx, tenantID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	old, err := userAttributes(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if maps.Equal(old, attributes) {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_attributes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for name, value := range attributes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_attributes(user_id, name, value) VALUES (?, ?, ?)", userID, name, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetUserAttributes,
+		TargetID: userID,
+		OldValue: formatAttributes(old),
+		NewValue: formatAttributes(attributes),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserAttributes returns the attributes of the user.
+func (s *Storage) UserAttributes(ctx context.Context, userID int64) (map[string]string, error) {
+	const op = "storage.sqlite.UserAttributes"
+
+	tx, err := s.beginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	attributes, err := userAttributes(ctx, tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attributes, nil
+}
+
+func userAttributes(ctx context.Context, tx querier, userID int64) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name, value FROM user_attributes WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		attributes[name] = value
+	}
+
+	return attributes, rows.Err()
+}
+
+// formatAttributes атрибуты для журнала аудита: name=value через пробел, по алфавиту
+func formatAttributes(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, name+"="+value)
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, " ")
+}
@@ -5,8 +5,14 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"grpc-service-ref/internal/domain/models"
 	"grpc-service-ref/internal/storage"
@@ -17,33 +23,95 @@ import (
 // создаём файл, в котором опишем тип Storage и конструктор для него:
 type Storage struct {
 	db *sql.DB
+	// Частые запросы подготавливаются один раз (см. statements)
+	stmts *statements
+	// Секреты приложений хранятся зашифрованными (см. lib/secrets)
+	keyring SecretKeyring
 }
 
-// Конструктор для хранилища
-func New(storagePath string) (*Storage, error) {
+var _ storage.Storage = (*Storage)(nil)
+
+// SecretKeyring шифрует и расшифровывает секреты приложений мастер-ключами (реализован secrets.Keyring)
+type SecretKeyring interface {
+	ActiveKeyID() string
+	Seal(secret string) (sealed string, keyID string, err error)
+	Open(sealed string, keyID string) (string, error)
+}
+
+// Options настройки соединений с базой. Нулевое значение - настройки драйвера по умолчанию.
+type Options struct {
+	MaxOpenConns int           // Максимум открытых соединений; 0 - без ограничения
+	MaxIdleConns int           // Сколько соединений держать открытыми без дела; 0 - по умолчанию database/sql
+	BusyTimeout  time.Duration // Сколько ждать, пока база заблокирована другим соединением
+	JournalMode  string        // PRAGMA journal_mode, например WAL
+	Synchronous  string        // PRAGMA synchronous: OFF, NORMAL, FULL или EXTRA
+	ForeignKeys  bool          // PRAGMA foreign_keys: проверять внешние ключи
+}
+
+// Конструктор для хранилища.
+// Таблицы уже должны быть созданы миграциями: частые запросы подготавливаются здесь же.
+func New(storagePath string, keyring SecretKeyring, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	// Указываем путь до файла БД
-	db, err := sql.Open("sqlite3", storagePath)
+	// Указываем путь до файла БД.
+	// PRAGMA задаются параметрами драйвера: так они применяются к каждому новому соединению пула
+	db, err := sql.Open("sqlite3", dataSourceName(storagePath, opts))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+
+	stmts, err := prepareStatements(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db, stmts: stmts, keyring: keyring}, nil
+}
+
+// dataSourceName добавляет к пути базы параметры драйвера go-sqlite3 для PRAGMA из opts
+func dataSourceName(storagePath string, opts Options) string {
+	params := url.Values{}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", opts.Synchronous)
+	}
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+	if len(params) == 0 {
+		return storagePath
+	}
+
+	// параметры могли быть заданы и в самом пути
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+
+	return storagePath + sep + params.Encode()
 }
 
 // Для хранилища нужно реализовать три метода: SaveUser(), User(), App()
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+// Пользователь сохраняется в каталог организации tenantID: email уникален только в её пределах.
+func (s *Storage) SaveUser(ctx context.Context, tenantID int64, email string, passHash []byte) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 
 	// запрос на добавление пользователя
-	stmt, err := s.db.Prepare("INSERT INTO users(email, pass_hash) VALUES (?, ?)")
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
-	}
-
 	// Выполняем запрос, передав параметры
-	res, err := stmt.ExecContext(ctx, email, passHash)
+	res, err := stmt(ctx, s.stmts.saveUser).ExecContext(ctx, tenantID, email, passHash)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 
@@ -71,22 +139,32 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (
 	return id, nil
 }
 
-// User returns user by email.
-func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+// User returns user of the tenant by email.
+func (s *Storage) User(ctx context.Context, tenantID int64, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE email = ?")
+	row := stmt(ctx, s.stmts.userByEmail).QueryRowContext(ctx, tenantID, email)
+
+	// Здесь мы аналогично определяем ошибку, но на этот раз нас интересует sql.ErrNoRows,
+	// она означает что мы не смогли найти соответствующую запись.
+	// В этом случае мы вернём наружу storage.ErrUserNotFound
+	user, err := scanUser(row)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, email)
+	return user, nil
+}
 
-	var user models.User
-	// Здесь мы аналогично определяем ошибку, но на этот раз нас интересует sql.ErrNoRows,
-	// она означает что мы не смогли найти соответствующую запись.
-	// В этом случае мы вернём наружу storage.ErrUserNotFound
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+// UserByID returns user by id.
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	user, err := scanUser(stmt(ctx, s.stmts.userByID).QueryRowContext(ctx, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -98,22 +176,177 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
-// App returns app by id.
+// Users returns a page of users matching the filter and the total number of matching users.
+func (s *Storage) Users(ctx context.Context, filter storage.UserFilter, offset int, limit int) ([]models.User, int, error) {
+	const op = "storage.sqlite.Users"
+
+	where, args := filterClause(filter.TenantID, map[string]string{
+		"email":       filter.Email,
+		"external_id": filter.ExternalID,
+	})
+	// удалённые пользователи ждут окончательного удаления, в списках их уже нет
+	where += " AND deleted_at IS NULL"
+
+	var total int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+userColumns+" FROM users"+where+" ORDER BY id LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, total, nil
+}
+
+// UpdateUser updates email, external id and disabled flag of the user.
+// Пароль здесь не меняется.
+func (s *Storage) UpdateUser(ctx context.Context, user models.User) error {
+	const op = "storage.sqlite.UpdateUser"
+
+	res, err := stmt(ctx, s.stmts.updateUser).ExecContext(ctx, user.Email, nullString(user.ExternalID), user.Disabled, user.ID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// SetPassHash replaces the password hash of the user
+// (например, хэш импортированного пользователя на bcrypt после входа).
+func (s *Storage) SetPassHash(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.sqlite.SetPassHash"
+
+	res, err := stmt(ctx, s.stmts.setPassHash).ExecContext(ctx, passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// userColumns колонки пользователя в порядке, который ожидает scanUser
+const userColumns = "id, tenant_id, email, pass_hash, disabled, external_id"
+
+// scanner общий интерфейс *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser читает пользователя из строки результата (колонки userColumns)
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	var externalID sql.NullString
+
+	err := row.Scan(&user.ID, &user.TenantID, &user.Email, &user.PassHash, &user.Disabled, &externalID)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.ExternalID = externalID.String
+
+	return user, nil
+}
+
+// filterClause строит условие WHERE из пар колонка-значение, пропуская пустые значения.
+// Выборка всегда ограничена организацией tenantID.
+func filterClause(tenantID int64, conditions map[string]string) (string, []any) {
+	columns := make([]string, 0, len(conditions))
+	for column, value := range conditions {
+		if value != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	// порядок обхода map случайный, а аргументы должны совпадать с плейсхолдерами
+	sort.Strings(columns)
+
+	parts := []string{"tenant_id = ?"}
+	args := []any{tenantID}
+	for _, column := range columns {
+		parts = append(parts, column+" = ?")
+		args = append(args, conditions[column])
+	}
+
+	return " WHERE " + strings.Join(parts, " AND "), args
+}
+
+// nullString пустую строку сохраняем как NULL (нужно для необязательных уникальных колонок)
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// App returns app by id. Для отключённого приложения возвращается storage.ErrAppDisabled.
 func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	row := stmt(ctx, s.stmts.app).QueryRowContext(ctx, id)
+
+	// Как и в предыдущих случаях, в случае отсутствия записи (sql.ErrNoRows),
+	// возвращаем наружу storage.ErrAppNotFound.
+	app, err := s.scanApp(row)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, id)
+	if app.Disabled {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+	}
 
-	var app models.App
+	return app, nil
+}
 
-	// Как и в предыдущих случаях, в случае отсутствия записи (sql.ErrNoRows),
-	// возвращаем наружу storage.ErrAppNotFound.
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+// SAMLApp returns SAML service provider app by its entity ID.
+// Для отключённого приложения возвращается storage.ErrAppDisabled.
+func (s *Storage) SAMLApp(ctx context.Context, entityID string) (models.App, error) {
+	const op = "storage.sqlite.SAMLApp"
+
+	row := stmt(ctx, s.stmts.samlApp).QueryRowContext(ctx, entityID)
+
+	app, err := s.scanApp(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -122,6 +355,66 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if app.Disabled {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+	}
+
+	return app, nil
+}
+
+// appColumns колонки приложения в порядке, который ожидает scanApp
+const appColumns = `id, tenant_id, name, secret, secret_key_id, previous_secret, previous_secret_key_id,
+	previous_secret_expires_at, kind, access_mode, allowed_domains, disabled,
+	saml_entity_id, saml_acs_url, saml_certificate,
+	access_token_ttl, token_algorithm, claim_attributes, static_claims`
+
+// scanApp читает приложение из строки результата и расшифровывает его секреты.
+// SAML-колонки заполнены только у SAML-приложений, поэтому читаем их через sql.NullString.
+func (s *Storage) scanApp(row scanner) (models.App, error) {
+	var app models.App
+	var domains, secretKeyID, previousKeyID, claimAttributes, staticClaims string
+	var previousSecret, entityID, acsURL, certificate sql.NullString
+	var previousExpiresAt sql.NullTime
+	var accessTTL int64
+
+	err := row.Scan(
+		&app.ID, &app.TenantID, &app.Name, &app.Secret, &secretKeyID, &previousSecret, &previousKeyID,
+		&previousExpiresAt, &app.Kind, &app.AccessMode, &domains, &app.Disabled,
+		&entityID, &acsURL, &certificate,
+		&accessTTL, &app.Tokens.Algorithm, &claimAttributes, &staticClaims,
+	)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	app.Tokens.AccessTTL = time.Duration(accessTTL) * time.Second
+	app.Tokens.ClaimAttributes = strings.Fields(claimAttributes)
+	if err := json.Unmarshal([]byte(staticClaims), &app.Tokens.StaticClaims); err != nil {
+		return models.App{}, fmt.Errorf("invalid static claims of app %d: %w", app.ID, err)
+	}
+
+	app.Secret, err = s.openSecret(app.Secret, secretKeyID)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	if previousSecret.Valid {
+		app.PreviousSecret, err = s.openSecret(previousSecret.String, previousKeyID)
+		if err != nil {
+			return models.App{}, err
+		}
+		app.PreviousSecretExpiresAt = previousExpiresAt.Time
+	}
+
+	// домены хранятся одной строкой через пробел
+	app.AllowedDomains = strings.Fields(domains)
+
+	app.SAML = models.SAMLServiceProvider{
+		EntityID:    entityID.String,
+		ACSURL:      acsURL.String,
+		Certificate: certificate.String,
+	}
+
 	return app, nil
 }
 
@@ -129,35 +422,224 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 func (s *Storage) Close() error {
 	const op = "storage.sqlite.Close"
 	//s.Log.With(slog.String("op", op)).Info("stopping gRPC server", slog.Int("port", a.port))
-	err := s.db.Close()
+	// подготовленные запросы закрываем до базы
+	err := errors.Join(s.stmts.close(), s.db.Close())
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-// IsAdmin
+// IsAdmin checks whether the user is admin directly or via membership in an admin group
+// (в том числе через вложенные группы).
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users WHERE id= ?")
+	row := stmt(ctx, s.stmts.isAdmin).QueryRowContext(ctx, userID, userID)
+
+	var IsAdmin bool
+
+	if err := row.Scan(&IsAdmin); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
 
-	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, userID)
+	return IsAdmin, nil
+}
+
+// userGroupsCTE все группы пользователя (параметр - ID пользователя): группы, где он состоит
+// напрямую, и все группы, в которые они вложены. UNION отбрасывает повторы,
+// поэтому каждая группа обходится один раз.
+const userGroupsCTE = `WITH RECURSIVE user_groups(id) AS (
+		SELECT group_id FROM group_members WHERE user_id = ?
+		UNION
+		SELECT gc.group_id FROM group_children gc JOIN user_groups ug ON gc.child_id = ug.id
+	)`
+
+// ExchangePolicy returns token exchange policy for given pair of apps.
+func (s *Storage) ExchangePolicy(ctx context.Context, sourceAppID int, targetAppID int) (models.ExchangePolicy, error) {
+	const op = "storage.sqlite.ExchangePolicy"
+
+	row := stmt(ctx, s.stmts.exchangePolicy).QueryRowContext(ctx, sourceAppID, targetAppID)
+
+	var policy models.ExchangePolicy
+	var scopes string
+
+	// Отсутствие политики означает, что обмен между этими приложениями запрещён
+	err := row.Scan(&policy.ID, &policy.SourceAppID, &policy.TargetAppID, &scopes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyNotFound)
+		}
+
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// scope'ы хранятся одной строкой через пробел (как в OAuth 2.0)
+	policy.Scopes = strings.Fields(scopes)
 
-	var IsAdmin bool
+	return policy, nil
+}
+
+// FederatedUser returns user of the tenant linked to the subject of external provider.
+func (s *Storage) FederatedUser(ctx context.Context, tenantID int64, provider string, subject string) (models.User, error) {
+	const op = "storage.sqlite.FederatedUser"
 
-	err = row.Scan(&IsAdmin)
+	row := stmt(ctx, s.stmts.federatedUser).QueryRowContext(ctx, tenantID, provider, subject)
+
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
 
-		return false, fmt.Errorf("%s: %w", op, err)
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return IsAdmin, nil
+	return user, nil
+}
+
+// SaveFederatedIdentity links the subject of external provider to the user of the tenant.
+func (s *Storage) SaveFederatedIdentity(ctx context.Context, tenantID int64, provider string, subject string, userID int64) error {
+	const op = "storage.sqlite.SaveFederatedIdentity"
+
+	_, err := stmt(ctx, s.stmts.saveFederatedIdentity).ExecContext(ctx, tenantID, provider, subject, userID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrIdentityExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateAdminStatus sets admin status of the user of the tenant on behalf of the actor
+// and records the change in the audit log.
+// Последнего администратора организации разжаловать нельзя: storage.ErrLastAdmin.
+func (s *Storage) UpdateAdminStatus(ctx context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error {
+	const op = "storage.sqlite.UpdateAdminStatus"
+
+	// Проверка "последнего администратора", изменение и запись в журнал - в одной транзакции,
+	// иначе два администратора могут одновременно разжаловать друг друга
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var wasAdmin bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT is_admin FROM users WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL", userID, tenantID,
+	).Scan(&wasAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Ничего не меняется - и в журнал писать нечего
+	if wasAdmin == isAdmin {
+		return nil
+	}
+
+	admins, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET is_admin = ? WHERE id = ?", isAdmin, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkAdminsLeft(ctx, tx, tenantID, admins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = insertAuditEvent(ctx, tx, models.AuditEvent{
+		ActorID:  actorID,
+		Action:   models.AuditActionSetAdmin,
+		TargetID: userID,
+		OldValue: strconv.FormatBool(wasAdmin),
+		NewValue: strconv.FormatBool(isAdmin),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// adminCount считает администраторов организации: назначенных напрямую и участников групп администраторов.
+// Группы и их участники всегда из одной организации, поэтому достаточно отбора по пользователям.
+func adminCount(ctx context.Context, tx querier, tenantID int64) (int, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `WITH RECURSIVE admin_groups(id) AS (
+			SELECT id FROM groups WHERE is_admin
+			UNION
+			SELECT gc.child_id FROM group_children gc JOIN admin_groups ag ON gc.group_id = ag.id
+		)
+		SELECT COUNT(*) FROM users
+		WHERE tenant_id = ? AND deleted_at IS NULL AND (is_admin OR id IN (
+			SELECT gm.user_id FROM group_members gm JOIN admin_groups ag ON ag.id = gm.group_id
+		))`, tenantID).Scan(&count)
+
+	return count, err
+}
+
+// checkAdminsLeft проверяет в транзакции изменения, что после него в организации остался хотя бы один администратор.
+// before - число администраторов до изменения: если их не было, то и проверять нечего.
+func checkAdminsLeft(ctx context.Context, tx querier, tenantID int64, before int) error {
+	if before == 0 {
+		return nil
+	}
+
+	after, err := adminCount(ctx, tx, tenantID)
+	if err != nil {
+		return err
+	}
+	if after == 0 {
+		return storage.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// userInTenant проверяет, что пользователь есть в каталоге организации.
+// Пользователи других организаций для неё не существуют: storage.ErrUserNotFound.
+func userInTenant(ctx context.Context, tx querier, tenantID int64, userID int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL)", userID, tenantID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// insertAuditEvent записывает событие в журнал аудита в рамках транзакции изменения
+func insertAuditEvent(ctx context.Context, tx querier, event models.AuditEvent) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO audit_log(actor_id, action, target_id, old_value, new_value) VALUES (?, ?, ?, ?, ?)",
+		event.ActorID, event.Action, event.TargetID, event.OldValue, event.NewValue,
+	)
+
+	return err
 }
@@ -0,0 +1,523 @@
+// internal/storage/sqlite/sqlite_test.go
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/lib/secrets"
+	"grpc-service-ref/internal/storage"
+	"grpc-service-ref/internal/storage/storagetest"
+)
+
+// Тесты хранилища на временной базе с настоящими миграциями из ./migrations.
+// Общее поведение проверяет storagetest, здесь - то, что специфично для SQLite.
+
+// tenant организация, в которой работают тесты (создаётся миграцией)
+const tenant = models.DefaultTenantID
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		return backend{newTestStorage(t)}
+	})
+}
+
+// С проверкой внешних ключей (storage.sqlite.foreign_keys) поведение должно быть тем же
+func TestStorage_ForeignKeys(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Backend {
+		return backend{newTestStorageWithOptions(t, Options{ForeignKeys: true})}
+	})
+}
+
+func TestNew_Options(t *testing.T) {
+	s := newTestStorageWithOptions(t, Options{
+		MaxOpenConns: 2,
+		BusyTimeout:  3 * time.Second,
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		ForeignKeys:  true,
+	})
+
+	// PRAGMA должны действовать в каждом соединении пула, а не только в первом
+	conn1, err := s.db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := s.db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	for _, conn := range []*sql.Conn{conn1, conn2} {
+		var journalMode string
+		var busyTimeout, synchronous int
+		var foreignKeys bool
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA journal_mode").Scan(&journalMode))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&busyTimeout))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA synchronous").Scan(&synchronous))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&foreignKeys))
+
+		assert.Equal(t, "wal", journalMode)
+		assert.Equal(t, 3000, busyTimeout)
+		assert.Equal(t, 1, synchronous) // NORMAL
+		assert.True(t, foreignKeys)
+	}
+	assert.Equal(t, 2, s.db.Stats().MaxOpenConnections)
+}
+
+func TestAppSecrets(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+
+	id, err := s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "first", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+	require.NoError(t, s.RotateAppSecret(ctx, tenant, admin, id, "second", time.Now().Add(time.Hour)))
+
+	// в базе секреты хранятся зашифрованными
+	var stored, keyID string
+	require.NoError(t, s.db.QueryRow("SELECT secret, secret_key_id FROM apps WHERE id = ?", id).Scan(&stored, &keyID))
+	assert.NotContains(t, stored, "second")
+	assert.Equal(t, "k1", keyID)
+
+	// секреты, записанные открытым текстом, читаются как есть и шифруются при запуске
+	_, err = s.db.Exec("INSERT INTO apps(id, name, secret) VALUES (100, 'legacy', 'plain')")
+	require.NoError(t, err)
+
+	encrypted, err := s.EncryptAppSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, encrypted)
+
+	app, err := s.App(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", app.Secret)
+
+	// после смены активного мастер-ключа секреты перешифровываются новым
+	s.keyring = testKeyring(t, "k2")
+	encrypted, err = s.EncryptAppSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, encrypted)
+
+	var stale int
+	require.NoError(t, s.db.QueryRow(
+		"SELECT COUNT(*) FROM apps WHERE secret_key_id != 'k2' OR (previous_secret IS NOT NULL AND previous_secret_key_id != 'k2')",
+	).Scan(&stale))
+	assert.Zero(t, stale)
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "second", app.Secret)
+	assert.Equal(t, "first", app.PreviousSecret)
+}
+
+// Регистрация пользователя, назначение роли и запись в журнал - одна транзакция
+func TestWithinTx_Commit(t *testing.T) {
+	s, admin, roleID := newTxTestStorage(t, Options{})
+	ctx := context.Background()
+
+	var userID int64
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.SaveUser(ctx, tenant, "new@example.com", []byte("hash"))
+		if err != nil {
+			return err
+		}
+
+		return s.AssignRole(ctx, tenant, admin, userID, roleID)
+	})
+	require.NoError(t, err)
+
+	user, err := s.User(ctx, tenant, "new@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+	assert.Len(t, backend{s}.AuditLog(t), 1)
+}
+
+func TestWithinTx_Rollback(t *testing.T) {
+	s, admin, roleID := newTxTestStorage(t, Options{})
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name    string
+		roleID  int64
+		fnErr   error
+		wantErr error
+	}{
+		{name: "fn error", roleID: roleID, fnErr: errAbort, wantErr: errAbort},
+		{name: "storage error", roleID: 999, wantErr: storage.ErrRoleNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.WithinTx(ctx, func(ctx context.Context) error {
+				userID, err := s.SaveUser(ctx, tenant, "new@example.com", []byte("hash"))
+				if err != nil {
+					return err
+				}
+				if err := s.AssignRole(ctx, tenant, admin, userID, tt.roleID); err != nil {
+					return err
+				}
+
+				return tt.fnErr
+			})
+			require.ErrorIs(t, err, tt.wantErr)
+
+			// ни пользователя, ни записи в журнале
+			_, err = s.User(ctx, tenant, "new@example.com")
+			assert.ErrorIs(t, err, storage.ErrUserNotFound)
+			assert.Empty(t, backend{s}.AuditLog(t))
+		})
+	}
+}
+
+// Ошибка метода или вложенного WithinTx откатывает только их изменения,
+// если fn решила её не возвращать
+func TestWithinTx_PartialRollback(t *testing.T) {
+	s, admin, _ := newTxTestStorage(t, Options{})
+	ctx := context.Background()
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		// UpdateAdminStatus успевает снять флаг до проверки последнего администратора
+		err := s.UpdateAdminStatus(ctx, tenant, admin, admin, false)
+		require.ErrorIs(t, err, storage.ErrLastAdmin)
+
+		err = s.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := s.SaveUser(ctx, tenant, "nested@example.com", []byte("hash")); err != nil {
+				return err
+			}
+
+			return errors.New("abort nested")
+		})
+		require.Error(t, err)
+
+		_, err = s.SaveUser(ctx, tenant, "new@example.com", []byte("hash"))
+		return err
+	})
+	require.NoError(t, err)
+
+	isAdmin, err := s.IsAdmin(ctx, admin)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	_, err = s.User(ctx, tenant, "new@example.com")
+	assert.NoError(t, err)
+	_, err = s.User(ctx, tenant, "nested@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func TestWithinTx_RetryBusy(t *testing.T) {
+	s, _, _ := newTxTestStorage(t, Options{BusyTimeout: time.Millisecond})
+	ctx := context.Background()
+
+	// другое соединение держит блокировку записи, пока не откатит свою транзакцию
+	lock, err := s.db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = lock.Exec("UPDATE users SET email = email")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_ = lock.Rollback()
+	}()
+
+	attempts := 0
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		attempts++
+		_, err := s.SaveUser(ctx, tenant, "new@example.com", []byte("hash"))
+		return err
+	})
+	require.NoError(t, err)
+	assert.Greater(t, attempts, 1)
+
+	_, err = s.User(ctx, tenant, "new@example.com")
+	assert.NoError(t, err)
+}
+
+// newTxTestStorage база с администратором и ролью приложения для тестов WithinTx
+func newTxTestStorage(t *testing.T, opts Options) (*Storage, int64, int64) {
+	t.Helper()
+
+	s := newTestStorageWithOptions(t, opts)
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	appID, err := s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "secret", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+	roleID, err := s.SaveRole(ctx, tenant, appID, "viewer", []string{"read"})
+	require.NoError(t, err)
+
+	// журнал нужен чистым: в нём только изменения самого теста
+	_, err = s.db.Exec("DELETE FROM audit_log")
+	require.NoError(t, err)
+
+	return s, admin, roleID
+}
+
+// Копия снимается, пока сервис пишет в базу, и восстанавливается в базу, открытую сервисом
+func TestBackupRestore_Online(t *testing.T) {
+	s := newTestStorageWithOptions(t, Options{JournalMode: "WAL", BusyTimeout: 5 * time.Second})
+	ctx := context.Background()
+	live := databasePath(t, s)
+	saveUser(t, s, "before@example.com")
+
+	stop := make(chan struct{})
+	written := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case <-stop:
+				written <- n
+				return
+			default:
+			}
+			_, err := s.SaveUser(ctx, tenant, fmt.Sprintf("user%d@example.com", n), []byte("hash"))
+			if err != nil {
+				t.Error(err)
+			}
+			n++
+		}
+	}()
+
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	err := Backup(ctx, live, backup)
+	close(stop)
+	total := <-written
+	require.NoError(t, err)
+
+	problems, err := IntegrityCheck(ctx, backup)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	// тестовые базы мигрируются без x-migrations-table, в таблицу migrate по умолчанию
+	version, dirty, err := SchemaVersion(ctx, backup, "schema_migrations")
+	require.NoError(t, err)
+	assert.Positive(t, version)
+	assert.False(t, dirty)
+
+	// в копии - согласованный срез: пользователь до копирования и часть записанных во время него
+	copied := countUsers(t, backup)
+	assert.GreaterOrEqual(t, copied, 1)
+	assert.LessOrEqual(t, copied, total+1)
+
+	// после восстановления открытое хранилище видит данные копии
+	saveUser(t, s, "after@example.com")
+	require.NoError(t, Backup(ctx, backup, live))
+
+	_, err = s.User(ctx, tenant, "after@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.User(ctx, tenant, "before@example.com")
+	assert.NoError(t, err)
+}
+
+func TestVacuumInto(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	live := databasePath(t, s)
+	saveUser(t, s, "user@example.com")
+
+	compacted := filepath.Join(t.TempDir(), "compacted.db")
+	require.NoError(t, VacuumInto(ctx, live, compacted))
+	assert.Equal(t, 1, countUsers(t, compacted))
+
+	// существующий файл не перезаписывается
+	assert.Error(t, VacuumInto(ctx, live, compacted))
+}
+
+func TestSchemaVersion_NotMigrated(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "empty.db")
+
+	_, _, err := SchemaVersion(ctx, path, "migrations")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE t (id INTEGER)")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	version, _, err := SchemaVersion(ctx, path, "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, -1, version)
+}
+
+// databasePath файл базы хранилища
+func databasePath(t *testing.T, s *Storage) string {
+	t.Helper()
+
+	var seq int
+	var name, path string
+	require.NoError(t, s.db.QueryRow("PRAGMA database_list").Scan(&seq, &name, &path))
+
+	return path
+}
+
+func countUsers(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n))
+
+	return n
+}
+
+func newTestStorage(t testing.TB) *Storage {
+	t.Helper()
+
+	return newTestStorageWithOptions(t, Options{})
+}
+
+func newTestStorageWithOptions(t testing.TB, opts Options) *Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+
+	m, err := migrate.New("file://../../../migrations", "sqlite3://"+path)
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	srcErr, dbErr := m.Close()
+	require.NoError(t, srcErr)
+	require.NoError(t, dbErr)
+
+	s, err := New(path, testKeyring(t, "k1"), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+// testKeyring набор из двух тестовых мастер-ключей k1 и k2
+func testKeyring(t testing.TB, active string) *secrets.Keyring {
+	t.Helper()
+
+	keyring, err := secrets.NewKeyring(map[string]string{
+		"k1": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+		"k2": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)),
+	}, active)
+	require.NoError(t, err)
+
+	return keyring
+}
+
+func saveUser(t testing.TB, s *Storage, email string) int64 {
+	t.Helper()
+
+	id, err := s.SaveUser(context.Background(), tenant, email, []byte("hash"))
+	require.NoError(t, err)
+
+	return id
+}
+
+// backend доступ общих тестов к служебным таблицам
+type backend struct {
+	*Storage
+}
+
+func (b backend) AddTenant(t *testing.T, id int64, name string) {
+	t.Helper()
+
+	_, err := b.db.Exec("INSERT INTO tenants(id, name) VALUES (?, ?)", id, name)
+	require.NoError(t, err)
+}
+
+func (b backend) AuditLog(t *testing.T) []models.AuditEvent {
+	t.Helper()
+
+	rows, err := b.db.Query("SELECT id, actor_id, action, target_id, old_value, new_value, created_at FROM audit_log ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var e models.AuditEvent
+		require.NoError(t, rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetID, &e.OldValue, &e.NewValue, &e.CreatedAt))
+		events = append(events, e)
+	}
+	require.NoError(t, rows.Err())
+
+	return events
+}
+
+// BenchmarkLogin запросы к хранилищу, которые делает вход пользователя
+// (без проверки пароля: bcrypt заслонил бы всё остальное)
+func BenchmarkLogin(b *testing.B) {
+	s, appID := newBenchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchLogin(b, ctx, s, appID)
+	}
+}
+
+func BenchmarkLoginParallel(b *testing.B) {
+	s, appID := newBenchStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			benchLogin(b, ctx, s, appID)
+		}
+	})
+}
+
+func benchLogin(b *testing.B, ctx context.Context, s *Storage, appID int) {
+	app, err := s.App(ctx, appID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	user, err := s.User(ctx, app.TenantID, "user@example.com")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := s.UserRoles(ctx, user.ID, app.ID); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := s.IsAdmin(ctx, user.ID); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// newBenchStorage база с приложением и пользователем, у которого есть роль в нём
+func newBenchStorage(b *testing.B) (*Storage, int) {
+	b.Helper()
+
+	// настройки по умолчанию из конфига (storage.sqlite)
+	s := newTestStorageWithOptions(b, Options{
+		MaxIdleConns: 4,
+		BusyTimeout:  5 * time.Second,
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+	})
+	ctx := context.Background()
+
+	userID := saveUser(b, s, "user@example.com")
+	appID, err := s.SaveApp(ctx, tenant, userID, models.App{Name: "bench", Secret: "secret", Kind: models.AppKindJWT})
+	require.NoError(b, err)
+	roleID, err := s.SaveRole(ctx, tenant, appID, "viewer", []string{"read"})
+	require.NoError(b, err)
+	require.NoError(b, s.AssignRole(ctx, tenant, userID, userID, roleID))
+
+	return s, appID
+}
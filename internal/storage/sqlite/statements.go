@@ -0,0 +1,101 @@
+// internal/storage/sqlite/statements.go
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// statements запросы, которые подготавливаются один раз в New и закрываются в Close.
+// Сюда попадают частые запросы вне транзакций: вход пользователя, проверка прав,
+// чтение пользователей, приложений и групп.
+type statements struct {
+	saveUser              *sql.Stmt
+	userByEmail           *sql.Stmt
+	userByID              *sql.Stmt
+	updateUser            *sql.Stmt
+	setPassHash           *sql.Stmt
+	app                   *sql.Stmt
+	samlApp               *sql.Stmt
+	isAdmin               *sql.Stmt
+	exchangePolicy        *sql.Stmt
+	federatedUser         *sql.Stmt
+	saveFederatedIdentity *sql.Stmt
+	saveGroup             *sql.Stmt
+	group                 *sql.Stmt
+	updateGroup           *sql.Stmt
+	userRoles             *sql.Stmt
+	hasPermission         *sql.Stmt
+
+	// все подготовленные запросы, для close
+	prepared []*sql.Stmt
+}
+
+// prepareStatements подготавливает все запросы statements.
+// Таблицы к этому моменту уже должны быть созданы миграциями.
+func prepareStatements(db *sql.DB) (*statements, error) {
+	s := &statements{}
+
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.saveUser, "INSERT INTO users(tenant_id, email, pass_hash) VALUES (?, ?, ?)"},
+		{&s.userByEmail, "SELECT " + userColumns + " FROM users WHERE tenant_id = ? AND email = ? AND deleted_at IS NULL"},
+		{&s.userByID, "SELECT " + userColumns + " FROM users WHERE id = ? AND deleted_at IS NULL"},
+		{&s.updateUser, "UPDATE users SET email = ?, external_id = ?, disabled = ? WHERE id = ? AND deleted_at IS NULL"},
+		{&s.setPassHash, "UPDATE users SET pass_hash = ? WHERE id = ? AND deleted_at IS NULL"},
+		{&s.app, "SELECT " + appColumns + " FROM apps WHERE id = ?"},
+		{&s.samlApp, "SELECT " + appColumns + " FROM apps WHERE kind = 'saml' AND saml_entity_id = ?"},
+		{&s.isAdmin, userGroupsCTE + `
+			SELECT u.is_admin OR EXISTS(SELECT 1 FROM groups g JOIN user_groups ug ON ug.id = g.id WHERE g.is_admin)
+			FROM users u WHERE u.id = ? AND u.deleted_at IS NULL`},
+		{&s.exchangePolicy, `SELECT id, source_app_id, target_app_id, scopes
+			FROM token_exchange_policies WHERE source_app_id = ? AND target_app_id = ?`},
+		{&s.federatedUser, `SELECT u.id, u.tenant_id, u.email, u.pass_hash, u.disabled, u.external_id
+			FROM federated_identities fi JOIN users u ON u.id = fi.user_id
+			WHERE fi.tenant_id = ? AND fi.provider = ? AND fi.subject = ? AND u.deleted_at IS NULL`},
+		{&s.saveFederatedIdentity, "INSERT INTO federated_identities(tenant_id, provider, subject, user_id) VALUES (?, ?, ?, ?)"},
+		{&s.saveGroup, "INSERT INTO groups(tenant_id, name, external_id) VALUES (?, ?, ?)"},
+		{&s.group, "SELECT " + groupColumns + " FROM groups WHERE id = ?"},
+		{&s.updateGroup, "UPDATE groups SET name = ?, external_id = ? WHERE id = ?"},
+		{&s.userRoles, userRolesCTE + `
+			SELECT r.name
+			FROM user_roles_all ur JOIN roles r ON r.id = ur.role_id
+			WHERE r.app_id = ?
+			ORDER BY r.name`},
+		{&s.hasPermission, userRolesCTE + `
+			SELECT EXISTS(
+				SELECT 1
+				FROM user_roles_all ur
+				JOIN roles r ON r.id = ur.role_id
+				JOIN role_permissions rp ON rp.role_id = r.id
+				WHERE r.app_id = ? AND rp.permission = ?
+			) AND NOT EXISTS(SELECT 1 FROM users WHERE id = ? AND (disabled OR deleted_at IS NOT NULL))`},
+	}
+
+	for _, q := range queries {
+		stmt, err := db.Prepare(q.query)
+		if err != nil {
+			// подготовленные до ошибки запросы не должны остаться открытыми
+			_ = s.close()
+			return nil, fmt.Errorf("prepare %q: %w", q.query, err)
+		}
+		*q.stmt = stmt
+		s.prepared = append(s.prepared, stmt)
+	}
+
+	return s, nil
+}
+
+// close закрывает подготовленные запросы
+func (s *statements) close() error {
+	var errs []error
+	for _, stmt := range s.prepared {
+		errs = append(errs, stmt.Close())
+	}
+
+	return errors.Join(errs...)
+}
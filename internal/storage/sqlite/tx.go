@@ -0,0 +1,177 @@
+// internal/storage/sqlite/tx.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Сколько раз WithinTx повторяет транзакцию, если база занята другим соединением (SQLITE_BUSY),
+// и пауза перед первым повтором (дальше она удваивается)
+const (
+	txAttempts   = 5
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// txKey ключ транзакции WithinTx в контексте
+type txKey struct{}
+
+// querier общие методы *sql.DB, *sql.Tx и txn: через него работают вспомогательные функции,
+// которым всё равно, в транзакции они выполняются или нет
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithinTx runs fn in a single transaction and commits it if fn returns nil.
+// Методы хранилища, вызванные внутри fn с переданным ей ctx, выполняются в этой же транзакции;
+// вложенный WithinTx тоже присоединяется к ней.
+// Если база занята другим соединением (SQLITE_BUSY), транзакция откатывается и fn
+// вызывается заново, поэтому fn не должна делать ничего, кроме работы с хранилищем.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.sqlite.WithinTx"
+
+	// Повторять можно только всю транзакцию целиком - это дело внешнего WithinTx
+	if txFromContext(ctx) != nil {
+		return s.runTx(ctx, fn)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if !isBusy(err) || attempt == txAttempts {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, errors.Join(err, ctx.Err()))
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runTx выполняет fn в транзакции (или точке сохранения внешней транзакции) и фиксирует её
+func (s *Storage) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := s.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx.Tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// txn транзакция метода хранилища.
+// Вне WithinTx это обычная транзакция. Внутри WithinTx метод работает в точке сохранения
+// внешней транзакции: при ошибке откатываются только его изменения, а фиксирует всё внешний WithinTx.
+type txn struct {
+	*sql.Tx
+	savepoint bool
+	done      bool
+}
+
+// savepointName имя точки сохранения метода. Имена могут повторяться:
+// RELEASE и ROLLBACK TO в SQLite относятся к последней точке с этим именем
+const savepointName = "storage_method"
+
+// beginTx начинает транзакцию метода. opts учитываются только для собственной транзакции
+func (s *Storage) beginTx(ctx context.Context, opts *sql.TxOptions) (*txn, error) {
+	if outer := txFromContext(ctx); outer != nil {
+		if _, err := outer.ExecContext(ctx, "SAVEPOINT "+savepointName); err != nil {
+			return nil, err
+		}
+
+		return &txn{Tx: outer, savepoint: true}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &txn{Tx: tx}, nil
+}
+
+// Commit фиксирует транзакцию или отпускает точку сохранения
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	_, err := t.Tx.Exec("RELEASE " + savepointName)
+
+	return err
+}
+
+// Rollback откатывает транзакцию или изменения с начала точки сохранения.
+// Как и у sql.Tx, после Commit ничего не делает, поэтому его можно откладывать через defer.
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	// ROLLBACK TO не закрывает точку сохранения, её нужно отпустить отдельно
+	if _, err := t.Tx.Exec("ROLLBACK TO " + savepointName); err != nil {
+		return err
+	}
+	_, err := t.Tx.Exec("RELEASE " + savepointName)
+
+	return err
+}
+
+// conn возвращает транзакцию WithinTx из ctx, а вне её - саму базу
+func (s *Storage) conn(ctx context.Context) querier {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+
+	return s.db
+}
+
+// stmt привязывает подготовленный запрос к транзакции WithinTx из ctx, если она есть
+func stmt(ctx context.Context, st *sql.Stmt) *sql.Stmt {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.StmtContext(ctx, st)
+	}
+
+	return st
+}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// isBusy база заблокирована другим соединением и не освободилась за busy_timeout
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
@@ -0,0 +1,178 @@
+// internal/storage/sqlite/userdata.go
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// UserData returns everything stored about the user: the users row and the records keyed by its ID.
+// Пользователь, помеченный удалённым, тоже выгружается: его данные ещё хранятся.
+func (s *Storage) UserData(ctx context.Context, userID int64) (models.UserData, error) {
+	const op = "storage.sqlite.UserData"
+
+	// одна транзакция, чтобы выгрузка была согласованной
+	tx, err := s.beginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var data models.UserData
+	var externalID sql.NullString
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT id, tenant_id, email, pass_hash, disabled, external_id, is_admin, deleted_at FROM users WHERE id = ?",
+		userID,
+	).Scan(
+		&data.User.ID, &data.User.TenantID, &data.User.Email, &data.User.PassHash, &data.User.Disabled, &externalID,
+		&data.IsAdmin, &deletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserData{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+	data.User.ExternalID = externalID.String
+	data.DeletedAt = deletedAt.Time
+
+	if data.Attributes, err = userAttributes(ctx, tx, userID); err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Identities, err = queryAll(ctx, tx, func(row scanner) (models.FederatedIdentity, error) {
+		var identity models.FederatedIdentity
+		err := row.Scan(&identity.Provider, &identity.Subject)
+		return identity, err
+	}, "SELECT provider, subject FROM federated_identities WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Groups, err = queryAll(ctx, tx, scanGroup,
+		"SELECT "+groupColumns+" FROM groups WHERE id IN (SELECT group_id FROM group_members WHERE user_id = ?) ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Roles, err = queryAll(ctx, tx, func(row scanner) (models.Role, error) {
+		var role models.Role
+		err := row.Scan(&role.ID, &role.AppID, &role.Name)
+		return role, err
+	}, `SELECT r.id, r.app_id, r.name FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = ? ORDER BY r.id`, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Apps, err = queryAll(ctx, tx, func(row scanner) (int, error) {
+		var appID int
+		err := row.Scan(&appID)
+		return appID, err
+	}, "SELECT app_id FROM app_members WHERE user_id = ? ORDER BY app_id", userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Invitations, err = queryAll(ctx, tx, scanInvitation,
+		"SELECT "+invitationColumns+` FROM invitations
+		WHERE accepted_by = ? OR created_by = ? OR (tenant_id = ? AND email = ?) ORDER BY id`,
+		userID, userID, data.User.TenantID, data.User.Email,
+	)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Policies, err = queryAll(ctx, tx, func(row scanner) (models.Policy, error) {
+		var p models.Policy
+		err := row.Scan(&p.AppID, &p.Version, &p.Document, &p.CreatedBy, &p.CreatedAt)
+		return p, err
+	}, `SELECT app_id, version, document, created_by, created_at FROM policies
+		WHERE created_by = ? ORDER BY app_id, version`, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if data.Audit, err = userAuditEvents(ctx, tx, data.User); err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// userAuditEvents записи журнала, где пользователь - автор действия или его объект:
+// по target_id, по ID в old_value/new_value (участники групп и приложений)
+// или по приглашению на его email
+func userAuditEvents(ctx context.Context, tx querier, user models.User) ([]models.AuditEvent, error) {
+	id := strconv.FormatInt(user.ID, 10)
+
+	args := []any{user.ID, user.ID}
+	for _, action := range models.AuditUserTargetActions {
+		args = append(args, action)
+	}
+	for _, action := range models.AuditUserValueActions {
+		args = append(args, action)
+	}
+	args = append(args, id, id)
+	for _, action := range models.AuditInvitationActions {
+		args = append(args, action)
+	}
+	args = append(args, user.TenantID, user.Email, user.ID)
+
+	return queryAll(ctx, tx, func(row scanner) (models.AuditEvent, error) {
+		var e models.AuditEvent
+		err := row.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetID, &e.OldValue, &e.NewValue, &e.CreatedAt)
+		return e, err
+	}, `SELECT id, actor_id, action, target_id, old_value, new_value, created_at FROM audit_log
+		WHERE actor_id = ?
+		OR (target_id = ? AND action IN (`+placeholders(len(models.AuditUserTargetActions))+`))
+		OR (action IN (`+placeholders(len(models.AuditUserValueActions))+`) AND (old_value = ? OR new_value = ?))
+		OR (action IN (`+placeholders(len(models.AuditInvitationActions))+`) AND target_id IN (
+			SELECT id FROM invitations WHERE tenant_id = ? AND (email = ? OR accepted_by = ?)
+		))
+		ORDER BY id`, args...)
+}
+
+// queryAll читает все строки результата запроса функцией scan
+func queryAll[T any](
+	ctx context.Context,
+	tx querier,
+	scan func(row scanner) (T, error),
+	query string,
+	args ...any,
+) ([]T, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// placeholders список из n плейсхолдеров для IN (...)
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
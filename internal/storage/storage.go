@@ -2,7 +2,13 @@
 
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grpc-service-ref/internal/domain/models"
+)
 
 //В основном пакете storage я храню лишь общие описания типов, ошибок и т.п.
 //В моём текущем случае — это только ошибки:
@@ -10,6 +16,24 @@ var (
 	ErrUserExists   = errors.New("user already exist")
 	ErrUserNotFound = errors.New("user not found")
 	ErrAppNotFound  = errors.New("app not found")
+	ErrAppExists    = errors.New("app already exist")
+	ErrAppDisabled  = errors.New("app is disabled")
+
+	ErrExchangePolicyNotFound = errors.New("token exchange policy not found")
+	ErrIdentityExists         = errors.New("federated identity already linked")
+
+	ErrGroupExists   = errors.New("group already exist")
+	ErrGroupNotFound = errors.New("group not found")
+	ErrGroupCycle    = errors.New("group nesting cycle")
+
+	ErrLastAdmin = errors.New("can't demote the last admin")
+
+	ErrRoleExists   = errors.New("role already exist")
+	ErrRoleNotFound = errors.New("role not found")
+
+	ErrInvitationNotFound = errors.New("invitation not found")
+
+	ErrPolicyNotFound = errors.New("policy not found")
 )
 
 // По этим ошибкам сервисный слой сможет понять, что конкретно пошло не так,
@@ -17,3 +41,108 @@ var (
 // Они не должны зависеть от конкретной реализации хранилища
 // (будь то SQLite, Postgres, MongoDB и т.п.),
 // поэтому мы их разместили в общем пакете.
+
+// UserFilter условия отбора пользователей. Пустое поле - без условия,
+// кроме TenantID: выборка всегда ограничена одной организацией.
+type UserFilter struct {
+	TenantID   int64
+	Email      string
+	ExternalID string
+}
+
+// GroupFilter условия отбора групп. Пустое поле - без условия,
+// кроме TenantID: выборка всегда ограничена одной организацией.
+type GroupFilter struct {
+	TenantID   int64
+	Name       string
+	ExternalID string
+}
+
+// Storage полный набор методов хранилища, его реализуют sqlite.Storage, postgres.Storage и memory.Storage.
+// Сервисы по-прежнему объявляют у себя только нужные им методы; этот интерфейс нужен,
+// чтобы выбирать реализацию хранилища при запуске (storage.driver в конфиге)
+// и проверять все реализации одними тестами (storagetest).
+type Storage interface {
+	// Пользователи
+	SaveUser(ctx context.Context, tenantID int64, email string, passHash []byte) (int64, error)
+	User(ctx context.Context, tenantID int64, email string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	Users(ctx context.Context, filter UserFilter, offset int, limit int) ([]models.User, int, error)
+	UpdateUser(ctx context.Context, user models.User) error
+	SetPassHash(ctx context.Context, userID int64, passHash []byte) error
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	UpdateAdminStatus(ctx context.Context, tenantID int64, actorID int64, userID int64, isAdmin bool) error
+	SetUserAttributes(ctx context.Context, tenantID int64, actorID int64, userID int64, attributes map[string]string) error
+	UserAttributes(ctx context.Context, userID int64) (map[string]string, error)
+	DeleteUser(ctx context.Context, tenantID int64, actorID int64, userID int64) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error)
+	UserData(ctx context.Context, userID int64) (models.UserData, error)
+
+	// Федерация
+	FederatedUser(ctx context.Context, tenantID int64, provider string, subject string) (models.User, error)
+	SaveFederatedIdentity(ctx context.Context, tenantID int64, provider string, subject string, userID int64) error
+
+	// Приложения
+	App(ctx context.Context, id int) (models.App, error)
+	SAMLApp(ctx context.Context, entityID string) (models.App, error)
+	Apps(ctx context.Context, tenantID int64, offset int, limit int) ([]models.App, int, error)
+	SaveApp(ctx context.Context, tenantID int64, actorID int64, app models.App) (int, error)
+	UpdateApp(ctx context.Context, tenantID int64, actorID int64, app models.App) error
+	SetAppDisabled(ctx context.Context, tenantID int64, actorID int64, appID int, disabled bool) error
+	SetAppTokenSettings(ctx context.Context, tenantID int64, actorID int64, appID int, settings models.TokenSettings) error
+	DeleteApp(ctx context.Context, tenantID int64, actorID int64, appID int) error
+	RotateAppSecret(ctx context.Context, tenantID int64, actorID int64, appID int, secret string, previousExpiresAt time.Time) error
+	EncryptAppSecrets(ctx context.Context) (int, error)
+	ExchangePolicy(ctx context.Context, sourceAppID int, targetAppID int) (models.ExchangePolicy, error)
+
+	// Доступ к приложениям
+	SetAppAccess(ctx context.Context, tenantID int64, actorID int64, appID int, mode string, domains []string) error
+	IsAppMember(ctx context.Context, appID int, userID int64) (bool, error)
+	AppMembers(ctx context.Context, tenantID int64, appID int) ([]int64, error)
+	AddAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error
+	RemoveAppMember(ctx context.Context, tenantID int64, actorID int64, appID int, userID int64) error
+
+	// Группы
+	SaveGroup(ctx context.Context, tenantID int64, name string, externalID string) (int64, error)
+	Group(ctx context.Context, id int64) (models.Group, error)
+	Groups(ctx context.Context, filter GroupFilter, offset int, limit int) ([]models.Group, int, error)
+	UpdateGroup(ctx context.Context, group models.Group) error
+	SetGroupMembers(ctx context.Context, tenantID int64, groupID int64, userIDs []int64) error
+	DeleteGroup(ctx context.Context, tenantID int64, id int64) error
+	AddGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error
+	RemoveGroupMember(ctx context.Context, tenantID int64, actorID int64, groupID int64, userID int64) error
+	AddSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error
+	RemoveSubgroup(ctx context.Context, tenantID int64, actorID int64, groupID int64, childID int64) error
+	SetGroupAdmin(ctx context.Context, tenantID int64, actorID int64, groupID int64, isAdmin bool) error
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+
+	// Роли и права
+	SaveRole(ctx context.Context, tenantID int64, appID int, name string, permissions []string) (int64, error)
+	Role(ctx context.Context, id int64) (models.Role, error)
+	Roles(ctx context.Context, tenantID int64, appID int) ([]models.Role, error)
+	SetRolePermissions(ctx context.Context, tenantID int64, roleID int64, permissions []string) error
+	DeleteRole(ctx context.Context, tenantID int64, id int64) error
+	AssignRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+	RevokeRole(ctx context.Context, tenantID int64, actorID int64, userID int64, roleID int64) error
+	AssignGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error
+	RevokeGroupRole(ctx context.Context, tenantID int64, actorID int64, groupID int64, roleID int64) error
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+	HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error)
+
+	// Приглашения
+	SaveInvitation(ctx context.Context, inv models.Invitation, tokenHash string) (int64, error)
+	Invitations(ctx context.Context, tenantID int64, appID int) ([]models.Invitation, error)
+	InvitationByToken(ctx context.Context, tokenHash string) (models.Invitation, error)
+	RevokeInvitation(ctx context.Context, tenantID int64, actorID int64, id int64) error
+	AcceptInvitation(ctx context.Context, id int64, userID int64) error
+
+	// Политики доступа
+	SavePolicy(ctx context.Context, tenantID int64, actorID int64, appID int, document string) (int, error)
+	Policy(ctx context.Context, appID int, version int) (models.Policy, error)
+
+	// Транзакции: методы, вызванные внутри fn с переданным ей ctx, выполняются в одной транзакции,
+	// которая фиксируется, только если fn вернула nil
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	Close() error
+}
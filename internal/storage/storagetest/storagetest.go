@@ -0,0 +1,866 @@
+// internal/storage/storagetest/storagetest.go
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"grpc-service-ref/internal/domain/models"
+	"grpc-service-ref/internal/storage"
+)
+
+// Общие тесты хранилища: каждая реализация storage.Storage прогоняет их
+// на чистом хранилище (SQL-реализации - на новой базе со своими миграциями)
+// и должна вести себя одинаково.
+
+// tenant организация, в которой работают тесты (создаётся миграцией)
+const tenant = models.DefaultTenantID
+
+// missingApp ID приложения, которого нет ни в одной организации
+const missingApp = 1000
+
+// Backend хранилище под тестом и доступ к тому, чего нет в storage.Storage
+type Backend interface {
+	storage.Storage
+
+	// AddTenant создаёт организацию
+	AddTenant(t *testing.T, id int64, name string)
+	// AuditLog возвращает журнал аудита в порядке записи
+	AuditLog(t *testing.T) []models.AuditEvent
+}
+
+// Run прогоняет общие тесты; newBackend вызывается для каждого теста
+// и должен вернуть хранилище на пустой мигрированной базе.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Backend)
+	}{
+		{"UpdateAdminStatus", testUpdateAdminStatus},
+		{"SetPassHash", testSetPassHash},
+		{"Roles", testRoles},
+		{"NestedGroups", testNestedGroups},
+		{"AppAccess", testAppAccess},
+		{"Invitations", testInvitations},
+		{"Policies", testPolicies},
+		{"AppManagement", testAppManagement},
+		{"AppSecrets", testAppSecrets},
+		{"AppTokenSettings", testAppTokenSettings},
+		{"Tenants", testTenants},
+		{"DeleteUser", testDeleteUser},
+		{"UserData", testUserData},
+		{"WithinTx", testWithinTx},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+func testUpdateAdminStatus(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	first := saveUser(t, s, "first@example.com")
+	second := saveUser(t, s, "second@example.com")
+	// первого администратора назначает сам сервис, это тоже записывается в журнал
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, first, true))
+
+	// назначение администратора записывается в журнал
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, first, second, true))
+
+	isAdmin, err := s.IsAdmin(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	events := s.AuditLog(t)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.SystemActorID, events[0].ActorID)
+	assert.Equal(t, first, events[0].TargetID)
+	assert.Equal(t, first, events[1].ActorID)
+	assert.Equal(t, models.AuditActionSetAdmin, events[1].Action)
+	assert.Equal(t, second, events[1].TargetID)
+	assert.Equal(t, "false", events[1].OldValue)
+	assert.Equal(t, "true", events[1].NewValue)
+
+	// повторная установка того же статуса ничего не меняет и не пишется в журнал
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, first, second, true))
+	assert.Len(t, s.AuditLog(t), 2)
+
+	// разжаловать можно, пока остаётся другой администратор
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, second, first, false))
+
+	// а последнего - нельзя
+	err = s.UpdateAdminStatus(ctx, tenant, second, second, false)
+	require.ErrorIs(t, err, storage.ErrLastAdmin)
+
+	isAdmin, err = s.IsAdmin(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	assert.Len(t, s.AuditLog(t), 3)
+
+	err = s.UpdateAdminStatus(ctx, tenant, second, 1000, true)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testSetPassHash(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	id := saveUser(t, s, "user@example.com")
+	require.NoError(t, s.SetPassHash(ctx, id, []byte("new-hash")))
+
+	user, err := s.User(ctx, tenant, "user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new-hash"), user.PassHash)
+
+	require.ErrorIs(t, s.SetPassHash(ctx, 1000, []byte("hash")), storage.ErrUserNotFound)
+}
+
+func testRoles(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	app := saveApp(t, s, tenant, admin, "app")
+	audit := len(s.AuditLog(t))
+
+	_, err := s.SaveRole(ctx, tenant, missingApp, "editor", nil)
+	require.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	roleID, err := s.SaveRole(ctx, tenant, app, "editor", []string{"reports:write", "reports:read", "reports:read"})
+	require.NoError(t, err)
+
+	_, err = s.SaveRole(ctx, tenant, app, "editor", nil)
+	require.ErrorIs(t, err, storage.ErrRoleExists)
+
+	role, err := s.Role(ctx, roleID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports:read", "reports:write"}, role.Permissions)
+
+	// назначение пишется в журнал один раз, повтор ничего не меняет
+	require.NoError(t, s.AssignRole(ctx, tenant, admin, user, roleID))
+	require.NoError(t, s.AssignRole(ctx, tenant, admin, user, roleID))
+	assert.Len(t, s.AuditLog(t), audit+1)
+
+	roles, err := s.UserRoles(ctx, user, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, roles)
+
+	allowed, err := s.HasPermission(ctx, user, app, "reports:write")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// у отключённого пользователя прав нет
+	require.NoError(t, s.UpdateUser(ctx, models.User{ID: user, Email: "user@example.com", Disabled: true}))
+	allowed, err = s.HasPermission(ctx, user, app, "reports:write")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// удаление роли снимает её со всех пользователей
+	require.NoError(t, s.DeleteRole(ctx, tenant, roleID))
+	roles, err = s.UserRoles(ctx, user, app)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	require.ErrorIs(t, s.AssignRole(ctx, tenant, admin, user, roleID), storage.ErrRoleNotFound)
+}
+
+func testNestedGroups(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	app := saveApp(t, s, tenant, admin, "app")
+
+	// company <- engineering <- backend, пользователь состоит только в backend
+	company, err := s.SaveGroup(ctx, tenant, "company", "")
+	require.NoError(t, err)
+	engineering, err := s.SaveGroup(ctx, tenant, "engineering", "")
+	require.NoError(t, err)
+	backend, err := s.SaveGroup(ctx, tenant, "backend", "")
+	require.NoError(t, err)
+
+	require.NoError(t, s.AddSubgroup(ctx, tenant, admin, company, engineering))
+	require.NoError(t, s.AddSubgroup(ctx, tenant, admin, engineering, backend))
+	require.NoError(t, s.AddGroupMember(ctx, tenant, admin, backend, user))
+
+	// циклы запрещены, в том числе через несколько уровней
+	require.ErrorIs(t, s.AddSubgroup(ctx, tenant, admin, backend, company), storage.ErrGroupCycle)
+	require.ErrorIs(t, s.AddSubgroup(ctx, tenant, admin, backend, backend), storage.ErrGroupCycle)
+
+	groups, err := s.UserGroups(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	// роль, выданная верхней группе, действует для участников вложенных
+	roleID, err := s.SaveRole(ctx, tenant, app, "employee", []string{"wiki:read"})
+	require.NoError(t, err)
+	require.NoError(t, s.AssignGroupRole(ctx, tenant, admin, company, roleID))
+
+	roles, err := s.UserRoles(ctx, user, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"employee"}, roles)
+
+	allowed, err := s.HasPermission(ctx, user, app, "wiki:read")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// администратор через группу
+	require.NoError(t, s.SetGroupAdmin(ctx, tenant, admin, engineering, true))
+	isAdmin, err := s.IsAdmin(ctx, user)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	// он единственный администратор: исключить его из группы нельзя
+	require.ErrorIs(t, s.RemoveSubgroup(ctx, tenant, admin, engineering, backend), storage.ErrLastAdmin)
+	require.ErrorIs(t, s.SetGroupAdmin(ctx, tenant, admin, engineering, false), storage.ErrLastAdmin)
+	require.ErrorIs(t, s.SetGroupMembers(ctx, tenant, backend, nil), storage.ErrLastAdmin)
+
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+	require.NoError(t, s.RemoveSubgroup(ctx, tenant, admin, engineering, backend))
+
+	isAdmin, err = s.IsAdmin(ctx, user)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+
+	roles, err = s.UserRoles(ctx, user, app)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func testAppAccess(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	appID := saveApp(t, s, tenant, admin, "app")
+	audit := len(s.AuditLog(t))
+
+	// по умолчанию приложение открыто для всех пользователей организации
+	app, err := s.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AppAccessOpen, app.AccessMode)
+	assert.Empty(t, app.AllowedDomains)
+
+	require.NoError(t, s.SetAppAccess(ctx, tenant, admin, appID, models.AppAccessDomain, []string{"example.com", "example.org"}))
+	app, err = s.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AppAccessDomain, app.AccessMode)
+	assert.Equal(t, []string{"example.com", "example.org"}, app.AllowedDomains)
+	require.ErrorIs(t, s.SetAppAccess(ctx, tenant, admin, missingApp, models.AppAccessOpen, nil), storage.ErrAppNotFound)
+
+	// повторное добавление не пишется в журнал
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, appID, user))
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, appID, user))
+	require.ErrorIs(t, s.AddAppMember(ctx, tenant, admin, appID, 100), storage.ErrUserNotFound)
+	require.ErrorIs(t, s.AddAppMember(ctx, tenant, admin, missingApp, user), storage.ErrAppNotFound)
+	assert.Len(t, s.AuditLog(t), audit+2)
+
+	isMember, err := s.IsAppMember(ctx, appID, user)
+	require.NoError(t, err)
+	assert.True(t, isMember)
+
+	members, err := s.AppMembers(ctx, tenant, appID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{user}, members)
+
+	require.NoError(t, s.RemoveAppMember(ctx, tenant, admin, appID, user))
+	isMember, err = s.IsAppMember(ctx, appID, user)
+	require.NoError(t, err)
+	assert.False(t, isMember)
+	assert.Len(t, s.AuditLog(t), audit+3)
+}
+
+func testInvitations(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	app := saveApp(t, s, tenant, admin, "app")
+	other := saveApp(t, s, tenant, admin, "other")
+	role, err := s.SaveRole(ctx, tenant, app, "editor", nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	inv := models.Invitation{
+		TenantID:  tenant,
+		AppID:     app,
+		Email:     "new@example.com",
+		RoleID:    role,
+		CreatedBy: admin,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	// роль должна быть из приложения приглашения
+	_, err = s.SaveInvitation(ctx, models.Invitation{TenantID: tenant, AppID: other, RoleID: role, ExpiresAt: inv.ExpiresAt}, "other-hash")
+	require.ErrorIs(t, err, storage.ErrRoleNotFound)
+
+	id, err := s.SaveInvitation(ctx, inv, "hash")
+	require.NoError(t, err)
+
+	saved, err := s.InvitationByToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, id, saved.ID)
+	assert.Equal(t, role, saved.RoleID)
+	assert.Equal(t, models.InvitationPending, saved.Status(now))
+
+	// принятие делает пользователя участником приложения и выдаёт роль
+	user := saveUser(t, s, "new@example.com")
+	require.NoError(t, s.AcceptInvitation(ctx, id, user))
+	require.ErrorIs(t, s.AcceptInvitation(ctx, id, user), storage.ErrInvitationNotFound)
+	require.ErrorIs(t, s.RevokeInvitation(ctx, tenant, admin, id), storage.ErrInvitationNotFound)
+
+	isMember, err := s.IsAppMember(ctx, app, user)
+	require.NoError(t, err)
+	assert.True(t, isMember)
+
+	roles, err := s.UserRoles(ctx, user, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, roles)
+
+	// отозванное и просроченное приглашения принять нельзя
+	revoked, err := s.SaveInvitation(ctx, models.Invitation{TenantID: tenant, AppID: app, ExpiresAt: inv.ExpiresAt}, "revoked-hash")
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeInvitation(ctx, tenant, admin, revoked))
+	require.ErrorIs(t, s.AcceptInvitation(ctx, revoked, user), storage.ErrInvitationNotFound)
+
+	expired, err := s.SaveInvitation(ctx, models.Invitation{TenantID: tenant, AppID: app, ExpiresAt: now.Add(-time.Minute)}, "expired-hash")
+	require.NoError(t, err)
+	require.ErrorIs(t, s.AcceptInvitation(ctx, expired, user), storage.ErrInvitationNotFound)
+
+	invitations, err := s.Invitations(ctx, tenant, app)
+	require.NoError(t, err)
+	require.Len(t, invitations, 3)
+	assert.Equal(t, models.InvitationExpired, invitations[0].Status(now))
+	assert.Equal(t, models.InvitationRevoked, invitations[1].Status(now))
+	assert.Equal(t, models.InvitationAccepted, invitations[2].Status(now))
+	assert.Equal(t, user, invitations[2].AcceptedBy)
+
+	// удалённая роль пропадает из приглашений, как при ON DELETE SET NULL
+	pending, err := s.SaveInvitation(ctx, models.Invitation{TenantID: tenant, AppID: app, RoleID: role, ExpiresAt: inv.ExpiresAt}, "pending-hash")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteRole(ctx, tenant, role))
+
+	saved, err = s.InvitationByToken(ctx, "pending-hash")
+	require.NoError(t, err)
+	assert.Equal(t, pending, saved.ID)
+	assert.Zero(t, saved.RoleID)
+}
+
+func testPolicies(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	app := saveApp(t, s, tenant, admin, "app")
+	audit := len(s.AuditLog(t))
+
+	_, err := s.Policy(ctx, app, 0)
+	require.ErrorIs(t, err, storage.ErrPolicyNotFound)
+
+	// каждое сохранение - новая версия, действует последняя
+	v1, err := s.SavePolicy(ctx, tenant, admin, app, `{"rules": []}`)
+	require.NoError(t, err)
+	v2, err := s.SavePolicy(ctx, tenant, admin, app, `{"rules": [{"id": "r"}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v1)
+	assert.Equal(t, 2, v2)
+
+	_, err = s.SavePolicy(ctx, tenant, admin, missingApp, `{"rules": []}`)
+	require.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	latest, err := s.Policy(ctx, app, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.Version)
+	assert.Equal(t, `{"rules": [{"id": "r"}]}`, latest.Document)
+	assert.Equal(t, admin, latest.CreatedBy)
+
+	first, err := s.Policy(ctx, app, 1)
+	require.NoError(t, err)
+	assert.Equal(t, `{"rules": []}`, first.Document)
+
+	_, err = s.Policy(ctx, app, 3)
+	require.ErrorIs(t, err, storage.ErrPolicyNotFound)
+
+	// атрибуты заменяются целиком; повторная установка тех же значений не пишется в журнал
+	require.NoError(t, s.SetUserAttributes(ctx, tenant, admin, user, map[string]string{"department": "sales", "level": "3"}))
+	require.NoError(t, s.SetUserAttributes(ctx, tenant, admin, user, map[string]string{"department": "sales", "level": "3"}))
+	require.NoError(t, s.SetUserAttributes(ctx, tenant, admin, user, map[string]string{"department": "hr"}))
+	require.ErrorIs(t, s.SetUserAttributes(ctx, tenant, admin, 100, map[string]string{"a": "b"}), storage.ErrUserNotFound)
+	assert.Len(t, s.AuditLog(t), audit+4)
+
+	attributes, err := s.UserAttributes(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"department": "hr"}, attributes)
+}
+
+func testAppManagement(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	const other int64 = 2
+	s.AddTenant(t, other, "other")
+
+	admin := saveUser(t, s, "admin@example.com")
+
+	id, err := s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "secret", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+
+	// имя уникально в пределах организации
+	_, err = s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "secret-2", Kind: models.AppKindJWT})
+	require.ErrorIs(t, err, storage.ErrAppExists)
+	_, err = s.SaveApp(ctx, other, admin, models.App{Name: "crm", Secret: "secret-3", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+
+	app := models.App{
+		ID:   id,
+		Name: "crm-sso",
+		Kind: models.AppKindSAML,
+		SAML: models.SAMLServiceProvider{EntityID: "https://crm.example.com", ACSURL: "https://crm.example.com/acs"},
+	}
+	require.NoError(t, s.UpdateApp(ctx, tenant, admin, app))
+	require.ErrorIs(t, s.UpdateApp(ctx, other, admin, app), storage.ErrAppNotFound)
+
+	saved, err := s.SAMLApp(ctx, "https://crm.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "crm-sso", saved.Name)
+	assert.Equal(t, "secret", saved.Secret)
+
+	apps, total, err := s.Apps(ctx, tenant, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, id, apps[0].ID)
+
+	// отключённое приложение видно в списке, но не выдаётся для входа
+	require.NoError(t, s.SetAppDisabled(ctx, tenant, admin, id, true))
+	require.NoError(t, s.SetAppDisabled(ctx, tenant, admin, id, true))
+	_, err = s.App(ctx, id)
+	require.ErrorIs(t, err, storage.ErrAppDisabled)
+	_, err = s.SAMLApp(ctx, "https://crm.example.com")
+	require.ErrorIs(t, err, storage.ErrAppDisabled)
+
+	apps, _, err = s.Apps(ctx, tenant, 0, 10)
+	require.NoError(t, err)
+	assert.True(t, apps[0].Disabled)
+
+	require.NoError(t, s.SetAppDisabled(ctx, tenant, admin, id, false))
+	_, err = s.App(ctx, id)
+	require.NoError(t, err)
+
+	// удаление убирает и зависимые записи
+	role, err := s.SaveRole(ctx, tenant, id, "viewer", []string{"crm:read"})
+	require.NoError(t, err)
+	require.NoError(t, s.AssignRole(ctx, tenant, admin, admin, role))
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, id, admin))
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		TenantID: tenant, AppID: id, RoleID: role, CreatedBy: admin, ExpiresAt: time.Now().Add(time.Hour),
+	}, "crm-hash")
+	require.NoError(t, err)
+
+	require.ErrorIs(t, s.DeleteApp(ctx, other, admin, id), storage.ErrAppNotFound)
+	require.NoError(t, s.DeleteApp(ctx, tenant, admin, id))
+	_, err = s.App(ctx, id)
+	require.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	_, err = s.Role(ctx, role)
+	require.ErrorIs(t, err, storage.ErrRoleNotFound)
+	roles, err := s.UserRoles(ctx, admin, id)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+	isMember, err := s.IsAppMember(ctx, id, admin)
+	require.NoError(t, err)
+	assert.False(t, isMember)
+	_, err = s.InvitationByToken(ctx, "crm-hash")
+	require.ErrorIs(t, err, storage.ErrInvitationNotFound)
+}
+
+func testAppSecrets(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+
+	id, err := s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "first", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+
+	app, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "first", app.Secret)
+	assert.Empty(t, app.PreviousSecret)
+
+	// после ротации прежний секрет действует до конца периода ротации
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, s.RotateAppSecret(ctx, tenant, admin, id, "second", expiresAt))
+	require.ErrorIs(t, s.RotateAppSecret(ctx, 2, admin, id, "third", expiresAt), storage.ErrAppNotFound)
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "second", app.Secret)
+	assert.Equal(t, "first", app.PreviousSecret)
+	assert.True(t, expiresAt.Equal(app.PreviousSecretExpiresAt))
+	assert.Equal(t, []string{"second", "first"}, app.VerificationSecrets(time.Now()))
+	assert.Equal(t, []string{"second"}, app.VerificationSecrets(expiresAt))
+
+	events := s.AuditLog(t)
+	assert.Equal(t, models.AuditActionRotateAppSecret, events[len(events)-1].Action)
+}
+
+func testAppTokenSettings(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+
+	id, err := s.SaveApp(ctx, tenant, admin, models.App{Name: "crm", Secret: "secret", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+
+	// по умолчанию - HS256 и время жизни из конфига
+	app, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.TokenAlgHS256, app.Tokens.Algorithm)
+	assert.Zero(t, app.Tokens.AccessTTL)
+	assert.Empty(t, app.Tokens.ClaimAttributes)
+	assert.Empty(t, app.Tokens.StaticClaims)
+
+	settings := models.TokenSettings{
+		AccessTTL:       15 * time.Minute,
+		Algorithm:       models.TokenAlgHS512,
+		ClaimAttributes: []string{"department", "level"},
+		StaticClaims:    map[string]string{"env": "prod"},
+	}
+	require.NoError(t, s.SetAppTokenSettings(ctx, tenant, admin, id, settings))
+	require.ErrorIs(t, s.SetAppTokenSettings(ctx, 2, admin, id, settings), storage.ErrAppNotFound)
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, settings, app.Tokens)
+
+	events := s.AuditLog(t)
+	last := events[len(events)-1]
+	assert.Equal(t, models.AuditActionSetTokenSettings, last.Action)
+	assert.Contains(t, last.NewValue, `"access_ttl":"15m0s"`)
+}
+
+func testTenants(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	const other int64 = 2
+	s.AddTenant(t, other, "other")
+
+	// один и тот же email в разных организациях - разные пользователи
+	admin := saveUser(t, s, "admin@example.com")
+	otherUser, err := s.SaveUser(ctx, other, "admin@example.com", []byte("hash"))
+	require.NoError(t, err)
+	assert.NotEqual(t, admin, otherUser)
+
+	_, err = s.SaveUser(ctx, other, "admin@example.com", []byte("hash"))
+	require.ErrorIs(t, err, storage.ErrUserExists)
+
+	user, err := s.User(ctx, other, "admin@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, otherUser, user.ID)
+	assert.Equal(t, other, user.TenantID)
+
+	otherApp := saveApp(t, s, other, otherUser, "other-app")
+	app, err := s.App(ctx, otherApp)
+	require.NoError(t, err)
+	assert.Equal(t, other, app.TenantID)
+
+	users, total, err := s.Users(ctx, storage.UserFilter{TenantID: other}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, otherUser, users[0].ID)
+
+	// объекты другой организации для администратора не существуют
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+	require.ErrorIs(t, s.UpdateAdminStatus(ctx, tenant, admin, otherUser, true), storage.ErrUserNotFound)
+
+	_, err = s.SaveRole(ctx, tenant, otherApp, "editor", nil)
+	require.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	otherRole, err := s.SaveRole(ctx, other, otherApp, "editor", nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, s.AssignRole(ctx, tenant, admin, admin, otherRole), storage.ErrRoleNotFound)
+	require.ErrorIs(t, s.AssignRole(ctx, other, admin, admin, otherRole), storage.ErrUserNotFound)
+
+	group, err := s.SaveGroup(ctx, tenant, "staff", "")
+	require.NoError(t, err)
+	otherGroup, err := s.SaveGroup(ctx, other, "staff", "")
+	require.NoError(t, err)
+
+	require.ErrorIs(t, s.AddGroupMember(ctx, tenant, admin, group, otherUser), storage.ErrUserNotFound)
+	require.ErrorIs(t, s.AddGroupMember(ctx, tenant, admin, otherGroup, admin), storage.ErrGroupNotFound)
+	require.ErrorIs(t, s.AddSubgroup(ctx, tenant, admin, group, otherGroup), storage.ErrGroupNotFound)
+	require.ErrorIs(t, s.DeleteGroup(ctx, tenant, otherGroup), storage.ErrGroupNotFound)
+
+	// последний администратор считается в каждой организации отдельно
+	require.NoError(t, s.UpdateAdminStatus(ctx, other, admin, otherUser, true))
+	require.ErrorIs(t, s.UpdateAdminStatus(ctx, other, otherUser, otherUser, false), storage.ErrLastAdmin)
+}
+
+func testDeleteUser(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+
+	app := saveApp(t, s, tenant, admin, "app")
+	role, err := s.SaveRole(ctx, tenant, app, "editor", []string{"docs:read"})
+	require.NoError(t, err)
+	require.NoError(t, s.AssignRole(ctx, tenant, admin, user, role))
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, app, user))
+	require.NoError(t, s.SaveFederatedIdentity(ctx, tenant, "google", "subject", user))
+	require.NoError(t, s.SetUserAttributes(ctx, tenant, admin, user, map[string]string{"department": "sales"}))
+	_, err = s.SavePolicy(ctx, tenant, user, app, `{"rules": []}`)
+	require.NoError(t, err)
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		TenantID: tenant, AppID: app, Email: "user@example.com", CreatedBy: admin, ExpiresAt: time.Now().Add(time.Hour),
+	}, "hash")
+	require.NoError(t, err)
+
+	// последнего администратора удалить нельзя
+	require.ErrorIs(t, s.DeleteUser(ctx, tenant, admin, admin), storage.ErrLastAdmin)
+	require.ErrorIs(t, s.DeleteUser(ctx, 2, admin, user), storage.ErrUserNotFound)
+
+	require.NoError(t, s.DeleteUser(ctx, tenant, admin, user))
+	require.ErrorIs(t, s.DeleteUser(ctx, tenant, admin, user), storage.ErrUserNotFound)
+
+	// удалённого пользователя нет ни для одного метода
+	_, err = s.User(ctx, tenant, "user@example.com")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByID(ctx, user)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.FederatedUser(ctx, tenant, "google", "subject")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.IsAdmin(ctx, user)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	require.ErrorIs(t, s.AssignRole(ctx, tenant, admin, user, role), storage.ErrUserNotFound)
+
+	_, total, err := s.Users(ctx, storage.UserFilter{TenantID: tenant}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	allowed, err := s.HasPermission(ctx, user, app, "docs:read")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// но до окончательного удаления его email занят
+	_, err = s.SaveUser(ctx, tenant, "user@example.com", []byte("hash"))
+	require.ErrorIs(t, err, storage.ErrUserExists)
+
+	// срок хранения ещё не истёк
+	purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	purged, err = s.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	// связи удалены, персональные данные обезличены
+	attributes, err := s.UserAttributes(ctx, user)
+	require.NoError(t, err)
+	assert.Empty(t, attributes)
+
+	members, err := s.AppMembers(ctx, tenant, app)
+	require.NoError(t, err)
+	assert.NotContains(t, members, user)
+
+	policy, err := s.Policy(ctx, app, 0)
+	require.NoError(t, err)
+	assert.Zero(t, policy.CreatedBy)
+
+	inv, err := s.InvitationByToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.Empty(t, inv.Email)
+	assert.Equal(t, models.InvitationRevoked, inv.Status(time.Now()))
+
+	events := s.AuditLog(t)
+	for _, event := range events {
+		assert.NotContains(t, event.OldValue+event.NewValue, "user@example.com")
+		assert.NotContains(t, event.OldValue+event.NewValue, "sales")
+	}
+	last := events[len(events)-1]
+	assert.Equal(t, models.AuditActionPurgeUser, last.Action)
+	assert.Equal(t, user, last.TargetID)
+	assert.Zero(t, last.ActorID)
+
+	// email снова свободен
+	_, err = s.SaveUser(ctx, tenant, "user@example.com", []byte("hash"))
+	require.NoError(t, err)
+}
+
+func testUserData(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	user := saveUser(t, s, "user@example.com")
+	other := saveUser(t, s, "other@example.com")
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+
+	app := saveApp(t, s, tenant, admin, "app")
+	role, err := s.SaveRole(ctx, tenant, app, "editor", []string{"docs:read"})
+	require.NoError(t, err)
+	require.NoError(t, s.AssignRole(ctx, tenant, admin, user, role))
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, app, user))
+	require.NoError(t, s.AddAppMember(ctx, tenant, admin, app, other))
+	require.NoError(t, s.SaveFederatedIdentity(ctx, tenant, "google", "subject", user))
+	require.NoError(t, s.SetUserAttributes(ctx, tenant, admin, user, map[string]string{"department": "sales"}))
+
+	groupID, err := s.SaveGroup(ctx, tenant, "staff", "")
+	require.NoError(t, err)
+	require.NoError(t, s.AddGroupMember(ctx, tenant, admin, groupID, user))
+	require.NoError(t, s.AddGroupMember(ctx, tenant, admin, groupID, other))
+
+	_, err = s.SavePolicy(ctx, tenant, user, app, `{"rules": []}`)
+	require.NoError(t, err)
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		TenantID: tenant, AppID: app, Email: "user@example.com", CreatedBy: admin, ExpiresAt: time.Now().Add(time.Hour),
+	}, "hash")
+	require.NoError(t, err)
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		TenantID: tenant, AppID: app, Email: "other@example.com", CreatedBy: admin, ExpiresAt: time.Now().Add(time.Hour),
+	}, "other-hash")
+	require.NoError(t, err)
+
+	_, err = s.UserData(ctx, 1000)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	data, err := s.UserData(ctx, user)
+	require.NoError(t, err)
+
+	assert.Equal(t, user, data.User.ID)
+	assert.Equal(t, "user@example.com", data.User.Email)
+	assert.False(t, data.IsAdmin)
+	assert.True(t, data.DeletedAt.IsZero())
+	assert.Equal(t, map[string]string{"department": "sales"}, data.Attributes)
+	assert.Equal(t, []models.FederatedIdentity{{Provider: "google", Subject: "subject"}}, data.Identities)
+	require.Len(t, data.Groups, 1)
+	assert.Equal(t, "staff", data.Groups[0].Name)
+	// участники группы - чужие данные
+	assert.Empty(t, data.Groups[0].Members)
+	require.Len(t, data.Roles, 1)
+	assert.Equal(t, "editor", data.Roles[0].Name)
+	assert.Equal(t, []int{app}, data.Apps)
+	require.Len(t, data.Invitations, 1)
+	assert.Equal(t, "user@example.com", data.Invitations[0].Email)
+	require.Len(t, data.Policies, 1)
+	assert.Equal(t, user, data.Policies[0].CreatedBy)
+
+	// в журнале - действия над пользователем и его собственные, но не над другими
+	var actions []string
+	for _, event := range data.Audit {
+		actions = append(actions, event.Action)
+	}
+	assert.ElementsMatch(t, []string{
+		models.AuditActionAssignRole,
+		models.AuditActionAddAppMember,
+		models.AuditActionSetUserAttributes,
+		models.AuditActionAddGroupMember,
+		models.AuditActionSetPolicy,
+		models.AuditActionCreateInvitation,
+	}, actions)
+
+	// данные удалённого пользователя выгружаются до окончательного удаления
+	require.NoError(t, s.DeleteUser(ctx, tenant, admin, user))
+	data, err = s.UserData(ctx, user)
+	require.NoError(t, err)
+	assert.False(t, data.DeletedAt.IsZero())
+}
+
+func testWithinTx(t *testing.T, s Backend) {
+	ctx := context.Background()
+
+	admin := saveUser(t, s, "admin@example.com")
+	require.NoError(t, s.UpdateAdminStatus(ctx, tenant, models.SystemActorID, admin, true))
+	app := saveApp(t, s, tenant, admin, "app")
+	roleID, err := s.SaveRole(ctx, tenant, app, "viewer", []string{"read"})
+	require.NoError(t, err)
+	audit := len(s.AuditLog(t))
+
+	// регистрация пользователя и назначение роли откатываются вместе
+	errAbort := errors.New("abort")
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		userID, err := s.SaveUser(ctx, tenant, "rollback@example.com", []byte("hash"))
+		if err != nil {
+			return err
+		}
+		if err := s.AssignRole(ctx, tenant, admin, userID, roleID); err != nil {
+			return err
+		}
+
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	_, err = s.User(ctx, tenant, "rollback@example.com")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	assert.Len(t, s.AuditLog(t), audit)
+
+	// и фиксируются вместе
+	var userID int64
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.SaveUser(ctx, tenant, "commit@example.com", []byte("hash"))
+		if err != nil {
+			return err
+		}
+
+		return s.AssignRole(ctx, tenant, admin, userID, roleID)
+	})
+	require.NoError(t, err)
+
+	roles, err := s.UserRoles(ctx, userID, app)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, roles)
+	assert.Len(t, s.AuditLog(t), audit+1)
+
+	// ошибка метода или вложенного WithinTx откатывает только их изменения, если fn её не вернула
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.UpdateAdminStatus(ctx, tenant, admin, admin, false)
+		require.ErrorIs(t, err, storage.ErrLastAdmin)
+
+		err = s.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := s.SaveUser(ctx, tenant, "nested@example.com", []byte("hash")); err != nil {
+				return err
+			}
+
+			return errAbort
+		})
+		require.ErrorIs(t, err, errAbort)
+
+		_, err = s.SaveUser(ctx, tenant, "partial@example.com", []byte("hash"))
+		return err
+	})
+	require.NoError(t, err)
+
+	isAdmin, err := s.IsAdmin(ctx, admin)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	_, err = s.User(ctx, tenant, "partial@example.com")
+	assert.NoError(t, err)
+	_, err = s.User(ctx, tenant, "nested@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func saveUser(t *testing.T, s Backend, email string) int64 {
+	t.Helper()
+
+	id, err := s.SaveUser(context.Background(), tenant, email, []byte("hash"))
+	require.NoError(t, err)
+
+	return id
+}
+
+func saveApp(t *testing.T, s Backend, tenantID int64, actorID int64, name string) int {
+	t.Helper()
+
+	id, err := s.SaveApp(context.Background(), tenantID, actorID, models.App{Name: name, Secret: name + "-secret", Kind: models.AppKindJWT})
+	require.NoError(t, err)
+
+	return id
+}
@@ -0,0 +1,45 @@
+-- 10_add_tenants.down.sql
+-- Откат возможен, только если email и имена групп уникальны во всех организациях
+CREATE TABLE federated_identities_old
+(
+    id       INTEGER PRIMARY KEY,
+    provider TEXT    NOT NULL,
+    subject  TEXT    NOT NULL,
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (provider, subject)
+);
+INSERT INTO federated_identities_old (id, provider, subject, user_id)
+SELECT id, provider, subject, user_id FROM federated_identities;
+DROP TABLE federated_identities;
+ALTER TABLE federated_identities_old RENAME TO federated_identities;
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities (user_id);
+
+CREATE TABLE groups_old
+(
+    id          INTEGER PRIMARY KEY,
+    name        TEXT    NOT NULL UNIQUE,
+    external_id TEXT,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE
+);
+INSERT INTO groups_old (id, name, external_id, is_admin)
+SELECT id, name, external_id, is_admin FROM groups;
+DROP TABLE groups;
+ALTER TABLE groups_old RENAME TO groups;
+
+CREATE TABLE users_old
+(
+    id          INTEGER PRIMARY KEY,
+    email       TEXT    NOT NULL UNIQUE,
+    pass_hash   BLOB    NOT NULL,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled    BOOLEAN NOT NULL DEFAULT FALSE,
+    external_id TEXT
+);
+INSERT INTO users_old (id, email, pass_hash, is_admin, disabled, external_id)
+SELECT id, email, pass_hash, is_admin, disabled, external_id FROM users;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+ALTER TABLE apps DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,62 @@
+-- 10_add_tenants.up.sql
+-- Организации (тенанты): у каждой свой каталог пользователей и групп.
+-- Приложение принадлежит организации, в него входят только её пользователи.
+-- Всё, что было создано до появления организаций, попадает в организацию по умолчанию (id = 1).
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+
+ALTER TABLE apps ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+
+-- email теперь уникален в пределах организации, а не глобально.
+-- SQLite не умеет удалять ограничения, поэтому таблицу пересоздаём.
+CREATE TABLE users_new
+(
+    id          INTEGER PRIMARY KEY,
+    tenant_id   INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    email       TEXT    NOT NULL,
+    pass_hash   BLOB    NOT NULL,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled    BOOLEAN NOT NULL DEFAULT FALSE,
+    external_id TEXT,
+    UNIQUE (tenant_id, email)
+);
+INSERT INTO users_new (id, tenant_id, email, pass_hash, is_admin, disabled, external_id)
+SELECT id, 1, email, pass_hash, is_admin, disabled, external_id FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+-- Имя группы тоже уникально в пределах организации
+CREATE TABLE groups_new
+(
+    id          INTEGER PRIMARY KEY,
+    tenant_id   INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    name        TEXT    NOT NULL,
+    external_id TEXT,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (tenant_id, name)
+);
+INSERT INTO groups_new (id, tenant_id, name, external_id, is_admin)
+SELECT id, 1, name, external_id, is_admin FROM groups;
+DROP TABLE groups;
+ALTER TABLE groups_new RENAME TO groups;
+
+-- Одна и та же учётная запись внешнего провайдера может быть связана
+-- с разными пользователями в разных организациях
+CREATE TABLE federated_identities_new
+(
+    id        INTEGER PRIMARY KEY,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    provider  TEXT    NOT NULL,
+    subject   TEXT    NOT NULL,
+    user_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (tenant_id, provider, subject)
+);
+INSERT INTO federated_identities_new (id, tenant_id, provider, subject, user_id)
+SELECT id, 1, provider, subject, user_id FROM federated_identities;
+DROP TABLE federated_identities;
+ALTER TABLE federated_identities_new RENAME TO federated_identities;
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities (user_id);
@@ -0,0 +1,4 @@
+-- 11_add_app_access.down.sql
+DROP TABLE IF EXISTS app_members;
+ALTER TABLE apps DROP COLUMN allowed_domains;
+ALTER TABLE apps DROP COLUMN access_mode;
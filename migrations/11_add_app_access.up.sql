@@ -0,0 +1,16 @@
+-- 11_add_app_access.up.sql
+-- Режим доступа к приложению:
+--   open        - войти может любой пользователь организации (как раньше),
+--   invite_only - только участники приложения (app_members),
+--   domain      - участники и пользователи с email из allowed_domains.
+-- Домены хранятся одной строкой через пробел (как scope'ы политик обмена).
+ALTER TABLE apps ADD COLUMN access_mode TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE apps ADD COLUMN allowed_domains TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS app_members
+(
+    app_id  INTEGER NOT NULL REFERENCES apps (id),
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    PRIMARY KEY (app_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_app_members_user_id ON app_members (user_id);
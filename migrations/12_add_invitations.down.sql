@@ -0,0 +1,2 @@
+-- 12_add_invitations.down.sql
+DROP TABLE IF EXISTS invitations;
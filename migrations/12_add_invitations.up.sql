@@ -0,0 +1,20 @@
+-- 12_add_invitations.up.sql
+-- Приглашения пользователей в приложение (и тем самым в организацию приложения).
+-- Сам токен приглашения не хранится: только его SHA-256 хэш (token_hash).
+-- Роль role_id (необязательная) выдаётся пользователю при принятии приглашения.
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          INTEGER PRIMARY KEY,
+    tenant_id   INTEGER  NOT NULL REFERENCES tenants (id),
+    app_id      INTEGER  NOT NULL REFERENCES apps (id),
+    email       TEXT     NOT NULL,
+    role_id     INTEGER REFERENCES roles (id),
+    token_hash  TEXT     NOT NULL UNIQUE,
+    created_by  INTEGER  NOT NULL,
+    created_at  DATETIME NOT NULL,
+    expires_at  DATETIME NOT NULL,
+    accepted_by INTEGER REFERENCES users (id),
+    accepted_at DATETIME,
+    revoked_at  DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_app_id ON invitations (app_id);
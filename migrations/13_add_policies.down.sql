@@ -0,0 +1,3 @@
+-- 13_add_policies.down.sql
+DROP TABLE IF EXISTS user_attributes;
+DROP TABLE IF EXISTS policies;
@@ -0,0 +1,22 @@
+-- 13_add_policies.up.sql
+-- Политики доступа (ABAC) приложений. Политика - JSON-документ с правилами;
+-- каждое изменение сохраняется новой версией, действует последняя.
+CREATE TABLE IF NOT EXISTS policies
+(
+    id         INTEGER PRIMARY KEY,
+    app_id     INTEGER   NOT NULL REFERENCES apps (id),
+    version    INTEGER   NOT NULL,
+    document   TEXT      NOT NULL,
+    created_by INTEGER   NOT NULL REFERENCES users (id),
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (app_id, version)
+);
+
+-- Атрибуты пользователей для политик (например, department = sales)
+CREATE TABLE IF NOT EXISTS user_attributes
+(
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name    TEXT    NOT NULL,
+    value   TEXT    NOT NULL,
+    PRIMARY KEY (user_id, name)
+);
@@ -0,0 +1,23 @@
+-- 14_add_app_management.down.sql
+-- Откат возможен, только если имена приложений уникальны во всех организациях
+CREATE TABLE apps_old
+(
+    id               INTEGER PRIMARY KEY,
+    name             TEXT    NOT NULL UNIQUE,
+    secret           TEXT    NOT NULL UNIQUE,
+    kind             TEXT    NOT NULL DEFAULT 'jwt',
+    saml_entity_id   TEXT,
+    saml_acs_url     TEXT,
+    saml_certificate TEXT,
+    tenant_id        INTEGER NOT NULL DEFAULT 1,
+    access_mode      TEXT    NOT NULL DEFAULT 'open',
+    allowed_domains  TEXT    NOT NULL DEFAULT ''
+);
+INSERT INTO apps_old (id, name, secret, kind, saml_entity_id, saml_acs_url, saml_certificate,
+                      tenant_id, access_mode, allowed_domains)
+SELECT id, name, secret, kind, saml_entity_id, saml_acs_url, saml_certificate,
+       tenant_id, access_mode, allowed_domains
+FROM apps;
+DROP TABLE apps;
+ALTER TABLE apps_old RENAME TO apps;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apps_saml_entity_id ON apps (saml_entity_id);
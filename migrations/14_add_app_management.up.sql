@@ -0,0 +1,28 @@
+-- 14_add_app_management.up.sql
+-- Приложения теперь создаются через API. Отключённое приложение (disabled) остаётся в базе,
+-- но войти в него нельзя, а выданные для него токены перестают приниматься.
+-- Имя приложения теперь уникально в пределах организации, а не глобально.
+-- SQLite не умеет удалять ограничения, поэтому таблицу пересоздаём.
+CREATE TABLE apps_new
+(
+    id               INTEGER PRIMARY KEY,
+    tenant_id        INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    name             TEXT    NOT NULL,
+    secret           TEXT    NOT NULL UNIQUE,
+    kind             TEXT    NOT NULL DEFAULT 'jwt',
+    saml_entity_id   TEXT,
+    saml_acs_url     TEXT,
+    saml_certificate TEXT,
+    access_mode      TEXT    NOT NULL DEFAULT 'open',
+    allowed_domains  TEXT    NOT NULL DEFAULT '',
+    disabled         BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (tenant_id, name)
+);
+INSERT INTO apps_new (id, tenant_id, name, secret, kind, saml_entity_id, saml_acs_url, saml_certificate,
+                      access_mode, allowed_domains)
+SELECT id, tenant_id, name, secret, kind, saml_entity_id, saml_acs_url, saml_certificate,
+       access_mode, allowed_domains
+FROM apps;
+DROP TABLE apps;
+ALTER TABLE apps_new RENAME TO apps;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apps_saml_entity_id ON apps (saml_entity_id);
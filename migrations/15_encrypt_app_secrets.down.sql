@@ -0,0 +1,7 @@
+-- 15_encrypt_app_secrets.down.sql
+-- Откат не расшифровывает секреты: зашифрованные секреты останутся в apps.secret
+-- и старая версия сервиса не сможет ими пользоваться. Приложениям придётся выдать новые секреты.
+ALTER TABLE apps DROP COLUMN previous_secret_expires_at;
+ALTER TABLE apps DROP COLUMN previous_secret_key_id;
+ALTER TABLE apps DROP COLUMN previous_secret;
+ALTER TABLE apps DROP COLUMN secret_key_id;
@@ -0,0 +1,10 @@
+-- 15_encrypt_app_secrets.up.sql
+-- Секреты приложений хранятся зашифрованными мастер-ключом из конфига (см. lib/secrets).
+-- secret_key_id - ID мастер-ключа, которым зашифрован секрет; пустой у секретов, записанных открытым текстом.
+-- Мастер-ключ SQL недоступен, поэтому существующие секреты шифрует сервис при запуске
+-- (storage.sqlite.Storage.EncryptAppSecrets), до этого они читаются как есть.
+-- После ротации секрета предыдущий принимается для проверки токенов до previous_secret_expires_at.
+ALTER TABLE apps ADD COLUMN secret_key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN previous_secret TEXT;
+ALTER TABLE apps ADD COLUMN previous_secret_key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN previous_secret_expires_at TIMESTAMP;
@@ -0,0 +1,5 @@
+-- 16_add_app_token_settings.down.sql
+ALTER TABLE apps DROP COLUMN static_claims;
+ALTER TABLE apps DROP COLUMN claim_attributes;
+ALTER TABLE apps DROP COLUMN token_algorithm;
+ALTER TABLE apps DROP COLUMN access_token_ttl;
@@ -0,0 +1,8 @@
+-- 16_add_app_token_settings.up.sql
+-- Настройки токенов приложения (models.TokenSettings).
+-- access_token_ttl - время жизни в секундах, 0 - значение token_ttl из конфига;
+-- claim_attributes - имена атрибутов пользователя через пробел; static_claims - JSON-объект.
+ALTER TABLE apps ADD COLUMN access_token_ttl INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE apps ADD COLUMN token_algorithm TEXT NOT NULL DEFAULT 'HS256';
+ALTER TABLE apps ADD COLUMN claim_attributes TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN static_claims TEXT NOT NULL DEFAULT '{}';
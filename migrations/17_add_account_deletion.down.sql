@@ -0,0 +1,19 @@
+-- 17_add_account_deletion.down.sql
+-- Автор политик, удалённый окончательно, при откате заменяется на 0
+CREATE TABLE policies_old
+(
+    id         INTEGER PRIMARY KEY,
+    app_id     INTEGER   NOT NULL REFERENCES apps (id),
+    version    INTEGER   NOT NULL,
+    document   TEXT      NOT NULL,
+    created_by INTEGER   NOT NULL REFERENCES users (id),
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (app_id, version)
+);
+INSERT INTO policies_old (id, app_id, version, document, created_by, created_at)
+SELECT id, app_id, version, document, COALESCE(created_by, 0), created_at FROM policies;
+DROP TABLE policies;
+ALTER TABLE policies_old RENAME TO policies;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,22 @@
+-- 17_add_account_deletion.up.sql
+-- Удаление учётных записей: сначала пользователь помечается удалённым (deleted_at),
+-- а по истечении срока хранения удаляется окончательно вместе с персональными данными.
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- Политики окончательно удалённого автора остаются, а created_by становится NULL.
+-- SQLite не умеет снимать NOT NULL, поэтому таблицу пересоздаём.
+CREATE TABLE policies_new
+(
+    id         INTEGER PRIMARY KEY,
+    app_id     INTEGER   NOT NULL REFERENCES apps (id),
+    version    INTEGER   NOT NULL,
+    document   TEXT      NOT NULL,
+    created_by INTEGER REFERENCES users (id),
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (app_id, version)
+);
+INSERT INTO policies_new (id, app_id, version, document, created_by, created_at)
+SELECT id, app_id, version, document, created_by, created_at FROM policies;
+DROP TABLE policies;
+ALTER TABLE policies_new RENAME TO policies;
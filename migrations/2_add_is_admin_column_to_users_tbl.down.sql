@@ -1,2 +1,2 @@
 -- 2_add_is_admin_column_to_users_tbl.down.sql
-ALTER TABLE users DROP COLUMNS is_admin;
+ALTER TABLE users DROP COLUMN is_admin;
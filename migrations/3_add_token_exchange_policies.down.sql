@@ -0,0 +1,2 @@
+-- 3_add_token_exchange_policies.down.sql
+DROP TABLE IF EXISTS token_exchange_policies;
@@ -0,0 +1,12 @@
+-- 3_add_token_exchange_policies.up.sql
+-- Политики обмена токенов (RFC 8693): какое приложение (source_app_id)
+-- может обменять токен пользователя на токен для другого приложения (target_app_id)
+-- и с какими scope'ами (список через пробел).
+CREATE TABLE IF NOT EXISTS token_exchange_policies
+(
+    id            INTEGER PRIMARY KEY,
+    source_app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    target_app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    scopes        TEXT    NOT NULL DEFAULT '',
+    UNIQUE (source_app_id, target_app_id)
+);
@@ -0,0 +1,2 @@
+-- 4_add_federated_identities.down.sql
+DROP TABLE IF EXISTS federated_identities;
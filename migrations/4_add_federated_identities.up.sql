@@ -0,0 +1,12 @@
+-- 4_add_federated_identities.up.sql
+-- Связь пользователя с учётной записью во внешнем OIDC-провайдере:
+-- subject - идентификатор пользователя у провайдера (claim sub или настроенный в claim_mapping)
+CREATE TABLE IF NOT EXISTS federated_identities
+(
+    id       INTEGER PRIMARY KEY,
+    provider TEXT    NOT NULL,
+    subject  TEXT    NOT NULL,
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (provider, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities (user_id);
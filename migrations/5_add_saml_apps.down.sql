@@ -0,0 +1,6 @@
+-- 5_add_saml_apps.down.sql
+DROP INDEX IF EXISTS idx_apps_saml_entity_id;
+ALTER TABLE apps DROP COLUMN saml_certificate;
+ALTER TABLE apps DROP COLUMN saml_acs_url;
+ALTER TABLE apps DROP COLUMN saml_entity_id;
+ALTER TABLE apps DROP COLUMN kind;
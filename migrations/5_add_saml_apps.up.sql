@@ -0,0 +1,10 @@
+-- 5_add_saml_apps.up.sql
+-- Приложения бывают разных видов: обычные (получают JWT, kind = 'jwt')
+-- и SAML service provider'ы (kind = 'saml'), для которых мы выступаем SAML IdP.
+-- Для SAML-приложений храним entity ID, адрес ACS (куда отправляется assertion)
+-- и сертификат SP в формате PEM (для шифрования assertion).
+ALTER TABLE apps ADD COLUMN kind TEXT NOT NULL DEFAULT 'jwt';
+ALTER TABLE apps ADD COLUMN saml_entity_id TEXT;
+ALTER TABLE apps ADD COLUMN saml_acs_url TEXT;
+ALTER TABLE apps ADD COLUMN saml_certificate TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apps_saml_entity_id ON apps (saml_entity_id);
@@ -0,0 +1,5 @@
+-- 6_add_scim_provisioning.down.sql
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+ALTER TABLE users DROP COLUMN external_id;
+ALTER TABLE users DROP COLUMN disabled;
@@ -0,0 +1,22 @@
+-- 6_add_scim_provisioning.up.sql
+-- Поддержка SCIM-провижининга:
+--  - disabled: отключённый пользователь (при увольнении учётная запись не удаляется, а отключается);
+--  - external_id: идентификатор пользователя во внешней системе (HR), externalId в SCIM;
+--  - группы пользователей и их состав.
+ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN external_id TEXT;
+
+CREATE TABLE IF NOT EXISTS groups
+(
+    id          INTEGER PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    external_id TEXT
+);
+
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id INTEGER NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
@@ -0,0 +1,14 @@
+-- 7_add_audit_log.up.sql
+-- Журнал изменений: кто (actor_id), что сделал (action), с кем (target_id)
+-- и какое значение было до и после изменения.
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id         INTEGER PRIMARY KEY,
+    actor_id   INTEGER  NOT NULL,
+    action     TEXT     NOT NULL,
+    target_id  INTEGER  NOT NULL,
+    old_value  TEXT     NOT NULL DEFAULT '',
+    new_value  TEXT     NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_id ON audit_log (target_id);
@@ -0,0 +1,4 @@
+-- 8_add_rbac.down.sql
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,24 @@
+-- 8_add_rbac.up.sql
+-- Роли задаются для каждого приложения отдельно; права роли - строки вида "reports:read"
+CREATE TABLE IF NOT EXISTS roles
+(
+    id     INTEGER PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps (id),
+    name   TEXT    NOT NULL,
+    UNIQUE (app_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id    INTEGER NOT NULL REFERENCES roles (id),
+    permission TEXT    NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    role_id INTEGER NOT NULL REFERENCES roles (id),
+    PRIMARY KEY (user_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
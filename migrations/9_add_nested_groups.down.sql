@@ -0,0 +1,4 @@
+-- 9_add_nested_groups.down.sql
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS group_children;
+ALTER TABLE groups DROP COLUMN is_admin;
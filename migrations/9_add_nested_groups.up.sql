@@ -0,0 +1,21 @@
+-- 9_add_nested_groups.up.sql
+-- Вложенные группы: участники группы child_id считаются участниками группы group_id
+CREATE TABLE IF NOT EXISTS group_children
+(
+    group_id INTEGER NOT NULL REFERENCES groups (id),
+    child_id INTEGER NOT NULL REFERENCES groups (id),
+    PRIMARY KEY (group_id, child_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_children_child_id ON group_children (child_id);
+
+-- Роли, выданные группе, действуют для всех её участников (в том числе через вложенные группы)
+CREATE TABLE IF NOT EXISTS group_roles
+(
+    group_id INTEGER NOT NULL REFERENCES groups (id),
+    role_id  INTEGER NOT NULL REFERENCES roles (id),
+    PRIMARY KEY (group_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_roles_role_id ON group_roles (role_id);
+
+-- Участники группы администраторов - администраторы
+ALTER TABLE groups ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+// migrations/migrations.go
+
+// Package migrations встраивает файлы миграций в бинарник: сервис и мигратор
+// не зависят от папки migrations на диске. Применяет их пакет storage/schema.
+package migrations
+
+import "embed"
+
+// FS миграции SQLite (в корне) и PostgreSQL (в postgres/)
+//
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
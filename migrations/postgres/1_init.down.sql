@@ -0,0 +1,18 @@
+-- migrations/postgres/1_init.down.sql
+DROP TABLE IF EXISTS user_attributes;
+DROP TABLE IF EXISTS policies;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS app_members;
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS group_children;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS federated_identities;
+DROP TABLE IF EXISTS token_exchange_policies;
+DROP TABLE IF EXISTS apps;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS tenants;
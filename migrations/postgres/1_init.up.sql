@@ -0,0 +1,195 @@
+-- migrations/postgres/1_init.up.sql
+-- Схема для PostgreSQL (storage.driver: postgres), соответствует миграциям SQLite 1-16 из ./migrations.
+-- В отличие от SQLite, внешние ключи здесь проверяются.
+-- Новые изменения схемы добавляются миграциями в оба каталога.
+
+-- Организации (тенанты): у каждой свой каталог пользователей и групп.
+-- Организация по умолчанию (id = 1) создаётся сразу.
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id   BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants));
+
+-- email уникален в пределах организации
+CREATE TABLE IF NOT EXISTS users
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    tenant_id   BIGINT  NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    email       TEXT    NOT NULL,
+    pass_hash   BYTEA   NOT NULL,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled    BOOLEAN NOT NULL DEFAULT FALSE,
+    external_id TEXT,
+    UNIQUE (tenant_id, email)
+);
+
+-- Приложения: обычные (kind = 'jwt') и SAML service provider'ы (kind = 'saml').
+-- Секреты хранятся зашифрованными мастер-ключом (secret_key_id), см. lib/secrets.
+-- Домены и атрибуты для claims хранятся строкой через пробел, статические claims - JSON-объектом.
+CREATE TABLE IF NOT EXISTS apps
+(
+    id                         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    tenant_id                  BIGINT      NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    name                       TEXT        NOT NULL,
+    secret                     TEXT        NOT NULL UNIQUE,
+    secret_key_id              TEXT        NOT NULL DEFAULT '',
+    previous_secret            TEXT,
+    previous_secret_key_id     TEXT        NOT NULL DEFAULT '',
+    previous_secret_expires_at TIMESTAMPTZ,
+    kind                       TEXT        NOT NULL DEFAULT 'jwt',
+    saml_entity_id             TEXT UNIQUE,
+    saml_acs_url               TEXT,
+    saml_certificate           TEXT,
+    access_mode                TEXT        NOT NULL DEFAULT 'open',
+    allowed_domains            TEXT        NOT NULL DEFAULT '',
+    disabled                   BOOLEAN     NOT NULL DEFAULT FALSE,
+    access_token_ttl           BIGINT      NOT NULL DEFAULT 0,
+    token_algorithm            TEXT        NOT NULL DEFAULT 'HS256',
+    claim_attributes           TEXT        NOT NULL DEFAULT '',
+    static_claims              TEXT        NOT NULL DEFAULT '{}',
+    UNIQUE (tenant_id, name)
+);
+
+-- Политики обмена токенов (RFC 8693)
+CREATE TABLE IF NOT EXISTS token_exchange_policies
+(
+    id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    source_app_id BIGINT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    target_app_id BIGINT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    scopes        TEXT   NOT NULL DEFAULT '',
+    UNIQUE (source_app_id, target_app_id)
+);
+
+-- Связь пользователя с учётной записью во внешнем OIDC-провайдере
+CREATE TABLE IF NOT EXISTS federated_identities
+(
+    id        BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    provider  TEXT   NOT NULL,
+    subject   TEXT   NOT NULL,
+    user_id   BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (tenant_id, provider, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities (user_id);
+
+-- Группы пользователей (в том числе вложенные) и их состав
+CREATE TABLE IF NOT EXISTS groups
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    tenant_id   BIGINT  NOT NULL DEFAULT 1 REFERENCES tenants (id),
+    name        TEXT    NOT NULL,
+    external_id TEXT,
+    is_admin    BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id  BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS group_children
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id),
+    child_id BIGINT NOT NULL REFERENCES groups (id),
+    PRIMARY KEY (group_id, child_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_children_child_id ON group_children (child_id);
+
+-- Журнал изменений
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    actor_id   BIGINT      NOT NULL,
+    action     TEXT        NOT NULL,
+    target_id  BIGINT      NOT NULL,
+    old_value  TEXT        NOT NULL DEFAULT '',
+    new_value  TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_id ON audit_log (target_id);
+
+-- Роли приложений и их права
+CREATE TABLE IF NOT EXISTS roles
+(
+    id     BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    app_id BIGINT NOT NULL REFERENCES apps (id),
+    name   TEXT   NOT NULL,
+    UNIQUE (app_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id    BIGINT NOT NULL REFERENCES roles (id),
+    permission TEXT   NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    role_id BIGINT NOT NULL REFERENCES roles (id),
+    PRIMARY KEY (user_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+CREATE TABLE IF NOT EXISTS group_roles
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id),
+    role_id  BIGINT NOT NULL REFERENCES roles (id),
+    PRIMARY KEY (group_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_roles_role_id ON group_roles (role_id);
+
+-- Участники приложений (для режимов доступа invite_only и domain)
+CREATE TABLE IF NOT EXISTS app_members
+(
+    app_id  BIGINT NOT NULL REFERENCES apps (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    PRIMARY KEY (app_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_app_members_user_id ON app_members (user_id);
+
+-- Приглашения: хранится только SHA-256 хэш токена
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    tenant_id   BIGINT      NOT NULL REFERENCES tenants (id),
+    app_id      BIGINT      NOT NULL REFERENCES apps (id),
+    email       TEXT        NOT NULL,
+    role_id     BIGINT REFERENCES roles (id) ON DELETE SET NULL,
+    token_hash  TEXT        NOT NULL UNIQUE,
+    created_by  BIGINT      NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    accepted_by BIGINT REFERENCES users (id),
+    accepted_at TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_app_id ON invitations (app_id);
+
+-- Версионированные политики доступа (ABAC) и атрибуты пользователей
+CREATE TABLE IF NOT EXISTS policies
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    app_id     BIGINT      NOT NULL REFERENCES apps (id),
+    version    INTEGER     NOT NULL,
+    document   TEXT        NOT NULL,
+    created_by BIGINT      NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (app_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS user_attributes
+(
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    name    TEXT   NOT NULL,
+    value   TEXT   NOT NULL,
+    PRIMARY KEY (user_id, name)
+);
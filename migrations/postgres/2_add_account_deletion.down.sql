@@ -0,0 +1,6 @@
+-- migrations/postgres/2_add_account_deletion.down.sql
+-- Откат возможен, только если нет политик окончательно удалённых авторов
+ALTER TABLE policies ALTER COLUMN created_by SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
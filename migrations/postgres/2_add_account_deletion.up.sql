@@ -0,0 +1,7 @@
+-- migrations/postgres/2_add_account_deletion.up.sql
+-- Соответствует миграции SQLite 17: удалённые пользователи (deleted_at) и политики,
+-- автор которых удалён окончательно (created_by = NULL).
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+ALTER TABLE policies ALTER COLUMN created_by DROP NOT NULL;
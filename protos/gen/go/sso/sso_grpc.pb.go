@@ -0,0 +1,2116 @@
+//версия protobuf
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: sso/sso.proto
+
+// текущий пакет - указывает пространство имен для сервиса и сообщений. Помогает избегать конфликтов
+
+package ssov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Auth_Register_FullMethodName      = "/auth.Auth/Register"
+	Auth_Login_FullMethodName         = "/auth.Auth/Login"
+	Auth_IsAdmin_FullMethodName       = "/auth.Auth/IsAdmin"
+	Auth_TokenExchange_FullMethodName = "/auth.Auth/TokenExchange"
+	Auth_SetAdmin_FullMethodName      = "/auth.Auth/SetAdmin"
+	Auth_DeleteAccount_FullMethodName = "/auth.Auth/DeleteAccount"
+	Auth_ExportMyData_FullMethodName  = "/auth.Auth/ExportMyData"
+)
+
+// AuthClient is the client API for Auth service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthClient interface {
+	// Register registers a new user
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Login logs in a user and returns auth token
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// IsAdmin checks whether a user of the caller's organization is admin.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	IsAdmin(ctx context.Context, in *IsAdminRequest, opts ...grpc.CallOption) (*IsAdminResponse, error)
+	// TokenExchange exchanges a user token issued for one app
+	// into a token for another app on the user's behalf (RFC 8693)
+	TokenExchange(ctx context.Context, in *TokenExchangeRequest, opts ...grpc.CallOption) (*TokenExchangeResponse, error)
+	// SetAdmin grants or revokes admin status of a user.
+	// The caller must pass a token of an admin in "authorization: Bearer <token>" metadata.
+	SetAdmin(ctx context.Context, in *SetAdminRequest, opts ...grpc.CallOption) (*SetAdminResponse, error)
+	// DeleteAccount deletes a user account. The account is hidden at once
+	// and purged permanently after the retention period.
+	// A user deletes own account (user_id = 0 or own ID) confirming it with the password,
+	// an admin deletes any account of the organization.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*DeleteAccountResponse, error)
+	// ExportMyData returns all the data stored about the caller as a JSON archive.
+	// A user can export own data once per configured interval.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	ExportMyData(ctx context.Context, in *ExportMyDataRequest, opts ...grpc.CallOption) (*ExportMyDataResponse, error)
+}
+
+type authClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthClient(cc grpc.ClientConnInterface) AuthClient {
+	return &authClient{cc}
+}
+
+func (c *authClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, Auth_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, Auth_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) IsAdmin(ctx context.Context, in *IsAdminRequest, opts ...grpc.CallOption) (*IsAdminResponse, error) {
+	out := new(IsAdminResponse)
+	err := c.cc.Invoke(ctx, Auth_IsAdmin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) TokenExchange(ctx context.Context, in *TokenExchangeRequest, opts ...grpc.CallOption) (*TokenExchangeResponse, error) {
+	out := new(TokenExchangeResponse)
+	err := c.cc.Invoke(ctx, Auth_TokenExchange_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) SetAdmin(ctx context.Context, in *SetAdminRequest, opts ...grpc.CallOption) (*SetAdminResponse, error) {
+	out := new(SetAdminResponse)
+	err := c.cc.Invoke(ctx, Auth_SetAdmin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*DeleteAccountResponse, error) {
+	out := new(DeleteAccountResponse)
+	err := c.cc.Invoke(ctx, Auth_DeleteAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ExportMyData(ctx context.Context, in *ExportMyDataRequest, opts ...grpc.CallOption) (*ExportMyDataResponse, error) {
+	out := new(ExportMyDataResponse)
+	err := c.cc.Invoke(ctx, Auth_ExportMyData_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthServer is the server API for Auth service.
+// All implementations must embed UnimplementedAuthServer
+// for forward compatibility
+type AuthServer interface {
+	// Register registers a new user
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Login logs in a user and returns auth token
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// IsAdmin checks whether a user of the caller's organization is admin.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	IsAdmin(context.Context, *IsAdminRequest) (*IsAdminResponse, error)
+	// TokenExchange exchanges a user token issued for one app
+	// into a token for another app on the user's behalf (RFC 8693)
+	TokenExchange(context.Context, *TokenExchangeRequest) (*TokenExchangeResponse, error)
+	// SetAdmin grants or revokes admin status of a user.
+	// The caller must pass a token of an admin in "authorization: Bearer <token>" metadata.
+	SetAdmin(context.Context, *SetAdminRequest) (*SetAdminResponse, error)
+	// DeleteAccount deletes a user account. The account is hidden at once
+	// and purged permanently after the retention period.
+	// A user deletes own account (user_id = 0 or own ID) confirming it with the password,
+	// an admin deletes any account of the organization.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	DeleteAccount(context.Context, *DeleteAccountRequest) (*DeleteAccountResponse, error)
+	// ExportMyData returns all the data stored about the caller as a JSON archive.
+	// A user can export own data once per configured interval.
+	// The caller's token is passed in "authorization: Bearer <token>" metadata.
+	ExportMyData(context.Context, *ExportMyDataRequest) (*ExportMyDataResponse, error)
+	mustEmbedUnimplementedAuthServer()
+}
+
+// UnimplementedAuthServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthServer struct {
+}
+
+func (UnimplementedAuthServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedAuthServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedAuthServer) IsAdmin(context.Context, *IsAdminRequest) (*IsAdminResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsAdmin not implemented")
+}
+func (UnimplementedAuthServer) TokenExchange(context.Context, *TokenExchangeRequest) (*TokenExchangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TokenExchange not implemented")
+}
+func (UnimplementedAuthServer) SetAdmin(context.Context, *SetAdminRequest) (*SetAdminResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAdmin not implemented")
+}
+func (UnimplementedAuthServer) DeleteAccount(context.Context, *DeleteAccountRequest) (*DeleteAccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccount not implemented")
+}
+func (UnimplementedAuthServer) ExportMyData(context.Context, *ExportMyDataRequest) (*ExportMyDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportMyData not implemented")
+}
+func (UnimplementedAuthServer) mustEmbedUnimplementedAuthServer() {}
+
+// UnsafeAuthServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthServer will
+// result in compilation errors.
+type UnsafeAuthServer interface {
+	mustEmbedUnimplementedAuthServer()
+}
+
+func RegisterAuthServer(s grpc.ServiceRegistrar, srv AuthServer) {
+	s.RegisterService(&Auth_ServiceDesc, srv)
+}
+
+func _Auth_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_IsAdmin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IsAdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).IsAdmin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_IsAdmin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).IsAdmin(ctx, req.(*IsAdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_TokenExchange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenExchangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).TokenExchange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_TokenExchange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).TokenExchange(ctx, req.(*TokenExchangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_SetAdmin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).SetAdmin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_SetAdmin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).SetAdmin(ctx, req.(*SetAdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_DeleteAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).DeleteAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_DeleteAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).DeleteAccount(ctx, req.(*DeleteAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ExportMyData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportMyDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ExportMyData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auth_ExportMyData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ExportMyData(ctx, req.(*ExportMyDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Auth_ServiceDesc is the grpc.ServiceDesc for Auth service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Auth_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Auth",
+	HandlerType: (*AuthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Auth_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Auth_Login_Handler,
+		},
+		{
+			MethodName: "IsAdmin",
+			Handler:    _Auth_IsAdmin_Handler,
+		},
+		{
+			MethodName: "TokenExchange",
+			Handler:    _Auth_TokenExchange_Handler,
+		},
+		{
+			MethodName: "SetAdmin",
+			Handler:    _Auth_SetAdmin_Handler,
+		},
+		{
+			MethodName: "DeleteAccount",
+			Handler:    _Auth_DeleteAccount_Handler,
+		},
+		{
+			MethodName: "ExportMyData",
+			Handler:    _Auth_ExportMyData_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
+
+const (
+	Permissions_CreateRole_FullMethodName         = "/auth.Permissions/CreateRole"
+	Permissions_SetRolePermissions_FullMethodName = "/auth.Permissions/SetRolePermissions"
+	Permissions_DeleteRole_FullMethodName         = "/auth.Permissions/DeleteRole"
+	Permissions_ListRoles_FullMethodName          = "/auth.Permissions/ListRoles"
+	Permissions_AssignRole_FullMethodName         = "/auth.Permissions/AssignRole"
+	Permissions_RevokeRole_FullMethodName         = "/auth.Permissions/RevokeRole"
+	Permissions_GetUserRoles_FullMethodName       = "/auth.Permissions/GetUserRoles"
+	Permissions_CheckPermission_FullMethodName    = "/auth.Permissions/CheckPermission"
+)
+
+// PermissionsClient is the client API for Permissions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PermissionsClient interface {
+	// CreateRole creates a role with the given permissions in the app
+	CreateRole(ctx context.Context, in *CreateRoleRequest, opts ...grpc.CallOption) (*CreateRoleResponse, error)
+	// SetRolePermissions replaces the permissions of a role
+	SetRolePermissions(ctx context.Context, in *SetRolePermissionsRequest, opts ...grpc.CallOption) (*SetRolePermissionsResponse, error)
+	// DeleteRole deletes a role and all its assignments
+	DeleteRole(ctx context.Context, in *DeleteRoleRequest, opts ...grpc.CallOption) (*DeleteRoleResponse, error)
+	// ListRoles returns all roles of the app
+	ListRoles(ctx context.Context, in *ListRolesRequest, opts ...grpc.CallOption) (*ListRolesResponse, error)
+	// AssignRole assigns a role to a user
+	AssignRole(ctx context.Context, in *AssignRoleRequest, opts ...grpc.CallOption) (*AssignRoleResponse, error)
+	// RevokeRole revokes a role from a user
+	RevokeRole(ctx context.Context, in *RevokeRoleRequest, opts ...grpc.CallOption) (*RevokeRoleResponse, error)
+	// GetUserRoles returns names of the user's roles in the app
+	GetUserRoles(ctx context.Context, in *GetUserRolesRequest, opts ...grpc.CallOption) (*GetUserRolesResponse, error)
+	// CheckPermission checks whether the user of the caller's organization has the permission in the app
+	CheckPermission(ctx context.Context, in *CheckPermissionRequest, opts ...grpc.CallOption) (*CheckPermissionResponse, error)
+}
+
+type permissionsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPermissionsClient(cc grpc.ClientConnInterface) PermissionsClient {
+	return &permissionsClient{cc}
+}
+
+func (c *permissionsClient) CreateRole(ctx context.Context, in *CreateRoleRequest, opts ...grpc.CallOption) (*CreateRoleResponse, error) {
+	out := new(CreateRoleResponse)
+	err := c.cc.Invoke(ctx, Permissions_CreateRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) SetRolePermissions(ctx context.Context, in *SetRolePermissionsRequest, opts ...grpc.CallOption) (*SetRolePermissionsResponse, error) {
+	out := new(SetRolePermissionsResponse)
+	err := c.cc.Invoke(ctx, Permissions_SetRolePermissions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) DeleteRole(ctx context.Context, in *DeleteRoleRequest, opts ...grpc.CallOption) (*DeleteRoleResponse, error) {
+	out := new(DeleteRoleResponse)
+	err := c.cc.Invoke(ctx, Permissions_DeleteRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) ListRoles(ctx context.Context, in *ListRolesRequest, opts ...grpc.CallOption) (*ListRolesResponse, error) {
+	out := new(ListRolesResponse)
+	err := c.cc.Invoke(ctx, Permissions_ListRoles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) AssignRole(ctx context.Context, in *AssignRoleRequest, opts ...grpc.CallOption) (*AssignRoleResponse, error) {
+	out := new(AssignRoleResponse)
+	err := c.cc.Invoke(ctx, Permissions_AssignRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) RevokeRole(ctx context.Context, in *RevokeRoleRequest, opts ...grpc.CallOption) (*RevokeRoleResponse, error) {
+	out := new(RevokeRoleResponse)
+	err := c.cc.Invoke(ctx, Permissions_RevokeRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) GetUserRoles(ctx context.Context, in *GetUserRolesRequest, opts ...grpc.CallOption) (*GetUserRolesResponse, error) {
+	out := new(GetUserRolesResponse)
+	err := c.cc.Invoke(ctx, Permissions_GetUserRoles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionsClient) CheckPermission(ctx context.Context, in *CheckPermissionRequest, opts ...grpc.CallOption) (*CheckPermissionResponse, error) {
+	out := new(CheckPermissionResponse)
+	err := c.cc.Invoke(ctx, Permissions_CheckPermission_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PermissionsServer is the server API for Permissions service.
+// All implementations must embed UnimplementedPermissionsServer
+// for forward compatibility
+type PermissionsServer interface {
+	// CreateRole creates a role with the given permissions in the app
+	CreateRole(context.Context, *CreateRoleRequest) (*CreateRoleResponse, error)
+	// SetRolePermissions replaces the permissions of a role
+	SetRolePermissions(context.Context, *SetRolePermissionsRequest) (*SetRolePermissionsResponse, error)
+	// DeleteRole deletes a role and all its assignments
+	DeleteRole(context.Context, *DeleteRoleRequest) (*DeleteRoleResponse, error)
+	// ListRoles returns all roles of the app
+	ListRoles(context.Context, *ListRolesRequest) (*ListRolesResponse, error)
+	// AssignRole assigns a role to a user
+	AssignRole(context.Context, *AssignRoleRequest) (*AssignRoleResponse, error)
+	// RevokeRole revokes a role from a user
+	RevokeRole(context.Context, *RevokeRoleRequest) (*RevokeRoleResponse, error)
+	// GetUserRoles returns names of the user's roles in the app
+	GetUserRoles(context.Context, *GetUserRolesRequest) (*GetUserRolesResponse, error)
+	// CheckPermission checks whether the user of the caller's organization has the permission in the app
+	CheckPermission(context.Context, *CheckPermissionRequest) (*CheckPermissionResponse, error)
+	mustEmbedUnimplementedPermissionsServer()
+}
+
+// UnimplementedPermissionsServer must be embedded to have forward compatible implementations.
+type UnimplementedPermissionsServer struct {
+}
+
+func (UnimplementedPermissionsServer) CreateRole(context.Context, *CreateRoleRequest) (*CreateRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRole not implemented")
+}
+func (UnimplementedPermissionsServer) SetRolePermissions(context.Context, *SetRolePermissionsRequest) (*SetRolePermissionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRolePermissions not implemented")
+}
+func (UnimplementedPermissionsServer) DeleteRole(context.Context, *DeleteRoleRequest) (*DeleteRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRole not implemented")
+}
+func (UnimplementedPermissionsServer) ListRoles(context.Context, *ListRolesRequest) (*ListRolesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRoles not implemented")
+}
+func (UnimplementedPermissionsServer) AssignRole(context.Context, *AssignRoleRequest) (*AssignRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignRole not implemented")
+}
+func (UnimplementedPermissionsServer) RevokeRole(context.Context, *RevokeRoleRequest) (*RevokeRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeRole not implemented")
+}
+func (UnimplementedPermissionsServer) GetUserRoles(context.Context, *GetUserRolesRequest) (*GetUserRolesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserRoles not implemented")
+}
+func (UnimplementedPermissionsServer) CheckPermission(context.Context, *CheckPermissionRequest) (*CheckPermissionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckPermission not implemented")
+}
+func (UnimplementedPermissionsServer) mustEmbedUnimplementedPermissionsServer() {}
+
+// UnsafePermissionsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PermissionsServer will
+// result in compilation errors.
+type UnsafePermissionsServer interface {
+	mustEmbedUnimplementedPermissionsServer()
+}
+
+func RegisterPermissionsServer(s grpc.ServiceRegistrar, srv PermissionsServer) {
+	s.RegisterService(&Permissions_ServiceDesc, srv)
+}
+
+func _Permissions_CreateRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).CreateRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_CreateRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).CreateRole(ctx, req.(*CreateRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_SetRolePermissions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRolePermissionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).SetRolePermissions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_SetRolePermissions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).SetRolePermissions(ctx, req.(*SetRolePermissionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_DeleteRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).DeleteRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_DeleteRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).DeleteRole(ctx, req.(*DeleteRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_ListRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).ListRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_ListRoles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).ListRoles(ctx, req.(*ListRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_AssignRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).AssignRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_AssignRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).AssignRole(ctx, req.(*AssignRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_RevokeRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).RevokeRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_RevokeRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).RevokeRole(ctx, req.(*RevokeRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_GetUserRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).GetUserRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_GetUserRoles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).GetUserRoles(ctx, req.(*GetUserRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Permissions_CheckPermission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckPermissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionsServer).CheckPermission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Permissions_CheckPermission_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionsServer).CheckPermission(ctx, req.(*CheckPermissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Permissions_ServiceDesc is the grpc.ServiceDesc for Permissions service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Permissions_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Permissions",
+	HandlerType: (*PermissionsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateRole",
+			Handler:    _Permissions_CreateRole_Handler,
+		},
+		{
+			MethodName: "SetRolePermissions",
+			Handler:    _Permissions_SetRolePermissions_Handler,
+		},
+		{
+			MethodName: "DeleteRole",
+			Handler:    _Permissions_DeleteRole_Handler,
+		},
+		{
+			MethodName: "ListRoles",
+			Handler:    _Permissions_ListRoles_Handler,
+		},
+		{
+			MethodName: "AssignRole",
+			Handler:    _Permissions_AssignRole_Handler,
+		},
+		{
+			MethodName: "RevokeRole",
+			Handler:    _Permissions_RevokeRole_Handler,
+		},
+		{
+			MethodName: "GetUserRoles",
+			Handler:    _Permissions_GetUserRoles_Handler,
+		},
+		{
+			MethodName: "CheckPermission",
+			Handler:    _Permissions_CheckPermission_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
+
+const (
+	Groups_CreateGroup_FullMethodName       = "/auth.Groups/CreateGroup"
+	Groups_GetGroup_FullMethodName          = "/auth.Groups/GetGroup"
+	Groups_DeleteGroup_FullMethodName       = "/auth.Groups/DeleteGroup"
+	Groups_AddGroupMember_FullMethodName    = "/auth.Groups/AddGroupMember"
+	Groups_RemoveGroupMember_FullMethodName = "/auth.Groups/RemoveGroupMember"
+	Groups_AddSubgroup_FullMethodName       = "/auth.Groups/AddSubgroup"
+	Groups_RemoveSubgroup_FullMethodName    = "/auth.Groups/RemoveSubgroup"
+	Groups_AssignGroupRole_FullMethodName   = "/auth.Groups/AssignGroupRole"
+	Groups_RevokeGroupRole_FullMethodName   = "/auth.Groups/RevokeGroupRole"
+	Groups_SetGroupAdmin_FullMethodName     = "/auth.Groups/SetGroupAdmin"
+	Groups_ListUserGroups_FullMethodName    = "/auth.Groups/ListUserGroups"
+)
+
+// GroupsClient is the client API for Groups service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GroupsClient interface {
+	// CreateGroup creates a new group
+	CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*CreateGroupResponse, error)
+	// GetGroup returns a group with its direct members, subgroups and roles
+	GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*GetGroupResponse, error)
+	// DeleteGroup deletes a group
+	DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error)
+	// AddGroupMember adds a user to a group
+	AddGroupMember(ctx context.Context, in *GroupMemberRequest, opts ...grpc.CallOption) (*GroupMemberResponse, error)
+	// RemoveGroupMember removes a user from a group
+	RemoveGroupMember(ctx context.Context, in *GroupMemberRequest, opts ...grpc.CallOption) (*GroupMemberResponse, error)
+	// AddSubgroup nests a group into another group. Cycles are not allowed.
+	AddSubgroup(ctx context.Context, in *SubgroupRequest, opts ...grpc.CallOption) (*SubgroupResponse, error)
+	// RemoveSubgroup removes a nested group from a group
+	RemoveSubgroup(ctx context.Context, in *SubgroupRequest, opts ...grpc.CallOption) (*SubgroupResponse, error)
+	// AssignGroupRole grants a role to all members of a group
+	AssignGroupRole(ctx context.Context, in *GroupRoleRequest, opts ...grpc.CallOption) (*GroupRoleResponse, error)
+	// RevokeGroupRole revokes a role from a group
+	RevokeGroupRole(ctx context.Context, in *GroupRoleRequest, opts ...grpc.CallOption) (*GroupRoleResponse, error)
+	// SetGroupAdmin makes all members of a group admins (or revokes it)
+	SetGroupAdmin(ctx context.Context, in *SetGroupAdminRequest, opts ...grpc.CallOption) (*SetGroupAdminResponse, error)
+	// ListUserGroups returns all groups of a user, including groups inherited via nesting
+	ListUserGroups(ctx context.Context, in *ListUserGroupsRequest, opts ...grpc.CallOption) (*ListUserGroupsResponse, error)
+}
+
+type groupsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGroupsClient(cc grpc.ClientConnInterface) GroupsClient {
+	return &groupsClient{cc}
+}
+
+func (c *groupsClient) CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*CreateGroupResponse, error) {
+	out := new(CreateGroupResponse)
+	err := c.cc.Invoke(ctx, Groups_CreateGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*GetGroupResponse, error) {
+	out := new(GetGroupResponse)
+	err := c.cc.Invoke(ctx, Groups_GetGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error) {
+	out := new(DeleteGroupResponse)
+	err := c.cc.Invoke(ctx, Groups_DeleteGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) AddGroupMember(ctx context.Context, in *GroupMemberRequest, opts ...grpc.CallOption) (*GroupMemberResponse, error) {
+	out := new(GroupMemberResponse)
+	err := c.cc.Invoke(ctx, Groups_AddGroupMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) RemoveGroupMember(ctx context.Context, in *GroupMemberRequest, opts ...grpc.CallOption) (*GroupMemberResponse, error) {
+	out := new(GroupMemberResponse)
+	err := c.cc.Invoke(ctx, Groups_RemoveGroupMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) AddSubgroup(ctx context.Context, in *SubgroupRequest, opts ...grpc.CallOption) (*SubgroupResponse, error) {
+	out := new(SubgroupResponse)
+	err := c.cc.Invoke(ctx, Groups_AddSubgroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) RemoveSubgroup(ctx context.Context, in *SubgroupRequest, opts ...grpc.CallOption) (*SubgroupResponse, error) {
+	out := new(SubgroupResponse)
+	err := c.cc.Invoke(ctx, Groups_RemoveSubgroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) AssignGroupRole(ctx context.Context, in *GroupRoleRequest, opts ...grpc.CallOption) (*GroupRoleResponse, error) {
+	out := new(GroupRoleResponse)
+	err := c.cc.Invoke(ctx, Groups_AssignGroupRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) RevokeGroupRole(ctx context.Context, in *GroupRoleRequest, opts ...grpc.CallOption) (*GroupRoleResponse, error) {
+	out := new(GroupRoleResponse)
+	err := c.cc.Invoke(ctx, Groups_RevokeGroupRole_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) SetGroupAdmin(ctx context.Context, in *SetGroupAdminRequest, opts ...grpc.CallOption) (*SetGroupAdminResponse, error) {
+	out := new(SetGroupAdminResponse)
+	err := c.cc.Invoke(ctx, Groups_SetGroupAdmin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupsClient) ListUserGroups(ctx context.Context, in *ListUserGroupsRequest, opts ...grpc.CallOption) (*ListUserGroupsResponse, error) {
+	out := new(ListUserGroupsResponse)
+	err := c.cc.Invoke(ctx, Groups_ListUserGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GroupsServer is the server API for Groups service.
+// All implementations must embed UnimplementedGroupsServer
+// for forward compatibility
+type GroupsServer interface {
+	// CreateGroup creates a new group
+	CreateGroup(context.Context, *CreateGroupRequest) (*CreateGroupResponse, error)
+	// GetGroup returns a group with its direct members, subgroups and roles
+	GetGroup(context.Context, *GetGroupRequest) (*GetGroupResponse, error)
+	// DeleteGroup deletes a group
+	DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error)
+	// AddGroupMember adds a user to a group
+	AddGroupMember(context.Context, *GroupMemberRequest) (*GroupMemberResponse, error)
+	// RemoveGroupMember removes a user from a group
+	RemoveGroupMember(context.Context, *GroupMemberRequest) (*GroupMemberResponse, error)
+	// AddSubgroup nests a group into another group. Cycles are not allowed.
+	AddSubgroup(context.Context, *SubgroupRequest) (*SubgroupResponse, error)
+	// RemoveSubgroup removes a nested group from a group
+	RemoveSubgroup(context.Context, *SubgroupRequest) (*SubgroupResponse, error)
+	// AssignGroupRole grants a role to all members of a group
+	AssignGroupRole(context.Context, *GroupRoleRequest) (*GroupRoleResponse, error)
+	// RevokeGroupRole revokes a role from a group
+	RevokeGroupRole(context.Context, *GroupRoleRequest) (*GroupRoleResponse, error)
+	// SetGroupAdmin makes all members of a group admins (or revokes it)
+	SetGroupAdmin(context.Context, *SetGroupAdminRequest) (*SetGroupAdminResponse, error)
+	// ListUserGroups returns all groups of a user, including groups inherited via nesting
+	ListUserGroups(context.Context, *ListUserGroupsRequest) (*ListUserGroupsResponse, error)
+	mustEmbedUnimplementedGroupsServer()
+}
+
+// UnimplementedGroupsServer must be embedded to have forward compatible implementations.
+type UnimplementedGroupsServer struct {
+}
+
+func (UnimplementedGroupsServer) CreateGroup(context.Context, *CreateGroupRequest) (*CreateGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGroup not implemented")
+}
+func (UnimplementedGroupsServer) GetGroup(context.Context, *GetGroupRequest) (*GetGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroup not implemented")
+}
+func (UnimplementedGroupsServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (UnimplementedGroupsServer) AddGroupMember(context.Context, *GroupMemberRequest) (*GroupMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddGroupMember not implemented")
+}
+func (UnimplementedGroupsServer) RemoveGroupMember(context.Context, *GroupMemberRequest) (*GroupMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveGroupMember not implemented")
+}
+func (UnimplementedGroupsServer) AddSubgroup(context.Context, *SubgroupRequest) (*SubgroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddSubgroup not implemented")
+}
+func (UnimplementedGroupsServer) RemoveSubgroup(context.Context, *SubgroupRequest) (*SubgroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveSubgroup not implemented")
+}
+func (UnimplementedGroupsServer) AssignGroupRole(context.Context, *GroupRoleRequest) (*GroupRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignGroupRole not implemented")
+}
+func (UnimplementedGroupsServer) RevokeGroupRole(context.Context, *GroupRoleRequest) (*GroupRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeGroupRole not implemented")
+}
+func (UnimplementedGroupsServer) SetGroupAdmin(context.Context, *SetGroupAdminRequest) (*SetGroupAdminResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetGroupAdmin not implemented")
+}
+func (UnimplementedGroupsServer) ListUserGroups(context.Context, *ListUserGroupsRequest) (*ListUserGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserGroups not implemented")
+}
+func (UnimplementedGroupsServer) mustEmbedUnimplementedGroupsServer() {}
+
+// UnsafeGroupsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GroupsServer will
+// result in compilation errors.
+type UnsafeGroupsServer interface {
+	mustEmbedUnimplementedGroupsServer()
+}
+
+func RegisterGroupsServer(s grpc.ServiceRegistrar, srv GroupsServer) {
+	s.RegisterService(&Groups_ServiceDesc, srv)
+}
+
+func _Groups_CreateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).CreateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_CreateGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).CreateGroup(ctx, req.(*CreateGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_GetGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).GetGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_GetGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).GetGroup(ctx, req.(*GetGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_DeleteGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_AddGroupMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).AddGroupMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_AddGroupMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).AddGroupMember(ctx, req.(*GroupMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_RemoveGroupMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).RemoveGroupMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_RemoveGroupMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).RemoveGroupMember(ctx, req.(*GroupMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_AddSubgroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubgroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).AddSubgroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_AddSubgroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).AddSubgroup(ctx, req.(*SubgroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_RemoveSubgroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubgroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).RemoveSubgroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_RemoveSubgroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).RemoveSubgroup(ctx, req.(*SubgroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_AssignGroupRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).AssignGroupRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_AssignGroupRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).AssignGroupRole(ctx, req.(*GroupRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_RevokeGroupRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).RevokeGroupRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_RevokeGroupRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).RevokeGroupRole(ctx, req.(*GroupRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_SetGroupAdmin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetGroupAdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).SetGroupAdmin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_SetGroupAdmin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).SetGroupAdmin(ctx, req.(*SetGroupAdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Groups_ListUserGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUserGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupsServer).ListUserGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Groups_ListUserGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupsServer).ListUserGroups(ctx, req.(*ListUserGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Groups_ServiceDesc is the grpc.ServiceDesc for Groups service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Groups_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Groups",
+	HandlerType: (*GroupsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateGroup",
+			Handler:    _Groups_CreateGroup_Handler,
+		},
+		{
+			MethodName: "GetGroup",
+			Handler:    _Groups_GetGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _Groups_DeleteGroup_Handler,
+		},
+		{
+			MethodName: "AddGroupMember",
+			Handler:    _Groups_AddGroupMember_Handler,
+		},
+		{
+			MethodName: "RemoveGroupMember",
+			Handler:    _Groups_RemoveGroupMember_Handler,
+		},
+		{
+			MethodName: "AddSubgroup",
+			Handler:    _Groups_AddSubgroup_Handler,
+		},
+		{
+			MethodName: "RemoveSubgroup",
+			Handler:    _Groups_RemoveSubgroup_Handler,
+		},
+		{
+			MethodName: "AssignGroupRole",
+			Handler:    _Groups_AssignGroupRole_Handler,
+		},
+		{
+			MethodName: "RevokeGroupRole",
+			Handler:    _Groups_RevokeGroupRole_Handler,
+		},
+		{
+			MethodName: "SetGroupAdmin",
+			Handler:    _Groups_SetGroupAdmin_Handler,
+		},
+		{
+			MethodName: "ListUserGroups",
+			Handler:    _Groups_ListUserGroups_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
+
+const (
+	Apps_CreateApp_FullMethodName           = "/auth.Apps/CreateApp"
+	Apps_ListApps_FullMethodName            = "/auth.Apps/ListApps"
+	Apps_UpdateApp_FullMethodName           = "/auth.Apps/UpdateApp"
+	Apps_SetAppDisabled_FullMethodName      = "/auth.Apps/SetAppDisabled"
+	Apps_DeleteApp_FullMethodName           = "/auth.Apps/DeleteApp"
+	Apps_RotateAppSecret_FullMethodName     = "/auth.Apps/RotateAppSecret"
+	Apps_SetAppTokenSettings_FullMethodName = "/auth.Apps/SetAppTokenSettings"
+	Apps_SetAppAccess_FullMethodName        = "/auth.Apps/SetAppAccess"
+	Apps_AddAppMember_FullMethodName        = "/auth.Apps/AddAppMember"
+	Apps_RemoveAppMember_FullMethodName     = "/auth.Apps/RemoveAppMember"
+	Apps_ListAppMembers_FullMethodName      = "/auth.Apps/ListAppMembers"
+)
+
+// AppsClient is the client API for Apps service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AppsClient interface {
+	// CreateApp creates an app and returns its generated secret
+	CreateApp(ctx context.Context, in *CreateAppRequest, opts ...grpc.CallOption) (*CreateAppResponse, error)
+	// ListApps returns a page of the organization apps
+	ListApps(ctx context.Context, in *ListAppsRequest, opts ...grpc.CallOption) (*ListAppsResponse, error)
+	// UpdateApp changes name, kind and SAML settings of an app
+	UpdateApp(ctx context.Context, in *UpdateAppRequest, opts ...grpc.CallOption) (*UpdateAppResponse, error)
+	// SetAppDisabled disables or enables an app
+	SetAppDisabled(ctx context.Context, in *SetAppDisabledRequest, opts ...grpc.CallOption) (*SetAppDisabledResponse, error)
+	// DeleteApp deletes an app with its roles, members, invitations and policies
+	DeleteApp(ctx context.Context, in *DeleteAppRequest, opts ...grpc.CallOption) (*DeleteAppResponse, error)
+	// RotateAppSecret issues a new app secret; the previous one stays valid for a grace period
+	RotateAppSecret(ctx context.Context, in *RotateAppSecretRequest, opts ...grpc.CallOption) (*RotateAppSecretResponse, error)
+	// SetAppTokenSettings changes lifetime, signing algorithm and claims of tokens issued for an app
+	SetAppTokenSettings(ctx context.Context, in *SetAppTokenSettingsRequest, opts ...grpc.CallOption) (*SetAppTokenSettingsResponse, error)
+	// SetAppAccess changes the access mode and allowed email domains of an app
+	SetAppAccess(ctx context.Context, in *SetAppAccessRequest, opts ...grpc.CallOption) (*SetAppAccessResponse, error)
+	// AddAppMember allows a user to log into an app
+	AddAppMember(ctx context.Context, in *AppMemberRequest, opts ...grpc.CallOption) (*AppMemberResponse, error)
+	// RemoveAppMember revokes app membership of a user
+	RemoveAppMember(ctx context.Context, in *AppMemberRequest, opts ...grpc.CallOption) (*AppMemberResponse, error)
+	// ListAppMembers returns IDs of the app members
+	ListAppMembers(ctx context.Context, in *ListAppMembersRequest, opts ...grpc.CallOption) (*ListAppMembersResponse, error)
+}
+
+type appsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAppsClient(cc grpc.ClientConnInterface) AppsClient {
+	return &appsClient{cc}
+}
+
+func (c *appsClient) CreateApp(ctx context.Context, in *CreateAppRequest, opts ...grpc.CallOption) (*CreateAppResponse, error) {
+	out := new(CreateAppResponse)
+	err := c.cc.Invoke(ctx, Apps_CreateApp_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) ListApps(ctx context.Context, in *ListAppsRequest, opts ...grpc.CallOption) (*ListAppsResponse, error) {
+	out := new(ListAppsResponse)
+	err := c.cc.Invoke(ctx, Apps_ListApps_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) UpdateApp(ctx context.Context, in *UpdateAppRequest, opts ...grpc.CallOption) (*UpdateAppResponse, error) {
+	out := new(UpdateAppResponse)
+	err := c.cc.Invoke(ctx, Apps_UpdateApp_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) SetAppDisabled(ctx context.Context, in *SetAppDisabledRequest, opts ...grpc.CallOption) (*SetAppDisabledResponse, error) {
+	out := new(SetAppDisabledResponse)
+	err := c.cc.Invoke(ctx, Apps_SetAppDisabled_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) DeleteApp(ctx context.Context, in *DeleteAppRequest, opts ...grpc.CallOption) (*DeleteAppResponse, error) {
+	out := new(DeleteAppResponse)
+	err := c.cc.Invoke(ctx, Apps_DeleteApp_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) RotateAppSecret(ctx context.Context, in *RotateAppSecretRequest, opts ...grpc.CallOption) (*RotateAppSecretResponse, error) {
+	out := new(RotateAppSecretResponse)
+	err := c.cc.Invoke(ctx, Apps_RotateAppSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) SetAppTokenSettings(ctx context.Context, in *SetAppTokenSettingsRequest, opts ...grpc.CallOption) (*SetAppTokenSettingsResponse, error) {
+	out := new(SetAppTokenSettingsResponse)
+	err := c.cc.Invoke(ctx, Apps_SetAppTokenSettings_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) SetAppAccess(ctx context.Context, in *SetAppAccessRequest, opts ...grpc.CallOption) (*SetAppAccessResponse, error) {
+	out := new(SetAppAccessResponse)
+	err := c.cc.Invoke(ctx, Apps_SetAppAccess_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) AddAppMember(ctx context.Context, in *AppMemberRequest, opts ...grpc.CallOption) (*AppMemberResponse, error) {
+	out := new(AppMemberResponse)
+	err := c.cc.Invoke(ctx, Apps_AddAppMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) RemoveAppMember(ctx context.Context, in *AppMemberRequest, opts ...grpc.CallOption) (*AppMemberResponse, error) {
+	out := new(AppMemberResponse)
+	err := c.cc.Invoke(ctx, Apps_RemoveAppMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appsClient) ListAppMembers(ctx context.Context, in *ListAppMembersRequest, opts ...grpc.CallOption) (*ListAppMembersResponse, error) {
+	out := new(ListAppMembersResponse)
+	err := c.cc.Invoke(ctx, Apps_ListAppMembers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AppsServer is the server API for Apps service.
+// All implementations must embed UnimplementedAppsServer
+// for forward compatibility
+type AppsServer interface {
+	// CreateApp creates an app and returns its generated secret
+	CreateApp(context.Context, *CreateAppRequest) (*CreateAppResponse, error)
+	// ListApps returns a page of the organization apps
+	ListApps(context.Context, *ListAppsRequest) (*ListAppsResponse, error)
+	// UpdateApp changes name, kind and SAML settings of an app
+	UpdateApp(context.Context, *UpdateAppRequest) (*UpdateAppResponse, error)
+	// SetAppDisabled disables or enables an app
+	SetAppDisabled(context.Context, *SetAppDisabledRequest) (*SetAppDisabledResponse, error)
+	// DeleteApp deletes an app with its roles, members, invitations and policies
+	DeleteApp(context.Context, *DeleteAppRequest) (*DeleteAppResponse, error)
+	// RotateAppSecret issues a new app secret; the previous one stays valid for a grace period
+	RotateAppSecret(context.Context, *RotateAppSecretRequest) (*RotateAppSecretResponse, error)
+	// SetAppTokenSettings changes lifetime, signing algorithm and claims of tokens issued for an app
+	SetAppTokenSettings(context.Context, *SetAppTokenSettingsRequest) (*SetAppTokenSettingsResponse, error)
+	// SetAppAccess changes the access mode and allowed email domains of an app
+	SetAppAccess(context.Context, *SetAppAccessRequest) (*SetAppAccessResponse, error)
+	// AddAppMember allows a user to log into an app
+	AddAppMember(context.Context, *AppMemberRequest) (*AppMemberResponse, error)
+	// RemoveAppMember revokes app membership of a user
+	RemoveAppMember(context.Context, *AppMemberRequest) (*AppMemberResponse, error)
+	// ListAppMembers returns IDs of the app members
+	ListAppMembers(context.Context, *ListAppMembersRequest) (*ListAppMembersResponse, error)
+	mustEmbedUnimplementedAppsServer()
+}
+
+// UnimplementedAppsServer must be embedded to have forward compatible implementations.
+type UnimplementedAppsServer struct {
+}
+
+func (UnimplementedAppsServer) CreateApp(context.Context, *CreateAppRequest) (*CreateAppResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateApp not implemented")
+}
+func (UnimplementedAppsServer) ListApps(context.Context, *ListAppsRequest) (*ListAppsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListApps not implemented")
+}
+func (UnimplementedAppsServer) UpdateApp(context.Context, *UpdateAppRequest) (*UpdateAppResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateApp not implemented")
+}
+func (UnimplementedAppsServer) SetAppDisabled(context.Context, *SetAppDisabledRequest) (*SetAppDisabledResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAppDisabled not implemented")
+}
+func (UnimplementedAppsServer) DeleteApp(context.Context, *DeleteAppRequest) (*DeleteAppResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteApp not implemented")
+}
+func (UnimplementedAppsServer) RotateAppSecret(context.Context, *RotateAppSecretRequest) (*RotateAppSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateAppSecret not implemented")
+}
+func (UnimplementedAppsServer) SetAppTokenSettings(context.Context, *SetAppTokenSettingsRequest) (*SetAppTokenSettingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAppTokenSettings not implemented")
+}
+func (UnimplementedAppsServer) SetAppAccess(context.Context, *SetAppAccessRequest) (*SetAppAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAppAccess not implemented")
+}
+func (UnimplementedAppsServer) AddAppMember(context.Context, *AppMemberRequest) (*AppMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAppMember not implemented")
+}
+func (UnimplementedAppsServer) RemoveAppMember(context.Context, *AppMemberRequest) (*AppMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveAppMember not implemented")
+}
+func (UnimplementedAppsServer) ListAppMembers(context.Context, *ListAppMembersRequest) (*ListAppMembersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAppMembers not implemented")
+}
+func (UnimplementedAppsServer) mustEmbedUnimplementedAppsServer() {}
+
+// UnsafeAppsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AppsServer will
+// result in compilation errors.
+type UnsafeAppsServer interface {
+	mustEmbedUnimplementedAppsServer()
+}
+
+func RegisterAppsServer(s grpc.ServiceRegistrar, srv AppsServer) {
+	s.RegisterService(&Apps_ServiceDesc, srv)
+}
+
+func _Apps_CreateApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAppRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).CreateApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_CreateApp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).CreateApp(ctx, req.(*CreateAppRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_ListApps_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAppsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).ListApps(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_ListApps_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).ListApps(ctx, req.(*ListAppsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_UpdateApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAppRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).UpdateApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_UpdateApp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).UpdateApp(ctx, req.(*UpdateAppRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_SetAppDisabled_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAppDisabledRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).SetAppDisabled(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_SetAppDisabled_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).SetAppDisabled(ctx, req.(*SetAppDisabledRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_DeleteApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAppRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).DeleteApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_DeleteApp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).DeleteApp(ctx, req.(*DeleteAppRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_RotateAppSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateAppSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).RotateAppSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_RotateAppSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).RotateAppSecret(ctx, req.(*RotateAppSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_SetAppTokenSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAppTokenSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).SetAppTokenSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_SetAppTokenSettings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).SetAppTokenSettings(ctx, req.(*SetAppTokenSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_SetAppAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAppAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).SetAppAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_SetAppAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).SetAppAccess(ctx, req.(*SetAppAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_AddAppMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).AddAppMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_AddAppMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).AddAppMember(ctx, req.(*AppMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_RemoveAppMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).RemoveAppMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_RemoveAppMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).RemoveAppMember(ctx, req.(*AppMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Apps_ListAppMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAppMembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppsServer).ListAppMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Apps_ListAppMembers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppsServer).ListAppMembers(ctx, req.(*ListAppMembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Apps_ServiceDesc is the grpc.ServiceDesc for Apps service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Apps_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Apps",
+	HandlerType: (*AppsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateApp",
+			Handler:    _Apps_CreateApp_Handler,
+		},
+		{
+			MethodName: "ListApps",
+			Handler:    _Apps_ListApps_Handler,
+		},
+		{
+			MethodName: "UpdateApp",
+			Handler:    _Apps_UpdateApp_Handler,
+		},
+		{
+			MethodName: "SetAppDisabled",
+			Handler:    _Apps_SetAppDisabled_Handler,
+		},
+		{
+			MethodName: "DeleteApp",
+			Handler:    _Apps_DeleteApp_Handler,
+		},
+		{
+			MethodName: "RotateAppSecret",
+			Handler:    _Apps_RotateAppSecret_Handler,
+		},
+		{
+			MethodName: "SetAppTokenSettings",
+			Handler:    _Apps_SetAppTokenSettings_Handler,
+		},
+		{
+			MethodName: "SetAppAccess",
+			Handler:    _Apps_SetAppAccess_Handler,
+		},
+		{
+			MethodName: "AddAppMember",
+			Handler:    _Apps_AddAppMember_Handler,
+		},
+		{
+			MethodName: "RemoveAppMember",
+			Handler:    _Apps_RemoveAppMember_Handler,
+		},
+		{
+			MethodName: "ListAppMembers",
+			Handler:    _Apps_ListAppMembers_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
+
+const (
+	Invitations_CreateInvitation_FullMethodName = "/auth.Invitations/CreateInvitation"
+	Invitations_ListInvitations_FullMethodName  = "/auth.Invitations/ListInvitations"
+	Invitations_RevokeInvitation_FullMethodName = "/auth.Invitations/RevokeInvitation"
+	Invitations_AcceptInvitation_FullMethodName = "/auth.Invitations/AcceptInvitation"
+)
+
+// InvitationsClient is the client API for Invitations service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type InvitationsClient interface {
+	// CreateInvitation invites a person into an app and sends them an email with the invitation token
+	CreateInvitation(ctx context.Context, in *CreateInvitationRequest, opts ...grpc.CallOption) (*CreateInvitationResponse, error)
+	// ListInvitations returns all invitations to an app
+	ListInvitations(ctx context.Context, in *ListInvitationsRequest, opts ...grpc.CallOption) (*ListInvitationsResponse, error)
+	// RevokeInvitation revokes a pending invitation
+	RevokeInvitation(ctx context.Context, in *RevokeInvitationRequest, opts ...grpc.CallOption) (*RevokeInvitationResponse, error)
+	// AcceptInvitation accepts an invitation by its token (no authorization token needed)
+	AcceptInvitation(ctx context.Context, in *AcceptInvitationRequest, opts ...grpc.CallOption) (*AcceptInvitationResponse, error)
+}
+
+type invitationsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInvitationsClient(cc grpc.ClientConnInterface) InvitationsClient {
+	return &invitationsClient{cc}
+}
+
+func (c *invitationsClient) CreateInvitation(ctx context.Context, in *CreateInvitationRequest, opts ...grpc.CallOption) (*CreateInvitationResponse, error) {
+	out := new(CreateInvitationResponse)
+	err := c.cc.Invoke(ctx, Invitations_CreateInvitation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *invitationsClient) ListInvitations(ctx context.Context, in *ListInvitationsRequest, opts ...grpc.CallOption) (*ListInvitationsResponse, error) {
+	out := new(ListInvitationsResponse)
+	err := c.cc.Invoke(ctx, Invitations_ListInvitations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *invitationsClient) RevokeInvitation(ctx context.Context, in *RevokeInvitationRequest, opts ...grpc.CallOption) (*RevokeInvitationResponse, error) {
+	out := new(RevokeInvitationResponse)
+	err := c.cc.Invoke(ctx, Invitations_RevokeInvitation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *invitationsClient) AcceptInvitation(ctx context.Context, in *AcceptInvitationRequest, opts ...grpc.CallOption) (*AcceptInvitationResponse, error) {
+	out := new(AcceptInvitationResponse)
+	err := c.cc.Invoke(ctx, Invitations_AcceptInvitation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InvitationsServer is the server API for Invitations service.
+// All implementations must embed UnimplementedInvitationsServer
+// for forward compatibility
+type InvitationsServer interface {
+	// CreateInvitation invites a person into an app and sends them an email with the invitation token
+	CreateInvitation(context.Context, *CreateInvitationRequest) (*CreateInvitationResponse, error)
+	// ListInvitations returns all invitations to an app
+	ListInvitations(context.Context, *ListInvitationsRequest) (*ListInvitationsResponse, error)
+	// RevokeInvitation revokes a pending invitation
+	RevokeInvitation(context.Context, *RevokeInvitationRequest) (*RevokeInvitationResponse, error)
+	// AcceptInvitation accepts an invitation by its token (no authorization token needed)
+	AcceptInvitation(context.Context, *AcceptInvitationRequest) (*AcceptInvitationResponse, error)
+	mustEmbedUnimplementedInvitationsServer()
+}
+
+// UnimplementedInvitationsServer must be embedded to have forward compatible implementations.
+type UnimplementedInvitationsServer struct {
+}
+
+func (UnimplementedInvitationsServer) CreateInvitation(context.Context, *CreateInvitationRequest) (*CreateInvitationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateInvitation not implemented")
+}
+func (UnimplementedInvitationsServer) ListInvitations(context.Context, *ListInvitationsRequest) (*ListInvitationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInvitations not implemented")
+}
+func (UnimplementedInvitationsServer) RevokeInvitation(context.Context, *RevokeInvitationRequest) (*RevokeInvitationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeInvitation not implemented")
+}
+func (UnimplementedInvitationsServer) AcceptInvitation(context.Context, *AcceptInvitationRequest) (*AcceptInvitationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptInvitation not implemented")
+}
+func (UnimplementedInvitationsServer) mustEmbedUnimplementedInvitationsServer() {}
+
+// UnsafeInvitationsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InvitationsServer will
+// result in compilation errors.
+type UnsafeInvitationsServer interface {
+	mustEmbedUnimplementedInvitationsServer()
+}
+
+func RegisterInvitationsServer(s grpc.ServiceRegistrar, srv InvitationsServer) {
+	s.RegisterService(&Invitations_ServiceDesc, srv)
+}
+
+func _Invitations_CreateInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInvitationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InvitationsServer).CreateInvitation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Invitations_CreateInvitation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InvitationsServer).CreateInvitation(ctx, req.(*CreateInvitationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Invitations_ListInvitations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInvitationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InvitationsServer).ListInvitations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Invitations_ListInvitations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InvitationsServer).ListInvitations(ctx, req.(*ListInvitationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Invitations_RevokeInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeInvitationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InvitationsServer).RevokeInvitation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Invitations_RevokeInvitation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InvitationsServer).RevokeInvitation(ctx, req.(*RevokeInvitationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Invitations_AcceptInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptInvitationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InvitationsServer).AcceptInvitation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Invitations_AcceptInvitation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InvitationsServer).AcceptInvitation(ctx, req.(*AcceptInvitationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Invitations_ServiceDesc is the grpc.ServiceDesc for Invitations service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Invitations_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Invitations",
+	HandlerType: (*InvitationsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateInvitation",
+			Handler:    _Invitations_CreateInvitation_Handler,
+		},
+		{
+			MethodName: "ListInvitations",
+			Handler:    _Invitations_ListInvitations_Handler,
+		},
+		{
+			MethodName: "RevokeInvitation",
+			Handler:    _Invitations_RevokeInvitation_Handler,
+		},
+		{
+			MethodName: "AcceptInvitation",
+			Handler:    _Invitations_AcceptInvitation_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
+
+const (
+	Policies_SetPolicy_FullMethodName         = "/auth.Policies/SetPolicy"
+	Policies_GetPolicy_FullMethodName         = "/auth.Policies/GetPolicy"
+	Policies_SetUserAttributes_FullMethodName = "/auth.Policies/SetUserAttributes"
+	Policies_Authorize_FullMethodName         = "/auth.Policies/Authorize"
+)
+
+// PoliciesClient is the client API for Policies service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PoliciesClient interface {
+	// SetPolicy validates a policy document and saves it as a new version of the app policy
+	SetPolicy(ctx context.Context, in *SetPolicyRequest, opts ...grpc.CallOption) (*SetPolicyResponse, error)
+	// GetPolicy returns a version of the app policy (the current one by default)
+	GetPolicy(ctx context.Context, in *GetPolicyRequest, opts ...grpc.CallOption) (*GetPolicyResponse, error)
+	// SetUserAttributes replaces the attributes of a user used by policies
+	SetUserAttributes(ctx context.Context, in *SetUserAttributesRequest, opts ...grpc.CallOption) (*SetUserAttributesResponse, error)
+	// Authorize decides whether a user may perform an action on a resource and explains the decision.
+	// С заполненным dry_run_policy вычисляет черновик политики вместо действующей (только для администратора).
+	Authorize(ctx context.Context, in *AuthorizeRequest, opts ...grpc.CallOption) (*AuthorizeResponse, error)
+}
+
+type policiesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPoliciesClient(cc grpc.ClientConnInterface) PoliciesClient {
+	return &policiesClient{cc}
+}
+
+func (c *policiesClient) SetPolicy(ctx context.Context, in *SetPolicyRequest, opts ...grpc.CallOption) (*SetPolicyResponse, error) {
+	out := new(SetPolicyResponse)
+	err := c.cc.Invoke(ctx, Policies_SetPolicy_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policiesClient) GetPolicy(ctx context.Context, in *GetPolicyRequest, opts ...grpc.CallOption) (*GetPolicyResponse, error) {
+	out := new(GetPolicyResponse)
+	err := c.cc.Invoke(ctx, Policies_GetPolicy_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policiesClient) SetUserAttributes(ctx context.Context, in *SetUserAttributesRequest, opts ...grpc.CallOption) (*SetUserAttributesResponse, error) {
+	out := new(SetUserAttributesResponse)
+	err := c.cc.Invoke(ctx, Policies_SetUserAttributes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policiesClient) Authorize(ctx context.Context, in *AuthorizeRequest, opts ...grpc.CallOption) (*AuthorizeResponse, error) {
+	out := new(AuthorizeResponse)
+	err := c.cc.Invoke(ctx, Policies_Authorize_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PoliciesServer is the server API for Policies service.
+// All implementations must embed UnimplementedPoliciesServer
+// for forward compatibility
+type PoliciesServer interface {
+	// SetPolicy validates a policy document and saves it as a new version of the app policy
+	SetPolicy(context.Context, *SetPolicyRequest) (*SetPolicyResponse, error)
+	// GetPolicy returns a version of the app policy (the current one by default)
+	GetPolicy(context.Context, *GetPolicyRequest) (*GetPolicyResponse, error)
+	// SetUserAttributes replaces the attributes of a user used by policies
+	SetUserAttributes(context.Context, *SetUserAttributesRequest) (*SetUserAttributesResponse, error)
+	// Authorize decides whether a user may perform an action on a resource and explains the decision.
+	// С заполненным dry_run_policy вычисляет черновик политики вместо действующей (только для администратора).
+	Authorize(context.Context, *AuthorizeRequest) (*AuthorizeResponse, error)
+	mustEmbedUnimplementedPoliciesServer()
+}
+
+// UnimplementedPoliciesServer must be embedded to have forward compatible implementations.
+type UnimplementedPoliciesServer struct {
+}
+
+func (UnimplementedPoliciesServer) SetPolicy(context.Context, *SetPolicyRequest) (*SetPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPolicy not implemented")
+}
+func (UnimplementedPoliciesServer) GetPolicy(context.Context, *GetPolicyRequest) (*GetPolicyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPolicy not implemented")
+}
+func (UnimplementedPoliciesServer) SetUserAttributes(context.Context, *SetUserAttributesRequest) (*SetUserAttributesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserAttributes not implemented")
+}
+func (UnimplementedPoliciesServer) Authorize(context.Context, *AuthorizeRequest) (*AuthorizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Authorize not implemented")
+}
+func (UnimplementedPoliciesServer) mustEmbedUnimplementedPoliciesServer() {}
+
+// UnsafePoliciesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PoliciesServer will
+// result in compilation errors.
+type UnsafePoliciesServer interface {
+	mustEmbedUnimplementedPoliciesServer()
+}
+
+func RegisterPoliciesServer(s grpc.ServiceRegistrar, srv PoliciesServer) {
+	s.RegisterService(&Policies_ServiceDesc, srv)
+}
+
+func _Policies_SetPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServer).SetPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Policies_SetPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServer).SetPolicy(ctx, req.(*SetPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policies_GetPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServer).GetPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Policies_GetPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServer).GetPolicy(ctx, req.(*GetPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policies_SetUserAttributes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserAttributesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServer).SetUserAttributes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Policies_SetUserAttributes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServer).SetUserAttributes(ctx, req.(*SetUserAttributesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Policies_Authorize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthorizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoliciesServer).Authorize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Policies_Authorize_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoliciesServer).Authorize(ctx, req.(*AuthorizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Policies_ServiceDesc is the grpc.ServiceDesc for Policies service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Policies_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.Policies",
+	HandlerType: (*PoliciesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetPolicy",
+			Handler:    _Policies_SetPolicy_Handler,
+		},
+		{
+			MethodName: "GetPolicy",
+			Handler:    _Policies_GetPolicy_Handler,
+		},
+		{
+			MethodName: "SetUserAttributes",
+			Handler:    _Policies_SetUserAttributes_Handler,
+		},
+		{
+			MethodName: "Authorize",
+			Handler:    _Policies_Authorize_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/sso.proto",
+}
@@ -0,0 +1 @@
+task generate
\ No newline at end of file
@@ -0,0 +1,172 @@
+// tests/app_access_test.go
+package tests
+
+import (
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+const restrictedAppID = 4 // Приложение, режим доступа которого меняет тест (см. tests/migrations)
+
+// Режимы доступа приложения restrictedAppID меняются последовательно в одном тесте,
+// чтобы параллельные тесты не мешали друг другу
+func TestAppAccess_Modes(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	memberID, memberEmail, memberPass := registerUser(ctx, t, st)
+	_, strangerEmail, strangerPass := registerUser(ctx, t, st)
+
+	domainEmail := gofakeit.Username() + "@allowed.example"
+	domainPass := randomFakePassword()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: domainEmail, Password: domainPass})
+	require.NoError(t, err)
+
+	loginApp := func(email string, pass string) error {
+		_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: restrictedAppID})
+		return err
+	}
+
+	// открытое приложение (по умолчанию): входят все
+	require.NoError(t, loginApp(strangerEmail, strangerPass))
+
+	// только по приглашениям
+	_, err = st.AppsClient.SetAppAccess(adminCtx, &ssov1.SetAppAccessRequest{
+		AppId:      restrictedAppID,
+		AccessMode: "invite_only",
+	})
+	require.NoError(t, err)
+
+	_, err = st.AppsClient.AddAppMember(adminCtx, &ssov1.AppMemberRequest{AppId: restrictedAppID, UserId: memberID})
+	require.NoError(t, err)
+
+	respMembers, err := st.AppsClient.ListAppMembers(adminCtx, &ssov1.ListAppMembersRequest{AppId: restrictedAppID})
+	require.NoError(t, err)
+	assert.Contains(t, respMembers.GetUserIds(), memberID)
+
+	require.NoError(t, loginApp(memberEmail, memberPass))
+
+	err = loginApp(strangerEmail, strangerPass)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access to the app denied")
+
+	err = loginApp(domainEmail, domainPass)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access to the app denied")
+
+	// неверный пароль по-прежнему отличается от запрета доступа
+	err = loginApp(strangerEmail, "wrong-password")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid email or password")
+
+	// по домену email: участники сохраняют доступ
+	_, err = st.AppsClient.SetAppAccess(adminCtx, &ssov1.SetAppAccessRequest{
+		AppId:          restrictedAppID,
+		AccessMode:     "domain",
+		AllowedDomains: []string{"Allowed.Example"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, loginApp(domainEmail, domainPass))
+	require.NoError(t, loginApp(memberEmail, memberPass))
+
+	err = loginApp(strangerEmail, strangerPass)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access to the app denied")
+
+	// исключённый участник теряет доступ
+	_, err = st.AppsClient.RemoveAppMember(adminCtx, &ssov1.AppMemberRequest{AppId: restrictedAppID, UserId: memberID})
+	require.NoError(t, err)
+
+	err = loginApp(memberEmail, memberPass)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access to the app denied")
+
+	// снова открываем приложение
+	_, err = st.AppsClient.SetAppAccess(adminCtx, &ssov1.SetAppAccessRequest{
+		AppId:      restrictedAppID,
+		AccessMode: "open",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, loginApp(strangerEmail, strangerPass))
+}
+
+func TestAppAccess_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Without token",
+			call: func() error {
+				_, err := st.AppsClient.SetAppAccess(ctx, &ssov1.SetAppAccessRequest{AppId: restrictedAppID, AccessMode: "open"})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Caller is not admin",
+			call: func() error {
+				_, err := st.AppsClient.AddAppMember(userCtx, &ssov1.AppMemberRequest{AppId: restrictedAppID, UserId: userID})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Unknown access mode",
+			call: func() error {
+				_, err := st.AppsClient.SetAppAccess(adminCtx, &ssov1.SetAppAccessRequest{AppId: restrictedAppID, AccessMode: "closed"})
+				return err
+			},
+			expectedErr: "invalid access mode",
+		},
+		{
+			name: "Domain mode without domains",
+			call: func() error {
+				_, err := st.AppsClient.SetAppAccess(adminCtx, &ssov1.SetAppAccessRequest{AppId: restrictedAppID, AccessMode: "domain"})
+				return err
+			},
+			expectedErr: "invalid access mode",
+		},
+		{
+			name: "App of another tenant",
+			call: func() error {
+				_, err := st.AppsClient.AddAppMember(adminCtx, &ssov1.AppMemberRequest{AppId: tenantAppID, UserId: userID})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Unknown user",
+			call: func() error {
+				_, err := st.AppsClient.AddAppMember(adminCtx, &ssov1.AppMemberRequest{AppId: restrictedAppID, UserId: 1 << 40})
+				return err
+			},
+			expectedErr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
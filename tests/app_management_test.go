@@ -0,0 +1,367 @@
+// tests/app_management_test.go
+package tests
+
+import (
+	"context"
+	"grpc-service-ref/tests/suite"
+	"testing"
+	"time"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+func TestAppManagement_Lifecycle(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	_, email, pass := registerUser(ctx, t, st)
+
+	name := "app-" + gofakeit.UUID()
+	respCreate, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: name})
+	require.NoError(t, err)
+	require.NotEmpty(t, respCreate.GetSecret())
+
+	app := respCreate.GetApp()
+	assert.Equal(t, name, app.GetName())
+	assert.Equal(t, "jwt", app.GetKind())
+	assert.Equal(t, "open", app.GetAccessMode())
+	assert.False(t, app.GetDisabled())
+
+	loginApp := func() (string, error) {
+		resp, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: app.GetId()})
+		return resp.GetToken(), err
+	}
+
+	// токен нового приложения подписан сгенерированным секретом
+	token, err := loginApp()
+	require.NoError(t, err)
+	_, err = jwt.Parse(token, func(token *jwt.Token) (any, error) {
+		return []byte(respCreate.GetSecret()), nil
+	})
+	require.NoError(t, err)
+
+	newName := name + "-renamed"
+	_, err = st.AppsClient.UpdateApp(adminCtx, &ssov1.UpdateAppRequest{AppId: app.GetId(), Name: newName})
+	require.NoError(t, err)
+
+	listed := findApp(t, st, adminCtx, app.GetId())
+	require.NotNil(t, listed)
+	assert.Equal(t, newName, listed.GetName())
+
+	// в отключённое приложение войти нельзя, но оно остаётся в списке
+	_, err = st.AppsClient.SetAppDisabled(adminCtx, &ssov1.SetAppDisabledRequest{AppId: app.GetId(), Disabled: true})
+	require.NoError(t, err)
+
+	_, err = loginApp()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app is disabled")
+
+	listed = findApp(t, st, adminCtx, app.GetId())
+	require.NotNil(t, listed)
+	assert.True(t, listed.GetDisabled())
+
+	_, err = st.AppsClient.SetAppDisabled(adminCtx, &ssov1.SetAppDisabledRequest{AppId: app.GetId(), Disabled: false})
+	require.NoError(t, err)
+	_, err = loginApp()
+	require.NoError(t, err)
+
+	_, err = st.AppsClient.DeleteApp(adminCtx, &ssov1.DeleteAppRequest{AppId: app.GetId()})
+	require.NoError(t, err)
+
+	_, err = loginApp()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app not found")
+	assert.Nil(t, findApp(t, st, adminCtx, app.GetId()))
+}
+
+// В приложение провижининга (вид "scim") не может войти никто: его секрет - bearer-токен SCIM-клиента
+func TestAppManagement_SCIMAppIsNotLoginable(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respCreate, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: "scim-" + gofakeit.UUID(), Kind: "scim"})
+	require.NoError(t, err)
+	assert.Equal(t, "scim", respCreate.GetApp().GetKind())
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: adminEmail, Password: adminPassword, AppId: respCreate.GetApp().GetId()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access to the app denied")
+}
+
+func TestAppManagement_RotateSecret(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respCreate, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: "app-" + gofakeit.UUID()})
+	require.NoError(t, err)
+	app := respCreate.GetApp()
+
+	loginApp := func() string {
+		t.Helper()
+
+		resp, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: adminEmail, Password: adminPassword, AppId: app.GetId()})
+		require.NoError(t, err)
+
+		return resp.GetToken()
+	}
+
+	// токен администратора, подписанный первым секретом приложения
+	firstToken := loginApp()
+
+	respRotate, err := st.AppsClient.RotateAppSecret(adminCtx, &ssov1.RotateAppSecretRequest{AppId: app.GetId()})
+	require.NoError(t, err)
+	require.NotEmpty(t, respRotate.GetSecret())
+	assert.NotEqual(t, respCreate.GetSecret(), respRotate.GetSecret())
+	assert.Greater(t, respRotate.GetPreviousSecretExpiresAt(), time.Now().Unix())
+
+	// новые токены подписываются новым секретом
+	secondToken := loginApp()
+	_, err = jwt.Parse(secondToken, func(token *jwt.Token) (any, error) {
+		return []byte(respRotate.GetSecret()), nil
+	})
+	require.NoError(t, err)
+
+	// а токены, подписанные прежним, пока принимаются
+	_, err = st.AppsClient.ListApps(withToken(ctx, firstToken), &ssov1.ListAppsRequest{Limit: 1})
+	require.NoError(t, err)
+
+	// после следующей ротации первый секрет перестаёт действовать сразу
+	_, err = st.AppsClient.RotateAppSecret(adminCtx, &ssov1.RotateAppSecretRequest{AppId: app.GetId()})
+	require.NoError(t, err)
+
+	_, err = st.AppsClient.ListApps(withToken(ctx, firstToken), &ssov1.ListAppsRequest{Limit: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid token")
+
+	_, err = st.AppsClient.ListApps(withToken(ctx, secondToken), &ssov1.ListAppsRequest{Limit: 1})
+	require.NoError(t, err)
+}
+
+func TestAppManagement_TokenSettings(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respCreate, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: "app-" + gofakeit.UUID()})
+	require.NoError(t, err)
+	app := respCreate.GetApp()
+	assert.Equal(t, "HS256", app.GetTokenSettings().GetAlgorithm())
+
+	userID, email, pass := registerUser(ctx, t, st)
+	_, err = st.PoliciesClient.SetUserAttributes(adminCtx, &ssov1.SetUserAttributesRequest{
+		UserId:     userID,
+		Attributes: map[string]string{"department": "sales", "level": "3"},
+	})
+	require.NoError(t, err)
+
+	settings := &ssov1.TokenSettings{
+		AccessTokenTtlSeconds: 600,
+		Algorithm:             "HS512",
+		ClaimAttributes:       []string{"department"},
+		StaticClaims:          map[string]string{"env": "test"},
+	}
+	_, err = st.AppsClient.SetAppTokenSettings(adminCtx, &ssov1.SetAppTokenSettingsRequest{
+		AppId:         app.GetId(),
+		TokenSettings: settings,
+	})
+	require.NoError(t, err)
+
+	listed := findApp(t, st, adminCtx, app.GetId())
+	require.NotNil(t, listed)
+	assert.Equal(t, settings.GetAccessTokenTtlSeconds(), listed.GetTokenSettings().GetAccessTokenTtlSeconds())
+	assert.Equal(t, settings.GetAlgorithm(), listed.GetTokenSettings().GetAlgorithm())
+	assert.Equal(t, settings.GetClaimAttributes(), listed.GetTokenSettings().GetClaimAttributes())
+	assert.Equal(t, settings.GetStaticClaims(), listed.GetTokenSettings().GetStaticClaims())
+
+	loginTime := time.Now()
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: app.GetId()})
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(respLogin.GetToken(), func(token *jwt.Token) (any, error) {
+		return []byte(respCreate.GetSecret()), nil
+	}, jwt.WithValidMethods([]string{"HS512"}))
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "sales", claims["department"])
+	assert.Equal(t, "test", claims["env"])
+	assert.NotContains(t, claims, "level")
+
+	const deltaSeconds = 1
+	assert.InDelta(t, loginTime.Add(10*time.Minute).Unix(), claims["exp"].(float64), deltaSeconds)
+}
+
+func TestAppManagement_ListPagination(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respFirst, err := st.AppsClient.ListApps(adminCtx, &ssov1.ListAppsRequest{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, respFirst.GetApps(), 1)
+	require.Greater(t, respFirst.GetTotal(), int32(1))
+
+	respSecond, err := st.AppsClient.ListApps(adminCtx, &ssov1.ListAppsRequest{Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, respSecond.GetApps(), 1)
+	assert.NotEqual(t, respFirst.GetApps()[0].GetId(), respSecond.GetApps()[0].GetId())
+
+	// приложения других организаций в список не попадают
+	assert.Nil(t, findApp(t, st, adminCtx, tenantAppID))
+}
+
+func TestAppManagement_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Create without token",
+			call: func() error {
+				_, err := st.AppsClient.CreateApp(ctx, &ssov1.CreateAppRequest{Name: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Create by non-admin",
+			call: func() error {
+				_, err := st.AppsClient.CreateApp(userCtx, &ssov1.CreateAppRequest{Name: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Duplicate name",
+			call: func() error {
+				_, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: "test"})
+				return err
+			},
+			expectedErr: "app already exists",
+		},
+		{
+			name: "Unknown kind",
+			call: func() error {
+				_, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{Name: gofakeit.UUID(), Kind: "oauth"})
+				return err
+			},
+			expectedErr: "invalid app kind or SAML settings",
+		},
+		{
+			name: "SAML app without ACS URL",
+			call: func() error {
+				_, err := st.AppsClient.CreateApp(adminCtx, &ssov1.CreateAppRequest{
+					Name:         gofakeit.UUID(),
+					Kind:         "saml",
+					SamlEntityId: "https://" + gofakeit.DomainName(),
+				})
+				return err
+			},
+			expectedErr: "invalid app kind or SAML settings",
+		},
+		{
+			name: "Disable app of another tenant",
+			call: func() error {
+				_, err := st.AppsClient.SetAppDisabled(adminCtx, &ssov1.SetAppDisabledRequest{AppId: tenantAppID, Disabled: true})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Unknown token algorithm",
+			call: func() error {
+				_, err := st.AppsClient.SetAppTokenSettings(adminCtx, &ssov1.SetAppTokenSettingsRequest{
+					AppId:         appID,
+					TokenSettings: &ssov1.TokenSettings{Algorithm: "none"},
+				})
+				return err
+			},
+			expectedErr: "invalid token TTL, algorithm or claims",
+		},
+		{
+			name: "Reserved static claim",
+			call: func() error {
+				_, err := st.AppsClient.SetAppTokenSettings(adminCtx, &ssov1.SetAppTokenSettingsRequest{
+					AppId:         appID,
+					TokenSettings: &ssov1.TokenSettings{StaticClaims: map[string]string{"roles": "admin"}},
+				})
+				return err
+			},
+			expectedErr: "invalid token TTL, algorithm or claims",
+		},
+		{
+			name: "Too long token TTL",
+			call: func() error {
+				_, err := st.AppsClient.SetAppTokenSettings(adminCtx, &ssov1.SetAppTokenSettingsRequest{
+					AppId:         appID,
+					TokenSettings: &ssov1.TokenSettings{AccessTokenTtlSeconds: 365 * 24 * 3600},
+				})
+				return err
+			},
+			expectedErr: "invalid token TTL, algorithm or claims",
+		},
+		{
+			name: "Rotate secret by non-admin",
+			call: func() error {
+				_, err := st.AppsClient.RotateAppSecret(userCtx, &ssov1.RotateAppSecretRequest{AppId: appID})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Rotate secret of another tenant's app",
+			call: func() error {
+				_, err := st.AppsClient.RotateAppSecret(adminCtx, &ssov1.RotateAppSecretRequest{AppId: tenantAppID})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Delete app of another tenant",
+			call: func() error {
+				_, err := st.AppsClient.DeleteApp(adminCtx, &ssov1.DeleteAppRequest{AppId: tenantAppID})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+// findApp ищет приложение в списке приложений организации администратора
+func findApp(t *testing.T, st *suite.Suite, adminCtx context.Context, appID int32) *ssov1.App {
+	t.Helper()
+
+	resp, err := st.AppsClient.ListApps(adminCtx, &ssov1.ListAppsRequest{Limit: 100})
+	require.NoError(t, err)
+
+	for _, app := range resp.GetApps() {
+		if app.GetId() == appID {
+			return app
+		}
+	}
+
+	return nil
+}
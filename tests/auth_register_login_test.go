@@ -271,3 +271,16 @@ func TestLogin_FailCases(t *testing.T) {
 		})
 	}
 }
+
+func TestLogin_DisabledUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// пользователь из tests/migrations/3_init_disabled_user.up.sql: пароль верный, но учётная запись отключена
+	_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    "disabled@example.com",
+		Password: "disabled-password",
+		AppId:    appID,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid email or password")
+}
@@ -0,0 +1,178 @@
+// tests/groups_test.go
+package tests
+
+import (
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+func TestGroups_NestedMembership(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	userID, _, _ := registerUser(ctx, t, st)
+
+	createGroup := func(name string) int64 {
+		t.Helper()
+
+		resp, err := st.GroupsClient.CreateGroup(adminCtx, &ssov1.CreateGroupRequest{Name: name + "-" + gofakeit.UUID()})
+		require.NoError(t, err)
+
+		return resp.GetGroupId()
+	}
+
+	// parent <- child, пользователь состоит только в child
+	parentID := createGroup("parent")
+	childID := createGroup("child")
+
+	_, err := st.GroupsClient.AddSubgroup(adminCtx, &ssov1.SubgroupRequest{GroupId: parentID, SubgroupId: childID})
+	require.NoError(t, err)
+
+	_, err = st.GroupsClient.AddGroupMember(adminCtx, &ssov1.GroupMemberRequest{GroupId: childID, UserId: userID})
+	require.NoError(t, err)
+
+	respGroup, err := st.GroupsClient.GetGroup(adminCtx, &ssov1.GetGroupRequest{GroupId: parentID})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{childID}, respGroup.GetGroup().GetSubgroups())
+	assert.Empty(t, respGroup.GetGroup().GetMembers())
+
+	respUserGroups, err := st.GroupsClient.ListUserGroups(adminCtx, &ssov1.ListUserGroupsRequest{UserId: userID})
+	require.NoError(t, err)
+
+	var groupIDs []int64
+	for _, group := range respUserGroups.GetGroups() {
+		groupIDs = append(groupIDs, group.GetId())
+	}
+	assert.ElementsMatch(t, []int64{parentID, childID}, groupIDs)
+
+	// роль родительской группы действует для участников вложенной
+	respRole, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        "group-role-" + gofakeit.UUID(),
+		Permissions: []string{"wiki:read"},
+	})
+	require.NoError(t, err)
+
+	_, err = st.GroupsClient.AssignGroupRole(adminCtx, &ssov1.GroupRoleRequest{GroupId: parentID, RoleId: respRole.GetRoleId()})
+	require.NoError(t, err)
+	assert.True(t, checkPermission(adminCtx, t, st, userID, appID, "wiki:read"))
+
+	// и статус администратора тоже
+	_, err = st.GroupsClient.SetGroupAdmin(adminCtx, &ssov1.SetGroupAdminRequest{GroupId: parentID, IsAdmin: true})
+	require.NoError(t, err)
+
+	respIsAdmin, err := st.AuthClient.IsAdmin(adminCtx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.True(t, respIsAdmin.GetIsAdmin())
+
+	// после исключения из группы права пропадают
+	_, err = st.GroupsClient.RemoveGroupMember(adminCtx, &ssov1.GroupMemberRequest{GroupId: childID, UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, checkPermission(adminCtx, t, st, userID, appID, "wiki:read"))
+
+	respIsAdmin, err = st.AuthClient.IsAdmin(adminCtx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+
+	for _, id := range []int64{childID, parentID} {
+		_, err = st.GroupsClient.DeleteGroup(adminCtx, &ssov1.DeleteGroupRequest{GroupId: id})
+		require.NoError(t, err)
+	}
+}
+
+func TestGroups_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respGroup, err := st.GroupsClient.CreateGroup(adminCtx, &ssov1.CreateGroupRequest{Name: "group-" + gofakeit.UUID()})
+	require.NoError(t, err)
+	groupID := respGroup.GetGroupId()
+
+	respSubgroup, err := st.GroupsClient.CreateGroup(adminCtx, &ssov1.CreateGroupRequest{Name: "subgroup-" + gofakeit.UUID()})
+	require.NoError(t, err)
+	subgroupID := respSubgroup.GetGroupId()
+
+	_, err = st.GroupsClient.AddSubgroup(adminCtx, &ssov1.SubgroupRequest{GroupId: groupID, SubgroupId: subgroupID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Create without token",
+			call: func() error {
+				_, err := st.GroupsClient.CreateGroup(ctx, &ssov1.CreateGroupRequest{Name: "x"})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Add member by not admin",
+			call: func() error {
+				_, err := st.GroupsClient.AddGroupMember(userCtx, &ssov1.GroupMemberRequest{GroupId: groupID, UserId: userID})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Add unknown user",
+			call: func() error {
+				_, err := st.GroupsClient.AddGroupMember(adminCtx, &ssov1.GroupMemberRequest{GroupId: groupID, UserId: 1 << 40})
+				return err
+			},
+			expectedErr: "user not found",
+		},
+		{
+			name: "Add member to unknown group",
+			call: func() error {
+				_, err := st.GroupsClient.AddGroupMember(adminCtx, &ssov1.GroupMemberRequest{GroupId: 1 << 40, UserId: userID})
+				return err
+			},
+			expectedErr: "group not found",
+		},
+		{
+			name: "Nesting cycle",
+			call: func() error {
+				_, err := st.GroupsClient.AddSubgroup(adminCtx, &ssov1.SubgroupRequest{GroupId: subgroupID, SubgroupId: groupID})
+				return err
+			},
+			expectedErr: "group nesting cycle",
+		},
+		{
+			name: "Assign unknown role",
+			call: func() error {
+				_, err := st.GroupsClient.AssignGroupRole(adminCtx, &ssov1.GroupRoleRequest{GroupId: groupID, RoleId: 1 << 40})
+				return err
+			},
+			expectedErr: "role not found",
+		},
+		{
+			name: "Without subgroup_id",
+			call: func() error {
+				_, err := st.GroupsClient.AddSubgroup(adminCtx, &ssov1.SubgroupRequest{GroupId: groupID})
+				return err
+			},
+			expectedErr: "subgroup_id is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// tests/invitations_test.go
+package tests
+
+import (
+	"grpc-service-ref/tests/suite"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+func TestInvitations_NewUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	roleName := "invited-" + gofakeit.UUID()
+	respRole, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: roleName})
+	require.NoError(t, err)
+
+	email := gofakeit.Email()
+	respInv, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{
+		AppId:  appID,
+		Email:  email,
+		RoleId: respRole.GetRoleId(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "pending", respInv.GetInvitation().GetStatus())
+
+	token := invitationToken(t, st, email)
+
+	// у нового пользователя пароль обязателен
+	_, err = st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "password is required")
+
+	pass := randomFakePassword()
+	respAccept, err := st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: pass})
+	require.NoError(t, err)
+	require.NotEmpty(t, respAccept.GetUserId())
+
+	// пользователь зарегистрирован и получил роль из приглашения
+	tokenParsed, err := jwt.Parse(login(ctx, t, st, email, pass), func(token *jwt.Token) (any, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, respAccept.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Contains(t, claims["roles"], roleName)
+
+	// приглашение одноразовое
+	_, err = st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: pass})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invitation is already accepted")
+
+	respList, err := st.InvitationsClient.ListInvitations(adminCtx, &ssov1.ListInvitationsRequest{AppId: appID})
+	require.NoError(t, err)
+
+	var found bool
+	for _, inv := range respList.GetInvitations() {
+		if inv.GetId() == respInv.GetInvitation().GetId() {
+			found = true
+			assert.Equal(t, "accepted", inv.GetStatus())
+			assert.Equal(t, respAccept.GetUserId(), inv.GetAcceptedBy())
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestInvitations_ExistingUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	userID, email, _ := registerUser(ctx, t, st)
+
+	_, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{AppId: appID, Email: email})
+	require.NoError(t, err)
+
+	// существующему пользователю пароль не нужен: приглашение привязывается к нему
+	respAccept, err := st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{
+		Token: invitationToken(t, st, email),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, userID, respAccept.GetUserId())
+}
+
+func TestInvitations_Revoke(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	email := gofakeit.Email()
+	respInv, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{AppId: appID, Email: email})
+	require.NoError(t, err)
+
+	invitationID := respInv.GetInvitation().GetId()
+	_, err = st.InvitationsClient.RevokeInvitation(adminCtx, &ssov1.RevokeInvitationRequest{InvitationId: invitationID})
+	require.NoError(t, err)
+
+	_, err = st.InvitationsClient.RevokeInvitation(adminCtx, &ssov1.RevokeInvitationRequest{InvitationId: invitationID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invitation not found")
+
+	_, err = st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{
+		Token:    invitationToken(t, st, email),
+		Password: randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invitation is already accepted, revoked or expired")
+}
+
+func TestInvitations_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Without token",
+			call: func() error {
+				_, err := st.InvitationsClient.CreateInvitation(ctx, &ssov1.CreateInvitationRequest{AppId: appID, Email: gofakeit.Email()})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Caller is not admin",
+			call: func() error {
+				_, err := st.InvitationsClient.CreateInvitation(userCtx, &ssov1.CreateInvitationRequest{AppId: appID, Email: gofakeit.Email()})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Invalid email",
+			call: func() error {
+				_, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{AppId: appID, Email: "not-an-email"})
+				return err
+			},
+			expectedErr: "invalid email",
+		},
+		{
+			name: "App of another tenant",
+			call: func() error {
+				_, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{AppId: tenantAppID, Email: gofakeit.Email()})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Unknown role",
+			call: func() error {
+				_, err := st.InvitationsClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{
+					AppId:  appID,
+					Email:  gofakeit.Email(),
+					RoleId: 1 << 40,
+				})
+				return err
+			},
+			expectedErr: "role not found",
+		},
+		{
+			name: "Unknown invitation token",
+			call: func() error {
+				_, err := st.InvitationsClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: "unknown", Password: "password"})
+				return err
+			},
+			expectedErr: "invitation not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+var invitationTokenRe = regexp.MustCompile(`Invitation token: (\S+)`)
+
+// invitationToken достаёт токен из последнего письма с приглашением для email.
+// Письма сохраняются сервером в каталог mail.dir; относительный путь отсчитывается
+// от корня репозитория, откуда запускается сервер.
+func invitationToken(t *testing.T, st *suite.Suite, email string) string {
+	t.Helper()
+
+	dir := st.Cfg.Mail.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join("..", dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*-"+email+".eml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "invitation email for %s not found in %s", email, dir)
+
+	// имена файлов начинаются со времени отправки, поэтому последний - самый свежий
+	content, err := os.ReadFile(files[len(files)-1])
+	require.NoError(t, err)
+
+	m := invitationTokenRe.FindStringSubmatch(string(content))
+	require.Len(t, m, 2)
+
+	return m[1]
+}
@@ -0,0 +1,10 @@
+-- tests/migrations/2_init_token_exchange.up.sql
+
+-- второе приложение, в которое первое может обменивать токены пользователей
+INSERT INTO apps (id, name, secret)
+VALUES (2, 'test-2', 'test-secret-2')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO token_exchange_policies (source_app_id, target_app_id, scopes)
+VALUES (1, 2, 'read write')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- tests/migrations/3_init_disabled_user.up.sql
+-- Отключённый (деактивированный через SCIM) пользователь, пароль: disabled-password
+
+INSERT INTO users (email, pass_hash, disabled)
+VALUES ('disabled@example.com', '$2a$04$72LsnpyZJGT/vbR8OrVqwep0rEO9d6eU/1Etk3pVNEitEjU44Eh7C', TRUE)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- tests/migrations/4_init_admin.up.sql
+-- Администратор для тестов SetAdmin, пароль: admin-password
+
+INSERT INTO users (email, pass_hash, is_admin)
+VALUES ('admin@example.com', '$2a$04$gYNVwOgUNddhMBMYQRn4g..CniV1XcivjNB7pHg0cCodH8NEcxi4O', TRUE)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,15 @@
+-- tests/migrations/5_init_tenant.up.sql
+
+-- вторая организация со своим приложением: её пользователи изолированы от организации по умолчанию
+INSERT INTO tenants (id, name)
+VALUES (2, 'test-tenant-2')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO apps (id, tenant_id, name, secret)
+VALUES (3, 2, 'test-3', 'test-secret-3')
+ON CONFLICT DO NOTHING;
+
+-- политика обмена в приложение чужой организации: обмен всё равно должен быть запрещён
+INSERT INTO token_exchange_policies (source_app_id, target_app_id, scopes)
+VALUES (1, 3, 'read')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- tests/migrations/6_init_restricted_app.up.sql
+
+-- приложение, режим доступа которого меняют тесты (остальные приложения остаются открытыми)
+INSERT INTO apps (id, name, secret)
+VALUES (4, 'test-restricted', 'test-secret-4')
+ON CONFLICT DO NOTHING;
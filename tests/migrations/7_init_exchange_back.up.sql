@@ -0,0 +1,7 @@
+-- tests/migrations/7_init_exchange_back.up.sql
+
+-- обратный обмен из второго приложения в первое: с другим набором scope'ов,
+-- поэтому токен, полученный обменом 1 -> 2 -> 1, остаётся без прав
+INSERT INTO token_exchange_policies (source_app_id, target_app_id, scopes)
+VALUES (2, 1, 'write')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,211 @@
+// tests/permissions_test.go
+package tests
+
+import (
+	"context"
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+func TestPermissions_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	userID, email, pass := registerUser(ctx, t, st)
+
+	roleName := "editor-" + gofakeit.UUID()
+	respRole, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        roleName,
+		Permissions: []string{"reports:read"},
+	})
+	require.NoError(t, err)
+	roleID := respRole.GetRoleId()
+
+	_, err = st.PermissionsClient.SetRolePermissions(adminCtx, &ssov1.SetRolePermissionsRequest{
+		RoleId:      roleID,
+		Permissions: []string{"reports:read", "reports:write"},
+	})
+	require.NoError(t, err)
+
+	// читать роли может любой пользователь организации
+	userCtx := withToken(ctx, login(ctx, t, st, email, pass))
+
+	respList, err := st.PermissionsClient.ListRoles(userCtx, &ssov1.ListRolesRequest{AppId: appID})
+	require.NoError(t, err)
+
+	var found *ssov1.Role
+	for _, role := range respList.GetRoles() {
+		if role.GetId() == roleID {
+			found = role
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, roleName, found.GetName())
+	assert.ElementsMatch(t, []string{"reports:read", "reports:write"}, found.GetPermissions())
+
+	_, err = st.PermissionsClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: roleID})
+	require.NoError(t, err)
+
+	respRoles, err := st.PermissionsClient.GetUserRoles(userCtx, &ssov1.GetUserRolesRequest{UserId: userID, AppId: appID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{roleName}, respRoles.GetRoles())
+
+	// права роли действуют только в её приложении
+	assert.True(t, checkPermission(userCtx, t, st, userID, appID, "reports:write"))
+	assert.False(t, checkPermission(userCtx, t, st, userID, appID, "reports:delete"))
+	assert.False(t, checkPermission(userCtx, t, st, userID, audienceAppID, "reports:write"))
+
+	// роли пользователя в приложении попадают в токен
+	tokenParsed, err := jwt.Parse(login(ctx, t, st, email, pass), func(token *jwt.Token) (any, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, []any{roleName}, claims["roles"])
+
+	_, err = st.PermissionsClient.RevokeRole(adminCtx, &ssov1.RevokeRoleRequest{UserId: userID, RoleId: roleID})
+	require.NoError(t, err)
+	assert.False(t, checkPermission(userCtx, t, st, userID, appID, "reports:write"))
+
+	_, err = st.PermissionsClient.DeleteRole(adminCtx, &ssov1.DeleteRoleRequest{RoleId: roleID})
+	require.NoError(t, err)
+
+	_, err = st.PermissionsClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: roleID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "role not found")
+}
+
+func TestPermissions_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	roleName := "viewer-" + gofakeit.UUID()
+	respRole, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        roleName,
+		Permissions: []string{"reports:read"},
+	})
+	require.NoError(t, err)
+	roleID := respRole.GetRoleId()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Create without token",
+			call: func() error {
+				_, err := st.PermissionsClient.CreateRole(ctx, &ssov1.CreateRoleRequest{AppId: appID, Name: "x"})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Create by not admin",
+			call: func() error {
+				_, err := st.PermissionsClient.CreateRole(userCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: "x"})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Create duplicate role",
+			call: func() error {
+				_, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: roleName})
+				return err
+			},
+			expectedErr: "role already exists",
+		},
+		{
+			name: "Create in unknown app",
+			call: func() error {
+				_, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: 1 << 20, Name: "x"})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Create with empty permission",
+			call: func() error {
+				_, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+					AppId:       appID,
+					Name:        "x",
+					Permissions: []string{" "},
+				})
+				return err
+			},
+			expectedErr: "invalid role name or permissions",
+		},
+		{
+			name: "Assign by not admin",
+			call: func() error {
+				_, err := st.PermissionsClient.AssignRole(userCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: roleID})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Assign to unknown user",
+			call: func() error {
+				_, err := st.PermissionsClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: 1 << 40, RoleId: roleID})
+				return err
+			},
+			expectedErr: "user not found",
+		},
+		{
+			name: "Assign unknown role",
+			call: func() error {
+				_, err := st.PermissionsClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: 1 << 40})
+				return err
+			},
+			expectedErr: "role not found",
+		},
+		{
+			name: "Check without permission",
+			call: func() error {
+				_, err := st.PermissionsClient.CheckPermission(userCtx, &ssov1.CheckPermissionRequest{UserId: userID, AppId: appID})
+				return err
+			},
+			expectedErr: "permission is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+
+	// роль так и не была назначена
+	assert.False(t, checkPermission(userCtx, t, st, userID, appID, "reports:read"))
+}
+
+func checkPermission(ctx context.Context, t *testing.T, st *suite.Suite, userID int64, app int32, permission string) bool {
+	t.Helper()
+
+	resp, err := st.PermissionsClient.CheckPermission(ctx, &ssov1.CheckPermissionRequest{
+		UserId:     userID,
+		AppId:      app,
+		Permission: permission,
+	})
+	require.NoError(t, err)
+
+	return resp.GetAllowed()
+}
@@ -0,0 +1,225 @@
+// tests/policies_test.go
+package tests
+
+import (
+	"fmt"
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// reportsPolicy менеджеры читают отчёты своего отдела в рабочее время
+const reportsPolicy = `{
+  "rules": [
+    {
+      "id": "managers-read-own-reports",
+      "effect": "allow",
+      "actions": ["reports:read"],
+      "conditions": [
+        {"attribute": "subject.roles", "op": "contains", "value": %q},
+        {"attribute": "resource.department", "op": "eq", "ref": "subject.department"},
+        {"attribute": "context.hour", "op": "gte", "value": 9},
+        {"attribute": "context.hour", "op": "lt", "value": 18}
+      ]
+    }
+  ]
+}`
+
+func TestPolicies_Authorize(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	userID, _, _ := registerUser(ctx, t, st)
+
+	roleName := "manager-" + gofakeit.UUID()
+	respRole, err := st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: roleName})
+	require.NoError(t, err)
+	_, err = st.PermissionsClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: respRole.GetRoleId()})
+	require.NoError(t, err)
+
+	_, err = st.PoliciesClient.SetUserAttributes(adminCtx, &ssov1.SetUserAttributesRequest{
+		UserId:     userID,
+		Attributes: map[string]string{"department": "sales"},
+	})
+	require.NoError(t, err)
+
+	document := fmt.Sprintf(reportsPolicy, roleName)
+	respSet, err := st.PoliciesClient.SetPolicy(adminCtx, &ssov1.SetPolicyRequest{AppId: appID, Document: document})
+	require.NoError(t, err)
+	require.NotZero(t, respSet.GetVersion())
+
+	respGet, err := st.PoliciesClient.GetPolicy(adminCtx, &ssov1.GetPolicyRequest{AppId: appID})
+	require.NoError(t, err)
+	assert.Equal(t, respSet.GetVersion(), respGet.GetVersion())
+	assert.Equal(t, document, respGet.GetDocument())
+
+	authorize := func(department string, hour string) *ssov1.AuthorizeResponse {
+		t.Helper()
+
+		resp, err := st.PoliciesClient.Authorize(ctx, &ssov1.AuthorizeRequest{
+			UserId:   userID,
+			AppId:    appID,
+			Action:   "reports:read",
+			Resource: map[string]string{"department": department},
+			Context:  map[string]string{"hour": hour},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, respSet.GetVersion(), resp.GetPolicyVersion())
+		assert.False(t, resp.GetDryRun())
+
+		return resp
+	}
+
+	resp := authorize("sales", "10")
+	assert.True(t, resp.GetAllowed())
+	assert.Equal(t, []string{"managers-read-own-reports"}, resp.GetRules())
+
+	// другой отдел
+	resp = authorize("hr", "10")
+	assert.False(t, resp.GetAllowed())
+	assert.Contains(t, resp.GetReasons()[0], "resource.department eq subject.department")
+
+	// нерабочее время
+	resp = authorize("sales", "20")
+	assert.False(t, resp.GetAllowed())
+	assert.Contains(t, resp.GetReasons()[0], `context.hour lt "18"`)
+
+	// пробное вычисление черновика не меняет действующую политику
+	respDry, err := st.PoliciesClient.Authorize(adminCtx, &ssov1.AuthorizeRequest{
+		UserId:       userID,
+		AppId:        appID,
+		Action:       "reports:read",
+		Resource:     map[string]string{"department": "sales"},
+		Context:      map[string]string{"hour": "10"},
+		DryRunPolicy: `{"rules": [{"id": "freeze", "effect": "deny", "actions": ["reports:*"]}]}`,
+	})
+	require.NoError(t, err)
+	assert.True(t, respDry.GetDryRun())
+	assert.False(t, respDry.GetAllowed())
+	assert.Equal(t, []string{"freeze"}, respDry.GetRules())
+	assert.Zero(t, respDry.GetPolicyVersion())
+
+	resp = authorize("sales", "10")
+	assert.True(t, resp.GetAllowed())
+}
+
+func TestPolicies_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, userEmail, userPassword := registerUser(ctx, t, st)
+	userCtx := withToken(ctx, login(ctx, t, st, userEmail, userPassword))
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	draft := `{"rules": []}`
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+	}{
+		{
+			name: "Set policy without token",
+			call: func() error {
+				_, err := st.PoliciesClient.SetPolicy(ctx, &ssov1.SetPolicyRequest{AppId: appID, Document: draft})
+				return err
+			},
+			expectedErr: "authorization token is required",
+		},
+		{
+			name: "Set policy by non-admin",
+			call: func() error {
+				_, err := st.PoliciesClient.SetPolicy(userCtx, &ssov1.SetPolicyRequest{AppId: appID, Document: draft})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Invalid policy",
+			call: func() error {
+				_, err := st.PoliciesClient.SetPolicy(adminCtx, &ssov1.SetPolicyRequest{
+					AppId:    appID,
+					Document: `{"rules": [{"id": "r", "effect": "maybe", "actions": ["a"]}]}`,
+				})
+				return err
+			},
+			expectedErr: `invalid policy: rule "r": effect must be "allow" or "deny"`,
+		},
+		{
+			name: "Policy of another tenant's app",
+			call: func() error {
+				_, err := st.PoliciesClient.SetPolicy(adminCtx, &ssov1.SetPolicyRequest{AppId: tenantAppID, Document: draft})
+				return err
+			},
+			expectedErr: "app not found",
+		},
+		{
+			name: "Unknown policy version",
+			call: func() error {
+				_, err := st.PoliciesClient.GetPolicy(adminCtx, &ssov1.GetPolicyRequest{AppId: appID, Version: 1 << 30})
+				return err
+			},
+			expectedErr: "policy not found",
+		},
+		{
+			name: "Reserved user attribute",
+			call: func() error {
+				_, err := st.PoliciesClient.SetUserAttributes(adminCtx, &ssov1.SetUserAttributesRequest{
+					UserId:     userID,
+					Attributes: map[string]string{"roles": "admin"},
+				})
+				return err
+			},
+			expectedErr: "invalid user attributes",
+		},
+		{
+			name: "Dry run without token",
+			call: func() error {
+				_, err := st.PoliciesClient.Authorize(ctx, &ssov1.AuthorizeRequest{
+					UserId: userID, AppId: appID, Action: "a", DryRunPolicy: draft,
+				})
+				return err
+			},
+			expectedErr: "authorization token is required for dry run",
+		},
+		{
+			name: "Dry run by non-admin",
+			call: func() error {
+				_, err := st.PoliciesClient.Authorize(userCtx, &ssov1.AuthorizeRequest{
+					UserId: userID, AppId: appID, Action: "a", DryRunPolicy: draft,
+				})
+				return err
+			},
+			expectedErr: "caller is not admin",
+		},
+		{
+			name: "Authorize without action",
+			call: func() error {
+				_, err := st.PoliciesClient.Authorize(ctx, &ssov1.AuthorizeRequest{UserId: userID, AppId: appID})
+				return err
+			},
+			expectedErr: "action is required",
+		},
+		{
+			name: "Authorize user of another tenant",
+			call: func() error {
+				_, err := st.PoliciesClient.Authorize(ctx, &ssov1.AuthorizeRequest{UserId: userID, AppId: tenantAppID, Action: "a"})
+				return err
+			},
+			expectedErr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
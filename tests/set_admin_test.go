@@ -0,0 +1,129 @@
+// tests/set_admin_test.go
+package tests
+
+import (
+	"context"
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+const (
+	adminEmail    = "admin@example.com" // Администратор, созданный миграцией (см. tests/migrations)
+	adminPassword = "admin-password"
+)
+
+func TestSetAdmin_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, _, _ := registerUser(ctx, t, st)
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	resp, err := st.AuthClient.SetAdmin(adminCtx, &ssov1.SetAdminRequest{UserId: userID, IsAdmin: true})
+	require.NoError(t, err)
+	assert.True(t, resp.GetIsAdmin())
+
+	respIsAdmin, err := st.AuthClient.IsAdmin(adminCtx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.True(t, respIsAdmin.GetIsAdmin())
+
+	_, err = st.AuthClient.SetAdmin(adminCtx, &ssov1.SetAdminRequest{UserId: userID, IsAdmin: false})
+	require.NoError(t, err)
+
+	respIsAdmin, err = st.AuthClient.IsAdmin(adminCtx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+}
+
+func TestSetAdmin_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userID, userEmail, userPassword := registerUser(ctx, t, st)
+	userToken := login(ctx, t, st, userEmail, userPassword)
+	adminToken := login(ctx, t, st, adminEmail, adminPassword)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		userID      int64
+		expectedErr string
+	}{
+		{
+			name:        "Without token",
+			ctx:         ctx,
+			userID:      userID,
+			expectedErr: "authorization token is required",
+		},
+		{
+			name:        "Invalid token",
+			ctx:         withToken(ctx, "invalid"),
+			userID:      userID,
+			expectedErr: "invalid token",
+		},
+		{
+			name:        "Caller is not admin",
+			ctx:         withToken(ctx, userToken),
+			userID:      userID,
+			expectedErr: "caller is not admin",
+		},
+		{
+			name:        "Without user_id",
+			ctx:         withToken(ctx, adminToken),
+			userID:      0,
+			expectedErr: "user_id is required",
+		},
+		{
+			name:        "Unknown user",
+			ctx:         withToken(ctx, adminToken),
+			userID:      1 << 40,
+			expectedErr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.SetAdmin(tt.ctx, &ssov1.SetAdminRequest{UserId: tt.userID, IsAdmin: true})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+
+	// пользователь так и не стал администратором
+	respIsAdmin, err := st.AuthClient.IsAdmin(withToken(ctx, adminToken), &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+}
+
+// registerUser регистрирует случайного пользователя и возвращает его ID, email и пароль
+func registerUser(ctx context.Context, t *testing.T, st *suite.Suite) (int64, string, string) {
+	t.Helper()
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	resp, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	return resp.GetUserId(), email, pass
+}
+
+func login(ctx context.Context, t *testing.T, st *suite.Suite, email string, password string) string {
+	t.Helper()
+
+	resp, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	return resp.GetToken()
+}
+
+// withToken добавляет токен вызывающего в метаданные запроса
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
@@ -18,11 +18,21 @@ import (
 //*testing.T — объект для управлением тестом, подробнее можно почитать тут
 //Cfg — обычный объект конфига, тот же что используется при запуске приложения из cmd
 //AuthClient — gRPC-клиент нашего Auth-сервера, основной компонент Suit'а, с его помощью будем отправлять запросы в тестируемое приложение
+//PermissionsClient — gRPC-клиент сервиса ролей и прав
+//GroupsClient — gRPC-клиент сервиса групп
+//AppsClient — gRPC-клиент сервиса доступа к приложениям
+//InvitationsClient — gRPC-клиент сервиса приглашений
+//PoliciesClient — gRPC-клиент сервиса политик доступа
 
 type Suite struct {
-	*testing.T                  // Потребуется для вызова методов *testing.T
-	Cfg        *config.Config   // Конфигурация приложения
-	AuthClient ssov1.AuthClient // Клиент для взаимодействия с gRPC-сервером Auth
+	*testing.T                                // Потребуется для вызова методов *testing.T
+	Cfg               *config.Config          // Конфигурация приложения
+	AuthClient        ssov1.AuthClient        // Клиент для взаимодействия с gRPC-сервером Auth
+	PermissionsClient ssov1.PermissionsClient // Клиент сервиса Permissions
+	GroupsClient      ssov1.GroupsClient      // Клиент сервиса Groups
+	AppsClient        ssov1.AppsClient        // Клиент сервиса Apps
+	InvitationsClient ssov1.InvitationsClient // Клиент сервиса Invitations
+	PoliciesClient    ssov1.PoliciesClient    // Клиент сервиса Policies
 }
 
 const (
@@ -82,8 +92,13 @@ func New(t *testing.T) (context.Context, *Suite) {
 	// authClient.Login() и authClient.Register()
 
 	return ctx, &Suite{
-		T:          t,
-		Cfg:        cfg,
-		AuthClient: authClient,
+		T:                 t,
+		Cfg:               cfg,
+		AuthClient:        authClient,
+		PermissionsClient: ssov1.NewPermissionsClient(cc),
+		GroupsClient:      ssov1.NewGroupsClient(cc),
+		AppsClient:        ssov1.NewAppsClient(cc),
+		InvitationsClient: ssov1.NewInvitationsClient(cc),
+		PoliciesClient:    ssov1.NewPoliciesClient(cc),
 	}
 }
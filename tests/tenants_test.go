@@ -0,0 +1,216 @@
+// tests/tenants_test.go
+package tests
+
+import (
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+const (
+	tenantAppID     = 3               // ID приложения второй организации (см. tests/migrations)
+	tenantAppSecret = "test-secret-3" // Секретный ключ этого приложения
+)
+
+func TestTenants_IsolatedUsers(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	// один и тот же email регистрируется в обеих организациях независимо
+	respDefault, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	respTenant, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass + "-2",
+		AppId:    tenantAppID,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, respDefault.GetUserId(), respTenant.GetUserId())
+
+	// а повторно в той же организации - нет
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass, AppId: tenantAppID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user already exists")
+
+	// пароль проверяется у пользователя организации приложения
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: tenantAppID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid email or password")
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass + "-2", AppId: tenantAppID})
+	require.NoError(t, err)
+
+	tokenParsed, err := jwt.Parse(respLogin.GetToken(), func(token *jwt.Token) (any, error) {
+		return []byte(tenantAppSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, respTenant.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Equal(t, 2, int(claims["tenant_id"].(float64)))
+
+	// в токене организации по умолчанию - её пользователь
+	tokenParsed, err = jwt.Parse(login(ctx, t, st, email, pass), func(token *jwt.Token) (any, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok = tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, respDefault.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Equal(t, 1, int(claims["tenant_id"].(float64)))
+}
+
+func TestTenants_AdminScope(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+
+	respTenant, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+		AppId:    tenantAppID,
+	})
+	require.NoError(t, err)
+	tenantUserID := respTenant.GetUserId()
+
+	// для администратора организации по умолчанию пользователей другой организации не существует
+	_, err = st.AuthClient.SetAdmin(adminCtx, &ssov1.SetAdminRequest{UserId: tenantUserID, IsAdmin: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	respGroup, err := st.GroupsClient.CreateGroup(adminCtx, &ssov1.CreateGroupRequest{Name: "group-" + gofakeit.UUID()})
+	require.NoError(t, err)
+
+	_, err = st.GroupsClient.AddGroupMember(adminCtx, &ssov1.GroupMemberRequest{
+		GroupId: respGroup.GetGroupId(),
+		UserId:  tenantUserID,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	// роли в приложениях другой организации создавать нельзя
+	_, err = st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId: tenantAppID,
+		Name:  "role-" + gofakeit.UUID(),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app not found")
+
+	_, err = st.GroupsClient.DeleteGroup(adminCtx, &ssov1.DeleteGroupRequest{GroupId: respGroup.GetGroupId()})
+	require.NoError(t, err)
+}
+
+func TestTenants_ReadScope(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminCtx := withToken(ctx, login(ctx, t, st, adminEmail, adminPassword))
+	userID, _, _ := registerUser(ctx, t, st)
+
+	respGroup, err := st.GroupsClient.CreateGroup(adminCtx, &ssov1.CreateGroupRequest{Name: "group-" + gofakeit.UUID()})
+	require.NoError(t, err)
+	groupID := respGroup.GetGroupId()
+
+	_, err = st.PermissionsClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId: appID,
+		Name:  "role-" + gofakeit.UUID(),
+	})
+	require.NoError(t, err)
+
+	// без токена группы и роли не читаются
+	_, err = st.GroupsClient.GetGroup(ctx, &ssov1.GetGroupRequest{GroupId: groupID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	_, err = st.GroupsClient.ListUserGroups(ctx, &ssov1.ListUserGroupsRequest{UserId: userID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	_, err = st.PermissionsClient.ListRoles(ctx, &ssov1.ListRolesRequest{AppId: appID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	_, err = st.PermissionsClient.GetUserRoles(ctx, &ssov1.GetUserRolesRequest{UserId: userID, AppId: appID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	_, err = st.PermissionsClient.CheckPermission(ctx, &ssov1.CheckPermissionRequest{
+		UserId:     userID,
+		AppId:      appID,
+		Permission: "reports:read",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	_, err = st.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: userID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authorization token is required")
+
+	// для пользователя другой организации группы, пользователи и роли организации по умолчанию не существуют
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass, AppId: tenantAppID})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: tenantAppID})
+	require.NoError(t, err)
+	tenantCtx := withToken(ctx, respLogin.GetToken())
+
+	_, err = st.GroupsClient.GetGroup(tenantCtx, &ssov1.GetGroupRequest{GroupId: groupID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "group not found")
+
+	_, err = st.GroupsClient.ListUserGroups(tenantCtx, &ssov1.ListUserGroupsRequest{UserId: userID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	respRoles, err := st.PermissionsClient.ListRoles(tenantCtx, &ssov1.ListRolesRequest{AppId: appID})
+	require.NoError(t, err)
+	assert.Empty(t, respRoles.GetRoles())
+
+	_, err = st.PermissionsClient.GetUserRoles(tenantCtx, &ssov1.GetUserRolesRequest{UserId: userID, AppId: appID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	_, err = st.PermissionsClient.CheckPermission(tenantCtx, &ssov1.CheckPermissionRequest{
+		UserId:     userID,
+		AppId:      appID,
+		Permission: "reports:read",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	_, err = st.AuthClient.IsAdmin(tenantCtx, &ssov1.IsAdminRequest{UserId: userID})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user not found")
+
+	_, err = st.GroupsClient.DeleteGroup(adminCtx, &ssov1.DeleteGroupRequest{GroupId: groupID})
+	require.NoError(t, err)
+}
+
+func TestTenants_ExchangeToAnotherTenant(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, email, pass := registerUser(ctx, t, st)
+
+	// политика обмена 1 -> 3 есть, но приложение 3 из другой организации
+	_, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: login(ctx, t, st, email, pass),
+		AppId:        appID,
+		AppSecret:    appSecret,
+		Audience:     tenantAppID,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token exchange not allowed")
+}
@@ -0,0 +1,211 @@
+// tests/token_exchange_test.go
+package tests
+
+import (
+	"grpc-service-ref/tests/suite"
+	"testing"
+
+	ssov1 "github.com/Alexxtn105/protos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+const (
+	audienceAppID     = 2               // ID приложения, в которое разрешён обмен (см. tests/migrations)
+	audienceAppSecret = "test-secret-2" // Секретный ключ этого приложения
+)
+
+func TestTokenExchange_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// Приложение 1 обменивает токен пользователя на токен для приложения 2
+	respExchange, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: respLogin.GetToken(),
+		AppId:        appID,
+		AppSecret:    appSecret,
+		Audience:     audienceAppID,
+		Scopes:       []string{"read"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, respExchange.GetScopes())
+
+	// Новый токен подписан секретом приложения-получателя
+	tokenParsed, err := jwt.Parse(respExchange.GetToken(), func(token *jwt.Token) (any, error) {
+		return []byte(audienceAppSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Equal(t, email, claims["email"].(string))
+	assert.Equal(t, audienceAppID, int(claims["app_id"].(float64)))
+	assert.Equal(t, "read", claims["scope"].(string))
+
+	// В claim act записано приложение, действующее от имени пользователя
+	act, ok := claims["act"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, appID, int(act["app_id"].(float64)))
+}
+
+func TestTokenExchange_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		subjectToken string
+		appSecret    string
+		audience     int32
+		scopes       []string
+		expectedErr  string
+	}{
+		{
+			name:         "Exchange with empty subject token",
+			subjectToken: "",
+			appSecret:    appSecret,
+			audience:     audienceAppID,
+			expectedErr:  "subject_token is required",
+		},
+		{
+			name:         "Exchange with invalid subject token",
+			subjectToken: "not-a-token",
+			appSecret:    appSecret,
+			audience:     audienceAppID,
+			expectedErr:  "invalid subject token",
+		},
+		{
+			name:         "Exchange with wrong app secret",
+			subjectToken: respLogin.GetToken(),
+			appSecret:    "wrong-secret",
+			audience:     audienceAppID,
+			expectedErr:  "invalid app credentials",
+		},
+		{
+			name:         "Exchange into app without policy",
+			subjectToken: respLogin.GetToken(),
+			appSecret:    appSecret,
+			audience:     appID,
+			expectedErr:  "token exchange not allowed",
+		},
+		{
+			name:         "Exchange with not allowed scope",
+			subjectToken: respLogin.GetToken(),
+			appSecret:    appSecret,
+			audience:     audienceAppID,
+			scopes:       []string{"admin"},
+			expectedErr:  "token exchange not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+				SubjectToken: tt.subjectToken,
+				AppId:        appID,
+				AppSecret:    tt.appSecret,
+				Audience:     tt.audience,
+				Scopes:       tt.scopes,
+			})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+// Токен, у которого после обменов не осталось scope'ов, нельзя обменять обратно на токен с правами
+func TestTokenExchange_EmptyScopeStaysEmpty(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// 1 -> 2 со scope read
+	toAudience, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: respLogin.GetToken(),
+		AppId:        appID,
+		AppSecret:    appSecret,
+		Audience:     audienceAppID,
+		Scopes:       []string{"read"},
+	})
+	require.NoError(t, err)
+
+	// 2 -> 1: политика разрешает только write, пересечение с read пустое
+	back, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: toAudience.GetToken(),
+		AppId:        audienceAppID,
+		AppSecret:    audienceAppSecret,
+		Audience:     appID,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, back.GetScopes())
+
+	// 1 -> 2 снова: пустой scope не превращается в полный набор политики
+	again, err := st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: back.GetToken(),
+		AppId:        appID,
+		AppSecret:    appSecret,
+		Audience:     audienceAppID,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, again.GetScopes())
+
+	_, err = st.AuthClient.TokenExchange(ctx, &ssov1.TokenExchangeRequest{
+		SubjectToken: back.GetToken(),
+		AppId:        appID,
+		AppSecret:    appSecret,
+		Audience:     audienceAppID,
+		Scopes:       []string{"read"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token exchange not allowed")
+}